@@ -1,3 +1,4 @@
+// Command app runs the server, like "conduit serve".
 package main
 
 import (
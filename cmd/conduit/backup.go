@@ -0,0 +1,67 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/hy00nc/conduit-go/internal/backup"
+	"github.com/hy00nc/conduit-go/internal/database"
+)
+
+func runExport(args []string) int {
+	flags := newFlags("export [-o file]")
+	output := flags.String("o", "", "write the archive to `file` instead of standard output")
+	if !parseArgs(flags, args, 0) {
+		return 2
+	}
+
+	var w io.Writer = os.Stdout
+	if *output != "" {
+		file, err := os.Create(*output)
+		if err != nil {
+			return fail(err)
+		}
+		defer file.Close()
+		w = file
+	}
+
+	db := initDB()
+	defer database.CloseDB(db)
+	stats, err := backup.Export(context.Background(), db, w)
+	if err != nil {
+		return fail(err)
+	}
+	fmt.Fprintln(os.Stderr, "exported", stats)
+	return 0
+}
+
+func runRestore(args []string) int {
+	flags := newFlags("restore [-remap-ids] [file]\nReads the archive from standard input without a file.")
+	remap := flags.Bool("remap-ids", false, "give restored rows new IDs, to add them to a database with content")
+	flags.Parse(args)
+	if flags.NArg() > 1 {
+		flags.Usage()
+		return 2
+	}
+
+	var r io.Reader = os.Stdin
+	if flags.NArg() == 1 {
+		file, err := os.Open(flags.Arg(0))
+		if err != nil {
+			return fail(err)
+		}
+		defer file.Close()
+		r = file
+	}
+
+	db := openDB()
+	defer database.CloseDB(db)
+	stats, err := backup.Restore(context.Background(), db, r, backup.RestoreOptions{RemapIDs: *remap})
+	if err != nil {
+		return fail(err)
+	}
+	fmt.Println("restored", stats)
+	return 0
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/hy00nc/conduit-go/internal/service"
+)
+
+var articleCommands = map[string]command{
+	"list":   {"list articles, newest first", runArticleList},
+	"delete": {"move articles to their authors' trash", runArticleDelete},
+}
+
+var tagCommands = map[string]command{
+	"merge":  {"move the articles of tags to another tag", runTagMerge},
+	"rename": {"rename a tag", runTagRename},
+}
+
+func runArticleList(args []string) int {
+	flags := newFlags("article list [-tag tag] [-author username] [-limit n] [-offset n]")
+	var filter service.ArticleFilter
+	flags.StringVar(&filter.Tag, "tag", "", "only articles with this tag")
+	flags.StringVar(&filter.Author, "author", "", "only articles by this username")
+	flags.IntVar(&filter.Limit, "limit", service.DefaultLimit, "the number of articles")
+	flags.IntVar(&filter.Offset, "offset", 0, "the number of articles to skip")
+	if !parseArgs(flags, args, 0) {
+		return 2
+	}
+	return withAdmin(func(ctx context.Context, admin *service.AdminService) error {
+		articles, count, err := service.NewArticleService(admin.Store, nil).List(ctx, filter)
+		if err != nil {
+			return err
+		}
+		w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(w, "SLUG\tAUTHOR\tCREATED\tTAGS\tTITLE")
+		for _, article := range articles {
+			tags := make([]string, len(article.Tags))
+			for i, tag := range article.Tags {
+				tags[i] = tag.Name
+			}
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", article.Slug, article.Author.Name,
+				article.CreatedAt.Format("2006-01-02"), strings.Join(tags, ","), article.Title)
+		}
+		w.Flush()
+		fmt.Printf("%d of %d articles\n", len(articles), count)
+		return nil
+	})
+}
+
+func runArticleDelete(args []string) int {
+	flags := newFlags("article delete slug...\nDeleted articles can be restored by their authors until the trash is purged.")
+	flags.Parse(args)
+	if flags.NArg() == 0 {
+		flags.Usage()
+		return 2
+	}
+	return withAdmin(func(ctx context.Context, admin *service.AdminService) error {
+		for _, slug := range flags.Args() {
+			if err := admin.DeleteArticle(ctx, slug); err != nil {
+				return fmt.Errorf("%s: %w", slug, err)
+			}
+			fmt.Println("deleted", slug)
+		}
+		return nil
+	})
+}
+
+func runTagMerge(args []string) int {
+	flags := newFlags("tag merge into tag...\nThe tags are removed; their articles are tagged with into instead.")
+	flags.Parse(args)
+	if flags.NArg() < 2 {
+		flags.Usage()
+		return 2
+	}
+	return withAdmin(func(ctx context.Context, admin *service.AdminService) error {
+		if err := admin.MergeTags(ctx, flags.Arg(0), flags.Args()[1:]...); err != nil {
+			return err
+		}
+		fmt.Printf("merged %s into %s\n", strings.Join(flags.Args()[1:], ", "), flags.Arg(0))
+		return nil
+	})
+}
+
+func runTagRename(args []string) int {
+	flags := newFlags("tag rename tag name\nTo rename a tag to an existing one, merge them.")
+	if !parseArgs(flags, args, 2) {
+		return 2
+	}
+	return withAdmin(func(ctx context.Context, admin *service.AdminService) error {
+		tag, err := admin.RenameTag(ctx, flags.Arg(0), flags.Arg(1))
+		if err != nil {
+			return err
+		}
+		fmt.Println("renamed", flags.Arg(0), "to", tag.Name)
+		return nil
+	})
+}
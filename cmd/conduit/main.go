@@ -0,0 +1,134 @@
+// Command conduit runs the server and the tasks that maintain its
+// database, which is app.db in the working directory. Like the server, the
+// commands work through the service layer and read the same environment.
+//
+// Usage:
+//
+//	conduit <command> [flags] [args]
+//
+// The commands are:
+//
+//	serve    run the HTTP and gRPC server
+//	migrate  create or update the database schema
+//	user     create users, reset passwords, set roles and suspend users
+//	article  list and delete articles
+//	tag      merge and rename tags
+//	seed     fill the database with generated data
+//	export   write all content to a JSON-lines archive
+//	restore  load an archive written by export
+//
+// Run "conduit <command> -h" for the flags and subcommands of a command.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+
+	"github.com/hy00nc/conduit-go/internal/database"
+	"github.com/hy00nc/conduit-go/internal/logging"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// command runs with the arguments after its name and returns the exit
+// status.
+type command struct {
+	summary string
+	run     func(args []string) int
+}
+
+var commands = map[string]command{
+	"serve":   {"run the HTTP and gRPC server", runServe},
+	"migrate": {"create or update the database schema", runMigrate},
+	"user":    {"create users, reset passwords, set roles and suspend users", group("conduit user", userCommands)},
+	"article": {"list and delete articles", group("conduit article", articleCommands)},
+	"tag":     {"merge and rename tags", group("conduit tag", tagCommands)},
+	"seed":    {"fill the database with generated data", runSeed},
+	"export":  {"write all content to a JSON-lines archive", runExport},
+	"restore": {"load an archive written by export", runRestore},
+}
+
+func usage(name string, commands map[string]command) {
+	fmt.Fprintf(os.Stderr, "usage: %s <command> [flags] [args]\n", name)
+	fmt.Fprintln(os.Stderr, "\ncommands:")
+	names := make([]string, 0, len(commands))
+	for name := range commands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		fmt.Fprintf(os.Stderr, "  %-15s %s\n", name, commands[name].summary)
+	}
+}
+
+// dispatch runs the command named by the first argument.
+func dispatch(name string, commands map[string]command, args []string) int {
+	if len(args) == 0 || args[0] == "-h" || args[0] == "-help" || args[0] == "help" {
+		usage(name, commands)
+		return 2
+	}
+	cmd, ok := commands[args[0]]
+	if !ok {
+		fmt.Fprintf(os.Stderr, "%s: unknown command %q\n", name, args[0])
+		usage(name, commands)
+		return 2
+	}
+	return cmd.run(args[1:])
+}
+
+// group returns a command with subcommands.
+func group(name string, commands map[string]command) func(args []string) int {
+	return func(args []string) int {
+		return dispatch(name, commands, args)
+	}
+}
+
+// newFlags returns the flags of a command with synopsis, e.g.
+// "user suspend [-lift] user".
+func newFlags(synopsis string) *flag.FlagSet {
+	flags := flag.NewFlagSet(synopsis, flag.ExitOnError)
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "usage: conduit "+synopsis)
+		flags.PrintDefaults()
+	}
+	return flags
+}
+
+// parseArgs parses the flags and checks there are n positional arguments,
+// printing the usage if not.
+func parseArgs(flags *flag.FlagSet, args []string, n int) bool {
+	flags.Parse(args)
+	if flags.NArg() != n {
+		flags.Usage()
+		return false
+	}
+	return true
+}
+
+// initDB opens the database of the server. Queries are not logged: lookups
+// of missing rows are expected, and failures are reported by the commands.
+func initDB() *gorm.DB {
+	db := database.InitDB()
+	db.Logger = logger.Default.LogMode(logger.Silent)
+	return db
+}
+
+// openDB opens the database of the server with an up to date schema.
+func openDB() *gorm.DB {
+	db := initDB()
+	database.MigrateDB(db)
+	return db
+}
+
+// fail reports err and returns the exit status of a failed command.
+func fail(err error) int {
+	fmt.Fprintln(os.Stderr, "conduit:", err)
+	return 1
+}
+
+func main() {
+	logging.Setup()
+	os.Exit(dispatch("conduit", commands, os.Args[1:]))
+}
@@ -0,0 +1,38 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/hy00nc/conduit-go/internal/database"
+	"github.com/hy00nc/conduit-go/internal/seed"
+	"github.com/hy00nc/conduit-go/internal/service"
+)
+
+func runSeed(args []string) int {
+	flags := newFlags("seed [flags]")
+	var options seed.Options
+	flags.IntVar(&options.Users, "users", 50, "the number of users")
+	flags.IntVar(&options.Articles, "articles", 200, "the number of articles")
+	flags.IntVar(&options.Comments, "comments", 500, "the number of comments")
+	flags.IntVar(&options.Tags, "tags", 20, "the number of distinct tags")
+	flags.IntVar(&options.Follows, "follows", 5, "the average number of users each user follows")
+	flags.IntVar(&options.Favorites, "favorites", 10, "the average number of articles each user favorites")
+	flags.StringVar(&options.Password, "password", "password", "the password of every user")
+	flags.Int64Var(&options.Seed, "seed", 1, "the random seed")
+	if !parseArgs(flags, args, 0) {
+		return 2
+	}
+
+	db := openDB()
+	defer database.CloseDB(db)
+	start := time.Now()
+	stats, err := seed.Run(context.Background(), service.GormStore{DB: db}, options)
+	if err != nil {
+		return fail(err)
+	}
+	fmt.Printf("seeded %s in %s\n", stats, time.Since(start).Round(time.Millisecond))
+	fmt.Printf("every user's password is %q\n", options.Password)
+	return 0
+}
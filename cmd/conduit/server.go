@@ -0,0 +1,25 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/hy00nc/conduit-go/internal/app"
+	"github.com/hy00nc/conduit-go/internal/database"
+)
+
+func runServe(args []string) int {
+	if !parseArgs(newFlags("serve"), args, 0) {
+		return 2
+	}
+	app.RunServer()
+	return 0
+}
+
+func runMigrate(args []string) int {
+	if !parseArgs(newFlags("migrate"), args, 0) {
+		return 2
+	}
+	database.CloseDB(openDB())
+	fmt.Println("migrated")
+	return 0
+}
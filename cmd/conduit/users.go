@@ -0,0 +1,125 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"fmt"
+
+	"github.com/hy00nc/conduit-go/internal/database"
+	"github.com/hy00nc/conduit-go/internal/models"
+	"github.com/hy00nc/conduit-go/internal/service"
+)
+
+var userCommands = map[string]command{
+	"create":         {"register a user", runUserCreate},
+	"reset-password": {"set a new password", runUserResetPassword},
+	"set-role":       {"make a user an admin or a regular user", runUserSetRole},
+	"suspend":        {"keep a user from logging in, or lift a suspension", runUserSuspend},
+}
+
+// withAdmin runs fn with the admin service on the database of the server.
+func withAdmin(fn func(ctx context.Context, admin *service.AdminService) error) int {
+	db := openDB()
+	defer database.CloseDB(db)
+	if err := fn(context.Background(), service.NewAdminService(service.GormStore{DB: db})); err != nil {
+		return fail(err)
+	}
+	return 0
+}
+
+// generatePassword returns a random password for users created or reset
+// without one.
+func generatePassword() string {
+	b := make([]byte, 12)
+	rand.Read(b)
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+func printUser(user models.User) {
+	status := ""
+	if user.Suspended() {
+		status = ", suspended since " + user.SuspendedAt.Format("2006-01-02 15:04")
+	}
+	fmt.Printf("%s <%s> %s%s\n", user.Profile.Name, user.Email, user.Role, status)
+}
+
+func runUserCreate(args []string) int {
+	flags := newFlags("user create -username name -email email [-password password] [-role role]")
+	username := flags.String("username", "", "the username")
+	email := flags.String("email", "", "the email address")
+	password := flags.String("password", "", "the password; a random one is printed if empty")
+	role := flags.String("role", models.RoleUser, `"user" or "admin"`)
+	if !parseArgs(flags, args, 0) {
+		return 2
+	}
+	generated := *password == ""
+	if generated {
+		*password = generatePassword()
+	}
+	return withAdmin(func(ctx context.Context, admin *service.AdminService) error {
+		user, err := admin.CreateUser(ctx, service.RegisterInput{Username: *username, Email: *email, Password: *password, Role: *role})
+		if err != nil {
+			return err
+		}
+		printUser(user)
+		if generated {
+			fmt.Println("password:", *password)
+		}
+		return nil
+	})
+}
+
+func runUserResetPassword(args []string) int {
+	flags := newFlags("user reset-password [-password password] user\nThe user is a username or an email address.")
+	password := flags.String("password", "", "the new password; a random one is printed if empty")
+	if !parseArgs(flags, args, 1) {
+		return 2
+	}
+	generated := *password == ""
+	if generated {
+		*password = generatePassword()
+	}
+	return withAdmin(func(ctx context.Context, admin *service.AdminService) error {
+		user, err := admin.ResetPassword(ctx, flags.Arg(0), *password)
+		if err != nil {
+			return err
+		}
+		printUser(user)
+		if generated {
+			fmt.Println("password:", *password)
+		}
+		return nil
+	})
+}
+
+func runUserSetRole(args []string) int {
+	flags := newFlags("user set-role user role\nThe role is \"user\" or \"admin\".")
+	if !parseArgs(flags, args, 2) {
+		return 2
+	}
+	return withAdmin(func(ctx context.Context, admin *service.AdminService) error {
+		user, err := admin.SetRole(ctx, flags.Arg(0), flags.Arg(1))
+		if err != nil {
+			return err
+		}
+		printUser(user)
+		return nil
+	})
+}
+
+func runUserSuspend(args []string) int {
+	flags := newFlags("user suspend [-lift] user")
+	lift := flags.Bool("lift", false, "lift the suspension instead")
+	if !parseArgs(flags, args, 1) {
+		return 2
+	}
+	return withAdmin(func(ctx context.Context, admin *service.AdminService) error {
+		user, err := admin.Suspend(ctx, flags.Arg(0), !*lift)
+		if err != nil {
+			return err
+		}
+		printUser(user)
+		return nil
+	})
+}
@@ -0,0 +1,79 @@
+// Command import creates articles from a directory of Markdown files with
+// YAML front matter:
+//
+//	---
+//	title: Hello World
+//	description: The first post
+//	tags: [go, web]
+//	author: sally
+//	date: 2019-05-01T10:00:00Z
+//	slug: hello-world
+//	---
+//	The body, in Markdown.
+//
+// Authors must have registered. Files whose slug already exists are
+// skipped, so an import can be re-run after fixing the files that failed.
+//
+// Usage:
+//
+//	import [-dry-run] [-author username] dir
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/hy00nc/conduit-go/internal/database"
+	"github.com/hy00nc/conduit-go/internal/importer"
+	"github.com/hy00nc/conduit-go/internal/service"
+)
+
+func main() {
+	os.Exit(run())
+}
+
+// run imports and returns the exit status: 1 if any file failed.
+func run() int {
+	dryRun := flag.Bool("dry-run", false, "check the files without creating articles")
+	author := flag.String("author", "", "username for files without an author")
+	flag.Usage = func() {
+		fmt.Fprintln(flag.CommandLine.Output(), "usage: import [-dry-run] [-author username] dir")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if flag.NArg() != 1 {
+		flag.Usage()
+		return 2
+	}
+
+	db := database.InitDB()
+	database.MigrateDB(db)
+	defer database.CloseDB(db)
+
+	im := importer.New(service.GormStore{DB: db}, importer.Options{DryRun: *dryRun, Author: *author})
+	results, err := im.Dir(context.Background(), flag.Arg(0))
+	counts := map[string]int{}
+	for _, result := range results {
+		counts[result.Outcome]++
+		if result.Err != nil {
+			fmt.Printf("%-8s %s: %v\n", result.Outcome, result.File, result.Err)
+		} else {
+			fmt.Printf("%-8s %s (%s)\n", result.Outcome, result.File, result.Slug)
+		}
+	}
+	summary := fmt.Sprintf("%d created, %d skipped, %d failed", counts[importer.Created], counts[importer.Skipped], counts[importer.Failed])
+	if *dryRun {
+		summary += " (dry run, nothing was saved)"
+	}
+	fmt.Println(summary)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	if counts[importer.Failed] > 0 {
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,150 @@
+package app
+
+import (
+	"archive/zip"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/hy00nc/conduit-go/internal/models"
+	"github.com/hy00nc/conduit-go/internal/utils"
+)
+
+// exportWait is how long GET /api/user/export waits for an export before
+// answering 202 and letting it finish in the background.
+var exportWait = 2 * time.Second
+
+// exportTTL is how long a finished export that nobody fetched is kept.
+const exportTTL = time.Hour
+
+// exportJob builds the data export of a user; done is closed once export or
+// err is set.
+type exportJob struct {
+	done     chan struct{}
+	export   models.ExportResponse
+	err      error
+	finished time.Time
+}
+
+// exportJobs are the running and unclaimed exports by user ID.
+var exportJobs = struct {
+	sync.Mutex
+	byUser map[uint]*exportJob
+}{byUser: map[uint]*exportJob{}}
+
+// startExport returns the running or unclaimed export of user, or starts one.
+func startExport(user models.User) *exportJob {
+	exportJobs.Lock()
+	defer exportJobs.Unlock()
+	for id, job := range exportJobs.byUser {
+		if !job.finished.IsZero() && time.Since(job.finished) > exportTTL {
+			delete(exportJobs.byUser, id)
+		}
+	}
+	if job, ok := exportJobs.byUser[user.ID]; ok {
+		return job
+	}
+	job := &exportJob{done: make(chan struct{})}
+	exportJobs.byUser[user.ID] = job
+	go func() {
+		data, err := accountService.Export(context.Background(), user)
+		serializer := models.ExportSerializer{
+			User:      data.User,
+			Articles:  data.Articles,
+			Comments:  data.Comments,
+			Following: data.Following,
+			Favorites: data.Favorites,
+		}
+		exportJobs.Lock()
+		job.export, job.err, job.finished = serializer.Response(), err, time.Now()
+		exportJobs.Unlock()
+		close(job.done)
+	}()
+	return job
+}
+
+// claimExport forgets a finished export, so the next request builds a fresh one.
+func claimExport(userID uint, job *exportJob) {
+	exportJobs.Lock()
+	defer exportJobs.Unlock()
+	if exportJobs.byUser[userID] == job {
+		delete(exportJobs.byUser, userID)
+	}
+}
+
+// writeExportZip writes an export as a ZIP with one JSON file per part.
+func writeExportZip(w http.ResponseWriter, export models.ExportResponse) error {
+	archive := zip.NewWriter(w)
+	for _, file := range []struct {
+		name string
+		data interface{}
+	}{
+		{"account.json", export.Account},
+		{"articles.json", export.Articles},
+		{"comments.json", export.Comments},
+		{"following.json", export.Following},
+		{"favorites.json", export.Favorites},
+	} {
+		entry, err := archive.Create(file.name)
+		if err != nil {
+			return err
+		}
+		encoder := json.NewEncoder(entry)
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(file.data); err != nil {
+			return err
+		}
+	}
+	return archive.Close()
+}
+
+// ExportUser returns the data of the current user as a ZIP of JSON files,
+// or with ?format=json as one JSON document. Exports that take longer than
+// exportWait answer 202 until they are ready.
+func ExportUser(w http.ResponseWriter, r *http.Request) {
+	userData := r.Context().Value(utils.ContextKeyUserData).(models.User)
+	job := startExport(userData)
+	select {
+	case <-job.done:
+	case <-time.After(exportWait):
+		w.Header().Set("Retry-After", "5")
+		writeResponse(w, r, map[string]interface{}{"export": map[string]interface{}{"status": "pending"}}, http.StatusAccepted)
+		return
+	case <-r.Context().Done():
+		return
+	}
+	claimExport(userData.ID, job)
+	if job.err != nil {
+		writeServiceError(w, r, job.err, "Export")
+		return
+	}
+
+	if r.URL.Query().Get("format") == "json" {
+		writeResponse(w, r, map[string]interface{}{"export": job.export}, http.StatusOK)
+		return
+	}
+	w.Header().Set("Content-Type", "application/zip")
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="conduit-%s.zip"`, userData.Profile.Name))
+	if err := writeExportZip(w, job.export); err != nil {
+		logError(r, err)
+	}
+}
+
+// DeleteUser deletes the account of the current user, confirmed with its
+// password.
+func DeleteUser(w http.ResponseWriter, r *http.Request) {
+	userData := r.Context().Value(utils.ContextKeyUserData).(models.User)
+	var deleteValidator models.DeleteUserValidator
+	if err := decodeRequest(w, r, &deleteValidator, maxBodySize); err != nil {
+		writeServiceError(w, r, err, "Data")
+		return
+	}
+	if err := accountService.Delete(r.Context(), userData, deleteValidator.User.Password); err != nil {
+		writeServiceError(w, r, err, "User")
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -1,26 +1,234 @@
 package app
 
 import (
-	"log"
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
 	"net/http"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
 
-	"github.com/gorilla/handlers"
 	"github.com/hy00nc/conduit-go/internal/database"
+	"github.com/hy00nc/conduit-go/internal/logging"
+	"github.com/hy00nc/conduit-go/internal/metrics"
+	"github.com/hy00nc/conduit-go/internal/storage"
+	"github.com/hy00nc/conduit-go/internal/tracing"
+	"github.com/hy00nc/conduit-go/internal/webhooks"
+	"google.golang.org/grpc"
 )
 
+// Limits of the HTTP server, so that slow or idle clients cannot hold
+// connections forever.
+const (
+	readHeaderTimeout = 10 * time.Second
+	readTimeout       = time.Minute
+	writeTimeout      = time.Minute
+	idleTimeout       = 2 * time.Minute
+	maxHeaderBytes    = 64 << 10
+)
+
+// DefaultShutdownTimeout is how long requests in flight and background
+// workers are given to finish on shutdown.
+const DefaultShutdownTimeout = 20 * time.Second
+
+var errShuttingDown = errors.New("shutting down")
+
+// fatal logs err and exits.
+func fatal(msg string, err error) {
+	slog.Error(msg, "error", err)
+	os.Exit(1)
+}
+
+// shutdownDelay is CONDUIT_SHUTDOWN_DELAY (e.g. "5s"), or none: how long
+// the server keeps accepting connections after failing readiness, so that
+// load balancers notice before it stops. It counts against the timeout.
+func shutdownDelay() time.Duration {
+	delay, err := time.ParseDuration(os.Getenv("CONDUIT_SHUTDOWN_DELAY"))
+	if err != nil || delay < 0 {
+		return 0
+	}
+	return delay
+}
+
+// shutdownTimeout is DefaultShutdownTimeout or CONDUIT_SHUTDOWN_TIMEOUT (e.g. "45s").
+func shutdownTimeout() time.Duration {
+	timeout, err := time.ParseDuration(os.Getenv("CONDUIT_SHUTDOWN_TIMEOUT"))
+	if err != nil || timeout <= 0 {
+		return DefaultShutdownTimeout
+	}
+	return timeout
+}
+
+// newHTTPServer returns the server of handler on addr.
+func newHTTPServer(addr string, handler http.Handler) *http.Server {
+	server := &http.Server{
+		Addr:              addr,
+		Handler:           handler,
+		ReadHeaderTimeout: readHeaderTimeout,
+		ReadTimeout:       readTimeout,
+		WriteTimeout:      writeTimeout,
+		IdleTimeout:       idleTimeout,
+		MaxHeaderBytes:    maxHeaderBytes,
+	}
+	// WebSockets are hijacked, so Shutdown does not wait for them
+	server.RegisterOnShutdown(live.closeAll)
+	return server
+}
+
+// servers are the running HTTP and gRPC servers and background workers.
+type servers struct {
+	http        *http.Server
+	grpc        *grpc.Server
+	stopWorkers context.CancelFunc
+	workers     sync.WaitGroup
+	// drainDelay is the time between failing readiness and refusing
+	// connections
+	drainDelay time.Duration
+}
+
+// work runs fn in the background until shutdown.
+func (s *servers) work(ctx context.Context, fn func(context.Context)) {
+	s.workers.Add(1)
+	go func() {
+		defer s.workers.Done()
+		fn(ctx)
+	}()
+}
+
+// shutdown fails readiness and, after the drain delay, stops accepting
+// connections, waits for requests and calls in flight, then for the
+// background workers, until ctx is done.
+func (s *servers) shutdown(ctx context.Context) error {
+	shuttingDown.Store(true)
+	var errs []error
+
+	delay := time.NewTimer(s.drainDelay)
+	select {
+	case <-delay.C:
+	case <-ctx.Done():
+		delay.Stop()
+	}
+
+	if err := s.http.Shutdown(ctx); err != nil {
+		s.http.Close()
+		errs = append(errs, fmt.Errorf("http: %w", err))
+	}
+
+	// streams only end when told to
+	newArticles.close()
+	stopped := make(chan struct{})
+	go func() {
+		s.grpc.GracefulStop()
+		close(stopped)
+	}()
+	select {
+	case <-stopped:
+	case <-ctx.Done():
+		s.grpc.Stop()
+		errs = append(errs, fmt.Errorf("grpc: %w", ctx.Err()))
+	}
+
+	s.stopWorkers()
+	finished := make(chan struct{})
+	go func() {
+		s.workers.Wait()
+		close(finished)
+	}()
+	select {
+	case <-finished:
+	case <-ctx.Done():
+		errs = append(errs, fmt.Errorf("workers: %w", ctx.Err()))
+	}
+	return errors.Join(errs...)
+}
+
+// RunServer serves the HTTP API and gRPC until SIGINT or SIGTERM, then
+// shuts down gracefully and exits.
 func RunServer() {
+	// Structured logs, at the level of CONDUIT_LOG_LEVEL
+	logging.Setup()
+
+	// Traces, to the exporter of OTEL_TRACES_EXPORTER
+	shutdownTracing, err := tracing.Setup(context.Background())
+	if err != nil {
+		fatal("tracing: setup failed", err)
+	}
+
 	// Get db
 	db := database.InitDB()
 	database.MigrateDB(db)
-	defer database.CloseDB(db)
+	if err := metrics.InstrumentDB(db); err != nil {
+		fatal("metrics: instrument database", err)
+	}
+	if err := tracing.InstrumentDB(db); err != nil {
+		fatal("tracing: instrument database", err)
+	}
+
+	// Media storage
+	mediaStorage = storage.FromEnv()
+
+	// gRPC for internal consumers, on its own port
+	grpcListener, err := net.Listen("tcp", grpcAddr())
+	if err != nil {
+		fatal("grpc: listen failed", err)
+	}
+
+	workerContext, stopWorkers := context.WithCancel(context.Background())
+	s := &servers{
+		http:        newHTTPServer(":8000", MakeWebHandler(true)),
+		grpc:        NewGRPCServer(),
+		stopWorkers: stopWorkers,
+		drainDelay:  shutdownDelay(),
+	}
 
-	// Headers
-	headersOk := handlers.AllowedHeaders([]string{"X-Requested-With", "Content-Type", "Authorization"})
-	originsOk := handlers.AllowedOrigins([]string{"http://localhost:4100", "http://0.0.0.0:4100"})
-	methodsOk := handlers.AllowedMethods([]string{"GET", "HEAD", "POST", "PUT", "OPTIONS", "DELETE"})
-	allowCredentials := handlers.AllowCredentials()
-	// ignoreOptions := handlers.IgnoreOptions()
+	// Deliver webhooks in the background
+	s.work(workerContext, webhooks.NewDispatcher(db).Run)
+
+	// Purge the trash once the retention period is over
+	s.work(workerContext, func(ctx context.Context) { trashService.RunPurge(ctx, time.Hour) })
 
 	// Start serving
-	log.Fatal(http.ListenAndServe(":8000", handlers.CORS(originsOk, headersOk, methodsOk, allowCredentials)(MakeWebHandler(true))))
+	failed := make(chan error, 2)
+	go func() {
+		if err := s.grpc.Serve(grpcListener); err != nil {
+			failed <- fmt.Errorf("grpc: %w", err)
+		}
+	}()
+	go func() {
+		if err := s.http.ListenAndServe(); !errors.Is(err, http.ErrServerClosed) {
+			failed <- fmt.Errorf("http: %w", err)
+		}
+	}()
+
+	// Shut down on SIGTERM, as sent on deploys, or Ctrl-C
+	signals, stopSignals := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	exit := 0
+	select {
+	case <-signals.Done():
+	case err := <-failed:
+		slog.Error("serve failed", "error", err)
+		exit = 1
+	}
+	// a second signal kills the process
+	stopSignals()
+
+	timeout := shutdownTimeout()
+	slog.Info("shutting down", "delay", s.drainDelay, "timeout", timeout)
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	if err := s.shutdown(ctx); err != nil {
+		slog.Error("shutdown incomplete", "error", err)
+		exit = 1
+	}
+	if err := shutdownTracing(ctx); err != nil {
+		slog.Error("tracing: flush failed", "error", err)
+	}
+	cancel()
+	database.CloseDB(db)
+	slog.Info("stopped")
+	os.Exit(exit)
 }
@@ -0,0 +1,134 @@
+package app
+
+import (
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/hy00nc/conduit-go/internal/models"
+	"github.com/stretchr/testify/assert"
+)
+
+// startServers serves handler over HTTP and the gRPC service on free
+// ports, returning the base URL of the HTTP server.
+func startServers(t *testing.T, handler http.Handler) (*servers, string) {
+	httpListener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	grpcListener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, stopWorkers := context.WithCancel(context.Background())
+	s := &servers{http: newHTTPServer("", handler), grpc: NewGRPCServer(), stopWorkers: stopWorkers}
+	go s.http.Serve(httpListener)
+	go s.grpc.Serve(grpcListener)
+
+	previous := newArticles
+	newArticles = &articleBroadcast{subscribers: map[chan models.Article]struct{}{}}
+	t.Cleanup(func() {
+		newArticles = previous
+		shuttingDown.Store(false)
+	})
+	return s, "http://" + httpListener.Addr().String()
+}
+
+func TestShutdownDrains(t *testing.T) {
+	asserts := assert.New(t)
+	started, release := make(chan struct{}), make(chan struct{})
+	s, url := startServers(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		close(started)
+		<-release
+		io.WriteString(w, "done")
+	}))
+	workerContext, stopWorkers := context.WithCancel(context.Background())
+	s.stopWorkers = stopWorkers
+	var workerFinished atomic.Bool
+	s.work(workerContext, func(ctx context.Context) {
+		<-ctx.Done()
+		time.Sleep(10 * time.Millisecond) // finishing a batch
+		workerFinished.Store(true)
+	})
+
+	responses := make(chan string)
+	go func() {
+		response, err := http.Get(url)
+		if err != nil {
+			responses <- err.Error()
+			return
+		}
+		defer response.Body.Close()
+		body, _ := io.ReadAll(response.Body)
+		responses <- string(body)
+	}()
+	<-started
+
+	stopped := make(chan error)
+	go func() { stopped <- s.shutdown(context.Background()) }()
+	asserts.Eventually(shuttingDown.Load, time.Second, time.Millisecond, "readiness fails at once")
+	asserts.Eventually(func() bool {
+		_, err := http.Get(url)
+		return err != nil
+	}, time.Second, 5*time.Millisecond, "new connections are refused")
+
+	close(release)
+	asserts.Equal("done", <-responses, "the request in flight is served")
+	asserts.NoError(<-stopped)
+	asserts.True(workerFinished.Load(), "workers are waited for")
+}
+
+func TestShutdownDelay(t *testing.T) {
+	asserts := assert.New(t)
+	s, url := startServers(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, "served")
+	}))
+	s.drainDelay = 200 * time.Millisecond
+
+	stopped := make(chan error)
+	begun := time.Now()
+	go func() { stopped <- s.shutdown(context.Background()) }()
+	asserts.Eventually(shuttingDown.Load, time.Second, time.Millisecond, "readiness fails at once")
+	response, err := http.Get(url)
+	if asserts.NoError(err, "connections are accepted while load balancers notice") {
+		body, _ := io.ReadAll(response.Body)
+		response.Body.Close()
+		asserts.Equal("served", string(body))
+	}
+	asserts.NoError(<-stopped)
+	asserts.GreaterOrEqual(time.Since(begun), s.drainDelay)
+	_, err = http.Get(url)
+	asserts.Error(err, "then they are refused")
+
+	// the delay counts against the deadline
+	s, _ = startServers(t, http.NotFoundHandler())
+	s.drainDelay = time.Hour
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	begun = time.Now()
+	asserts.ErrorIs(s.shutdown(ctx), context.DeadlineExceeded)
+	asserts.Less(time.Since(begun), time.Second)
+}
+
+func TestShutdownDeadline(t *testing.T) {
+	asserts := assert.New(t)
+	release := make(chan struct{})
+	defer close(release)
+	started := make(chan struct{})
+	s, url := startServers(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		close(started)
+		<-release
+	}))
+	go http.Get(url)
+	<-started
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	err := s.shutdown(ctx)
+	asserts.ErrorIs(err, context.DeadlineExceeded)
+	asserts.ErrorContains(err, "http")
+}
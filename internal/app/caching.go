@@ -0,0 +1,135 @@
+package app
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/hy00nc/conduit-go/internal/models"
+	"github.com/hy00nc/conduit-go/internal/utils"
+)
+
+// Cache-Control policies of API responses. Anonymous responses may be kept
+// by shared caches, those of signed in users only by their own; either is
+// revalidated with its ETag before use, except for the short lived tags.
+const (
+	cacheTags    = "public, max-age=60"
+	cachePublic  = "public, no-cache"
+	cachePrivate = "private, no-cache"
+)
+
+// cachePolicy is public for anonymous requests and cachePrivate for those of
+// signed in users, whose responses depend on who they are.
+func cachePolicy(r *http.Request, public string) string {
+	if _, ok := r.Context().Value(utils.ContextKeyUserData).(models.User); ok {
+		return cachePrivate
+	}
+	return public
+}
+
+// version identifies when a record was last updated in ETags, to the
+// microsecond as databases keep it.
+func version(updatedAt time.Time) string {
+	return strconv.FormatInt(updatedAt.UnixMicro(), 36)
+}
+
+// contentETag is a strong ETag of body, led by the version of the record it
+// represents, if any.
+func contentETag(version string, body []byte) string {
+	sum := sha256.Sum256(body)
+	if version != "" {
+		return `"` + version + "-" + hex.EncodeToString(sum[:8]) + `"`
+	}
+	return `"` + hex.EncodeToString(sum[:16]) + `"`
+}
+
+// notModified reports whether the client copy identified by the conditional
+// request headers is still current. If-None-Match takes precedence.
+func notModified(r *http.Request, etag string, lastModified time.Time) bool {
+	if match := r.Header.Get("If-None-Match"); match != "" {
+		for _, candidate := range strings.Split(match, ",") {
+			candidate = strings.TrimSpace(candidate)
+			if candidate == "*" || strings.TrimPrefix(candidate, "W/") == etag {
+				return true
+			}
+		}
+		return false
+	}
+	if since, err := http.ParseTime(r.Header.Get("If-Modified-Since")); err == nil && !lastModified.IsZero() {
+		return !lastModified.After(since)
+	}
+	return false
+}
+
+// writeCacheable writes body with its validators, or only them with 304 Not
+// Modified if the client copy fetched by a GET or HEAD is still current.
+func writeCacheable(w http.ResponseWriter, r *http.Request, body []byte, contentType, etag string, lastModified time.Time, cacheControl string) {
+	w.Header().Set("ETag", etag)
+	w.Header().Set("Cache-Control", cacheControl)
+	if !lastModified.IsZero() {
+		w.Header().Set("Last-Modified", lastModified.Format(http.TimeFormat))
+	}
+	if (r.Method == "GET" || r.Method == "HEAD") && notModified(r, etag, lastModified) {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+	w.Header().Set("Content-Type", contentType)
+	w.WriteHeader(http.StatusOK)
+	w.Write(body)
+}
+
+// writeCached writes data like writeResponse, with an ETag and, unless
+// updatedAt is zero, the Last-Modified time of the record it represents.
+// Responses vary with the viewer, so shared caches key them by
+// Authorization.
+func writeCached(w http.ResponseWriter, r *http.Request, data map[string]interface{}, updatedAt time.Time, cacheControl string) {
+	format := responseFormat(r)
+	body, err := format.encode(data)
+	if err != nil {
+		writeServiceError(w, r, err, "Response")
+		return
+	}
+	var etag string
+	var lastModified time.Time
+	if updatedAt.IsZero() {
+		etag = contentETag("", body)
+	} else {
+		etag = contentETag(version(updatedAt), body)
+		lastModified = updatedAt.UTC().Truncate(time.Second)
+	}
+	w.Header().Add("Vary", "Accept")
+	w.Header().Add("Vary", "Authorization")
+	writeCacheable(w, r, body, format.contentType, etag, lastModified, cacheControl)
+}
+
+// ifMatch returns the record update times listed by the If-Match header of
+// r. Only the version of an ETag is compared, so that a tag taken from
+// another viewer's copy, or before the favorites count changed, still
+// matches. conditional is false without If-Match or with "*"; with only
+// tags of no version, times is empty and nothing can match.
+func ifMatch(r *http.Request) (times []time.Time, conditional bool) {
+	match := r.Header.Get("If-Match")
+	if match == "" || strings.TrimSpace(match) == "*" {
+		return nil, false
+	}
+	for _, candidate := range strings.Split(match, ",") {
+		// If-Match compares strong tags only
+		candidate = strings.TrimSpace(candidate)
+		if !strings.HasPrefix(candidate, `"`) {
+			continue
+		}
+		version, _, ok := strings.Cut(strings.Trim(candidate, `"`), "-")
+		if !ok {
+			continue
+		}
+		micros, err := strconv.ParseInt(version, 36, 64)
+		if err != nil {
+			continue
+		}
+		times = append(times, time.UnixMicro(micros))
+	}
+	return times, true
+}
@@ -0,0 +1,132 @@
+package app
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/hy00nc/conduit-go/internal/cache"
+	"github.com/hy00nc/conduit-go/internal/service"
+	"github.com/hy00nc/conduit-go/internal/utils"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestConditionalRequests(t *testing.T) {
+	asserts := assert.New(t)
+	useTempDB(t)
+	user, err := userService.Register(t.Context(), service.RegisterInput{Username: "sally", Email: "sally@example.com", Password: "secret"})
+	asserts.NoError(err)
+	token, err := utils.GetToken(user.ID)
+	asserts.NoError(err)
+	article, err := articleService.Create(t.Context(), user, service.ArticleInput{Title: "First", Description: "d", Body: "b", TagList: []string{"go"}})
+	asserts.NoError(err)
+	handler := MakeWebHandler(false)
+	serve := func(method, path, body string, header http.Header) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		for name, values := range header {
+			req.Header[name] = values
+		}
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+		return w
+	}
+	path := "/api/articles/" + article.Slug
+
+	w := serve("GET", path, "", nil)
+	asserts.Equal(http.StatusOK, w.Code)
+	etag := w.Header().Get("ETag")
+	asserts.Regexp(`^"[0-9a-z]+-[0-9a-f]{16}"$`, etag)
+	asserts.Equal(article.UpdatedAt.UTC().Format(http.TimeFormat), w.Header().Get("Last-Modified"))
+	asserts.Equal(cachePublic, w.Header().Get("Cache-Control"))
+	asserts.Equal([]string{"Origin", "Accept", "Authorization", "Accept-Encoding"}, w.Header().Values("Vary"))
+
+	w = serve("GET", path, "", http.Header{"If-None-Match": {`"other", ` + etag}})
+	asserts.Equal(http.StatusNotModified, w.Code)
+	asserts.Empty(w.Body.String())
+	asserts.Equal(etag, w.Header().Get("ETag"))
+	w = serve("GET", path, "", http.Header{"If-Modified-Since": {w.Header().Get("Last-Modified")}})
+	asserts.Equal(http.StatusNotModified, w.Code)
+	w = serve("GET", path+"?render=html", "", http.Header{"If-None-Match": {etag}})
+	asserts.Equal(http.StatusOK, w.Code, "another representation has another ETag")
+
+	w = serve("GET", "/api/profiles/sally", "", nil)
+	asserts.Equal(cachePublic, w.Header().Get("Cache-Control"))
+	profileTag := w.Header().Get("ETag")
+	harry, err := userService.Register(t.Context(), service.RegisterInput{Username: "harry", Email: "harry@example.com", Password: "secret"})
+	asserts.NoError(err)
+	_, err = profileService.Follow(t.Context(), harry, "sally")
+	asserts.NoError(err)
+	harryToken, err := utils.GetToken(harry.ID)
+	asserts.NoError(err)
+	w = serve("GET", "/api/profiles/sally", "", http.Header{"Authorization": {"Token " + harryToken}, "If-None-Match": {profileTag}})
+	asserts.Equal(http.StatusOK, w.Code, "the profile as seen by a follower is another representation")
+	asserts.Equal(cachePrivate, w.Header().Get("Cache-Control"))
+
+	w = serve("GET", "/api/tags", "", nil)
+	asserts.Equal(cacheTags, w.Header().Get("Cache-Control"))
+	asserts.Empty(w.Header().Get("Last-Modified"))
+	w = serve("GET", "/api/tags", "", http.Header{"If-None-Match": {w.Header().Get("ETag")}})
+	asserts.Equal(http.StatusNotModified, w.Code)
+
+	// two editors start from the same copy
+	auth := http.Header{"Authorization": {"Token " + token}, "If-Match": {etag}}
+	w = serve("PUT", path, `{"article":{"body":"first edit"}}`, auth)
+	asserts.Equal(http.StatusOK, w.Code)
+	newTag := w.Header().Get("ETag")
+	asserts.NotEqual(etag, newTag)
+	w = serve("PUT", path, `{"article":{"body":"second edit"}}`, auth)
+	asserts.Equal(http.StatusPreconditionFailed, w.Code)
+	asserts.JSONEq(`{"errors":{"article":"is invalid"}}`, w.Body.String())
+
+	w = serve("GET", path, "", http.Header{"If-None-Match": {etag}})
+	asserts.Equal(http.StatusOK, w.Code)
+	asserts.Contains(w.Body.String(), "first edit")
+	asserts.Equal(newTag, w.Header().Get("ETag"), "PUT returns the ETag of the updated article")
+
+	w = serve("PUT", path, `{"article":{"body":"any"}}`, http.Header{"Authorization": {"Token " + token}, "If-Match": {"W/" + newTag}})
+	asserts.Equal(http.StatusPreconditionFailed, w.Code, "weak tags never match")
+	w = serve("PUT", path, `{"article":{"body":"any"}}`, http.Header{"Authorization": {"Token " + token}, "If-Match": {"*"}})
+	asserts.Equal(http.StatusOK, w.Code)
+	w = serve("PUT", path, `{"article":{"body":"unconditional"}}`, http.Header{"Authorization": {"Token " + token}})
+	asserts.Equal(http.StatusOK, w.Code)
+}
+
+func TestCachedReads(t *testing.T) {
+	asserts := assert.New(t)
+	useTempDB(t)
+	user, err := userService.Register(t.Context(), service.RegisterInput{Username: "sally", Email: "sally@example.com", Password: "secret"})
+	asserts.NoError(err)
+	token, err := utils.GetToken(user.ID)
+	asserts.NoError(err)
+	article, err := articleService.Create(t.Context(), user, service.ArticleInput{Title: "First", Description: "d", Body: "b"})
+	asserts.NoError(err)
+	handler := MakeWebHandler(false)
+	serve := func(method, path, body string, authenticated bool) string {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		if authenticated {
+			req.Header.Set("Authorization", "Token "+token)
+		}
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+		asserts.Equal(http.StatusOK, w.Code, "%s %s", method, path)
+		return w.Body.String()
+	}
+	path := "/api/articles/" + article.Slug
+
+	serve("GET", path, "", false)
+	_, ok, _ := appCache.Get(t.Context(), cache.ArticleKey(article.Slug, false))
+	asserts.True(ok, "anonymous responses are cached")
+	serve("POST", path+"/favorite", "", true)
+	asserts.Contains(serve("GET", path, "", false), `"favoritesCount":1`)
+
+	asserts.Contains(serve("GET", "/api/user", "", true), `"username":"sally"`)
+	cached, ok, _ := appCache.Get(t.Context(), cache.UserKey(user.ID))
+	asserts.True(ok, "the user of a token is cached")
+	asserts.NotContains(string(cached), user.Hash, "but not its password hash")
+	serve("PUT", "/api/user", `{"user":{"username":"sally2"}}`, true)
+	asserts.Contains(serve("GET", "/api/user", "", true), `"username":"sally2"`)
+	asserts.Contains(serve("GET", path, "", false), `"username":"sally2"`)
+	_, err = userService.Login(t.Context(), "sally@example.com", "secret")
+	asserts.NoError(err, "updates from the cached user keep the password")
+}
@@ -0,0 +1,198 @@
+package app
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/andybalholm/brotli"
+)
+
+// minCompressSize is the size under which responses are sent as they are,
+// compressing them saving too little to be worth it.
+const minCompressSize = 1024
+
+// compressibleTypes are the prefixes of the content types worth
+// compressing; images and other binary media already are.
+var compressibleTypes = []string{"application/json", "application/msgpack", "application/cbor", "application/xml", "application/atom+xml", "application/rss+xml", "text/"}
+
+// Compressors, reused across responses as their state is large.
+var (
+	gzipWriters   = sync.Pool{New: func() any { return gzip.NewWriter(io.Discard) }}
+	brotliWriters = sync.Pool{New: func() any {
+		// level 5 compresses better than gzip at a comparable speed
+		return brotli.NewWriterLevel(io.Discard, 5)
+	}}
+)
+
+// compressMiddleware compresses responses with brotli or gzip, as
+// Accept-Encoding prefers. ETags of compressed responses get a suffix, as
+// they are other representations, which conditional request headers lose
+// again before reaching the handler.
+func compressMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		for _, name := range []string{"If-None-Match", "If-Match"} {
+			if value := r.Header.Get(name); value != "" {
+				value = strings.ReplaceAll(value, `-br"`, `"`)
+				r.Header.Set(name, strings.ReplaceAll(value, `-gzip"`, `"`))
+			}
+		}
+		cw := &compressWriter{ResponseWriter: w, encoding: preferred(r.Header.Get("Accept-Encoding"), []string{"br", "gzip"}), head: r.Method == "HEAD"}
+		defer cw.Close()
+		next.ServeHTTP(cw, r)
+	})
+}
+
+// compressWriter holds back the start of a response until it knows whether
+// it is big enough to compress.
+type compressWriter struct {
+	http.ResponseWriter
+	encoding string // "br", "gzip" or "" for none
+	head     bool
+
+	status     int
+	buf        bytes.Buffer
+	started    bool
+	compressor io.WriteCloser
+	hijacked   bool
+}
+
+func (w *compressWriter) WriteHeader(status int) {
+	if w.status == 0 && !w.started {
+		w.status = status
+	}
+}
+
+func (w *compressWriter) Write(b []byte) (int, error) {
+	if w.started {
+		if w.compressor != nil {
+			return w.compressor.Write(b)
+		}
+		return w.ResponseWriter.Write(b)
+	}
+	w.buf.Write(b)
+	if w.buf.Len() >= minCompressSize {
+		if err := w.start(true); err != nil {
+			return 0, err
+		}
+	}
+	return len(b), nil
+}
+
+// compressible reports whether the response may be compressed, whatever
+// its size.
+func (w *compressWriter) compressible() bool {
+	switch {
+	case w.head, w.status < http.StatusOK, w.status == http.StatusNoContent, w.status == http.StatusNotModified:
+		return false
+	case w.Header().Get("Content-Encoding") != "":
+		return false
+	}
+	contentType := w.Header().Get("Content-Type")
+	for _, prefix := range compressibleTypes {
+		if strings.HasPrefix(contentType, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// start sends the header, compressing the rest of the response if big is
+// set and the client accepts it, and what was held back.
+func (w *compressWriter) start(big bool) error {
+	w.started = true
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	header := w.Header()
+	if w.compressible() {
+		header.Add("Vary", "Accept-Encoding")
+		if big && w.encoding != "" {
+			header.Set("Content-Encoding", w.encoding)
+			header.Del("Content-Length")
+			if etag := header.Get("ETag"); strings.HasSuffix(etag, `"`) {
+				header.Set("ETag", strings.TrimSuffix(etag, `"`)+"-"+w.encoding+`"`)
+			}
+			if w.encoding == "br" {
+				compressor := brotliWriters.Get().(*brotli.Writer)
+				compressor.Reset(w.ResponseWriter)
+				w.compressor = compressor
+			} else {
+				compressor := gzipWriters.Get().(*gzip.Writer)
+				compressor.Reset(w.ResponseWriter)
+				w.compressor = compressor
+			}
+		}
+	}
+	w.ResponseWriter.WriteHeader(w.status)
+	if w.buf.Len() == 0 {
+		return nil
+	}
+	_, err := w.Write(w.buf.Bytes())
+	w.buf.Reset()
+	return err
+}
+
+// Close sends what was held back and finishes the compressed stream.
+func (w *compressWriter) Close() error {
+	if w.hijacked {
+		return nil
+	}
+	if !w.started {
+		if w.status == 0 && w.buf.Len() == 0 {
+			// nothing was written, leave the default response to the server
+			return nil
+		}
+		if err := w.start(false); err != nil {
+			return err
+		}
+	}
+	if w.compressor == nil {
+		return nil
+	}
+	err := w.compressor.Close()
+	switch compressor := w.compressor.(type) {
+	case *brotli.Writer:
+		compressor.Reset(io.Discard)
+		brotliWriters.Put(compressor)
+	case *gzip.Writer:
+		compressor.Reset(io.Discard)
+		gzipWriters.Put(compressor)
+	}
+	w.compressor = nil
+	return err
+}
+
+// Flush sends what was written so far, compressed if it may be, as the
+// response is being streamed.
+func (w *compressWriter) Flush() {
+	if !w.started {
+		w.start(true)
+	}
+	if flusher, ok := w.compressor.(interface{ Flush() error }); ok {
+		flusher.Flush()
+	}
+	if flusher, ok := w.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+// Hijack lets WebSocket upgrades through.
+func (w *compressWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	hijacker, ok := w.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, errors.New("hijacking is not supported")
+	}
+	w.hijacked = true
+	return hijacker.Hijack()
+}
+
+func (w *compressWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
@@ -0,0 +1,78 @@
+package app
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/andybalholm/brotli"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCompression(t *testing.T) {
+	asserts := assert.New(t)
+	big := []byte(strings.Repeat(`{"body":"lorem ipsum"}`, 100))
+	handler := compressMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/big":
+			writeCacheable(w, r, big, "application/json; charset=utf-8", `"tag"`, time.Time{}, cachePublic)
+		case "/small":
+			w.Header().Set("Content-Type", "application/json")
+			io.WriteString(w, `{}`)
+		case "/image":
+			w.Header().Set("Content-Type", "image/png")
+			w.Write(big)
+		}
+	}))
+	serve := func(path string, header http.Header) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", path, nil)
+		for name, values := range header {
+			req.Header[name] = values
+		}
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+		return w
+	}
+
+	w := serve("/big", http.Header{"Accept-Encoding": {"gzip, br"}})
+	asserts.Equal("br", w.Header().Get("Content-Encoding"), "brotli is preferred")
+	asserts.Equal("Accept-Encoding", w.Header().Get("Vary"))
+	asserts.Equal(`"tag-br"`, w.Header().Get("ETag"))
+	body, err := io.ReadAll(brotli.NewReader(w.Body))
+	asserts.NoError(err)
+	asserts.Equal(big, body)
+
+	w = serve("/big", http.Header{"Accept-Encoding": {"br;q=0.5, gzip"}})
+	asserts.Equal("gzip", w.Header().Get("Content-Encoding"))
+	asserts.Equal(`"tag-gzip"`, w.Header().Get("ETag"))
+	reader, err := gzip.NewReader(w.Body)
+	asserts.NoError(err)
+	body, err = io.ReadAll(reader)
+	asserts.NoError(err)
+	asserts.Equal(big, body)
+
+	w = serve("/big", http.Header{"Accept-Encoding": {"gzip"}, "If-None-Match": {`"tag-gzip"`}})
+	asserts.Equal(http.StatusNotModified, w.Code, "conditional requests see the ETag of the handler")
+
+	w = serve("/big", nil)
+	asserts.Empty(w.Header().Get("Content-Encoding"))
+	asserts.Equal("Accept-Encoding", w.Header().Get("Vary"))
+	asserts.Equal(big, w.Body.Bytes())
+	w = serve("/big", http.Header{"Accept-Encoding": {"gzip;q=0, identity"}})
+	asserts.Empty(w.Header().Get("Content-Encoding"))
+
+	w = serve("/small", http.Header{"Accept-Encoding": {"gzip"}})
+	asserts.Empty(w.Header().Get("Content-Encoding"), "small responses are not worth compressing")
+	asserts.Equal("Accept-Encoding", w.Header().Get("Vary"))
+	asserts.Equal(`{}`, w.Body.String())
+
+	w = serve("/image", http.Header{"Accept-Encoding": {"gzip"}})
+	asserts.Empty(w.Header().Get("Content-Encoding"), "images are compressed already")
+	asserts.Empty(w.Header().Get("Vary"))
+	asserts.True(bytes.Equal(big, w.Body.Bytes()))
+}
@@ -0,0 +1,262 @@
+package app
+
+import (
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// Origins allowed to call the API from a browser, changed with
+// CONDUIT_CORS_ORIGINS.
+const defaultCORSOrigins = "http://localhost:4100,http://0.0.0.0:4100"
+
+// defaultCORSMaxAge is how long browsers may keep preflight responses,
+// changed with CONDUIT_CORS_MAX_AGE.
+const defaultCORSMaxAge = 10 * time.Minute
+
+// Headers scripts may send to the API, and those they may read in its
+// responses; CONDUIT_CORS_HEADERS and CONDUIT_CORS_EXPOSED_HEADERS add
+// to them.
+var (
+	corsAllowedHeaders = []string{"X-Requested-With", "Content-Type", "Authorization", requestIDHeader, "traceparent", "tracestate", "If-Match", "If-None-Match", "If-Modified-Since"}
+	corsExposedHeaders = []string{requestIDHeader, "ETag", "Last-Modified", "Retry-After", "RateLimit-Limit", "RateLimit-Remaining", "RateLimit-Reset", "RateLimit-Policy"}
+)
+
+// corsPolicy tells browsers which origins may call routes, and what
+// scripts of those may send and read.
+type corsPolicy struct {
+	origins        originPatterns
+	allowedHeaders []string
+	exposedHeaders []string
+	maxAge         time.Duration
+	// credentials lets scripts send cookies and client certificates; it
+	// is never set for all origins.
+	credentials bool
+}
+
+// corsRoute overrides the policy of the routes under prefix; a nil
+// policy keeps browsers from calling them at all.
+type corsRoute struct {
+	prefix string
+	policy *corsPolicy
+}
+
+var (
+	// apiCORS is the policy of the API, CONDUIT_CORS_* changing the default.
+	apiCORS = corsFromEnv()
+
+	// publicCORS lets any site read public documents, which are not
+	// personalized for signed in users.
+	publicCORS = &corsPolicy{origins: originPatterns{any: true}, maxAge: defaultCORSMaxAge}
+
+	// corsRoutes override apiCORS, the first matching prefix winning.
+	corsRoutes = []corsRoute{
+		{"/feeds/", publicCORS},
+		{"/uploads/", publicCORS},
+		{"/api/openapi.json", publicCORS},
+		{"/api/docs", nil},
+		{"/metrics", nil},
+		{"/healthz", nil},
+		{"/readyz", nil},
+	}
+)
+
+// corsFromEnv is the default API policy changed by CONDUIT_CORS_ORIGINS,
+// CONDUIT_CORS_HEADERS, CONDUIT_CORS_EXPOSED_HEADERS and
+// CONDUIT_CORS_MAX_AGE. Invalid values are logged and ignored.
+func corsFromEnv() *corsPolicy {
+	policy := &corsPolicy{
+		allowedHeaders: append(slices.Clone(corsAllowedHeaders), splitList(os.Getenv("CONDUIT_CORS_HEADERS"))...),
+		exposedHeaders: append(slices.Clone(corsExposedHeaders), splitList(os.Getenv("CONDUIT_CORS_EXPOSED_HEADERS"))...),
+		maxAge:         defaultCORSMaxAge,
+	}
+	origins, err := parseOrigins(os.Getenv("CONDUIT_CORS_ORIGINS"))
+	if err != nil {
+		slog.Error("CONDUIT_CORS_ORIGINS ignored", "error", err)
+	}
+	if err != nil || origins.empty() {
+		origins, _ = parseOrigins(defaultCORSOrigins)
+	}
+	policy.origins = origins
+	policy.credentials = !origins.any
+	if value := os.Getenv("CONDUIT_CORS_MAX_AGE"); value != "" {
+		if maxAge, err := time.ParseDuration(value); err != nil || maxAge < 0 {
+			slog.Error("CONDUIT_CORS_MAX_AGE ignored", "value", value)
+		} else {
+			policy.maxAge = maxAge
+		}
+	}
+	return policy
+}
+
+// corsPolicyFor is the policy of the route of path, nil if browsers may
+// not call it from other origins.
+func corsPolicyFor(path string) *corsPolicy {
+	for _, route := range corsRoutes {
+		if strings.HasPrefix(path, route.prefix) {
+			return route.policy
+		}
+	}
+	return apiCORS
+}
+
+// originPatterns match the origins allowed by a policy.
+type originPatterns struct {
+	any      bool
+	patterns []*regexp.Regexp
+}
+
+// parseOrigins parses a comma separated list of origins, where "*" allows
+// any origin, a "*" within an origin any host name part or port, as in
+// "https://*.example.com", and an entry starting with "^" is a regular
+// expression matching whole origins, as in "^https://app[0-9]+\.example\.com".
+func parseOrigins(s string) (originPatterns, error) {
+	var origins originPatterns
+	for _, origin := range splitList(s) {
+		expr := origin
+		switch {
+		case origin == "*":
+			origins.any = true
+			continue
+		case strings.HasPrefix(origin, "^"):
+			expr = "(?:" + origin + ")$"
+		default:
+			expr = "^" + strings.ReplaceAll(regexp.QuoteMeta(strings.ToLower(origin)), `\*`, `[a-z0-9-]+`) + "$"
+		}
+		pattern, err := regexp.Compile(expr)
+		if err != nil {
+			return originPatterns{}, fmt.Errorf("origin %q: %w", origin, err)
+		}
+		origins.patterns = append(origins.patterns, pattern)
+	}
+	return origins, nil
+}
+
+func (o originPatterns) empty() bool {
+	return !o.any && len(o.patterns) == 0
+}
+
+func (o originPatterns) match(origin string) bool {
+	if o.any {
+		return true
+	}
+	origin = strings.ToLower(origin)
+	for _, pattern := range o.patterns {
+		if pattern.MatchString(origin) {
+			return true
+		}
+	}
+	return false
+}
+
+// allowOrigin lets scripts of origin read the response, if the policy
+// allows it.
+func (p *corsPolicy) allowOrigin(header http.Header, origin string) bool {
+	if !p.origins.match(origin) {
+		return false
+	}
+	if p.origins.any {
+		header.Set("Access-Control-Allow-Origin", "*")
+		return true
+	}
+	header.Set("Access-Control-Allow-Origin", origin)
+	if p.credentials {
+		header.Set("Access-Control-Allow-Credentials", "true")
+	}
+	return true
+}
+
+// preflight answers a preflight request for a route taking methods,
+// leaving the allow headers out if the policy does not allow the origin,
+// method or headers asked for, which the browser then refuses.
+func (p *corsPolicy) preflight(header http.Header, r *http.Request, methods []string) {
+	header.Add("Vary", "Access-Control-Request-Method")
+	header.Add("Vary", "Access-Control-Request-Headers")
+	if !slices.Contains(methods, r.Header.Get("Access-Control-Request-Method")) {
+		return
+	}
+	requested := splitList(r.Header.Get("Access-Control-Request-Headers"))
+	for _, name := range requested {
+		if !slices.ContainsFunc(p.allowedHeaders, func(allowed string) bool { return strings.EqualFold(name, allowed) }) && !p.origins.any {
+			return
+		}
+	}
+	if !p.allowOrigin(header, r.Header.Get("Origin")) {
+		return
+	}
+	header.Set("Access-Control-Allow-Methods", strings.Join(methods, ", "))
+	if len(requested) > 0 {
+		header.Set("Access-Control-Allow-Headers", strings.Join(requested, ", "))
+	}
+	if p.maxAge > 0 {
+		header.Set("Access-Control-Max-Age", strconv.Itoa(int(p.maxAge.Seconds())))
+	}
+}
+
+// corsMiddleware answers OPTIONS requests, preflights included, with the
+// methods of the route in router, and lets scripts of the origins its
+// policy allows read the responses of other requests.
+func corsMiddleware(router *mux.Router) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			policy := corsPolicyFor(r.URL.Path)
+			header := w.Header()
+			if policy != nil && !policy.origins.any {
+				header.Add("Vary", "Origin")
+			}
+			origin := r.Header.Get("Origin")
+			if r.Method != http.MethodOptions {
+				if origin != "" && policy != nil && policy.allowOrigin(header, origin) && len(policy.exposedHeaders) > 0 {
+					header.Set("Access-Control-Expose-Headers", strings.Join(policy.exposedHeaders, ", "))
+				}
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			methods := routeMethods(router, r)
+			if len(methods) == 0 {
+				// unknown routes are not found
+				next.ServeHTTP(w, r)
+				return
+			}
+			header.Set("Allow", strings.Join(append(methods, http.MethodOptions), ", "))
+			if origin != "" && r.Header.Get("Access-Control-Request-Method") != "" && policy != nil {
+				policy.preflight(header, r, methods)
+			}
+			w.WriteHeader(http.StatusNoContent)
+		})
+	}
+}
+
+// routeMethods are the methods router serves the URL of r with.
+func routeMethods(router *mux.Router, r *http.Request) []string {
+	var methods []string
+	for _, method := range []string{http.MethodGet, http.MethodHead, http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodDelete} {
+		probe := r.WithContext(r.Context())
+		probe.Method = method
+		var match mux.RouteMatch
+		if router.Match(probe, &match) && match.MatchErr == nil {
+			methods = append(methods, method)
+		}
+	}
+	return methods
+}
+
+// splitList splits a comma separated list, dropping blanks.
+func splitList(s string) []string {
+	var items []string
+	for _, item := range strings.Split(s, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
@@ -0,0 +1,105 @@
+package app
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseOrigins(t *testing.T) {
+	asserts := assert.New(t)
+	origins, err := parseOrigins(`http://localhost:*, https://*.example.com,^https://app[0-9]+\.example\.org`)
+	asserts.NoError(err)
+	for origin, allowed := range map[string]bool{
+		"http://localhost:4100":          true,
+		"http://localhost":               false,
+		"https://www.example.com":        true,
+		"https://WWW.Example.com":        true,
+		"https://example.com":            false,
+		"https://a.b.example.com":        false,
+		"https://evil.com/.example.com":  false,
+		"https://www.example.com.evil":   false,
+		"https://app12.example.org":      true,
+		"https://app12.example.org.evil": false,
+		"http://app12.example.org":       false,
+	} {
+		asserts.Equal(allowed, origins.match(origin), origin)
+	}
+
+	origins, err = parseOrigins("*")
+	asserts.NoError(err)
+	asserts.True(origins.match("https://anywhere.net"))
+
+	_, err = parseOrigins("^https://(")
+	asserts.Error(err)
+	origins, _ = parseOrigins(" , ")
+	asserts.True(origins.empty())
+}
+
+func TestCORS(t *testing.T) {
+	asserts := assert.New(t)
+	useTempDB(t)
+	previous := apiCORS
+	origins, _ := parseOrigins("https://*.example.com")
+	apiCORS = &corsPolicy{origins: origins, allowedHeaders: corsAllowedHeaders, exposedHeaders: corsExposedHeaders, maxAge: defaultCORSMaxAge, credentials: true}
+	t.Cleanup(func() { apiCORS = previous })
+	handler := MakeWebHandler(false)
+	serve := func(method, path string, header http.Header) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, nil)
+		for name, values := range header {
+			req.Header[name] = values
+		}
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+		return w
+	}
+	preflight := func(path, origin, method, headers string) *httptest.ResponseRecorder {
+		return serve("OPTIONS", path, http.Header{
+			"Origin":                         {origin},
+			"Access-Control-Request-Method":  {method},
+			"Access-Control-Request-Headers": {headers},
+		})
+	}
+
+	w := preflight("/api/articles/some-slug", "https://app.example.com", "PUT", "content-type,authorization,if-match")
+	asserts.Equal(http.StatusNoContent, w.Code)
+	asserts.Equal("https://app.example.com", w.Header().Get("Access-Control-Allow-Origin"))
+	asserts.Equal("true", w.Header().Get("Access-Control-Allow-Credentials"))
+	asserts.Equal("GET, PUT, DELETE", w.Header().Get("Access-Control-Allow-Methods"), "the methods of the route")
+	asserts.Equal("content-type, authorization, if-match", w.Header().Get("Access-Control-Allow-Headers"))
+	asserts.Equal("600", w.Header().Get("Access-Control-Max-Age"))
+	asserts.Equal([]string{"Origin", "Access-Control-Request-Method", "Access-Control-Request-Headers"}, w.Header().Values("Vary"))
+	asserts.Empty(w.Body.String())
+
+	for name, w := range map[string]*httptest.ResponseRecorder{
+		"origin":  preflight("/api/articles", "https://example.net", "POST", "content-type"),
+		"method":  preflight("/api/tags", "https://app.example.com", "DELETE", ""),
+		"headers": preflight("/api/articles", "https://app.example.com", "POST", "x-evil"),
+		"route":   preflight("/metrics", "https://app.example.com", "GET", ""),
+	} {
+		asserts.Equal(http.StatusNoContent, w.Code, name)
+		asserts.Empty(w.Header().Get("Access-Control-Allow-Origin"), name)
+		asserts.Empty(w.Header().Get("Access-Control-Allow-Methods"), name)
+	}
+
+	w = preflight("/feeds/articles.atom", "https://example.net", "GET", "authorization")
+	asserts.Equal("*", w.Header().Get("Access-Control-Allow-Origin"), "public routes may be read by any site")
+	asserts.Empty(w.Header().Get("Access-Control-Allow-Credentials"))
+	asserts.Equal("GET, HEAD", w.Header().Get("Access-Control-Allow-Methods"))
+
+	w = serve("OPTIONS", "/api/tags", nil)
+	asserts.Equal(http.StatusNoContent, w.Code)
+	asserts.Equal("GET, OPTIONS", w.Header().Get("Allow"))
+	asserts.Empty(w.Header().Get("Access-Control-Allow-Origin"))
+	asserts.Equal(http.StatusNotFound, serve("OPTIONS", "/api/missing", nil).Code)
+
+	w = serve("GET", "/api/tags", http.Header{"Origin": {"https://app.example.com"}})
+	asserts.Equal(http.StatusOK, w.Code)
+	asserts.Equal("https://app.example.com", w.Header().Get("Access-Control-Allow-Origin"))
+	asserts.Contains(w.Header().Get("Access-Control-Expose-Headers"), "ETag")
+	w = serve("GET", "/api/tags", http.Header{"Origin": {"https://example.net"}})
+	asserts.Equal(http.StatusOK, w.Code, "browsers keep the response from scripts")
+	asserts.Empty(w.Header().Get("Access-Control-Allow-Origin"))
+}
@@ -0,0 +1,116 @@
+package app
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"mime"
+	"net/http"
+	"os"
+	"reflect"
+	"strings"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/hy00nc/conduit-go/internal/service"
+)
+
+// Size limits of request bodies, by route. Articles take markdown bodies,
+// as do the mutations of GraphQL; the rest are small.
+const (
+	maxBodySize        = 64 << 10
+	maxArticleBodySize = 1 << 20
+	maxGraphQLBodySize = 1 << 20
+)
+
+// Kinds of errors of request bodies that could not be read, reported
+// like domain errors.
+var (
+	errTooLarge           = errors.New("is too large")
+	errUnsupportedContent = errors.New("is not supported")
+)
+
+// strictJSON refuses request bodies with fields the route does not know,
+// as CONDUIT_STRICT_JSON=true asks; they are ignored otherwise, as some
+// clients send whole objects back.
+var strictJSON = os.Getenv("CONDUIT_STRICT_JSON") == "true"
+
+// validate checks request bodies against the validate tags of their
+// struct, naming fields by their json tags in errors.
+var validate = newValidator()
+
+func newValidator() *validator.Validate {
+	v := validator.New()
+	v.RegisterTagNameFunc(func(field reflect.StructField) string {
+		name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if name == "-" {
+			return ""
+		}
+		return name
+	})
+	return v
+}
+
+// decodeRequest decodes the JSON body of r, of up to limit bytes, into v
+// and validates it. Errors are domain errors about the field at fault, or
+// the request body as a whole, for writeServiceError.
+func decodeRequest(w http.ResponseWriter, r *http.Request, v interface{}, limit int64) error {
+	if err := decodeJSON(w, r, v, limit); err != nil {
+		return err
+	}
+	if err := validateInput(v); err != nil {
+		logInvalid(r, err)
+		return err
+	}
+	return nil
+}
+
+// validateInput checks v against its validate tags, whichever API it came
+// through, reporting the first field at fault as invalid.
+func validateInput(v interface{}) error {
+	err := validate.Struct(v)
+	var fields validator.ValidationErrors
+	if errors.As(err, &fields) && len(fields) > 0 {
+		return service.Invalid(fields[0].Field())
+	}
+	return err
+}
+
+// decodeJSON decodes the JSON body of r, of up to limit bytes, into v.
+// Bodies without a Content-Type are taken for JSON.
+func decodeJSON(w http.ResponseWriter, r *http.Request, v interface{}, limit int64) error {
+	if contentType := r.Header.Get("Content-Type"); contentType != "" {
+		if mediaType, _, err := mime.ParseMediaType(contentType); err != nil || mediaType != "application/json" {
+			logInvalid(r, errors.New("content type "+contentType))
+			return &service.Error{Kind: errUnsupportedContent, Field: "content type"}
+		}
+	}
+	r.Body = http.MaxBytesReader(w, r.Body, limit)
+	decoder := json.NewDecoder(r.Body)
+	if strictJSON {
+		decoder.DisallowUnknownFields()
+	}
+	err := decoder.Decode(v)
+	if err == nil && decoder.Decode(&struct{}{}) != io.EOF {
+		err = errors.New("data after the JSON value")
+	}
+	if err != nil {
+		logInvalid(r, err)
+		return decodingError(err)
+	}
+	return nil
+}
+
+// decodingError is the domain error of a body that failed to decode.
+func decodingError(err error) error {
+	var tooLarge *http.MaxBytesError
+	var typeError *json.UnmarshalTypeError
+	switch {
+	case errors.As(err, &tooLarge):
+		return &service.Error{Kind: errTooLarge, Field: "request body"}
+	case errors.As(err, &typeError) && typeError.Field != "":
+		return service.Invalid(typeError.Field[strings.LastIndex(typeError.Field, ".")+1:])
+	case strings.HasPrefix(err.Error(), "json: unknown field "):
+		return service.Invalid(strings.Trim(strings.TrimPrefix(err.Error(), "json: unknown field "), `"`))
+	}
+	return service.Invalid("request body")
+}
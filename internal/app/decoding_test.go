@@ -0,0 +1,70 @@
+package app
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/hy00nc/conduit-go/internal/service"
+	"github.com/hy00nc/conduit-go/internal/utils"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDecodeRequest(t *testing.T) {
+	asserts := assert.New(t)
+	useTempDB(t)
+	user, err := userService.Register(t.Context(), service.RegisterInput{Username: "sally", Email: "sally@example.com", Password: "secret"})
+	asserts.NoError(err)
+	token, err := utils.GetToken(user.ID)
+	asserts.NoError(err)
+	handler := MakeWebHandler(false)
+	post := func(path, contentType, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("POST", path, strings.NewReader(body))
+		req.Header.Set("Authorization", "Token "+token)
+		if contentType != "" {
+			req.Header.Set("Content-Type", contentType)
+		}
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+		return w
+	}
+	article := func(title, extra string) string {
+		return `{"article":{"title":"` + title + `","description":"d","body":"b"` + extra + `}}`
+	}
+
+	for name, test := range map[string]struct {
+		contentType, body string
+		status            int
+		field             string
+	}{
+		"wrong content type": {"text/plain", article("Title", ""), http.StatusUnsupportedMediaType, "content type"},
+		"too large":          {"application/json", article(strings.Repeat("x", maxArticleBodySize), ""), http.StatusRequestEntityTooLarge, "request body"},
+		"malformed":          {"application/json", `{"article":`, http.StatusBadRequest, "request body"},
+		"trailing data":      {"application/json", article("Title", "") + `{}`, http.StatusBadRequest, "request body"},
+		"wrong type":         {"application/json", `{"article":{"title":1}}`, http.StatusBadRequest, "title"},
+		"missing field":      {"application/json", `{"article":{"title":"Title","body":"b"}}`, http.StatusBadRequest, "description"},
+		"title too long":     {"application/json", article(strings.Repeat("x", 201), ""), http.StatusBadRequest, "title"},
+		"too many tags":      {"", article("Title", `,"tagList":["a","b","c","d","e","f","g","h","i","j","k"]`), http.StatusBadRequest, "tagList"},
+	} {
+		w := post("/api/articles", test.contentType, test.body)
+		asserts.Equal(test.status, w.Code, name)
+		asserts.JSONEq(`{"errors":{"`+test.field+`":"is invalid"}}`, w.Body.String(), name)
+	}
+
+	w := post("/api/articles", "application/json; charset=utf-8", article("Title", `,"unknown":true`))
+	asserts.Equal(http.StatusOK, w.Code, "unknown fields are ignored")
+	strictJSON = true
+	t.Cleanup(func() { strictJSON = false })
+	w = post("/api/articles", "", article("Other", `,"unknown":true`))
+	asserts.Equal(http.StatusBadRequest, w.Code)
+	asserts.JSONEq(`{"errors":{"unknown":"is invalid"}}`, w.Body.String(), "unless strict")
+
+	w = post("/api/users", "", `{"user":{"username":"`+strings.Repeat("x", 41)+`","email":"x@example.com","password":"secret"}}`)
+	asserts.Equal(http.StatusBadRequest, w.Code)
+	asserts.JSONEq(`{"errors":{"username":"is invalid"}}`, w.Body.String())
+
+	w = post("/graphql", "", `{"query":"{tags}","variables":{"padding":"`+strings.Repeat("x", maxGraphQLBodySize)+`"}}`)
+	asserts.Equal(http.StatusRequestEntityTooLarge, w.Code)
+	asserts.Contains(w.Body.String(), "request body is too large")
+}
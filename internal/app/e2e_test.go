@@ -46,6 +46,15 @@ var tests = []struct {
 		http.StatusCreated,
 		fmt.Sprintf(`{"user":{"email":"harry@something","token":"([a-zA-Z0-9-_.]+)","username":"harry","bio":"","image":"%s"}}`, defaultImage),
 	},
+	{
+		"Register user (username taken)",
+		"/api/users",
+		func(req *http.Request) {},
+		"POST",
+		`{"user":{"username":"harry","email":"harry2@something","password":"strongpassword"}}`,
+		http.StatusConflict,
+		`{"errors":{"username":"is invalid"}}`,
+	},
     /* User login tests */
 	{
 		"User login (normal)",
@@ -99,6 +108,166 @@ var tests = []struct {
 		http.StatusUnauthorized,
 		``,
 	},
+	/* Trash tests */
+	{
+		"Trash (empty)",
+		"/api/user/trash",
+		func(req *http.Request) {
+			token, _ := utils.GetToken(1)
+			req.Header.Set("Authorization", fmt.Sprintf("Token %v", token))
+		},
+		"GET",
+		``,
+		http.StatusOK,
+		`{"trash":{"articles":\[\],"comments":\[\]}}`,
+	},
+	{
+		"Restore article (not in trash)",
+		"/api/articles/missing/restore",
+		func(req *http.Request) {
+			token, _ := utils.GetToken(1)
+			req.Header.Set("Authorization", fmt.Sprintf("Token %v", token))
+		},
+		"POST",
+		``,
+		http.StatusNotFound,
+		`{"errors":{"article":"is invalid"}}`,
+	},
+	/* Account export and deletion tests */
+	{
+		"Export user (JSON)",
+		"/api/user/export?format=json",
+		func(req *http.Request) {
+			token, _ := utils.GetToken(1)
+			req.Header.Set("Authorization", fmt.Sprintf("Token %v", token))
+		},
+		"GET",
+		``,
+		http.StatusOK,
+		`{"export":{"account":{"email":"sally@something","username":"sally","bio":"","image":"[^"]*","createdAt":"[^"]+"},"articles":\[\],"comments":\[\],"following":\[\],"favorites":\[\]}}`,
+	},
+	{
+		"Export user (ZIP)",
+		"/api/user/export",
+		func(req *http.Request) {
+			token, _ := utils.GetToken(1)
+			req.Header.Set("Authorization", fmt.Sprintf("Token %v", token))
+		},
+		"GET",
+		``,
+		http.StatusOK,
+		`^PK`,
+	},
+	{
+		"Register user",
+		"/api/users",
+		func(req *http.Request) {},
+		"POST",
+		`{"user":{"username":"tom","email":"tom@something","password":"strongpassword"}}`,
+		http.StatusCreated,
+		`"username":"tom"`,
+	},
+	{
+		"Delete user (wrong password)",
+		"/api/user",
+		func(req *http.Request) {
+			token, _ := utils.GetToken(3)
+			req.Header.Set("Authorization", fmt.Sprintf("Token %v", token))
+		},
+		"DELETE",
+		`{"user":{"password":"wrong"}}`,
+		http.StatusForbidden,
+		`{"errors":{"password":"is invalid"}}`,
+	},
+	{
+		"Delete user",
+		"/api/user",
+		func(req *http.Request) {
+			token, _ := utils.GetToken(3)
+			req.Header.Set("Authorization", fmt.Sprintf("Token %v", token))
+		},
+		"DELETE",
+		`{"user":{"password":"strongpassword"}}`,
+		http.StatusNoContent,
+		`^$`,
+	},
+	{
+		"Get user (deleted)",
+		"/api/user",
+		func(req *http.Request) {
+			token, _ := utils.GetToken(3)
+			req.Header.Set("Authorization", fmt.Sprintf("Token %v", token))
+		},
+		"GET",
+		``,
+		http.StatusUnauthorized,
+		`{"errors":{"User data":"is invalid"}}`,
+	},
+	/* Webhook tests */
+	{
+		"Create webhook on a private address",
+		"/api/webhooks",
+		func(req *http.Request) {
+			token, _ := utils.GetToken(1)
+			req.Header.Set("Authorization", fmt.Sprintf("Token %v", token))
+		},
+		"POST",
+		`{"webhook":{"url":"http://169.254.169.254/latest/meta-data/","events":["article.created"]}}`,
+		http.StatusBadRequest,
+		`{"errors":{"url":"is invalid"}}`,
+	},
+	{
+		"Create site-wide webhook (not an admin)",
+		"/api/webhooks",
+		func(req *http.Request) {
+			token, _ := utils.GetToken(1)
+			req.Header.Set("Authorization", fmt.Sprintf("Token %v", token))
+		},
+		"POST",
+		`{"webhook":{"url":"https://example.com/hook","events":["user.followed"],"siteWide":true}}`,
+		http.StatusForbidden,
+		`{"errors":{"siteWide":"is invalid"}}`,
+	},
+	/* GraphQL tests */
+	{
+		"GraphQL current user",
+		"/graphql",
+		func(req *http.Request) {
+			token, _ := utils.GetToken(1)
+			req.Header.Set("Authorization", fmt.Sprintf("Token %v", token))
+		},
+		"POST",
+		`{"query":"{currentUser{username} tags}"}`,
+		http.StatusOK,
+		`{"data":{"currentUser":{"username":"sally"},"tags":\[\]}}`,
+	},
+	{
+		"GraphQL mutation without authentication",
+		"/graphql",
+		func(req *http.Request) {},
+		"POST",
+		`{"query":"mutation{createArticle(article:{title:\"t\",description:\"d\",body:\"b\"}){slug}}"}`,
+		http.StatusOK,
+		`"message":"authentication required"`,
+	},
+	{
+		"GraphQL mutation over GET",
+		"/graphql?query=mutation%7BdeleteComment(id:%221%22)%7D",
+		func(req *http.Request) {},
+		"GET",
+		``,
+		http.StatusMethodNotAllowed,
+		`mutations must use POST`,
+	},
+	{
+		"GraphQL query over the complexity limit",
+		"/graphql",
+		func(req *http.Request) {},
+		"POST",
+		`{"query":"{articles(limit:100){articles{comments{author{username}}}}}"}`,
+		http.StatusBadRequest,
+		`query complexity \d+ exceeds the limit`,
+	},
 }
 
 // e2e test using table-driven tests (https://github.com/golang/go/wiki/TableDrivenTests)
@@ -108,6 +277,7 @@ func TestRoutesAndHandlers(t *testing.T) {
 	test_db = database.InitTestDB()
 	database.MigrateDB(test_db)
 	defer database.RemoveDB(test_db)
+	clearCache()
 
 	// Setup Router
 	r := MakeWebHandler(false)
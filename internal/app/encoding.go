@@ -0,0 +1,126 @@
+package app
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/fxamacker/cbor/v2"
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+// format is an encoding of API responses a client may ask for with Accept.
+type format struct {
+	contentType string
+	// mediaTypes are those of Accept selecting the format.
+	mediaTypes []string
+	encode     func(data interface{}) ([]byte, error)
+}
+
+// formats are the encodings of API responses, JSON, the default, first.
+// MessagePack and CBOR encode the same fields, named by their json tags.
+var formats = []format{
+	{"application/json; charset=utf-8", []string{"application/json"}, encodeJSON},
+	{"application/msgpack", []string{"application/msgpack", "application/x-msgpack", "application/vnd.msgpack"}, encodeMsgpack},
+	{"application/cbor", []string{"application/cbor"}, cbor.Marshal},
+}
+
+func encodeJSON(data interface{}) ([]byte, error) {
+	var buf bytes.Buffer
+	err := json.NewEncoder(&buf).Encode(data)
+	return buf.Bytes(), err
+}
+
+func encodeMsgpack(data interface{}) ([]byte, error) {
+	var buf bytes.Buffer
+	encoder := msgpack.NewEncoder(&buf)
+	encoder.SetCustomStructTag("json")
+	err := encoder.Encode(data)
+	return buf.Bytes(), err
+}
+
+// responseFormat is the format r accepts best, JSON if it accepts none.
+func responseFormat(r *http.Request) format {
+	var offers []string
+	for _, f := range formats {
+		offers = append(offers, f.mediaTypes...)
+	}
+	best := preferred(r.Header.Get("Accept"), offers)
+	for _, f := range formats {
+		for _, mediaType := range f.mediaTypes {
+			if mediaType == best {
+				return f
+			}
+		}
+	}
+	return formats[0]
+}
+
+// preferred returns the offer header, an Accept or Accept-Encoding value,
+// gives the highest quality, earlier offers winning ties; offers are
+// matched by the most specific of their ranges, such as "text/*" or "*".
+// It returns "" if header accepts none of offers, or is empty.
+func preferred(header string, offers []string) string {
+	if strings.TrimSpace(header) == "" {
+		return ""
+	}
+	type accepted struct {
+		value string
+		q     float64
+	}
+	var ranges []accepted
+	for _, item := range strings.Split(header, ",") {
+		value, params, _ := strings.Cut(item, ";")
+		a := accepted{value: strings.ToLower(strings.TrimSpace(value)), q: 1}
+		for _, param := range strings.Split(params, ";") {
+			name, q, _ := strings.Cut(strings.TrimSpace(param), "=")
+			if name == "q" {
+				if parsed, err := strconv.ParseFloat(q, 64); err == nil {
+					a.q = parsed
+				}
+			}
+		}
+		ranges = append(ranges, a)
+	}
+
+	best, bestQ := "", 0.0
+	for _, offer := range offers {
+		q, specificity := 0.0, -1
+		for _, a := range ranges {
+			// 2 for the offer itself, 1 for "type/*", 0 for "*/*" or "*"
+			s := -1
+			switch {
+			case a.value == offer:
+				s = 2
+			case strings.HasSuffix(a.value, "/*") && strings.HasPrefix(offer, strings.TrimSuffix(a.value, "*")):
+				s = 1
+			case a.value == "*/*" || a.value == "*":
+				s = 0
+			}
+			if s > specificity {
+				q, specificity = a.q, s
+			}
+		}
+		if q > bestQ {
+			best, bestQ = offer, q
+		}
+	}
+	return best
+}
+
+// writeResponse writes data in the format r accepts best.
+func writeResponse(w http.ResponseWriter, r *http.Request, data map[string]interface{}, statusCode int) {
+	format := responseFormat(r)
+	body, err := format.encode(data)
+	if err != nil {
+		logError(r, err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", format.contentType)
+	w.Header().Add("Vary", "Accept")
+	w.WriteHeader(statusCode)
+	w.Write(body)
+}
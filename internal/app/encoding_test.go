@@ -0,0 +1,69 @@
+package app
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/fxamacker/cbor/v2"
+	"github.com/hy00nc/conduit-go/internal/service"
+	"github.com/stretchr/testify/assert"
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+func TestPreferred(t *testing.T) {
+	offers := []string{"application/json", "application/msgpack", "application/cbor"}
+	for header, want := range map[string]string{
+		"":                                     "",
+		"application/cbor":                     "application/cbor",
+		"application/*":                        "application/json",
+		"*/*":                                  "application/json",
+		"text/html, application/msgpack;q=0.9": "application/msgpack",
+		"application/json;q=0.5, application/cbor":  "application/cbor",
+		"application/*;q=0.5, application/json;q=0": "application/msgpack",
+		"text/html": "",
+	} {
+		assert.Equal(t, want, preferred(header, offers), header)
+	}
+}
+
+func TestResponseFormats(t *testing.T) {
+	asserts := assert.New(t)
+	useTempDB(t)
+	user, err := userService.Register(t.Context(), service.RegisterInput{Username: "sally", Email: "sally@example.com", Password: "secret"})
+	asserts.NoError(err)
+	_, err = articleService.Create(t.Context(), user, service.ArticleInput{Title: "First", Description: "d", Body: "b", TagList: []string{"go"}})
+	asserts.NoError(err)
+	handler := MakeWebHandler(false)
+	get := func(path, accept string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", path, nil)
+		req.Header.Set("Accept", accept)
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+		return w
+	}
+
+	w := get("/api/profiles/sally", "application/msgpack")
+	asserts.Equal("application/msgpack", w.Header().Get("Content-Type"))
+	var profile map[string]map[string]interface{}
+	asserts.NoError(msgpack.Unmarshal(w.Body.Bytes(), &profile))
+	asserts.Equal("sally", profile["profile"]["username"], "fields are named as in JSON")
+	asserts.Equal(false, profile["profile"]["following"])
+
+	w = get("/api/tags", "application/cbor")
+	asserts.Equal("application/cbor", w.Header().Get("Content-Type"))
+	var tags map[string][]string
+	asserts.NoError(cbor.Unmarshal(w.Body.Bytes(), &tags))
+	asserts.Equal([]string{"go"}, tags["tags"])
+	cborTag := w.Header().Get("ETag")
+	asserts.NotEqual(cborTag, get("/api/tags", "application/json").Header().Get("ETag"), "formats have their own ETags")
+
+	w = get("/api/articles/missing", "application/cbor")
+	asserts.Equal(http.StatusNotFound, w.Code)
+	var errs map[string]map[string]string
+	asserts.NoError(cbor.Unmarshal(w.Body.Bytes(), &errs), "errors are in the format asked for too")
+
+	w = get("/api/tags", "text/html")
+	asserts.Equal("application/json; charset=utf-8", w.Header().Get("Content-Type"), "JSON is the default")
+	asserts.JSONEq(`{"tags":["go"]}`, w.Body.String())
+}
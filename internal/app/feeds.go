@@ -0,0 +1,347 @@
+package app
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/hy00nc/conduit-go/internal/database"
+	"github.com/hy00nc/conduit-go/internal/models"
+	"github.com/hy00nc/conduit-go/internal/service"
+	"github.com/hy00nc/conduit-go/internal/utils"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Public URL of the front-end; feed entries link to its article pages.
+var siteURL = "http://localhost:4100"
+
+type atomLink struct {
+	Href string `xml:"href,attr"`
+	Rel  string `xml:"rel,attr,omitempty"`
+	Type string `xml:"type,attr,omitempty"`
+}
+
+type atomPerson struct {
+	Name string `xml:"name"`
+	URI  string `xml:"uri,omitempty"`
+}
+
+type atomText struct {
+	Type string `xml:"type,attr"`
+	Body string `xml:",chardata"`
+}
+
+type atomCategory struct {
+	Term string `xml:"term,attr"`
+}
+
+type atomEntry struct {
+	Title      string         `xml:"title"`
+	ID         string         `xml:"id"`
+	Updated    string         `xml:"updated"`
+	Published  string         `xml:"published"`
+	Author     atomPerson     `xml:"author"`
+	Link       atomLink       `xml:"link"`
+	Categories []atomCategory `xml:"category"`
+	Summary    atomText       `xml:"summary"`
+	Content    atomText       `xml:"content"`
+}
+
+type atomFeed struct {
+	XMLName xml.Name    `xml:"http://www.w3.org/2005/Atom feed"`
+	Title   string      `xml:"title"`
+	ID      string      `xml:"id"`
+	Updated string      `xml:"updated"`
+	Links   []atomLink  `xml:"link"`
+	Entries []atomEntry `xml:"entry"`
+}
+
+type rssGUID struct {
+	IsPermaLink bool   `xml:"isPermaLink,attr"`
+	Value       string `xml:",chardata"`
+}
+
+type rssItem struct {
+	Title       string   `xml:"title"`
+	Link        string   `xml:"link"`
+	GUID        rssGUID  `xml:"guid"`
+	PubDate     string   `xml:"pubDate"`
+	Creator     string   `xml:"dc:creator"`
+	Categories  []string `xml:"category"`
+	Description string   `xml:"description"`
+}
+
+type rssChannel struct {
+	Title         string    `xml:"title"`
+	Link          string    `xml:"link"`
+	Description   string    `xml:"description"`
+	LastBuildDate string    `xml:"lastBuildDate"`
+	Items         []rssItem `xml:"item"`
+}
+
+type rssFeed struct {
+	XMLName xml.Name   `xml:"rss"`
+	Version string     `xml:"version,attr"`
+	DC      string     `xml:"xmlns:dc,attr"`
+	Channel rssChannel `xml:"channel"`
+}
+
+// feedUpdated is the most recent UpdatedAt of the articles, or the latest
+// deletion when that is newer, since dropping an entry changes the feed too.
+// It is used for the Last-Modified header; zero when there is neither.
+func feedUpdated(articles []models.Article, deleted time.Time) time.Time {
+	updated := deleted
+	for _, article := range articles {
+		if article.UpdatedAt.After(updated) {
+			updated = article.UpdatedAt
+		}
+	}
+	if updated.IsZero() {
+		return updated
+	}
+	return updated.UTC().Truncate(time.Second)
+}
+
+// lastDeletion is when an article was last deleted, zero if none was.
+func lastDeletion(r *http.Request) (time.Time, error) {
+	var article models.Article
+	err := database.GetDB().WithContext(r.Context()).Unscoped().Select("deleted_at").
+		Where("deleted_at IS NOT NULL").Order("deleted_at desc").Limit(1).Find(&article).Error
+	return article.DeletedAt.Time, err
+}
+
+func articleURL(article models.Article) string {
+	return fmt.Sprintf("%s/article/%s", siteURL, article.Slug)
+}
+
+func requestURL(r *http.Request) string {
+	return fmt.Sprintf("%s://%s%s", requestScheme(r), r.Host, r.URL.RequestURI())
+}
+
+func tagNames(article models.Article) []string {
+	tags := make([]string, len(article.Tags))
+	for i, tag := range article.Tags {
+		tags[i] = tag.Name
+	}
+	return tags
+}
+
+func renderAtom(r *http.Request, title string, articles []models.Article, updated time.Time) ([]byte, error) {
+	self := requestURL(r)
+	feed := atomFeed{
+		Title:   title,
+		ID:      self,
+		Updated: updated.Format(time.RFC3339),
+		Links: []atomLink{
+			{Href: self, Rel: "self", Type: "application/atom+xml"},
+			{Href: siteURL, Rel: "alternate", Type: "text/html"},
+		},
+	}
+	for _, article := range articles {
+		entry := atomEntry{
+			Title:     article.Title,
+			ID:        articleURL(article),
+			Updated:   article.UpdatedAt.UTC().Format(time.RFC3339),
+			Published: article.CreatedAt.UTC().Format(time.RFC3339),
+			Author:    atomPerson{Name: article.Author.Name, URI: fmt.Sprintf("%s/profile/%s", siteURL, article.Author.Name)},
+			Link:      atomLink{Href: articleURL(article), Rel: "alternate", Type: "text/html"},
+			Summary:   atomText{Type: "text", Body: article.Description},
+			Content:   atomText{Type: "text", Body: article.Body},
+		}
+		for _, tag := range tagNames(article) {
+			entry.Categories = append(entry.Categories, atomCategory{Term: tag})
+		}
+		feed.Entries = append(feed.Entries, entry)
+	}
+	return marshalFeed(feed)
+}
+
+func renderRSS(r *http.Request, title string, articles []models.Article, updated time.Time) ([]byte, error) {
+	feed := rssFeed{
+		Version: "2.0",
+		DC:      "http://purl.org/dc/elements/1.1/",
+		Channel: rssChannel{
+			Title:         title,
+			Link:          siteURL,
+			Description:   title,
+			LastBuildDate: updated.Format(time.RFC1123Z),
+		},
+	}
+	for _, article := range articles {
+		feed.Channel.Items = append(feed.Channel.Items, rssItem{
+			Title:       article.Title,
+			Link:        articleURL(article),
+			GUID:        rssGUID{IsPermaLink: false, Value: articleURL(article)},
+			PubDate:     article.CreatedAt.UTC().Format(time.RFC1123Z),
+			Creator:     article.Author.Name,
+			Categories:  tagNames(article),
+			Description: article.Description,
+		})
+	}
+	return marshalFeed(feed)
+}
+
+func marshalFeed(feed interface{}) ([]byte, error) {
+	var buf bytes.Buffer
+	buf.WriteString(xml.Header)
+	encoder := xml.NewEncoder(&buf)
+	encoder.Indent("", "  ")
+	if err := encoder.Encode(feed); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func writeFeed(w http.ResponseWriter, r *http.Request, body []byte, contentType string, lastModified time.Time, cacheControl string) {
+	writeCacheable(w, r, body, contentType, contentETag("", body), lastModified, cacheControl)
+}
+
+func serveArticlesFeed(w http.ResponseWriter, r *http.Request, format, title string, articles []models.Article, cacheControl string) {
+	deleted, err := lastDeletion(r)
+	if err != nil {
+		logError(r, err)
+		http.Error(w, "failed to render feed", http.StatusInternalServerError)
+		return
+	}
+	lastModified := feedUpdated(articles, deleted)
+	// an empty feed of a site without deletions is as new as the request;
+	// it is left to the ETag rather than claimed in Last-Modified
+	updated := lastModified
+	if updated.IsZero() {
+		updated = time.Now().UTC().Truncate(time.Second)
+	}
+	var body []byte
+	contentType := "application/atom+xml; charset=utf-8"
+	if format == "rss" {
+		contentType = "application/rss+xml; charset=utf-8"
+		body, err = renderRSS(r, title, articles, updated)
+	} else {
+		body, err = renderAtom(r, title, articles, updated)
+	}
+	if err != nil {
+		logError(r, err)
+		http.Error(w, "failed to render feed", http.StatusInternalServerError)
+		return
+	}
+	writeFeed(w, r, body, contentType, lastModified, cacheControl)
+}
+
+// GetArticlesFeed serves /feeds/articles.{atom,rss}, accepting the same
+// filters as GET /api/articles.
+func GetArticlesFeed(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	articles, _, err := articleService.List(r.Context(), service.ArticleFilter{
+		Tag:       query.Get("tag"),
+		Author:    query.Get("author"),
+		Favorited: query.Get("favorited"),
+		Limit:     queryInt(r, "limit"),
+		Offset:    queryInt(r, "offset"),
+	})
+	if err != nil {
+		logInvalid(r, err)
+		http.Error(w, "invalid parameter", http.StatusBadRequest)
+		return
+	}
+	serveArticlesFeed(w, r, mux.Vars(r)["format"], "Conduit articles", articles, "public, max-age=300")
+}
+
+func GetAuthorFeed(w http.ResponseWriter, r *http.Request) {
+	username := mux.Vars(r)["username"]
+	if _, err := profileService.Get(r.Context(), username); err != nil {
+		http.Error(w, "author not found", http.StatusNotFound)
+		return
+	}
+	articles, _, err := articleService.List(r.Context(), service.ArticleFilter{Author: username, Limit: queryInt(r, "limit"), Offset: queryInt(r, "offset")})
+	if err != nil {
+		logInvalid(r, err)
+		http.Error(w, "invalid parameter", http.StatusBadRequest)
+		return
+	}
+	serveArticlesFeed(w, r, "atom", fmt.Sprintf("Conduit articles by %s", username), articles, "public, max-age=300")
+}
+
+func GetTagFeed(w http.ResponseWriter, r *http.Request) {
+	tag := mux.Vars(r)["tag"]
+	articles, _, err := articleService.List(r.Context(), service.ArticleFilter{Tag: tag, Limit: queryInt(r, "limit"), Offset: queryInt(r, "offset")})
+	if err != nil {
+		logInvalid(r, err)
+		http.Error(w, "invalid parameter", http.StatusBadRequest)
+		return
+	}
+	serveArticlesFeed(w, r, "atom", fmt.Sprintf("Conduit articles tagged %s", tag), articles, "public, max-age=300")
+}
+
+// GetPrivateFeed mirrors GET /api/articles/feed for feed readers, which
+// cannot send the Authorization header; the token in the URL stands in for it.
+func GetPrivateFeed(w http.ResponseWriter, r *http.Request) {
+	db := database.GetDB()
+	var feedToken models.FeedToken
+	db.Preload("User").Preload("User.Profile").Where("token = ?", mux.Vars(r)["token"]).Find(&feedToken)
+	// the feeds of suspended users are gone, like their sessions
+	if feedToken.ID == 0 || feedToken.User.ID == 0 || feedToken.User.Suspended() {
+		http.Error(w, "feed not found", http.StatusNotFound)
+		return
+	}
+	articles, _, err := articleService.Feed(r.Context(), feedToken.User, queryInt(r, "limit"), queryInt(r, "offset"))
+	if err != nil {
+		logInvalid(r, err)
+		http.Error(w, "invalid parameter", http.StatusBadRequest)
+		return
+	}
+	serveArticlesFeed(w, r, "atom", fmt.Sprintf("Conduit feed of %s", feedToken.User.Profile.Name), articles, "private, max-age=300")
+}
+
+func feedTokenResponse(r *http.Request, feedToken models.FeedToken) map[string]interface{} {
+	return map[string]interface{}{
+		"token": feedToken.Token,
+		"url":   fmt.Sprintf("%s://%s/feeds/private/%s.atom", requestScheme(r), r.Host, feedToken.Token),
+	}
+}
+
+// CreateFeedToken issues a private feed token, replacing (and thereby
+// revoking) any previous one.
+func CreateFeedToken(w http.ResponseWriter, r *http.Request) {
+	userData := r.Context().Value(utils.ContextKeyUserData).(models.User)
+	secret := make([]byte, 24)
+	if _, err := rand.Read(secret); err != nil {
+		logError(r, err)
+		writeResponse(w, r, map[string]interface{}{"errors": utils.CreateInvalidResponse("Token")}, http.StatusInternalServerError)
+		return
+	}
+	feedToken := models.FeedToken{UserID: userData.ID, Token: hex.EncodeToString(secret)}
+	err := database.Transaction(r.Context(), database.GetDB(), func(tx *gorm.DB) error {
+		if err := tx.Unscoped().Where("user_id = ?", userData.ID).Delete(&models.FeedToken{}).Error; err != nil {
+			return err
+		}
+		return tx.Omit(clause.Associations).Create(&feedToken).Error
+	})
+	if err != nil {
+		logError(r, err)
+		writeResponse(w, r, map[string]interface{}{"errors": utils.CreateInvalidResponse("Token")}, http.StatusInternalServerError)
+		return
+	}
+	writeResponse(w, r, map[string]interface{}{"feedToken": feedTokenResponse(r, feedToken)}, http.StatusCreated)
+}
+
+func GetFeedToken(w http.ResponseWriter, r *http.Request) {
+	userData := r.Context().Value(utils.ContextKeyUserData).(models.User)
+	var feedToken models.FeedToken
+	database.GetDB().Where("user_id = ?", userData.ID).Find(&feedToken)
+	if feedToken.ID == 0 {
+		writeResponse(w, r, map[string]interface{}{"errors": utils.CreateInvalidResponse("Token")}, http.StatusNotFound)
+		return
+	}
+	writeResponse(w, r, map[string]interface{}{"feedToken": feedTokenResponse(r, feedToken)}, http.StatusOK)
+}
+
+func RevokeFeedToken(w http.ResponseWriter, r *http.Request) {
+	userData := r.Context().Value(utils.ContextKeyUserData).(models.User)
+	database.GetDB().Unscoped().Where("user_id = ?", userData.ID).Delete(&models.FeedToken{})
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,227 @@
+package app
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/hy00nc/conduit-go/internal/database"
+	"github.com/hy00nc/conduit-go/internal/models"
+	"github.com/hy00nc/conduit-go/internal/service"
+	"github.com/hy00nc/conduit-go/internal/utils"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// feedAuthors registers sally, who wrote a tagged article, and harry, who
+// follows her.
+func feedAuthors(t *testing.T) (article models.Article, harry models.User) {
+	useTempDB(t)
+	sally, err := userService.Register(t.Context(), service.RegisterInput{Username: "sally", Email: "sally@example.com", Password: "secret"})
+	require.NoError(t, err)
+	harry, err = userService.Register(t.Context(), service.RegisterInput{Username: "harry", Email: "harry@example.com", Password: "secret"})
+	require.NoError(t, err)
+	article, err = articleService.Create(t.Context(), sally, service.ArticleInput{Title: "Feeds", Description: "About feeds", Body: "Body", TagList: []string{"xml"}})
+	require.NoError(t, err)
+	_, err = profileService.Follow(t.Context(), harry, "sally")
+	require.NoError(t, err)
+	return article, harry
+}
+
+func getFeed(handler http.Handler, path string, header http.Header) *httptest.ResponseRecorder {
+	req := httptest.NewRequest("GET", path, nil)
+	for name, values := range header {
+		req.Header[name] = values
+	}
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+	return w
+}
+
+func TestFeeds(t *testing.T) {
+	asserts := assert.New(t)
+	article, _ := feedAuthors(t)
+	handler := MakeWebHandler(false)
+
+	w := getFeed(handler, "/feeds/articles.atom", nil)
+	asserts.Equal(http.StatusOK, w.Code)
+	asserts.Equal("application/atom+xml; charset=utf-8", w.Header().Get("Content-Type"))
+	var atom atomFeed
+	asserts.NoError(xml.Unmarshal(w.Body.Bytes(), &atom))
+	asserts.Equal("Conduit articles", atom.Title)
+	asserts.Equal("http://example.com/feeds/articles.atom", atom.Links[0].Href)
+	if asserts.Len(atom.Entries, 1) {
+		entry := atom.Entries[0]
+		asserts.Equal("Feeds", entry.Title)
+		asserts.Equal(siteURL+"/article/"+article.Slug, entry.ID)
+		asserts.Equal("sally", entry.Author.Name)
+		asserts.Equal([]atomCategory{{Term: "xml"}}, entry.Categories)
+		asserts.Equal("About feeds", entry.Summary.Body)
+		asserts.Equal("Body", entry.Content.Body)
+	}
+
+	w = getFeed(handler, "/feeds/articles.rss", nil)
+	asserts.Equal(http.StatusOK, w.Code)
+	asserts.Equal("application/rss+xml; charset=utf-8", w.Header().Get("Content-Type"))
+	var rss struct {
+		Version string `xml:"version,attr"`
+		Items   []struct {
+			Title   string `xml:"title"`
+			Link    string `xml:"link"`
+			Creator string `xml:"creator"`
+		} `xml:"channel>item"`
+	}
+	asserts.NoError(xml.Unmarshal(w.Body.Bytes(), &rss))
+	asserts.Equal("2.0", rss.Version)
+	if asserts.Len(rss.Items, 1) {
+		asserts.Equal("Feeds", rss.Items[0].Title)
+		asserts.Equal(siteURL+"/article/"+article.Slug, rss.Items[0].Link)
+		asserts.Equal("sally", rss.Items[0].Creator)
+	}
+
+	w = getFeed(handler, "/feeds/tags/xml.atom", nil)
+	atom = atomFeed{}
+	asserts.NoError(xml.Unmarshal(w.Body.Bytes(), &atom))
+	asserts.Len(atom.Entries, 1)
+	w = getFeed(handler, "/feeds/tags/json.atom", nil)
+	atom = atomFeed{}
+	asserts.NoError(xml.Unmarshal(w.Body.Bytes(), &atom))
+	asserts.Empty(atom.Entries)
+
+	w = getFeed(handler, "/feeds/authors/sally.atom", nil)
+	asserts.Equal(http.StatusOK, w.Code)
+	atom = atomFeed{}
+	asserts.NoError(xml.Unmarshal(w.Body.Bytes(), &atom))
+	asserts.Equal("Conduit articles by sally", atom.Title)
+	asserts.Len(atom.Entries, 1)
+	asserts.Equal(http.StatusNotFound, getFeed(handler, "/feeds/authors/nobody.atom", nil).Code)
+}
+
+func TestFeedsNotModified(t *testing.T) {
+	asserts := assert.New(t)
+	feedAuthors(t)
+	handler := MakeWebHandler(false)
+
+	w := getFeed(handler, "/feeds/articles.atom", nil)
+	etag, lastModified := w.Header().Get("ETag"), w.Header().Get("Last-Modified")
+	asserts.NotEmpty(etag)
+	asserts.NotEmpty(lastModified)
+
+	w = getFeed(handler, "/feeds/articles.atom", http.Header{"If-None-Match": {etag}})
+	asserts.Equal(http.StatusNotModified, w.Code)
+	asserts.Empty(w.Body.String())
+	w = getFeed(handler, "/feeds/articles.atom", http.Header{"If-Modified-Since": {lastModified}})
+	asserts.Equal(http.StatusNotModified, w.Code)
+	w = getFeed(handler, "/feeds/articles.atom", http.Header{"If-None-Match": {`"stale"`}})
+	asserts.Equal(http.StatusOK, w.Code)
+}
+
+func TestFeedsUpdatedAfterDeletion(t *testing.T) {
+	asserts := assert.New(t)
+	article, _ := feedAuthors(t)
+	handler := MakeWebHandler(false)
+	hourAgo := time.Now().Add(-time.Hour).UTC().Truncate(time.Second)
+	require.NoError(t, database.GetDB().Model(&article).UpdateColumn("updated_at", hourAgo).Error)
+
+	var atom atomFeed
+	w := getFeed(handler, "/feeds/tags/json.atom", nil)
+	asserts.NoError(xml.Unmarshal(w.Body.Bytes(), &atom))
+	updated, err := time.Parse(time.RFC3339, atom.Updated)
+	asserts.NoError(err)
+	asserts.WithinDuration(time.Now(), updated, time.Minute, "empty feeds are as new as the request")
+	asserts.Empty(w.Header().Get("Last-Modified"))
+
+	w = getFeed(handler, "/feeds/articles.atom", nil)
+	lastModified := w.Header().Get("Last-Modified")
+	asserts.Equal(hourAgo.Format(http.TimeFormat), lastModified)
+
+	require.NoError(t, database.GetDB().Delete(&article).Error)
+	w = getFeed(handler, "/feeds/articles.atom", http.Header{"If-Modified-Since": {lastModified}})
+	asserts.Equal(http.StatusOK, w.Code, "dropping an entry changes the feed")
+	modified, err := http.ParseTime(w.Header().Get("Last-Modified"))
+	asserts.NoError(err)
+	asserts.True(modified.After(hourAgo), "Last-Modified does not move backwards")
+	atom = atomFeed{}
+	asserts.NoError(xml.Unmarshal(w.Body.Bytes(), &atom))
+	asserts.Empty(atom.Entries)
+	asserts.Equal(modified.UTC().Format(time.RFC3339), atom.Updated, "empty feeds are as new as the latest deletion")
+}
+
+func TestPrivateFeed(t *testing.T) {
+	asserts := assert.New(t)
+	_, harry := feedAuthors(t)
+	handler := MakeWebHandler(false)
+	token, _ := utils.GetToken(harry.ID)
+	authorization := http.Header{"Authorization": {"Token " + token}}
+
+	req := httptest.NewRequest("POST", "/api/user/feed-token", nil)
+	req.Header.Set("Authorization", "Token "+token)
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+	asserts.Equal(http.StatusCreated, w.Code)
+	var created struct {
+		FeedToken struct {
+			Token string `json:"token"`
+			URL   string `json:"url"`
+		} `json:"feedToken"`
+	}
+	asserts.NoError(json.Unmarshal(w.Body.Bytes(), &created))
+	feedURL := "/feeds/private/" + created.FeedToken.Token + ".atom"
+	asserts.Equal("http://example.com"+feedURL, created.FeedToken.URL)
+
+	w = getFeed(handler, feedURL, nil)
+	asserts.Equal(http.StatusOK, w.Code)
+	asserts.Equal("private, max-age=300", w.Header().Get("Cache-Control"))
+	var atom atomFeed
+	asserts.NoError(xml.Unmarshal(w.Body.Bytes(), &atom))
+	asserts.Equal("Conduit feed of harry", atom.Title)
+	asserts.Len(atom.Entries, 1, "the feed has the articles of followed authors")
+
+	admin := service.NewAdminService(service.GormStore{DB: database.GetDB()})
+	_, err := admin.Suspend(t.Context(), "harry", true)
+	require.NoError(t, err)
+	asserts.Equal(http.StatusNotFound, getFeed(handler, feedURL, nil).Code, "suspended users have no feed")
+	_, err = admin.Suspend(t.Context(), "harry", false)
+	require.NoError(t, err)
+	asserts.Equal(http.StatusOK, getFeed(handler, feedURL, nil).Code)
+
+	req = httptest.NewRequest("DELETE", "/api/user/feed-token", nil)
+	req.Header = authorization
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+	asserts.Equal(http.StatusNoContent, w.Code)
+	asserts.Equal(http.StatusNotFound, getFeed(handler, feedURL, nil).Code, "revoked tokens are unknown")
+	asserts.Equal(http.StatusNotFound, getFeed(handler, "/api/user/feed-token", authorization).Code)
+}
+
+func TestFeedsForwardedProto(t *testing.T) {
+	asserts := assert.New(t)
+	feedAuthors(t)
+	handler := MakeWebHandler(false)
+	forwarded := http.Header{"X-Forwarded-Proto": {"https"}}
+
+	var atom atomFeed
+	w := getFeed(handler, "/feeds/articles.atom", forwarded)
+	asserts.NoError(xml.Unmarshal(w.Body.Bytes(), &atom))
+	asserts.Equal("http://example.com/feeds/articles.atom", atom.ID, "clients cannot claim HTTPS")
+
+	trustedProxies, _ = parseProxies("192.0.2.0/24")
+	t.Cleanup(func() { trustedProxies = nil })
+	w = getFeed(handler, "/feeds/articles.atom", forwarded)
+	atom = atomFeed{}
+	asserts.NoError(xml.Unmarshal(w.Body.Bytes(), &atom))
+	asserts.Equal("https://example.com/feeds/articles.atom", atom.ID, "trusted proxies can")
+}
+
+func TestParseProxies(t *testing.T) {
+	asserts := assert.New(t)
+	proxies, err := parseProxies("10.0.0.0/8, 192.168.1.10,::1")
+	asserts.NoError(err)
+	asserts.Equal("[10.0.0.0/8 192.168.1.10/32 ::1/128]", fmt.Sprint(proxies))
+	_, err = parseProxies("proxy.local")
+	asserts.Error(err)
+}
@@ -0,0 +1,798 @@
+package app
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/graphql-go/graphql"
+	"github.com/graphql-go/graphql/gqlerrors"
+	"github.com/graphql-go/graphql/language/ast"
+	"github.com/graphql-go/graphql/language/parser"
+	"github.com/hy00nc/conduit-go/internal/logging"
+	"github.com/hy00nc/conduit-go/internal/markdown"
+	"github.com/hy00nc/conduit-go/internal/models"
+	"github.com/hy00nc/conduit-go/internal/service"
+	"github.com/hy00nc/conduit-go/internal/utils"
+)
+
+// graphQLMaxLimit bounds the page size a client may ask for.
+const graphQLMaxLimit = 100
+
+var (
+	errAuthRequired = errors.New("authentication required")
+	errInvalidLimit = errors.New("limit must be between 0 and " + strconv.Itoa(graphQLMaxLimit))
+)
+
+// graphQLError turns a service error into the message shown to clients;
+// database failures are logged instead of exposed.
+func graphQLError(ctx context.Context, err error) error {
+	switch {
+	case service.Field(err) != "", errors.Is(err, errAuthRequired), errors.Is(err, errInvalidLimit):
+		return err
+	}
+	logging.FromContext(ctx).Error("graphql: resolve failed", "error", err)
+	return errors.New("internal error")
+}
+
+func viewerFromContext(ctx context.Context) (models.User, bool) {
+	userData, ok := ctx.Value(utils.ContextKeyUserData).(models.User)
+	return userData, ok
+}
+
+type graphQLRequestKey struct{}
+
+// limitMutation counts a mutation under the rate limit policy of its REST
+// route, per client as told by identify.
+func limitMutation(p graphql.ResolveParams, policy string, identify func(*http.Request) string) error {
+	r := p.Context.Value(graphQLRequestKey{}).(*http.Request)
+	return allowRate(p.Context, policy, identify(r))
+}
+
+func requireViewer(p graphql.ResolveParams) (models.User, error) {
+	userData, ok := viewerFromContext(p.Context)
+	if !ok {
+		return userData, errAuthRequired
+	}
+	return userData, nil
+}
+
+// bindInput decodes the arguments into one of the REST request structs,
+// whose JSON wrapper key matches the argument name ("article", "user", ...),
+// and validates it as the REST route would.
+func bindInput(args map[string]interface{}, input interface{}) error {
+	data, err := json.Marshal(args)
+	if err != nil {
+		return err
+	}
+	if err := json.Unmarshal(data, input); err != nil {
+		return err
+	}
+	return validateInput(input)
+}
+
+func pageArgs(p graphql.ResolveParams) (limit, offset int, err error) {
+	limit, offset = p.Args["limit"].(int), p.Args["offset"].(int)
+	if limit < 0 || limit > graphQLMaxLimit {
+		return 0, 0, errInvalidLimit
+	}
+	if offset < 0 {
+		offset = 0
+	}
+	return limit, offset, nil
+}
+
+// graphQLCommentID parses the id argument; malformed ids match no comment.
+func graphQLCommentID(p graphql.ResolveParams) (uint, error) {
+	id, err := strconv.ParseUint(p.Args["id"].(string), 10, 0)
+	if err != nil {
+		return 0, service.NotFound("comment")
+	}
+	return uint(id), nil
+}
+
+func formatTime(t time.Time) string {
+	return t.UTC().Format("2006-01-02T15:04:05.999Z")
+}
+
+// thunk adapts a dataloader result to the deferred resolver form graphql-go
+// resolves after the whole level has been queued.
+func thunk[V any](ctx context.Context, load func() (V, error)) func() (interface{}, error) {
+	return func() (interface{}, error) {
+		value, err := load()
+		if err != nil {
+			return nil, graphQLError(ctx, err)
+		}
+		return value, nil
+	}
+}
+
+// afterMutation converts the result of an operation and drops loader caches
+// the write may have invalidated.
+func afterMutation(p graphql.ResolveParams, value interface{}, err error) (interface{}, error) {
+	if err != nil {
+		return nil, graphQLError(p.Context, err)
+	}
+	loadersFromContext(p.Context).clearAll()
+	return value, nil
+}
+
+var profileType = graphql.NewObject(graphql.ObjectConfig{
+	Name: "Profile",
+	Fields: graphql.Fields{
+		"username": &graphql.Field{
+			Type: graphql.NewNonNull(graphql.String),
+			Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+				return p.Source.(models.Profile).Name, nil
+			},
+		},
+		"bio":   &graphql.Field{Type: graphql.NewNonNull(graphql.String)},
+		"image": &graphql.Field{Type: graphql.NewNonNull(graphql.String)},
+		"following": &graphql.Field{
+			Type: graphql.NewNonNull(graphql.Boolean),
+			Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+				profile := p.Source.(models.Profile)
+				return thunk(p.Context, loadersFromContext(p.Context).following.Load(p.Context, profile.ID)), nil
+			},
+		},
+	},
+})
+
+// resolveAuthor returns the preloaded author, or batches the lookup.
+func resolveAuthor(p graphql.ResolveParams, author models.Profile, authorID uint) (interface{}, error) {
+	if author.ID != 0 {
+		return author, nil
+	}
+	return thunk(p.Context, loadersFromContext(p.Context).profiles.Load(p.Context, authorID)), nil
+}
+
+var commentType = graphql.NewObject(graphql.ObjectConfig{
+	Name: "Comment",
+	Fields: graphql.Fields{
+		"id": &graphql.Field{
+			Type: graphql.NewNonNull(graphql.ID),
+			Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+				return p.Source.(models.Comment).ID, nil
+			},
+		},
+		"body": &graphql.Field{Type: graphql.NewNonNull(graphql.String)},
+		"createdAt": &graphql.Field{
+			Type: graphql.NewNonNull(graphql.String),
+			Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+				return formatTime(p.Source.(models.Comment).CreatedAt), nil
+			},
+		},
+		"updatedAt": &graphql.Field{
+			Type: graphql.NewNonNull(graphql.String),
+			Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+				return formatTime(p.Source.(models.Comment).UpdatedAt), nil
+			},
+		},
+		"author": &graphql.Field{
+			Type: graphql.NewNonNull(profileType),
+			Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+				comment := p.Source.(models.Comment)
+				return resolveAuthor(p, comment.Author, comment.AuthorID)
+			},
+		},
+	},
+})
+
+var headingType = graphql.NewObject(graphql.ObjectConfig{
+	Name: "Heading",
+	Fields: graphql.Fields{
+		"level": &graphql.Field{Type: graphql.NewNonNull(graphql.Int)},
+		"text":  &graphql.Field{Type: graphql.NewNonNull(graphql.String)},
+		"id":    &graphql.Field{Type: graphql.NewNonNull(graphql.String)},
+	},
+})
+
+func renderArticle(p graphql.ResolveParams) markdown.Rendered {
+	article := p.Source.(models.Article)
+	return markdown.DefaultCache.Render(article.ID, article.UpdatedAt, article.Body)
+}
+
+var articleType = graphql.NewObject(graphql.ObjectConfig{
+	Name: "Article",
+	Fields: graphql.Fields{
+		"slug":        &graphql.Field{Type: graphql.NewNonNull(graphql.String)},
+		"title":       &graphql.Field{Type: graphql.NewNonNull(graphql.String)},
+		"description": &graphql.Field{Type: graphql.NewNonNull(graphql.String)},
+		"body":        &graphql.Field{Type: graphql.NewNonNull(graphql.String)},
+		"bodyHtml": &graphql.Field{
+			Type: graphql.NewNonNull(graphql.String),
+			Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+				return renderArticle(p).HTML, nil
+			},
+		},
+		"toc": &graphql.Field{
+			Type: graphql.NewNonNull(graphql.NewList(graphql.NewNonNull(headingType))),
+			Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+				return renderArticle(p).TOC, nil
+			},
+		},
+		"readingTimeMinutes": &graphql.Field{
+			Type: graphql.NewNonNull(graphql.Int),
+			Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+				return markdown.ReadingTime(p.Source.(models.Article).Body), nil
+			},
+		},
+		"createdAt": &graphql.Field{
+			Type: graphql.NewNonNull(graphql.String),
+			Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+				return formatTime(p.Source.(models.Article).CreatedAt), nil
+			},
+		},
+		"updatedAt": &graphql.Field{
+			Type: graphql.NewNonNull(graphql.String),
+			Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+				return formatTime(p.Source.(models.Article).UpdatedAt), nil
+			},
+		},
+		"tagList": &graphql.Field{
+			Type: graphql.NewNonNull(graphql.NewList(graphql.NewNonNull(graphql.String))),
+			Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+				serializer := models.TagsSerializer{Tags: p.Source.(models.Article).Tags}
+				return serializer.Response(), nil
+			},
+		},
+		"favorited": &graphql.Field{
+			Type: graphql.NewNonNull(graphql.Boolean),
+			Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+				article := p.Source.(models.Article)
+				return thunk(p.Context, loadersFromContext(p.Context).favorited.Load(p.Context, article.ID)), nil
+			},
+		},
+		"favoritesCount": &graphql.Field{
+			Type: graphql.NewNonNull(graphql.Int),
+			Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+				article := p.Source.(models.Article)
+				return thunk(p.Context, loadersFromContext(p.Context).favoritesCount.Load(p.Context, article.ID)), nil
+			},
+		},
+		"author": &graphql.Field{
+			Type: graphql.NewNonNull(profileType),
+			Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+				article := p.Source.(models.Article)
+				return resolveAuthor(p, article.Author, article.AuthorID)
+			},
+		},
+		"comments": &graphql.Field{
+			Type: graphql.NewNonNull(graphql.NewList(graphql.NewNonNull(commentType))),
+			Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+				article := p.Source.(models.Article)
+				return thunk(p.Context, loadersFromContext(p.Context).comments.Load(p.Context, article.ID)), nil
+			},
+		},
+	},
+})
+
+var articleListType = graphql.NewObject(graphql.ObjectConfig{
+	Name: "ArticleList",
+	Fields: graphql.Fields{
+		"articles":      &graphql.Field{Type: graphql.NewNonNull(graphql.NewList(graphql.NewNonNull(articleType)))},
+		"articlesCount": &graphql.Field{Type: graphql.NewNonNull(graphql.Int)},
+	},
+})
+
+// userType resolves from models.UserResponse, the REST user representation.
+var userType = graphql.NewObject(graphql.ObjectConfig{
+	Name: "User",
+	Fields: graphql.Fields{
+		"email":    &graphql.Field{Type: graphql.NewNonNull(graphql.String)},
+		"token":    &graphql.Field{Type: graphql.NewNonNull(graphql.String)},
+		"username": &graphql.Field{Type: graphql.NewNonNull(graphql.String)},
+		"bio":      &graphql.Field{Type: graphql.NewNonNull(graphql.String)},
+		"image":    &graphql.Field{Type: graphql.NewNonNull(graphql.String)},
+	},
+})
+
+func userResponse(user models.User) models.UserResponse {
+	serializer := models.UserSerializer{User: user}
+	return serializer.Response()
+}
+
+func articleList(articles []models.Article, count int64) map[string]interface{} {
+	if articles == nil {
+		articles = []models.Article{}
+	}
+	return map[string]interface{}{"articles": articles, "articlesCount": count}
+}
+
+func inputObject(name string, fields graphql.InputObjectConfigFieldMap) *graphql.InputObject {
+	return graphql.NewInputObject(graphql.InputObjectConfig{Name: name, Fields: fields})
+}
+
+var (
+	requiredString = &graphql.InputObjectFieldConfig{Type: graphql.NewNonNull(graphql.String)}
+	optionalString = &graphql.InputObjectFieldConfig{Type: graphql.String}
+
+	newUserInput = inputObject("NewUserInput", graphql.InputObjectConfigFieldMap{
+		"username": requiredString,
+		"email":    requiredString,
+		"password": requiredString,
+	})
+	loginInput = inputObject("LoginInput", graphql.InputObjectConfigFieldMap{
+		"email":    requiredString,
+		"password": requiredString,
+	})
+	updateUserInput = inputObject("UpdateUserInput", graphql.InputObjectConfigFieldMap{
+		"email":    optionalString,
+		"bio":      optionalString,
+		"image":    optionalString,
+		"username": optionalString,
+		"password": optionalString,
+		"imageId":  &graphql.InputObjectFieldConfig{Type: graphql.Int},
+	})
+	newArticleInput = inputObject("NewArticleInput", graphql.InputObjectConfigFieldMap{
+		"title":       requiredString,
+		"description": requiredString,
+		"body":        requiredString,
+		"tagList":     &graphql.InputObjectFieldConfig{Type: graphql.NewList(graphql.NewNonNull(graphql.String))},
+	})
+	updateArticleInput = inputObject("UpdateArticleInput", graphql.InputObjectConfigFieldMap{
+		"title":       optionalString,
+		"description": optionalString,
+		"body":        optionalString,
+	})
+	newCommentInput = inputObject("NewCommentInput", graphql.InputObjectConfigFieldMap{
+		"body": requiredString,
+	})
+)
+
+func nonNullArg(t graphql.Input) *graphql.ArgumentConfig {
+	return &graphql.ArgumentConfig{Type: graphql.NewNonNull(t)}
+}
+
+var pageArguments = graphql.FieldConfigArgument{
+	"limit":  &graphql.ArgumentConfig{Type: graphql.Int, DefaultValue: graphQLDefaultListSize},
+	"offset": &graphql.ArgumentConfig{Type: graphql.Int, DefaultValue: 0},
+}
+
+var queryType = graphql.NewObject(graphql.ObjectConfig{
+	Name: "Query",
+	Fields: graphql.Fields{
+		"articles": &graphql.Field{
+			Type: graphql.NewNonNull(articleListType),
+			Args: graphql.FieldConfigArgument{
+				"tag":       &graphql.ArgumentConfig{Type: graphql.String},
+				"author":    &graphql.ArgumentConfig{Type: graphql.String},
+				"favorited": &graphql.ArgumentConfig{Type: graphql.String},
+				"limit":     pageArguments["limit"],
+				"offset":    pageArguments["offset"],
+			},
+			Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+				limit, offset, err := pageArgs(p)
+				if err != nil {
+					return nil, err
+				}
+				filter := service.ArticleFilter{Limit: limit, Offset: offset}
+				filter.Tag, _ = p.Args["tag"].(string)
+				filter.Author, _ = p.Args["author"].(string)
+				filter.Favorited, _ = p.Args["favorited"].(string)
+				articles, count, err := articleService.List(p.Context, filter)
+				if err != nil {
+					return nil, graphQLError(p.Context, err)
+				}
+				return articleList(articles, count), nil
+			},
+		},
+		"feed": &graphql.Field{
+			Type: graphql.NewNonNull(articleListType),
+			Args: pageArguments,
+			Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+				userData, err := requireViewer(p)
+				if err != nil {
+					return nil, err
+				}
+				limit, offset, err := pageArgs(p)
+				if err != nil {
+					return nil, err
+				}
+				articles, count, err := articleService.Feed(p.Context, userData, limit, offset)
+				if err != nil {
+					return nil, graphQLError(p.Context, err)
+				}
+				return articleList(articles, count), nil
+			},
+		},
+		"article": &graphql.Field{
+			Type: articleType,
+			Args: graphql.FieldConfigArgument{"slug": nonNullArg(graphql.String)},
+			Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+				article, err := articleService.Get(p.Context, p.Args["slug"].(string))
+				if errors.Is(err, service.ErrNotFound) {
+					return nil, nil
+				}
+				if err != nil {
+					return nil, graphQLError(p.Context, err)
+				}
+				return article, nil
+			},
+		},
+		"profile": &graphql.Field{
+			Type: profileType,
+			Args: graphql.FieldConfigArgument{"username": nonNullArg(graphql.String)},
+			Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+				profile, err := profileService.Get(p.Context, p.Args["username"].(string))
+				if errors.Is(err, service.ErrNotFound) {
+					return nil, nil
+				}
+				if err != nil {
+					return nil, graphQLError(p.Context, err)
+				}
+				return profile, nil
+			},
+		},
+		"comments": &graphql.Field{
+			Type: graphql.NewNonNull(graphql.NewList(graphql.NewNonNull(commentType))),
+			Args: graphql.FieldConfigArgument{"slug": nonNullArg(graphql.String)},
+			Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+				comments, err := commentService.List(p.Context, p.Args["slug"].(string))
+				if err != nil {
+					return nil, graphQLError(p.Context, err)
+				}
+				return comments, nil
+			},
+		},
+		"tags": &graphql.Field{
+			Type: graphql.NewNonNull(graphql.NewList(graphql.NewNonNull(graphql.String))),
+			Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+				tags, err := articleService.Tags(p.Context)
+				if err != nil {
+					return nil, graphQLError(p.Context, err)
+				}
+				serializer := models.TagsSerializer{Tags: tags}
+				return serializer.Response(), nil
+			},
+		},
+		"currentUser": &graphql.Field{
+			Type: userType,
+			Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+				userData, ok := viewerFromContext(p.Context)
+				if !ok {
+					return nil, nil
+				}
+				return userResponse(userData), nil
+			},
+		},
+	},
+})
+
+var mutationType = graphql.NewObject(graphql.ObjectConfig{
+	Name: "Mutation",
+	Fields: graphql.Fields{
+		"createUser": &graphql.Field{
+			Type: graphql.NewNonNull(userType),
+			Args: graphql.FieldConfigArgument{"user": nonNullArg(newUserInput)},
+			Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+				if err := limitMutation(p, "signup", byIP); err != nil {
+					return nil, graphQLError(p.Context, err)
+				}
+				var input models.RegisterValidator
+				if err := bindInput(p.Args, &input); err != nil {
+					return nil, graphQLError(p.Context, err)
+				}
+				user, err := userService.Register(p.Context, service.RegisterInput{
+					Username: input.User.Username,
+					Email:    input.User.Email,
+					Password: input.User.Password,
+				})
+				return afterMutation(p, userResponse(user), err)
+			},
+		},
+		"login": &graphql.Field{
+			Type: graphql.NewNonNull(userType),
+			Args: graphql.FieldConfigArgument{"user": nonNullArg(loginInput)},
+			Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+				if err := limitMutation(p, "login", byIP); err != nil {
+					return nil, graphQLError(p.Context, err)
+				}
+				var input models.LoginValidator
+				if err := bindInput(p.Args, &input); err != nil {
+					return nil, graphQLError(p.Context, err)
+				}
+				user, err := userService.Login(p.Context, input.User.Email, input.User.Password)
+				if err != nil {
+					return nil, graphQLError(p.Context, err)
+				}
+				return userResponse(user), nil
+			},
+		},
+		"updateUser": &graphql.Field{
+			Type: graphql.NewNonNull(userType),
+			Args: graphql.FieldConfigArgument{"user": nonNullArg(updateUserInput)},
+			Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+				userData, err := requireViewer(p)
+				if err != nil {
+					return nil, err
+				}
+				var input models.UserRequest
+				if err := bindInput(p.Args, &input); err != nil {
+					return nil, graphQLError(p.Context, err)
+				}
+				user, err := userService.Update(p.Context, userData, service.UserUpdate{
+					Email:    input.User.Email,
+					Username: input.User.Username,
+					Password: input.User.Password,
+					Bio:      input.User.Bio,
+					Image:    input.User.Image,
+					ImageID:  input.User.ImageID,
+				})
+				return afterMutation(p, userResponse(user), err)
+			},
+		},
+		"createArticle": &graphql.Field{
+			Type: graphql.NewNonNull(articleType),
+			Args: graphql.FieldConfigArgument{"article": nonNullArg(newArticleInput)},
+			Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+				userData, err := requireViewer(p)
+				if err != nil {
+					return nil, err
+				}
+				if err := limitMutation(p, "articles", byUser); err != nil {
+					return nil, graphQLError(p.Context, err)
+				}
+				var input models.ArticleValidator
+				if err := bindInput(p.Args, &input); err != nil {
+					return nil, graphQLError(p.Context, err)
+				}
+				article, err := articleService.Create(p.Context, userData, service.ArticleInput{
+					Title:       input.Article.Title,
+					Description: input.Article.Description,
+					Body:        input.Article.Body,
+					TagList:     input.Article.TagList,
+				})
+				return afterMutation(p, article, err)
+			},
+		},
+		"updateArticle": &graphql.Field{
+			Type: graphql.NewNonNull(articleType),
+			Args: graphql.FieldConfigArgument{
+				"slug":    nonNullArg(graphql.String),
+				"article": nonNullArg(updateArticleInput),
+			},
+			Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+				userData, err := requireViewer(p)
+				if err != nil {
+					return nil, err
+				}
+				var input models.ArticleRequest
+				if err := bindInput(p.Args, &input); err != nil {
+					return nil, graphQLError(p.Context, err)
+				}
+				article, err := articleService.Update(p.Context, userData, p.Args["slug"].(string), service.ArticleUpdate{
+					Title:       input.Article.Title,
+					Description: input.Article.Description,
+					Body:        input.Article.Body,
+				})
+				return afterMutation(p, article, err)
+			},
+		},
+		"deleteArticle": &graphql.Field{
+			Type: graphql.NewNonNull(graphql.Boolean),
+			Args: graphql.FieldConfigArgument{"slug": nonNullArg(graphql.String)},
+			Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+				userData, err := requireViewer(p)
+				if err != nil {
+					return nil, err
+				}
+				return afterMutation(p, true, articleService.Delete(p.Context, userData, p.Args["slug"].(string)))
+			},
+		},
+		"addComment": &graphql.Field{
+			Type: graphql.NewNonNull(commentType),
+			Args: graphql.FieldConfigArgument{
+				"slug":    nonNullArg(graphql.String),
+				"comment": nonNullArg(newCommentInput),
+			},
+			Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+				userData, err := requireViewer(p)
+				if err != nil {
+					return nil, err
+				}
+				if err := limitMutation(p, "comments", byUser); err != nil {
+					return nil, graphQLError(p.Context, err)
+				}
+				var input models.CommentValidator
+				if err := bindInput(p.Args, &input); err != nil {
+					return nil, graphQLError(p.Context, err)
+				}
+				comment, err := commentService.Add(p.Context, userData, p.Args["slug"].(string), input.Comment.Body)
+				return afterMutation(p, comment, err)
+			},
+		},
+		"updateComment": &graphql.Field{
+			Type: graphql.NewNonNull(commentType),
+			Args: graphql.FieldConfigArgument{
+				"id":      nonNullArg(graphql.ID),
+				"comment": nonNullArg(newCommentInput),
+			},
+			Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+				userData, err := requireViewer(p)
+				if err != nil {
+					return nil, err
+				}
+				var input models.CommentValidator
+				if err := bindInput(p.Args, &input); err != nil {
+					return nil, graphQLError(p.Context, err)
+				}
+				id, err := graphQLCommentID(p)
+				if err != nil {
+					return nil, err
+				}
+				comment, err := commentService.Update(p.Context, userData, id, input.Comment.Body)
+				return afterMutation(p, comment, err)
+			},
+		},
+		"deleteComment": &graphql.Field{
+			Type: graphql.NewNonNull(graphql.Boolean),
+			Args: graphql.FieldConfigArgument{"id": nonNullArg(graphql.ID)},
+			Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+				userData, err := requireViewer(p)
+				if err != nil {
+					return nil, err
+				}
+				id, err := graphQLCommentID(p)
+				if err != nil {
+					return nil, err
+				}
+				return afterMutation(p, true, commentService.Delete(p.Context, userData, id))
+			},
+		},
+		"favoriteArticle":   favoriteMutation(true),
+		"unfavoriteArticle": favoriteMutation(false),
+		"followUser":        followMutation(true),
+		"unfollowUser":      followMutation(false),
+	},
+})
+
+func favoriteMutation(favorite bool) *graphql.Field {
+	return &graphql.Field{
+		Type: graphql.NewNonNull(articleType),
+		Args: graphql.FieldConfigArgument{"slug": nonNullArg(graphql.String)},
+		Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+			userData, err := requireViewer(p)
+			if err != nil {
+				return nil, err
+			}
+			setFavorite := articleService.Favorite
+			if !favorite {
+				setFavorite = articleService.Unfavorite
+			}
+			article, err := setFavorite(p.Context, userData, p.Args["slug"].(string))
+			return afterMutation(p, article, err)
+		},
+	}
+}
+
+func followMutation(follow bool) *graphql.Field {
+	return &graphql.Field{
+		Type: graphql.NewNonNull(profileType),
+		Args: graphql.FieldConfigArgument{"username": nonNullArg(graphql.String)},
+		Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+			userData, err := requireViewer(p)
+			if err != nil {
+				return nil, err
+			}
+			setFollow := profileService.Follow
+			if !follow {
+				setFollow = profileService.Unfollow
+			}
+			profile, err := setFollow(p.Context, userData, p.Args["username"].(string))
+			return afterMutation(p, profile, err)
+		},
+	}
+}
+
+var graphQLSchema = func() graphql.Schema {
+	schema, err := graphql.NewSchema(graphql.SchemaConfig{Query: queryType, Mutation: mutationType})
+	if err != nil {
+		panic(err)
+	}
+	return schema
+}()
+
+type graphQLRequest struct {
+	Query         string                 `json:"query"`
+	OperationName string                 `json:"operationName"`
+	Variables     map[string]interface{} `json:"variables"`
+}
+
+func readGraphQLRequest(w http.ResponseWriter, r *http.Request) (graphQLRequest, error) {
+	var request graphQLRequest
+	if r.Method == http.MethodGet {
+		query := r.URL.Query()
+		request.Query = query.Get("query")
+		request.OperationName = query.Get("operationName")
+		if variables := query.Get("variables"); variables != "" {
+			if err := json.Unmarshal([]byte(variables), &request.Variables); err != nil {
+				logInvalid(r, err)
+				return request, service.Invalid("variables")
+			}
+		}
+		return request, nil
+	}
+	err := decodeJSON(w, r, &request, maxGraphQLBodySize)
+	return request, err
+}
+
+// selectOperation finds the operation to run, as graphql-go's executor would.
+func selectOperation(document *ast.Document, name string) *ast.OperationDefinition {
+	var selected *ast.OperationDefinition
+	for _, definition := range document.Definitions {
+		operation, ok := definition.(*ast.OperationDefinition)
+		if !ok {
+			continue
+		}
+		if name == "" {
+			if selected != nil {
+				return nil // ambiguous
+			}
+			selected = operation
+		} else if operation.Name != nil && operation.Name.Value == name {
+			return operation
+		}
+	}
+	return selected
+}
+
+func writeGraphQLErrors(w http.ResponseWriter, r *http.Request, statusCode int, errs ...gqlerrors.FormattedError) {
+	writeResponse(w, r, map[string]interface{}{"errors": errs}, statusCode)
+}
+
+// GraphQLEndpoint serves queries over GET and POST and mutations over POST.
+// Authentication is optional and uses the same Authorization header as the
+// REST API; resolvers that need a user report "authentication required".
+func GraphQLEndpoint(w http.ResponseWriter, r *http.Request) {
+	request, err := readGraphQLRequest(w, r)
+	if err != nil {
+		writeGraphQLErrors(w, r, serviceStatus(err), gqlerrors.NewFormattedError(err.Error()))
+		return
+	}
+	document, err := parser.Parse(parser.ParseParams{Source: request.Query})
+	if err != nil {
+		writeGraphQLErrors(w, r, http.StatusBadRequest, gqlerrors.FormatError(err))
+		return
+	}
+	if validation := graphql.ValidateDocument(&graphQLSchema, document, nil); !validation.IsValid {
+		writeGraphQLErrors(w, r, http.StatusBadRequest, validation.Errors...)
+		return
+	}
+	operation := selectOperation(document, request.OperationName)
+	if operation == nil {
+		writeGraphQLErrors(w, r, http.StatusBadRequest, gqlerrors.NewFormattedError("unknown or ambiguous operation"))
+		return
+	}
+	if operation.Operation != ast.OperationTypeQuery && r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		writeGraphQLErrors(w, r, http.StatusMethodNotAllowed, gqlerrors.NewFormattedError("mutations must use POST"))
+		return
+	}
+	if err := checkQueryLimits(document, operation, request.Variables); err != nil {
+		writeGraphQLErrors(w, r, http.StatusBadRequest, gqlerrors.NewFormattedError(err.Error()))
+		return
+	}
+
+	var viewer *models.User
+	if userData, ok := viewerFromContext(r.Context()); ok {
+		viewer = &userData
+	}
+	ctx := context.WithValue(r.Context(), graphQLLoadersKey{}, newGraphQLLoaders(viewer))
+	ctx = context.WithValue(ctx, graphQLRequestKey{}, r)
+	result := graphql.Execute(graphql.ExecuteParams{
+		Schema:        graphQLSchema,
+		AST:           document,
+		OperationName: request.OperationName,
+		Args:          request.Variables,
+		Context:       ctx,
+	})
+	response := map[string]interface{}{"data": result.Data}
+	if result.HasErrors() {
+		response["errors"] = result.Errors
+	}
+	writeResponse(w, r, response, http.StatusOK)
+}
@@ -0,0 +1,125 @@
+package app
+
+import (
+	"fmt"
+	"maps"
+	"strconv"
+	"strings"
+
+	"github.com/graphql-go/graphql/language/ast"
+)
+
+// Queries are rejected before execution when they nest deeper than
+// graphQLMaxDepth fields or their estimated cost exceeds graphQLMaxComplexity.
+// Every field costs one; paged and list fields multiply the cost of their
+// selection by the requested limit, or by graphQLDefaultListSize, which is
+// also what the store lists for a limit of 0.
+const (
+	graphQLMaxDepth        = 8
+	graphQLMaxComplexity   = 2000
+	graphQLDefaultListSize = 20
+)
+
+// graphQLPagedFields return an ArticleList sized by their limit argument;
+// its "articles" field is already accounted for by the page.
+var graphQLPagedFields = map[string]bool{"articles": true, "feed": true}
+
+// graphQLListFields return unbounded lists.
+var graphQLListFields = map[string]bool{"comments": true, "tags": true, "tagList": true, "toc": true}
+
+type queryCost struct {
+	fragments map[string]*ast.FragmentDefinition
+	variables map[string]interface{}
+}
+
+// checkQueryLimits measures the operation that will be executed; the
+// document must already be validated, so fragment cycles cannot occur.
+func checkQueryLimits(document *ast.Document, operation *ast.OperationDefinition, variables map[string]interface{}) error {
+	cost := queryCost{fragments: map[string]*ast.FragmentDefinition{}, variables: map[string]interface{}{}}
+	// the variables a request leaves out take their defaults
+	for _, definition := range operation.VariableDefinitions {
+		if definition.DefaultValue != nil {
+			cost.variables[definition.Variable.Name.Value] = definition.DefaultValue
+		}
+	}
+	maps.Copy(cost.variables, variables)
+	for _, definition := range document.Definitions {
+		if fragment, ok := definition.(*ast.FragmentDefinition); ok {
+			cost.fragments[fragment.Name.Value] = fragment
+		}
+	}
+	depth, complexity := cost.measure(operation.SelectionSet, false)
+	if depth > graphQLMaxDepth {
+		return fmt.Errorf("query depth %d exceeds the limit of %d", depth, graphQLMaxDepth)
+	}
+	if complexity > graphQLMaxComplexity {
+		return fmt.Errorf("query complexity %d exceeds the limit of %d", complexity, graphQLMaxComplexity)
+	}
+	return nil
+}
+
+func (c queryCost) measure(selectionSet *ast.SelectionSet, inPage bool) (depth, complexity int) {
+	if selectionSet == nil {
+		return 0, 0
+	}
+	for _, selection := range selectionSet.Selections {
+		var d, n int
+		switch selection := selection.(type) {
+		case *ast.Field:
+			name := selection.Name.Value
+			if strings.HasPrefix(name, "__") {
+				continue // introspection is bounded by the schema itself
+			}
+			paged := graphQLPagedFields[name] && !inPage
+			d, n = c.measure(selection.SelectionSet, paged)
+			switch {
+			case paged:
+				n *= c.pageSize(selection)
+			case graphQLListFields[name]:
+				n *= graphQLDefaultListSize
+			}
+			d, n = d+1, n+1
+		case *ast.InlineFragment:
+			d, n = c.measure(selection.SelectionSet, inPage)
+		case *ast.FragmentSpread:
+			if fragment, ok := c.fragments[selection.Name.Value]; ok {
+				d, n = c.measure(fragment.SelectionSet, inPage)
+			}
+		}
+		if d > depth {
+			depth = d
+		}
+		complexity += n
+	}
+	return depth, complexity
+}
+
+func (c queryCost) pageSize(field *ast.Field) int {
+	for _, argument := range field.Arguments {
+		if argument.Name.Value != "limit" {
+			continue
+		}
+		if limit := c.intValue(argument.Value); limit > 0 {
+			return limit
+		}
+	}
+	return graphQLDefaultListSize
+}
+
+// intValue is the value of an integer argument, a literal or a variable,
+// or -1 if it has none.
+func (c queryCost) intValue(value interface{}) int {
+	switch value := value.(type) {
+	case *ast.IntValue:
+		if n, err := strconv.Atoi(value.Value); err == nil {
+			return n
+		}
+	case *ast.Variable:
+		return c.intValue(c.variables[value.Name.Value])
+	case float64: // variables are decoded from JSON
+		return int(value)
+	case int:
+		return value
+	}
+	return -1
+}
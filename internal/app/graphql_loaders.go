@@ -0,0 +1,121 @@
+package app
+
+import (
+	"context"
+
+	"github.com/graph-gophers/dataloader/v7"
+	"github.com/hy00nc/conduit-go/internal/database"
+	"github.com/hy00nc/conduit-go/internal/models"
+)
+
+// graphQLLoaders batch the per-item lookups of a single GraphQL request, so
+// a list of N articles costs one query per field instead of N.
+type graphQLLoaders struct {
+	profiles       *dataloader.Loader[uint, models.Profile]
+	favoritesCount *dataloader.Loader[uint, int]
+	favorited      *dataloader.Loader[uint, bool]
+	following      *dataloader.Loader[uint, bool]
+	comments       *dataloader.Loader[uint, []models.Comment]
+}
+
+type graphQLLoadersKey struct{}
+
+// newGraphQLLoaders creates the loaders for one request; favorited and
+// following are answered for viewer, which may be nil.
+func newGraphQLLoaders(viewer *models.User) *graphQLLoaders {
+	return &graphQLLoaders{
+		profiles:       dataloader.NewBatchedLoader(loadProfiles),
+		favoritesCount: dataloader.NewBatchedLoader(loadFavoritesCount),
+		favorited:      dataloader.NewBatchedLoader(loadFavorited(viewer)),
+		following:      dataloader.NewBatchedLoader(loadFollowing(viewer)),
+		comments:       dataloader.NewBatchedLoader(loadComments),
+	}
+}
+
+func loadersFromContext(ctx context.Context) *graphQLLoaders {
+	return ctx.Value(graphQLLoadersKey{}).(*graphQLLoaders)
+}
+
+// results orders values by keys, leaving the zero value for missing keys.
+func results[V any](keys []uint, values map[uint]V, err error) []*dataloader.Result[V] {
+	output := make([]*dataloader.Result[V], len(keys))
+	for i, key := range keys {
+		output[i] = &dataloader.Result[V]{Data: values[key], Error: err}
+	}
+	return output
+}
+
+func loadProfiles(ctx context.Context, ids []uint) []*dataloader.Result[models.Profile] {
+	var profiles []models.Profile
+	err := database.GetDB().Where("id IN ?", ids).Find(&profiles).Error
+	values := map[uint]models.Profile{}
+	for _, profile := range profiles {
+		values[profile.ID] = profile
+	}
+	return results(ids, values, err)
+}
+
+func loadFavoritesCount(ctx context.Context, articleIDs []uint) []*dataloader.Result[int] {
+	var rows []struct {
+		ArticleID uint
+		Count     int
+	}
+	err := database.GetDB().Model(&models.Favorite{}).Select("article_id, count(*) as count").
+		Where("article_id IN ?", articleIDs).Group("article_id").Find(&rows).Error
+	values := map[uint]int{}
+	for _, row := range rows {
+		values[row.ArticleID] = row.Count
+	}
+	return results(articleIDs, values, err)
+}
+
+func loadFavorited(viewer *models.User) dataloader.BatchFunc[uint, bool] {
+	return func(ctx context.Context, articleIDs []uint) []*dataloader.Result[bool] {
+		values := map[uint]bool{}
+		if viewer == nil {
+			return results(articleIDs, values, nil)
+		}
+		var favorited []uint
+		err := database.GetDB().Model(&models.Favorite{}).Where("favorited_by_id = ? AND article_id IN ?", viewer.ProfileID, articleIDs).
+			Pluck("article_id", &favorited).Error
+		for _, id := range favorited {
+			values[id] = true
+		}
+		return results(articleIDs, values, err)
+	}
+}
+
+func loadFollowing(viewer *models.User) dataloader.BatchFunc[uint, bool] {
+	return func(ctx context.Context, profileIDs []uint) []*dataloader.Result[bool] {
+		values := map[uint]bool{}
+		if viewer == nil {
+			return results(profileIDs, values, nil)
+		}
+		var following []uint
+		err := database.GetDB().Model(&models.Follow{}).Where("user_id = ? AND following_id IN ?", viewer.ProfileID, profileIDs).
+			Pluck("following_id", &following).Error
+		for _, id := range following {
+			values[id] = true
+		}
+		return results(profileIDs, values, err)
+	}
+}
+
+func loadComments(ctx context.Context, articleIDs []uint) []*dataloader.Result[[]models.Comment] {
+	var comments []models.Comment
+	err := database.GetDB().Where("article_id IN ?", articleIDs).Order("id").Find(&comments).Error
+	values := map[uint][]models.Comment{}
+	for _, comment := range comments {
+		values[comment.ArticleID] = append(values[comment.ArticleID], comment)
+	}
+	return results(articleIDs, values, err)
+}
+
+// clearAll drops cached results after a mutation changed the data behind them.
+func (l *graphQLLoaders) clearAll() {
+	l.profiles.ClearAll()
+	l.favoritesCount.ClearAll()
+	l.favorited.ClearAll()
+	l.following.ClearAll()
+	l.comments.ClearAll()
+}
@@ -0,0 +1,167 @@
+package app
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/hy00nc/conduit-go/internal/models"
+	"github.com/hy00nc/conduit-go/internal/service"
+	"github.com/hy00nc/conduit-go/internal/utils"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+// postGraphQL runs query as the user holding token, if any.
+func postGraphQL(handler http.Handler, token, query string) *httptest.ResponseRecorder {
+	body, _ := json.Marshal(map[string]string{"query": query})
+	req := httptest.NewRequest("POST", "/graphql", strings.NewReader(string(body)))
+	req.Header.Set("Content-Type", "application/json")
+	if token != "" {
+		req.Header.Set("Authorization", "Token "+token)
+	}
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+	return w
+}
+
+// queryCounter counts the queries made on each table.
+type queryCounter struct {
+	mu     sync.Mutex
+	tables map[string]int
+}
+
+func countQueries(t *testing.T, db *gorm.DB) *queryCounter {
+	counter := &queryCounter{tables: map[string]int{}}
+	count := func(tx *gorm.DB) {
+		counter.mu.Lock()
+		defer counter.mu.Unlock()
+		counter.tables[tx.Statement.Table]++
+	}
+	require.NoError(t, db.Callback().Query().After("gorm:query").Register("test:count_queries", count))
+	require.NoError(t, db.Callback().Row().After("gorm:row").Register("test:count_rows", count))
+	return counter
+}
+
+func (c *queryCounter) reset() map[string]int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	tables := c.tables
+	c.tables = map[string]int{}
+	return tables
+}
+
+func TestGraphQLLoadersBatch(t *testing.T) {
+	asserts := assert.New(t)
+	db := useTempDB(t)
+	handler := MakeWebHandler(false)
+	register := func(name string) models.User {
+		user, err := userService.Register(t.Context(), service.RegisterInput{Username: name, Email: name + "@example.com", Password: "secret"})
+		require.NoError(t, err)
+		return user
+	}
+	viewer := register("viewer")
+	token, _ := utils.GetToken(viewer.ID)
+	query := func(limit int) string {
+		return fmt.Sprintf(`{articles(limit:%d){articles{favorited favoritesCount author{username following} comments{author{username following}}}}}`, limit)
+	}
+
+	queries := map[int]map[string]int{}
+	counter := countQueries(t, db)
+	for i := 1; i <= 4; i++ {
+		author, commenter := register(fmt.Sprintf("author%d", i)), register(fmt.Sprintf("commenter%d", i))
+		article, err := articleService.Create(t.Context(), author, service.ArticleInput{Title: fmt.Sprintf("Article %d", i), Description: "d", Body: "b"})
+		require.NoError(t, err)
+		_, err = commentService.Add(t.Context(), commenter, article.Slug, "Hello")
+		require.NoError(t, err)
+		_, err = articleService.Favorite(t.Context(), commenter, article.Slug)
+		require.NoError(t, err)
+		_, err = profileService.Follow(t.Context(), viewer, author.Profile.Name)
+		require.NoError(t, err)
+
+		counter.reset()
+		w := postGraphQL(handler, token, query(i))
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		queries[i] = counter.reset()
+
+		var result struct {
+			Data struct {
+				Articles struct {
+					Articles []struct {
+						Favorited      bool
+						FavoritesCount int
+						Author         struct{ Following bool }
+						Comments       []struct{ Author struct{ Username string } }
+					}
+				}
+			}
+			Errors []interface{}
+		}
+		asserts.NoError(json.Unmarshal(w.Body.Bytes(), &result))
+		asserts.Empty(result.Errors)
+		asserts.Len(result.Data.Articles.Articles, i)
+		for _, article := range result.Data.Articles.Articles {
+			asserts.Equal(1, article.FavoritesCount)
+			asserts.True(article.Author.Following)
+			asserts.Len(article.Comments, 1)
+			asserts.True(strings.HasPrefix(article.Comments[0].Author.Username, "commenter"))
+		}
+	}
+
+	// the first request also loads the viewer, which is cached afterwards
+	asserts.Equal(queries[2], queries[4], "as many queries for four articles as for two")
+	for _, table := range []string{"profiles", "favorites", "follows", "comments"} {
+		asserts.LessOrEqual(queries[4][table], 2, "lookups in %s are batched: %v", table, queries[4])
+	}
+}
+
+func TestGraphQLValidatesInput(t *testing.T) {
+	asserts := assert.New(t)
+	useTempDB(t)
+	handler := MakeWebHandler(false)
+	user, err := userService.Register(t.Context(), service.RegisterInput{Username: "sally", Email: "sally@example.com", Password: "secret"})
+	require.NoError(t, err)
+	token, _ := utils.GetToken(user.ID)
+
+	w := postGraphQL(handler, token, fmt.Sprintf(`mutation{createArticle(article:{title:%q,description:"d",body:"b"}){slug}}`, strings.Repeat("t", 201)))
+	asserts.Contains(w.Body.String(), "title is invalid", "titles are limited as over REST")
+	articles, _, err := articleService.List(t.Context(), service.ArticleFilter{Limit: 20})
+	require.NoError(t, err)
+	asserts.Empty(articles)
+
+	w = postGraphQL(handler, token, fmt.Sprintf(`mutation{createArticle(article:{title:%q,description:"d",body:"b"}){slug}}`, strings.Repeat("t", 200)))
+	asserts.NotContains(w.Body.String(), "errors")
+}
+
+func TestGraphQLComplexityPricesDefaults(t *testing.T) {
+	asserts := assert.New(t)
+	useTempDB(t)
+	handler := MakeWebHandler(false)
+	post := func(query string, variables map[string]interface{}) *httptest.ResponseRecorder {
+		body, _ := json.Marshal(map[string]interface{}{"query": query, "variables": variables})
+		req := httptest.NewRequest("POST", "/graphql", strings.NewReader(string(body)))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+		return w
+	}
+	page := `{articles{comments{author{username}}}}`
+
+	w := post(`{a:articles(limit:0)`+page+` b:articles(limit:0)`+page+` c:articles(limit:0)`+page+`}`, nil)
+	asserts.Equal(http.StatusBadRequest, w.Code, "a limit of 0 lists the default page: %s", w.Body)
+	asserts.Contains(w.Body.String(), "query complexity")
+	w = post(`{a:articles(limit:1)`+page+` b:articles(limit:1)`+page+` c:articles(limit:1)`+page+`}`, nil)
+	asserts.Equal(http.StatusOK, w.Code, w.Body.String())
+
+	byVariable := `query($n:Int=100){articles(limit:$n)` + page + `}`
+	w = post(byVariable, nil)
+	asserts.Equal(http.StatusBadRequest, w.Code, "variables left out are priced at their default: %s", w.Body)
+	asserts.Contains(w.Body.String(), "query complexity")
+	w = post(byVariable, map[string]interface{}{"n": 5})
+	asserts.Equal(http.StatusOK, w.Code, w.Body.String())
+}
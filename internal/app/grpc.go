@@ -0,0 +1,586 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/url"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+
+	conduitv1 "github.com/hy00nc/conduit-go/api/conduit/v1"
+	"github.com/hy00nc/conduit-go/internal/database"
+	"github.com/hy00nc/conduit-go/internal/logging"
+	"github.com/hy00nc/conduit-go/internal/models"
+	"github.com/hy00nc/conduit-go/internal/service"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/emptypb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// grpcAddr is where RunServer serves gRPC, next to the HTTP listener.
+func grpcAddr() string {
+	if addr := os.Getenv("CONDUIT_GRPC_ADDR"); addr != "" {
+		return addr
+	}
+	return ":9000"
+}
+
+// articleBroadcast fans newly created articles out to WatchArticles streams.
+type articleBroadcast struct {
+	mu          sync.Mutex
+	subscribers map[chan models.Article]struct{}
+	closed      bool
+}
+
+var newArticles = &articleBroadcast{subscribers: map[chan models.Article]struct{}{}}
+
+func (b *articleBroadcast) subscribe() chan models.Article {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	ch := make(chan models.Article, 16)
+	if b.closed {
+		close(ch)
+		return ch
+	}
+	b.subscribers[ch] = struct{}{}
+	return ch
+}
+
+func (b *articleBroadcast) unsubscribe(ch chan models.Article) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	delete(b.subscribers, ch)
+}
+
+// close ends the streams, on shutdown, by closing their channels.
+func (b *articleBroadcast) close() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.closed = true
+	for ch := range b.subscribers {
+		close(ch)
+		delete(b.subscribers, ch)
+	}
+}
+
+func (b *articleBroadcast) publish(article models.Article) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for ch := range b.subscribers {
+		select {
+		case ch <- article:
+		default: // a slow watcher misses articles rather than blocking writers
+		}
+	}
+}
+
+// authenticateRPC gives the call a request ID and logger like
+// requestIDMiddleware does for HTTP, with the ID echoed in the response
+// header metadata, and puts the user of the "authorization" metadata, if
+// any, into the context the same way jwtMiddleware does.
+func authenticateRPC(ctx context.Context, method string) (context.Context, error) {
+	sent := metadata.ValueFromIncomingContext(ctx, "x-request-id")
+	id := requestID(strings.Join(sent, ""))
+	grpc.SetHeader(ctx, metadata.Pairs("x-request-id", id))
+	ctx = requestContext(ctx, id)
+	logging.With(ctx, "method", method)
+
+	values := metadata.ValueFromIncomingContext(ctx, "authorization")
+	if len(values) == 0 {
+		return ctx, nil
+	}
+	userData, err := retrieveUserFromToken(ctx, strings.TrimPrefix(values[0], "Token "))
+	if err != nil {
+		return ctx, status.Error(codes.Unauthenticated, err.Error()+" is invalid")
+	}
+	logging.With(ctx, "userId", userData.ID)
+	return contextWithUser(ctx, userData), nil
+}
+
+func unaryAuthInterceptor(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	ctx, err := authenticateRPC(ctx, info.FullMethod)
+	if err != nil {
+		return nil, err
+	}
+	return handler(ctx, req)
+}
+
+type authenticatedStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s authenticatedStream) Context() context.Context {
+	return s.ctx
+}
+
+func streamAuthInterceptor(srv interface{}, stream grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	ctx, err := authenticateRPC(stream.Context(), info.FullMethod)
+	if err != nil {
+		return err
+	}
+	return handler(srv, authenticatedStream{ServerStream: stream, ctx: ctx})
+}
+
+// rpcRateLimit is the rate limit policy of an RPC and whether its clients
+// are counted by user, like byUser, rather than by address.
+type rpcRateLimit struct {
+	policy string
+	byUser bool
+}
+
+// rpcRateLimits limit the RPCs that create content or check passwords as
+// their REST routes are.
+var rpcRateLimits = map[string]rpcRateLimit{
+	conduitv1.ConduitService_Register_FullMethodName:      {policy: "signup"},
+	conduitv1.ConduitService_Login_FullMethodName:         {policy: "login"},
+	conduitv1.ConduitService_CreateArticle_FullMethodName: {policy: "articles", byUser: true},
+	conduitv1.ConduitService_AddComment_FullMethodName:    {policy: "comments", byUser: true},
+}
+
+// unaryRateInterceptor refuses calls over their rate limit with
+// ResourceExhausted and the seconds to wait in the "retry-after" header.
+// It runs after unaryAuthInterceptor, which puts the user in the context.
+func unaryRateInterceptor(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	limit, ok := rpcRateLimits[info.FullMethod]
+	if !ok {
+		return handler(ctx, req)
+	}
+	client := "ip:unknown"
+	if p, ok := peer.FromContext(ctx); ok {
+		client = ipKey(p.Addr.String())
+	}
+	if limit.byUser {
+		client = userKey(ctx, client)
+	}
+	if err := allowRate(ctx, limit.policy, client); err != nil {
+		grpc.SetHeader(ctx, metadata.Pairs("retry-after", strconv.Itoa(ceilSeconds(service.RetryAfter(err)))))
+		return nil, grpcError(ctx, err)
+	}
+	return handler(ctx, req)
+}
+
+// NewGRPCServer returns a server with the Conduit service registered.
+func NewGRPCServer() *grpc.Server {
+	server := grpc.NewServer(
+		grpc.ChainUnaryInterceptor(unaryAuthInterceptor, unaryRateInterceptor),
+		grpc.StreamInterceptor(streamAuthInterceptor),
+	)
+	conduitv1.RegisterConduitServiceServer(server, conduitServer{})
+	return server
+}
+
+// grpcError maps a service error to a status; database failures are
+// logged instead of exposed.
+func grpcError(ctx context.Context, err error) error {
+	switch {
+	case errors.Is(err, service.ErrCredentials):
+		return status.Error(codes.Unauthenticated, err.Error())
+	case errors.Is(err, service.ErrValidation):
+		return status.Error(codes.InvalidArgument, err.Error())
+	case errors.Is(err, service.ErrNotFound):
+		return status.Error(codes.NotFound, err.Error())
+	case errors.Is(err, service.ErrForbidden):
+		return status.Error(codes.PermissionDenied, err.Error())
+	case errors.Is(err, service.ErrConflict):
+		return status.Error(codes.AlreadyExists, err.Error())
+	case errors.Is(err, service.ErrLimited):
+		return status.Error(codes.ResourceExhausted, err.Error())
+	case errors.Is(err, service.ErrPrecondition):
+		return status.Error(codes.FailedPrecondition, err.Error())
+	}
+	logging.FromContext(ctx).Error("grpc: call failed", "error", err)
+	return status.Error(codes.Internal, "internal error")
+}
+
+func rpcViewer(ctx context.Context) (models.User, error) {
+	userData, ok := viewerFromContext(ctx)
+	if !ok {
+		return userData, status.Error(codes.Unauthenticated, errAuthRequired.Error())
+	}
+	return userData, nil
+}
+
+// viewerRequest lets the REST serializers answer favorited and following
+// for the caller of an RPC.
+func viewerRequest(ctx context.Context) *http.Request {
+	return (&http.Request{URL: &url.URL{}}).WithContext(ctx)
+}
+
+func profileMessage(response models.ProfileResponse) *conduitv1.Profile {
+	return &conduitv1.Profile{
+		Username:  response.Username,
+		Bio:       response.Bio,
+		Image:     response.Image,
+		Following: response.Following,
+	}
+}
+
+func userMessage(user models.User) *conduitv1.User {
+	serializer := models.UserSerializer{User: user}
+	response := serializer.Response()
+	return &conduitv1.User{
+		Email:    response.Email,
+		Token:    response.Token,
+		Username: response.Username,
+		Bio:      response.Bio,
+		Image:    response.Image,
+	}
+}
+
+func articleMessage(ctx context.Context, article models.Article) *conduitv1.Article {
+	serializer := models.ArticleSerializer{Article: article}
+	response := serializer.Response(database.GetDB(), viewerRequest(ctx))
+	return &conduitv1.Article{
+		Slug:               response.Slug,
+		Title:              response.Title,
+		Description:        response.Description,
+		Body:               response.Body,
+		TagList:            response.Tags,
+		CreatedAt:          timestamppb.New(article.CreatedAt),
+		UpdatedAt:          timestamppb.New(article.UpdatedAt),
+		Favorited:          response.Favorite,
+		FavoritesCount:     uint32(response.FavoritesCount),
+		Author:             profileMessage(response.Author),
+		ReadingTimeMinutes: int32(response.ReadingTimeMinutes),
+	}
+}
+
+func articlesMessage(ctx context.Context, articles []models.Article, count int64) *conduitv1.ListArticlesResponse {
+	response := &conduitv1.ListArticlesResponse{ArticlesCount: count}
+	for _, article := range articles {
+		response.Articles = append(response.Articles, articleMessage(ctx, article))
+	}
+	return response
+}
+
+func commentMessage(ctx context.Context, comment models.Comment) *conduitv1.Comment {
+	serializer := models.CommentSerializer{Comment: comment}
+	response := serializer.Response(database.GetDB(), viewerRequest(ctx))
+	return &conduitv1.Comment{
+		Id:        uint64(response.ID),
+		Body:      response.Body,
+		CreatedAt: timestamppb.New(comment.CreatedAt),
+		UpdatedAt: timestamppb.New(comment.UpdatedAt),
+		Author:    profileMessage(response.Author),
+	}
+}
+
+// conduitServer implements the gRPC service on top of the same services as
+// the REST handlers.
+type conduitServer struct {
+	conduitv1.UnimplementedConduitServiceServer
+}
+
+func (conduitServer) Register(ctx context.Context, req *conduitv1.RegisterRequest) (*conduitv1.User, error) {
+	var input models.RegisterValidator
+	input.User.Username, input.User.Email, input.User.Password = req.GetUsername(), req.GetEmail(), req.GetPassword()
+	if err := validateInput(&input); err != nil {
+		return nil, grpcError(ctx, err)
+	}
+	user, err := userService.Register(ctx, service.RegisterInput{
+		Username: req.GetUsername(),
+		Email:    req.GetEmail(),
+		Password: req.GetPassword(),
+	})
+	if err != nil {
+		return nil, grpcError(ctx, err)
+	}
+	return userMessage(user), nil
+}
+
+func (conduitServer) Login(ctx context.Context, req *conduitv1.LoginRequest) (*conduitv1.User, error) {
+	var input models.LoginValidator
+	input.User.Email, input.User.Password = req.GetEmail(), req.GetPassword()
+	if err := validateInput(&input); err != nil {
+		return nil, grpcError(ctx, err)
+	}
+	user, err := userService.Login(ctx, req.GetEmail(), req.GetPassword())
+	if err != nil {
+		return nil, grpcError(ctx, err)
+	}
+	return userMessage(user), nil
+}
+
+func (conduitServer) GetCurrentUser(ctx context.Context, _ *emptypb.Empty) (*conduitv1.User, error) {
+	userData, err := rpcViewer(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return userMessage(userData), nil
+}
+
+func (conduitServer) UpdateUser(ctx context.Context, req *conduitv1.UpdateUserRequest) (*conduitv1.User, error) {
+	userData, err := rpcViewer(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var input models.UserRequest
+	input.User.Email, input.User.Username, input.User.Password = req.GetEmail(), req.GetUsername(), req.GetPassword()
+	input.User.Bio, input.User.Image = req.GetBio(), req.GetImage()
+	if err := validateInput(&input); err != nil {
+		return nil, grpcError(ctx, err)
+	}
+	user, err := userService.Update(ctx, userData, service.UserUpdate{
+		Email:    req.GetEmail(),
+		Username: req.GetUsername(),
+		Password: req.GetPassword(),
+		Bio:      req.GetBio(),
+		Image:    req.GetImage(),
+		ImageID:  uint(req.GetImageId()),
+	})
+	if err != nil {
+		return nil, grpcError(ctx, err)
+	}
+	return userMessage(user), nil
+}
+
+func (conduitServer) GetProfile(ctx context.Context, req *conduitv1.GetProfileRequest) (*conduitv1.Profile, error) {
+	profile, err := profileService.Get(ctx, req.GetUsername())
+	if err != nil {
+		return nil, grpcError(ctx, err)
+	}
+	serializer := models.ProfileSerializer{Profile: profile}
+	return profileMessage(serializer.Response(database.GetDB(), viewerRequest(ctx))), nil
+}
+
+func setFollowRPC(ctx context.Context, username string, follow bool) (*conduitv1.Profile, error) {
+	userData, err := rpcViewer(ctx)
+	if err != nil {
+		return nil, err
+	}
+	setFollow := profileService.Follow
+	if !follow {
+		setFollow = profileService.Unfollow
+	}
+	profile, err := setFollow(ctx, userData, username)
+	if err != nil {
+		return nil, grpcError(ctx, err)
+	}
+	serializer := models.ProfileSerializer{Profile: profile}
+	return profileMessage(serializer.Response(database.GetDB(), viewerRequest(ctx))), nil
+}
+
+func (conduitServer) FollowUser(ctx context.Context, req *conduitv1.FollowUserRequest) (*conduitv1.Profile, error) {
+	return setFollowRPC(ctx, req.GetUsername(), true)
+}
+
+func (conduitServer) UnfollowUser(ctx context.Context, req *conduitv1.FollowUserRequest) (*conduitv1.Profile, error) {
+	return setFollowRPC(ctx, req.GetUsername(), false)
+}
+
+func (conduitServer) ListArticles(ctx context.Context, req *conduitv1.ListArticlesRequest) (*conduitv1.ListArticlesResponse, error) {
+	articles, count, err := articleService.List(ctx, service.ArticleFilter{
+		Tag:       req.GetTag(),
+		Author:    req.GetAuthor(),
+		Favorited: req.GetFavorited(),
+		Limit:     int(req.GetLimit()),
+		Offset:    int(req.GetOffset()),
+	})
+	if err != nil {
+		return nil, grpcError(ctx, err)
+	}
+	return articlesMessage(ctx, articles, count), nil
+}
+
+func (conduitServer) FeedArticles(ctx context.Context, req *conduitv1.FeedArticlesRequest) (*conduitv1.ListArticlesResponse, error) {
+	userData, err := rpcViewer(ctx)
+	if err != nil {
+		return nil, err
+	}
+	articles, count, err := articleService.Feed(ctx, userData, int(req.GetLimit()), int(req.GetOffset()))
+	if err != nil {
+		return nil, grpcError(ctx, err)
+	}
+	return articlesMessage(ctx, articles, count), nil
+}
+
+func (conduitServer) GetArticle(ctx context.Context, req *conduitv1.GetArticleRequest) (*conduitv1.Article, error) {
+	article, err := articleService.Get(ctx, req.GetSlug())
+	if err != nil {
+		return nil, grpcError(ctx, err)
+	}
+	return articleMessage(ctx, article), nil
+}
+
+func (conduitServer) CreateArticle(ctx context.Context, req *conduitv1.CreateArticleRequest) (*conduitv1.Article, error) {
+	userData, err := rpcViewer(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var input models.ArticleValidator
+	input.Article.Title, input.Article.Description, input.Article.Body = req.GetTitle(), req.GetDescription(), req.GetBody()
+	input.Article.TagList = req.GetTagList()
+	if err := validateInput(&input); err != nil {
+		return nil, grpcError(ctx, err)
+	}
+	article, err := articleService.Create(ctx, userData, service.ArticleInput{
+		Title:       req.GetTitle(),
+		Description: req.GetDescription(),
+		Body:        req.GetBody(),
+		TagList:     req.GetTagList(),
+	})
+	if err != nil {
+		return nil, grpcError(ctx, err)
+	}
+	return articleMessage(ctx, article), nil
+}
+
+func (conduitServer) UpdateArticle(ctx context.Context, req *conduitv1.UpdateArticleRequest) (*conduitv1.Article, error) {
+	userData, err := rpcViewer(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var input models.ArticleRequest
+	input.Article.Title, input.Article.Description, input.Article.Body = req.GetTitle(), req.GetDescription(), req.GetBody()
+	if err := validateInput(&input); err != nil {
+		return nil, grpcError(ctx, err)
+	}
+	article, err := articleService.Update(ctx, userData, req.GetSlug(), service.ArticleUpdate{
+		Title:       req.GetTitle(),
+		Description: req.GetDescription(),
+		Body:        req.GetBody(),
+	})
+	if err != nil {
+		return nil, grpcError(ctx, err)
+	}
+	return articleMessage(ctx, article), nil
+}
+
+func (conduitServer) DeleteArticle(ctx context.Context, req *conduitv1.DeleteArticleRequest) (*emptypb.Empty, error) {
+	userData, err := rpcViewer(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if err := articleService.Delete(ctx, userData, req.GetSlug()); err != nil {
+		return nil, grpcError(ctx, err)
+	}
+	return &emptypb.Empty{}, nil
+}
+
+func setFavoriteRPC(ctx context.Context, slug string, favorite bool) (*conduitv1.Article, error) {
+	userData, err := rpcViewer(ctx)
+	if err != nil {
+		return nil, err
+	}
+	setFavorite := articleService.Favorite
+	if !favorite {
+		setFavorite = articleService.Unfavorite
+	}
+	article, err := setFavorite(ctx, userData, slug)
+	if err != nil {
+		return nil, grpcError(ctx, err)
+	}
+	return articleMessage(ctx, article), nil
+}
+
+func (conduitServer) FavoriteArticle(ctx context.Context, req *conduitv1.FavoriteArticleRequest) (*conduitv1.Article, error) {
+	return setFavoriteRPC(ctx, req.GetSlug(), true)
+}
+
+func (conduitServer) UnfavoriteArticle(ctx context.Context, req *conduitv1.FavoriteArticleRequest) (*conduitv1.Article, error) {
+	return setFavoriteRPC(ctx, req.GetSlug(), false)
+}
+
+func (conduitServer) ListComments(ctx context.Context, req *conduitv1.ListCommentsRequest) (*conduitv1.ListCommentsResponse, error) {
+	comments, err := commentService.List(ctx, req.GetSlug())
+	if err != nil {
+		return nil, grpcError(ctx, err)
+	}
+	response := &conduitv1.ListCommentsResponse{}
+	for _, comment := range comments {
+		response.Comments = append(response.Comments, commentMessage(ctx, comment))
+	}
+	return response, nil
+}
+
+func (conduitServer) AddComment(ctx context.Context, req *conduitv1.AddCommentRequest) (*conduitv1.Comment, error) {
+	userData, err := rpcViewer(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var input models.CommentValidator
+	input.Comment.Body = req.GetBody()
+	if err := validateInput(&input); err != nil {
+		return nil, grpcError(ctx, err)
+	}
+	comment, err := commentService.Add(ctx, userData, req.GetSlug(), req.GetBody())
+	if err != nil {
+		return nil, grpcError(ctx, err)
+	}
+	return commentMessage(ctx, comment), nil
+}
+
+func (conduitServer) UpdateComment(ctx context.Context, req *conduitv1.UpdateCommentRequest) (*conduitv1.Comment, error) {
+	userData, err := rpcViewer(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var input models.CommentValidator
+	input.Comment.Body = req.GetBody()
+	if err := validateInput(&input); err != nil {
+		return nil, grpcError(ctx, err)
+	}
+	comment, err := commentService.Update(ctx, userData, uint(req.GetId()), req.GetBody())
+	if err != nil {
+		return nil, grpcError(ctx, err)
+	}
+	return commentMessage(ctx, comment), nil
+}
+
+func (conduitServer) DeleteComment(ctx context.Context, req *conduitv1.DeleteCommentRequest) (*emptypb.Empty, error) {
+	userData, err := rpcViewer(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if err := commentService.Delete(ctx, userData, uint(req.GetId())); err != nil {
+		return nil, grpcError(ctx, err)
+	}
+	return &emptypb.Empty{}, nil
+}
+
+func (conduitServer) ListTags(ctx context.Context, _ *emptypb.Empty) (*conduitv1.ListTagsResponse, error) {
+	tags, err := articleService.Tags(ctx)
+	if err != nil {
+		return nil, grpcError(ctx, err)
+	}
+	response := &conduitv1.ListTagsResponse{}
+	for _, tag := range tags {
+		response.Tags = append(response.Tags, &conduitv1.Tag{Name: tag.Name})
+	}
+	return response, nil
+}
+
+func (conduitServer) WatchArticles(req *conduitv1.WatchArticlesRequest, stream conduitv1.ConduitService_WatchArticlesServer) error {
+	ctx := stream.Context()
+	articles := newArticles.subscribe()
+	defer newArticles.unsubscribe(articles)
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case article, ok := <-articles:
+			if !ok {
+				return status.Error(codes.Unavailable, "server is shutting down")
+			}
+			message := articleMessage(ctx, article)
+			if req.GetAuthor() != "" && message.Author.Username != req.GetAuthor() {
+				continue
+			}
+			if req.GetTag() != "" && !slices.Contains(message.TagList, req.GetTag()) {
+				continue
+			}
+			if err := stream.Send(message); err != nil {
+				return err
+			}
+		}
+	}
+}
@@ -0,0 +1,156 @@
+package app
+
+import (
+	"context"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	conduitv1 "github.com/hy00nc/conduit-go/api/conduit/v1"
+	"github.com/hy00nc/conduit-go/internal/ratelimit"
+	"github.com/hy00nc/conduit-go/internal/service"
+	"github.com/hy00nc/conduit-go/internal/utils"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+	"google.golang.org/protobuf/types/known/emptypb"
+)
+
+// grpcClient serves NewGRPCServer over an in-memory listener.
+func grpcClient(t *testing.T) conduitv1.ConduitServiceClient {
+	listener := bufconn.Listen(1 << 20)
+	server := NewGRPCServer()
+	go server.Serve(listener)
+	t.Cleanup(server.Stop)
+
+	conn, err := grpc.NewClient("passthrough:///bufconn",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return listener.DialContext(ctx)
+		}),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	require.NoError(t, err)
+	t.Cleanup(func() { conn.Close() })
+	return conduitv1.NewConduitServiceClient(conn)
+}
+
+func withToken(ctx context.Context, token string) context.Context {
+	return metadata.AppendToOutgoingContext(ctx, "authorization", "Token "+token)
+}
+
+func TestGRPC(t *testing.T) {
+	asserts := assert.New(t)
+	useTempDB(t)
+	client := grpcClient(t)
+	ctx := t.Context()
+
+	registered, err := client.Register(ctx, &conduitv1.RegisterRequest{Username: "sally", Email: "sally@example.com", Password: "secret"})
+	require.NoError(t, err)
+	asserts.Equal("sally", registered.GetUsername())
+	asserts.NotEmpty(registered.GetToken())
+	sally := withToken(ctx, registered.GetToken())
+
+	var header metadata.MD
+	user, err := client.GetCurrentUser(sally, &emptypb.Empty{}, grpc.Header(&header))
+	asserts.NoError(err)
+	asserts.Equal("sally@example.com", user.GetEmail())
+	asserts.NotEmpty(header.Get("x-request-id"))
+
+	article, err := client.CreateArticle(sally, &conduitv1.CreateArticleRequest{Title: "Over gRPC", Description: "d", Body: "b", TagList: []string{"grpc"}})
+	asserts.NoError(err)
+	asserts.Equal("sally", article.GetAuthor().GetUsername())
+	asserts.Equal([]string{"grpc"}, article.GetTagList())
+
+	_, err = client.GetCurrentUser(ctx, &emptypb.Empty{})
+	asserts.Equal(codes.Unauthenticated, status.Code(err), "protected RPCs need a token")
+	_, err = client.CreateArticle(ctx, &conduitv1.CreateArticleRequest{Title: "t", Description: "d", Body: "b"})
+	asserts.Equal(codes.Unauthenticated, status.Code(err))
+	_, err = client.ListTags(withToken(ctx, "garbage"), &emptypb.Empty{})
+	asserts.Equal(codes.Unauthenticated, status.Code(err), "bad tokens are refused, even for public RPCs")
+
+	_, err = client.GetArticle(ctx, &conduitv1.GetArticleRequest{Slug: "missing"})
+	asserts.Equal(codes.NotFound, status.Code(err))
+	_, err = client.Register(ctx, &conduitv1.RegisterRequest{Username: "sally", Email: "other@example.com", Password: "secret"})
+	asserts.Equal(codes.AlreadyExists, status.Code(err))
+	_, err = client.Register(ctx, &conduitv1.RegisterRequest{Username: "", Email: "harry@example.com", Password: "secret"})
+	asserts.Equal(codes.InvalidArgument, status.Code(err))
+	_, err = client.CreateArticle(sally, &conduitv1.CreateArticleRequest{Title: strings.Repeat("t", 201), Description: "d", Body: "b"})
+	asserts.Equal(codes.InvalidArgument, status.Code(err), "titles are limited as over REST")
+	_, err = client.Login(ctx, &conduitv1.LoginRequest{Email: "sally@example.com", Password: "wrong"})
+	asserts.Equal(codes.Unauthenticated, status.Code(err))
+
+	harryUser, err := userService.Register(ctx, service.RegisterInput{Username: "harry", Email: "harry@example.com", Password: "secret"})
+	require.NoError(t, err)
+	harryToken, _ := utils.GetToken(harryUser.ID)
+	harry := withToken(ctx, harryToken)
+	_, err = client.DeleteArticle(harry, &conduitv1.DeleteArticleRequest{Slug: article.GetSlug()})
+	asserts.Equal(codes.PermissionDenied, status.Code(err))
+	favorited, err := client.FavoriteArticle(harry, &conduitv1.FavoriteArticleRequest{Slug: article.GetSlug()})
+	asserts.NoError(err)
+	asserts.True(favorited.GetFavorited())
+	asserts.Equal(uint32(1), favorited.GetFavoritesCount())
+}
+
+func TestGRPCWatchArticles(t *testing.T) {
+	asserts := assert.New(t)
+	useTempDB(t)
+	client := grpcClient(t)
+	sally, err := userService.Register(t.Context(), service.RegisterInput{Username: "sally", Email: "sally@example.com", Password: "secret"})
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithTimeout(t.Context(), 5*time.Second)
+	defer cancel()
+	stream, err := client.WatchArticles(ctx, &conduitv1.WatchArticlesRequest{Tag: "go"})
+	require.NoError(t, err)
+	// the stream is established once the server subscribed
+	require.Eventually(t, func() bool {
+		newArticles.mu.Lock()
+		defer newArticles.mu.Unlock()
+		return len(newArticles.subscribers) > 0
+	}, 5*time.Second, 10*time.Millisecond)
+
+	_, err = articleService.Create(t.Context(), sally, service.ArticleInput{Title: "Untagged", Description: "d", Body: "b"})
+	require.NoError(t, err)
+	_, err = articleService.Create(t.Context(), sally, service.ArticleInput{Title: "Tagged", Description: "d", Body: "b", TagList: []string{"go"}})
+	require.NoError(t, err)
+
+	article, err := stream.Recv()
+	require.NoError(t, err)
+	asserts.Equal("Tagged", article.GetTitle(), "articles without the tag are skipped")
+	asserts.Equal("sally", article.GetAuthor().GetUsername())
+}
+
+func TestGRPCRateLimit(t *testing.T) {
+	asserts := assert.New(t)
+	useTempDB(t)
+	useRateLimits(t, ratelimit.Policy{Name: "login", Limit: 1, Period: time.Minute}, ratelimit.Policy{Name: "articles", Limit: 1, Period: time.Minute})
+	client := grpcClient(t)
+	ctx := t.Context()
+
+	_, err := client.Login(ctx, &conduitv1.LoginRequest{Email: "nobody@example.com", Password: "secret"})
+	asserts.Equal(codes.Unauthenticated, status.Code(err))
+	var header metadata.MD
+	_, err = client.Login(ctx, &conduitv1.LoginRequest{Email: "nobody@example.com", Password: "secret"}, grpc.Header(&header))
+	asserts.Equal(codes.ResourceExhausted, status.Code(err))
+	asserts.Equal([]string{"60"}, header.Get("retry-after"))
+
+	sally, err := userService.Register(ctx, service.RegisterInput{Username: "sally", Email: "sally@example.com", Password: "secret"})
+	require.NoError(t, err)
+	harry, err := userService.Register(ctx, service.RegisterInput{Username: "harry", Email: "harry@example.com", Password: "secret"})
+	require.NoError(t, err)
+	sallyToken, _ := utils.GetToken(sally.ID)
+	harryToken, _ := utils.GetToken(harry.ID)
+	create := func(token string) error {
+		_, err := client.CreateArticle(withToken(ctx, token), &conduitv1.CreateArticleRequest{Title: "t", Description: "d", Body: "b"})
+		return err
+	}
+	asserts.NoError(create(sallyToken))
+	asserts.Equal(codes.ResourceExhausted, status.Code(create(sallyToken)))
+	asserts.NoError(create(harryToken), "articles are limited per user")
+}
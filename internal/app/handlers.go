@@ -1,488 +1,335 @@
 package app
 
 import (
-	"encoding/json"
-	"log"
+	"context"
 	"net/http"
 	"strconv"
+	"time"
 
-	"github.com/go-playground/validator/v10"
-	"github.com/google/uuid"
 	"github.com/gorilla/mux"
-	"github.com/gosimple/slug"
+	"github.com/hy00nc/conduit-go/internal/cache"
 	"github.com/hy00nc/conduit-go/internal/database"
 	"github.com/hy00nc/conduit-go/internal/models"
+	"github.com/hy00nc/conduit-go/internal/service"
 	"github.com/hy00nc/conduit-go/internal/utils"
-	"golang.org/x/crypto/bcrypt"
-	"gorm.io/gorm/clause"
 )
 
-var defaultImage = "https://static.productionready.io/images/smiley-cyrus.jpg"
-
-func RetrieveArticle(slug string) (models.Article, error) {
-	db := database.GetDB()
-	var article models.Article
-
-	err := db.Model(&article).Preload(clause.Associations).Preload("Tags").Find(&article, "slug = ?", slug).Error
-	return article, err
-}
-
-func RetrieveArticles(tag_param, author_param, limit_param, offset_param, favorited_param string) ([]models.Article, int64, error) {
-	db := database.GetDB()
-	var articles []models.Article
-	var count int64
-	var err error
-
-	offset_int, err := strconv.Atoi(offset_param)
-	if err != nil {
-		offset_int = 0 // set default value
+func GetArticles(w http.ResponseWriter, r *http.Request) {
+	// Retrieve optional params
+	query := r.URL.Query()
+	filter := service.ArticleFilter{
+		Tag:       query.Get("tag"),
+		Author:    query.Get("author"),
+		Favorited: query.Get("favorited"),
+		Limit:     queryInt(r, "limit"),
+		Offset:    queryInt(r, "offset"),
 	}
 
-	limit_int, err := strconv.Atoi(limit_param)
+	articles, count, err := articleService.List(r.Context(), filter)
 	if err != nil {
-		limit_int = 20 // set default default value
-	}
-	if tag_param != "" {
-		var tag models.Tag
-		db.First(&tag, "name = ?", tag_param)
-		var arr []string
-		db.Table("article_tags").Select("article_id").Where("tag_id = ?", tag.ID).Find(&arr)
-		err = db.Model(&articles).Order("created_at desc").Offset(offset_int).Limit(limit_int).Preload(clause.Associations).Find(&articles, "id IN ?", arr).Count(&count).Error
-	} else if author_param != "" {
-		var profile models.Profile
-		db.First(&profile, "name = ?", author_param)
-		err = db.Model(&articles).Order("created_at desc").Offset(offset_int).Limit(limit_int).Preload(clause.Associations).Find(&articles, "author_id = ?", profile.ID).Count(&count).Error
-	} else if favorited_param != "" {
-		var profile models.Profile
-		db.First(&profile, "name = ?", favorited_param)
-		var favoriteArticleIds []uint
-		db.Table("favorites").Select("article_id").Where("favorited_by_id = ?", profile.ID).Where("deleted_at IS NULL").Find(&favoriteArticleIds)
-		err = db.Model(&articles).Where("id IN ?", favoriteArticleIds).Order("created_at desc").Offset(offset_int).Limit(limit_int).Preload(clause.Associations).Find(&articles).Count(&count).Error
-	} else {
-		err = db.Model(&articles).Order("created_at desc").Offset(offset_int).Limit(limit_int).Preload(clause.Associations).Find(&articles).Count(&count).Error
+		writeServiceError(w, r, err, "Parameter")
+		return
 	}
-	return articles, count, err
+	serializer := models.ArticlesSerializer{Articles: articles}
+	writeResponse(w, r, map[string]interface{}{"articles": serializer.Response(database.GetDB(), r), "articlesCount": count}, http.StatusOK)
 }
 
-func RetrieveArticlesFeed(userData models.User, limitParam, offsetParam string) ([]models.Article, int64, error) {
-	db := database.GetDB()
-	var articles []models.Article
-	var count int64
+// cachedArticle is an anonymous article response, with the UpdatedAt its
+// validators derive from.
+type cachedArticle struct {
+	Article   models.ArticleResponse
+	UpdatedAt time.Time
+}
 
-	offset_int, err := strconv.Atoi(offsetParam)
-	if err != nil {
-		offset_int = 0 // set default value
+// GetArticle serves anonymous requests from appCache for articleTTL, the
+// services invalidating it on writes.
+func GetArticle(w http.ResponseWriter, r *http.Request) {
+	slug := mux.Vars(r)["slug"]
+	load := func(ctx context.Context) (cachedArticle, error) {
+		article, err := articleService.Get(ctx, slug)
+		if err != nil {
+			return cachedArticle{}, err
+		}
+		serializer := models.ArticleSerializer{Article: article}
+		return cachedArticle{serializer.Response(database.GetDB(), r.WithContext(ctx)), article.UpdatedAt}, nil
 	}
-
-	limit_int, err := strconv.Atoi(limitParam)
-	if err != nil {
-		limit_int = 20 // set default default value
+	var response cachedArticle
+	var err error
+	if _, ok := r.Context().Value(utils.ContextKeyUserData).(models.User); ok {
+		response, err = load(r.Context())
+	} else {
+		key := cache.ArticleKey(slug, r.URL.Query().Get("render") == "html")
+		response, err = cache.Fetch(r.Context(), appCache, "articles", key, articleTTL, load)
 	}
-
-	var followingIds []uint
-	db.Table("follows").Select("following_id").Where("user_id = ?", userData.ProfileID).Where("deleted_at IS NULL").Find(&followingIds)
-	err = db.Where("author_id IN ?", followingIds).Order("created_at desc").Offset(offset_int).Limit(limit_int).Preload(clause.Associations).Find(&articles).Count(&count).Error
-	return articles, count, err
-}
-
-func RetrieveComments(slug string) ([]models.Comment, error) {
-	db := database.GetDB()
-	var comments []models.Comment
-	var article models.Article
-
-	err := db.Model(&article).Preload(clause.Associations).First(&article, "slug = ?", slug).Error
 	if err != nil {
-		return nil, err
+		writeServiceError(w, r, err, "Parameter")
+		return
 	}
-	err = db.Model(&comments).Preload(clause.Associations).Find(&comments, "article_id = ?", article.ID).Error
-	return comments, err
-}
-
-func RetrieveTags() ([]models.Tag, error) {
-	db := database.GetDB()
-	var tags []models.Tag
-	err := db.Model(&tags).Find(&tags).Error
-	return tags, err
+	writeCached(w, r, map[string]interface{}{"article": response.Article}, response.UpdatedAt, cachePolicy(r, cachePublic))
 }
 
-func RetrieveProfile(username string) (models.Profile, error) {
-	db := database.GetDB()
-	var profile models.Profile
-	err := db.Model(&profile).First(&profile, "name = ?", username).Error
-	return profile, err
-}
-
-func GetArticles(w http.ResponseWriter, r *http.Request) {
-	// Retrieve optional params
-	tag := r.URL.Query().Get("tag")
-	author := r.URL.Query().Get("author")
-	favorited := r.URL.Query().Get("favorited")
-	limit := r.URL.Query().Get("limit")
-	offset := r.URL.Query().Get("offset")
-
-	articles, count, err := RetrieveArticles(tag, author, limit, offset, favorited)
+func GetFeed(w http.ResponseWriter, r *http.Request) {
+	userData := r.Context().Value(utils.ContextKeyUserData).(models.User)
+	articles, count, err := articleService.Feed(r.Context(), userData, queryInt(r, "limit"), queryInt(r, "offset"))
 	if err != nil {
-		log.Println(err)
-		writeResponse(w, map[string]interface{}{"errors": utils.CreateInvalidResponse("Parameter")}, http.StatusBadRequest)
+		writeServiceError(w, r, err, "Parameter")
 		return
 	}
-	serializer := models.ArticlesSerializer{articles}
-	writeResponse(w, map[string]interface{}{"articles": serializer.Response(database.GetDB(), r), "articlesCount": count}, http.StatusOK)
+	serializer := models.ArticlesSerializer{Articles: articles}
+	writeResponse(w, r, map[string]interface{}{"articles": serializer.Response(database.GetDB(), r), "articlesCount": count}, http.StatusOK)
 }
 
-func GetArticle(w http.ResponseWriter, r *http.Request) {
-	slug := mux.Vars(r)["slug"]
-	article, err := RetrieveArticle(slug)
+func GetComments(w http.ResponseWriter, r *http.Request) {
+	comments, err := commentService.List(r.Context(), mux.Vars(r)["slug"])
 	if err != nil {
-		log.Println(err)
-		writeResponse(w, map[string]interface{}{"errors": utils.CreateInvalidResponse("Parameter")}, http.StatusBadRequest)
+		writeServiceError(w, r, err, "Parameter")
 		return
 	}
-	serializer := models.ArticleSerializer{article}
-	writeResponse(w, map[string]interface{}{"article": serializer.Response(database.GetDB(), r)}, http.StatusOK)
+	serializer := models.CommentsSerializer{Comments: comments}
+	writeResponse(w, r, map[string]interface{}{"comments": serializer.Response(database.GetDB(), r)}, http.StatusOK)
 }
 
-func GetFeed(w http.ResponseWriter, r *http.Request) {
-	// Retrieve optional params
-	limit := r.URL.Query().Get("limit")
-	offset := r.URL.Query().Get("offset")
-
+func AddComments(w http.ResponseWriter, r *http.Request) {
 	userData := r.Context().Value(utils.ContextKeyUserData).(models.User)
-	articles, count, err := RetrieveArticlesFeed(userData, limit, offset)
+
+	// get comment data from request
+	var commentValidator models.CommentValidator
+	if err := decodeRequest(w, r, &commentValidator, maxBodySize); err != nil {
+		writeServiceError(w, r, err, "Parameter")
+		return
+	}
+	comment, err := commentService.Add(r.Context(), userData, mux.Vars(r)["slug"], commentValidator.Comment.Body)
 	if err != nil {
-		log.Println(err)
-		writeResponse(w, map[string]interface{}{"errors": utils.CreateInvalidResponse("Parameter")}, http.StatusBadRequest)
+		writeServiceError(w, r, err, "Comment")
 		return
 	}
-	serializer := models.ArticlesSerializer{articles}
-	writeResponse(w, map[string]interface{}{"articles": serializer.Response(database.GetDB(), r), "articlesCount": count}, http.StatusOK)
+	serializer := models.CommentSerializer{Comment: comment}
+	writeResponse(w, r, map[string]interface{}{"comment": serializer.Response(database.GetDB(), r)}, http.StatusCreated)
 }
 
-func GetComments(w http.ResponseWriter, r *http.Request) {
-	slug := mux.Vars(r)["slug"]
-	comments, err := RetrieveComments(slug)
+// commentID parses the id route variable; malformed ids match no comment.
+func commentID(r *http.Request) (uint, error) {
+	id, err := strconv.ParseUint(mux.Vars(r)["id"], 10, 0)
 	if err != nil {
-		log.Println(err)
-		writeResponse(w, map[string]interface{}{"errors": utils.CreateInvalidResponse("Parameter")}, http.StatusBadRequest)
-		return
+		return 0, service.NotFound("comment")
 	}
-	serializer := models.CommentsSerializer{comments}
-	writeResponse(w, map[string]interface{}{"comments": serializer.Response(database.GetDB(), r)}, http.StatusOK)
+	return uint(id), nil
 }
 
-func AddComments(w http.ResponseWriter, r *http.Request) {
+func UpdateComment(w http.ResponseWriter, r *http.Request) {
 	userData := r.Context().Value(utils.ContextKeyUserData).(models.User)
 
 	// get comment data from request
 	var commentValidator models.CommentValidator
-	if err := json.NewDecoder(r.Body).Decode(&commentValidator); err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
-		writeResponse(w, map[string]interface{}{"errors": utils.CreateInvalidResponse("Parameter")}, http.StatusBadRequest)
+	if err := decodeRequest(w, r, &commentValidator, maxBodySize); err != nil {
+		writeServiceError(w, r, err, "Parameter")
 		return
 	}
-	validate := validator.New()
-	if err := validate.Struct(commentValidator); err != nil {
-		writeResponse(w, map[string]interface{}{"errors": utils.CreateInvalidResponse("Parameter")}, http.StatusBadRequest)
+	id, err := commentID(r)
+	if err != nil {
+		writeServiceError(w, r, err, "Comment")
 		return
 	}
-	// Create comment in database
-	slugParam := mux.Vars(r)["slug"]
-	db := database.GetDB()
-	var article models.Article
-	db.Model(&article).Find(&article, "slug = ?", slugParam)
-	comment := models.Comment{
-		Body:      commentValidator.Comment.Body,
-		ArticleID: article.ID,
-		AuthorID:  userData.ID,
-	}
-	db.Create(&comment)
-	db.Save(&comment)
-	serializer := models.CommentSerializer{comment}
-	writeResponse(w, map[string]interface{}{"comment": serializer.Response(db, r)}, http.StatusCreated)
+	comment, err := commentService.Update(r.Context(), userData, id, commentValidator.Comment.Body)
+	if err != nil {
+		writeServiceError(w, r, err, "Comment")
+		return
+	}
+	serializer := models.CommentSerializer{Comment: comment}
+	writeResponse(w, r, map[string]interface{}{"comment": serializer.Response(database.GetDB(), r)}, http.StatusOK)
 }
 
 func DeleteComment(w http.ResponseWriter, r *http.Request) {
-	idParam := mux.Vars(r)["id"]
-	db := database.GetDB()
-	var comment models.Comment
-	db.Model(&comment).Find(&comment, "id = ?", idParam)
-	db.Delete(&comment)
+	userData := r.Context().Value(utils.ContextKeyUserData).(models.User)
+	id, err := commentID(r)
+	if err == nil {
+		err = commentService.Delete(r.Context(), userData, id)
+	}
+	if err != nil {
+		writeServiceError(w, r, err, "Comment")
+	}
 }
 
 func GetTags(w http.ResponseWriter, r *http.Request) {
 	// Return list of tags
-	tags, err := RetrieveTags()
+	tags, err := articleService.Tags(r.Context())
 	if err != nil {
-		log.Println(err)
-		writeResponse(w, map[string]interface{}{"errors": utils.CreateInvalidResponse("Parameter")}, http.StatusBadRequest)
+		writeServiceError(w, r, err, "Parameter")
 		return
 	}
-	serializer := models.TagsSerializer{tags}
-	writeResponse(w, map[string]interface{}{"tags": serializer.Response()}, http.StatusOK)
+	serializer := models.TagsSerializer{Tags: tags}
+	writeCached(w, r, map[string]interface{}{"tags": serializer.Response()}, time.Time{}, cachePolicy(r, cacheTags))
 }
 
 func GetProfile(w http.ResponseWriter, r *http.Request) {
-	username := mux.Vars(r)["username"]
-	profile, err := RetrieveProfile(username)
+	profile, err := profileService.Get(r.Context(), mux.Vars(r)["username"])
 	if err != nil {
-		log.Println(err)
-		writeResponse(w, map[string]interface{}{"errors": utils.CreateInvalidResponse("Parameter")}, http.StatusBadRequest)
+		writeServiceError(w, r, err, "Parameter")
+		return
 	}
-	serializer := models.ProfileSerializer{profile}
-	writeResponse(w, map[string]interface{}{"profile": serializer.Response(database.GetDB(), r)}, http.StatusOK)
+	serializer := models.ProfileSerializer{Profile: profile}
+	writeCached(w, r, map[string]interface{}{"profile": serializer.Response(database.GetDB(), r)}, profile.UpdatedAt, cachePolicy(r, cachePublic))
 }
 
 func CreateUser(w http.ResponseWriter, r *http.Request) {
 	// get user data from request
 	var registerValidator models.RegisterValidator
-	if err := json.NewDecoder(r.Body).Decode(&registerValidator); err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
-		writeResponse(w, map[string]interface{}{"errors": utils.CreateInvalidResponse("Parameter")}, http.StatusBadRequest)
+	if err := decodeRequest(w, r, &registerValidator, maxBodySize); err != nil {
+		writeServiceError(w, r, err, "Parameter")
 		return
 	}
-	validate := validator.New()
-	if err := validate.Struct(registerValidator); err != nil {
-		writeResponse(w, map[string]interface{}{"errors": utils.CreateInvalidResponse("Parameter")}, http.StatusBadRequest)
-		return
-	}
-	// Create User to database
-	hash, _ := bcrypt.GenerateFromPassword([]byte(registerValidator.User.Password), bcrypt.DefaultCost)
-	user := models.User{
-		Email: registerValidator.User.Email,
-		Profile: models.Profile{
-			Name:  registerValidator.User.Username,
-			Bio:   "",
-			Image: defaultImage,
-		},
-		Hash: string(hash),
-	}
-	db := database.GetDB()
-	if err := db.Create(&user).Error; err != nil {
-		log.Println(err.Error())
-		writeResponse(w, map[string]interface{}{"errors": utils.CreateInvalidResponse("Parameter")}, http.StatusNotFound)
-		return
-	}
-	if err := db.Save(&user).Error; err != nil {
-		log.Println(err.Error())
-		writeResponse(w, map[string]interface{}{"errors": utils.CreateInvalidResponse("Parameter")}, http.StatusNotFound)
+	user, err := userService.Register(r.Context(), service.RegisterInput{
+		Username: registerValidator.User.Username,
+		Email:    registerValidator.User.Email,
+		Password: registerValidator.User.Password,
+	})
+	if err != nil {
+		writeServiceError(w, r, err, "Parameter")
 		return
 	}
 
-	serializer := models.UserSerializer{user}
-	writeResponse(w, map[string]interface{}{"user": serializer.Response()}, http.StatusCreated)
+	serializer := models.UserSerializer{User: user}
+	writeResponse(w, r, map[string]interface{}{"user": serializer.Response()}, http.StatusCreated)
 }
 
 func LoginUser(w http.ResponseWriter, r *http.Request) {
 	// get user data from request
 	var loginValidator models.LoginValidator
-	if err := json.NewDecoder(r.Body).Decode(&loginValidator); err != nil {
-		log.Println(err.Error())
-		writeResponse(w, map[string]interface{}{"errors": utils.CreateInvalidResponse("Data")}, http.StatusBadRequest)
+	if err := decodeRequest(w, r, &loginValidator, maxBodySize); err != nil {
+		writeServiceError(w, r, err, "Data")
 		return
 	}
-	validate := validator.New()
-	if err := validate.Struct(loginValidator); err != nil {
-		log.Println(err.Error())
-		writeResponse(w, map[string]interface{}{"errors": utils.CreateInvalidResponse("Data")}, http.StatusBadRequest)
-		return
-	}
-	// Match user from DB
-	db := database.GetDB()
-	var user models.User
-	db.Model(&user).Preload(clause.Associations).Where("email = ?", loginValidator.User.Email).First(&user)
-
-	if err := user.CheckPassword(loginValidator.User.Password); err != nil || user.ID == 0 {
-		log.Println(err.Error())
-		writeResponse(w, map[string]interface{}{"errors": utils.CreateInvalidResponse("email or password")}, http.StatusForbidden)
+	user, err := userService.Login(r.Context(), loginValidator.User.Email, loginValidator.User.Password)
+	if err != nil {
+		writeServiceError(w, r, err, "Data")
 		return
 	}
 
-	serializer := models.UserSerializer{user}
-	writeResponse(w, map[string]interface{}{"user": serializer.Response()}, http.StatusOK)
+	serializer := models.UserSerializer{User: user}
+	writeResponse(w, r, map[string]interface{}{"user": serializer.Response()}, http.StatusOK)
 }
 
 func GetUser(w http.ResponseWriter, r *http.Request) {
 	userData := r.Context().Value(utils.ContextKeyUserData).(models.User)
-	serializer := models.UserSerializer{userData}
-	writeResponse(w, map[string]interface{}{"user": serializer.Response()}, http.StatusOK)
+	serializer := models.UserSerializer{User: userData}
+	writeResponse(w, r, map[string]interface{}{"user": serializer.Response()}, http.StatusOK)
 }
 
 func UpdateUser(w http.ResponseWriter, r *http.Request) {
 	userData := r.Context().Value(utils.ContextKeyUserData).(models.User)
 	// get user data from request
 	var userRequest models.UserRequest
-	err := json.NewDecoder(r.Body).Decode(&userRequest)
-	if err != nil {
-		log.Println(err.Error())
-		writeResponse(w, map[string]interface{}{"errors": utils.CreateInvalidResponse("Data")}, http.StatusBadRequest)
+	if err := decodeRequest(w, r, &userRequest, maxBodySize); err != nil {
+		writeServiceError(w, r, err, "Data")
 		return
 	}
-	// perform update on DB
-	db := database.GetDB()
-
-	// profile update
-	if userRequest.User.Bio != "" || userRequest.User.Username != "" || userRequest.User.Image != "" {
-		var profile models.Profile
-		db.Model(&profile).Find(&profile, "id = ?", userData.ProfileID)
-		db.Model(&profile).Updates(
-			models.Profile{
-				Bio:   userRequest.User.Bio,
-				Name:  userRequest.User.Username,
-				Image: userRequest.User.Image,
-			},
-		)
-	}
-
-	// user update
-	if userRequest.User.Password != "" || userRequest.User.Email != "" {
-		var hash string
-		if userRequest.User.Password != "" {
-			hashByte, _ := bcrypt.GenerateFromPassword([]byte(userRequest.User.Password), bcrypt.DefaultCost)
-			hash = string(hashByte)
-		}
-		db.Model(&userData).Updates(
-			models.User{
-				Email: userRequest.User.Email,
-				Hash:  hash,
-			},
-		)
+	userData, err := userService.Update(r.Context(), userData, service.UserUpdate{
+		Email:    userRequest.User.Email,
+		Username: userRequest.User.Username,
+		Password: userRequest.User.Password,
+		Bio:      userRequest.User.Bio,
+		Image:    userRequest.User.Image,
+		ImageID:  userRequest.User.ImageID,
+	})
+	if err != nil {
+		writeServiceError(w, r, err, "Data")
+		return
 	}
 
-	// Retrieve updated
-	db.Model(&userData).Preload(clause.Associations).Find(&userData, "id = ?", userData.ID)
-
-	// Return response
-	serializer := models.UserSerializer{userData}
-	writeResponse(w, map[string]interface{}{"user": serializer.Response()}, http.StatusOK)
+	serializer := models.UserSerializer{User: userData}
+	writeResponse(w, r, map[string]interface{}{"user": serializer.Response()}, http.StatusOK)
 }
 
 func CreateArticle(w http.ResponseWriter, r *http.Request) {
 	// get article data from request
 	userData := r.Context().Value(utils.ContextKeyUserData).(models.User)
 	var articleValidator models.ArticleValidator
-	if err := json.NewDecoder(r.Body).Decode(&articleValidator); err != nil {
-		log.Println(err.Error())
-		writeResponse(w, map[string]interface{}{"errors": utils.CreateInvalidResponse("Data")}, http.StatusBadRequest)
-		return
-	}
-	validate := validator.New()
-	if err := validate.Struct(articleValidator); err != nil {
-		log.Println(err.Error())
-		writeResponse(w, map[string]interface{}{"errors": utils.CreateInvalidResponse("Data")}, http.StatusBadRequest)
+	if err := decodeRequest(w, r, &articleValidator, maxArticleBodySize); err != nil {
+		writeServiceError(w, r, err, "Data")
 		return
 	}
-	// Create Article data
-	db := database.GetDB()
-	tagList := articleValidator.Article.TagList
-	tagLen := len(tagList)
-	tags := make([]models.Tag, tagLen)
-	for i := 0; i < len(tagList); i++ {
-		var tag models.Tag
-		db.Where("name = ?", tagList[i]).Find(&tag)
-		if tag.ID != 0 {
-			tags[i] = tag
-		} else {
-			tags[i].Name = tagList[i]
-		}
-	}
-	article := models.Article{
-		Slug:        slug.Make(articleValidator.Article.Title + " " + uuid.NewString()),
+	article, err := articleService.Create(r.Context(), userData, service.ArticleInput{
 		Title:       articleValidator.Article.Title,
 		Description: articleValidator.Article.Description,
 		Body:        articleValidator.Article.Body,
-		AuthorID:    userData.ProfileID,
-		Tags:        tags,
+		TagList:     articleValidator.Article.TagList,
+	})
+	if err != nil {
+		writeServiceError(w, r, err, "Article")
+		return
 	}
-	db.Create(&article)
-	db.Save(&article)
-	serializer := models.ArticleSerializer{article}
-	writeResponse(w, map[string]interface{}{"article": serializer.Response(db, r)}, http.StatusOK)
+	serializer := models.ArticleSerializer{Article: article}
+	writeResponse(w, r, map[string]interface{}{"article": serializer.Response(database.GetDB(), r)}, http.StatusOK)
 }
 
 func ArticleSlugEndpointAuthenticated(w http.ResponseWriter, r *http.Request) {
-	// Get article by slug
+	userData := r.Context().Value(utils.ContextKeyUserData).(models.User)
 	slugParam := mux.Vars(r)["slug"]
-	db := database.GetDB()
-	var article models.Article
-	db.Model(&article).Find(&article, "slug = ?", slugParam)
 
 	if r.Method == "DELETE" {
-		db.Delete(&article)
-	} else { // PUT
-		// Get request data
-		var articleRequest models.ArticleRequest
-		err := json.NewDecoder(r.Body).Decode(&articleRequest)
-		if err != nil {
-			log.Println(err.Error())
-			writeResponse(w, map[string]interface{}{"errors": utils.CreateInvalidResponse("Data")}, http.StatusBadRequest)
-			return
-		}
-		db.Model(&article).Updates(
-			models.Article{
-				Title:       articleRequest.Article.Title,
-				Description: articleRequest.Article.Description,
-				Body:        articleRequest.Article.Body,
-			},
-		)
-		if articleRequest.Article.Title != "" {
-			db.Model(&article).Updates(
-				models.Article{
-					Slug: slug.Make(article.Title) + uuid.NewString(),
-				},
-			)
+		if err := articleService.Delete(r.Context(), userData, slugParam); err != nil {
+			writeServiceError(w, r, err, "Article")
 		}
+		return
+	}
 
-		// Retrieve updated
-		db.Model(&article).Preload(clause.Associations).Find(&article, "id = ?", article.ID)
-
-		// Return response
-		serializer := models.ArticleSerializer{article}
-		writeResponse(w, map[string]interface{}{"article": serializer.Response(db, r)}, http.StatusOK)
+	// PUT, with If-Match guarding against overwriting the edits of others
+	var articleRequest models.ArticleRequest
+	if err := decodeRequest(w, r, &articleRequest, maxArticleBodySize); err != nil {
+		writeServiceError(w, r, err, "Data")
+		return
 	}
+	ifUpdatedAt, conditional := ifMatch(r)
+	if conditional && len(ifUpdatedAt) == 0 {
+		writeServiceError(w, r, service.Changed("article"), "Article")
+		return
+	}
+	article, err := articleService.Update(r.Context(), userData, slugParam, service.ArticleUpdate{
+		Title:       articleRequest.Article.Title,
+		Description: articleRequest.Article.Description,
+		Body:        articleRequest.Article.Body,
+		IfUpdatedAt: ifUpdatedAt,
+	})
+	if err != nil {
+		writeServiceError(w, r, err, "Article")
+		return
+	}
+	serializer := models.ArticleSerializer{Article: article}
+	writeCached(w, r, map[string]interface{}{"article": serializer.Response(database.GetDB(), r)}, article.UpdatedAt, cachePrivate)
 }
 
 func FollowUserEndpoint(w http.ResponseWriter, r *http.Request) {
 	currUser := r.Context().Value(utils.ContextKeyUserData).(models.User)
-	// get use data to un/follow
-	db := database.GetDB()
-	targetUsername := mux.Vars(r)["username"]
-	var targetUserProfile models.Profile
-	db.Model(&targetUserProfile).Find(&targetUserProfile, "name = ?", targetUsername)
-
-	var followData models.Follow
-	db.FirstOrCreate(
-		&followData,
-		models.Follow{
-			UserID:      currUser.ProfileID,
-			FollowingID: targetUserProfile.ID,
-		},
-	)
+	username := mux.Vars(r)["username"]
+	var profile models.Profile
+	var err error
 	if r.Method == "DELETE" {
-		db.Delete(&followData)
+		profile, err = profileService.Unfollow(r.Context(), currUser, username)
+	} else {
+		profile, err = profileService.Follow(r.Context(), currUser, username)
 	}
-	serializer := models.ProfileSerializer{targetUserProfile}
-	writeResponse(w, map[string]interface{}{"profile": serializer.Response(db, r)}, http.StatusOK)
+	if err != nil {
+		writeServiceError(w, r, err, "Follow")
+		return
+	}
+	serializer := models.ProfileSerializer{Profile: profile}
+	writeResponse(w, r, map[string]interface{}{"profile": serializer.Response(database.GetDB(), r)}, http.StatusOK)
 }
 
 func FavoriteArticleEndpoint(w http.ResponseWriter, r *http.Request) {
-	// get article data to unfavorite
-	articleSlug2Favorite := mux.Vars(r)["slug"]
-	db := database.GetDB()
-	var targetArticle models.Article
-	db.Model(&targetArticle).Preload(clause.Associations).Find(&targetArticle, "slug = ?", articleSlug2Favorite)
-
-	var favoriteData models.Favorite
 	userData := r.Context().Value(utils.ContextKeyUserData).(models.User)
-	db.FirstOrCreate(
-		&favoriteData,
-		models.Favorite{
-			ArticleID:     targetArticle.ID,
-			FavoritedByID: userData.ProfileID,
-		},
-	)
+	slugParam := mux.Vars(r)["slug"]
+	var article models.Article
+	var err error
 	if r.Method == "DELETE" {
-		db.Delete(&favoriteData)
+		article, err = articleService.Unfavorite(r.Context(), userData, slugParam)
+	} else {
+		article, err = articleService.Favorite(r.Context(), userData, slugParam)
+	}
+	if err != nil {
+		writeServiceError(w, r, err, "Favorite")
+		return
 	}
-	serializer := models.ArticleSerializer{targetArticle}
-	writeResponse(w, map[string]interface{}{"article": serializer.Response(db, r)}, http.StatusOK)
+	serializer := models.ArticleSerializer{Article: article}
+	writeResponse(w, r, map[string]interface{}{"article": serializer.Response(database.GetDB(), r)}, http.StatusOK)
 }
@@ -0,0 +1,69 @@
+package app
+
+import (
+	"context"
+	"net/http"
+	"sync/atomic"
+	"time"
+
+	"github.com/hy00nc/conduit-go/internal/database"
+	"github.com/hy00nc/conduit-go/internal/logging"
+)
+
+// shuttingDown is set once the server starts draining, so that load
+// balancers stop sending it requests during the drain delay.
+var shuttingDown atomic.Bool
+
+// migrated is set once the schema is found up to date, as it then stays so.
+var migrated atomic.Bool
+
+// readyTimeout bounds the database checks of a readiness probe.
+const readyTimeout = 2 * time.Second
+
+// GetHealth answers liveness probes: the process is up and serving.
+func GetHealth(w http.ResponseWriter, r *http.Request) {
+	writeResponse(w, r, map[string]interface{}{"status": "ok"}, http.StatusOK)
+}
+
+// GetReadiness answers readiness probes: the database answers, its schema
+// is migrated and the server is not shutting down.
+func GetReadiness(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), readyTimeout)
+	defer cancel()
+	checks := map[string]interface{}{}
+	ready := true
+	check := func(name string, err error) {
+		if err != nil {
+			checks[name] = err.Error()
+			ready = false
+			return
+		}
+		checks[name] = "ok"
+	}
+
+	if shuttingDown.Load() {
+		check("server", errShuttingDown)
+	} else {
+		check("server", nil)
+	}
+	db := database.GetDB().WithContext(ctx)
+	sqlDB, err := db.DB()
+	if err == nil {
+		err = sqlDB.PingContext(ctx)
+	}
+	check("database", err)
+	if migrated.Load() {
+		check("migrations", nil)
+	} else {
+		err := database.Migrated(db)
+		migrated.Store(err == nil)
+		check("migrations", err)
+	}
+
+	if !ready {
+		logging.FromContext(r.Context()).Warn("not ready", "checks", checks)
+		writeResponse(w, r, map[string]interface{}{"status": "unavailable", "checks": checks}, http.StatusServiceUnavailable)
+		return
+	}
+	writeResponse(w, r, map[string]interface{}{"status": "ok", "checks": checks}, http.StatusOK)
+}
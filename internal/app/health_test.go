@@ -0,0 +1,57 @@
+package app
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/hy00nc/conduit-go/internal/database"
+	"github.com/hy00nc/conduit-go/internal/models"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHealth(t *testing.T) {
+	asserts := assert.New(t)
+	db := useTempDB(t)
+	migrated.Store(false)
+	handler := MakeWebHandler(false)
+	probe := func(path string) (int, map[string]interface{}) {
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, httptest.NewRequest("GET", path, nil))
+		var body map[string]interface{}
+		asserts.NoError(json.Unmarshal(w.Body.Bytes(), &body))
+		return w.Code, body
+	}
+
+	code, body := probe("/healthz")
+	asserts.Equal(http.StatusOK, code)
+	asserts.Equal("ok", body["status"])
+
+	asserts.NoError(db.Migrator().DropTable(&models.FeedToken{}))
+	code, body = probe("/readyz")
+	asserts.Equal(http.StatusServiceUnavailable, code)
+	asserts.Equal("unavailable", body["status"])
+	asserts.Equal(map[string]interface{}{"server": "ok", "database": "ok", "migrations": "table feed_tokens is missing"}, body["checks"])
+
+	database.MigrateDB(db)
+	code, body = probe("/readyz")
+	asserts.Equal(http.StatusOK, code)
+	asserts.Equal(map[string]interface{}{"server": "ok", "database": "ok", "migrations": "ok"}, body["checks"])
+
+	shuttingDown.Store(true)
+	code, body = probe("/readyz")
+	shuttingDown.Store(false)
+	asserts.Equal(http.StatusServiceUnavailable, code)
+	asserts.Equal("shutting down", body["checks"].(map[string]interface{})["server"])
+
+	sqlDB, err := db.DB()
+	asserts.NoError(err)
+	asserts.NoError(sqlDB.Close())
+	code, body = probe("/readyz")
+	asserts.Equal(http.StatusServiceUnavailable, code)
+	asserts.Contains(body["checks"].(map[string]interface{})["database"], "closed")
+
+	code, _ = probe("/healthz")
+	asserts.Equal(http.StatusOK, code, "liveness does not depend on the database")
+}
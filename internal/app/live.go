@@ -0,0 +1,375 @@
+package app
+
+import (
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/gorilla/websocket"
+	"github.com/hy00nc/conduit-go/internal/database"
+	"github.com/hy00nc/conduit-go/internal/logging"
+	"github.com/hy00nc/conduit-go/internal/models"
+	"github.com/hy00nc/conduit-go/internal/service"
+	"github.com/hy00nc/conduit-go/internal/utils"
+)
+
+const (
+	liveWriteWait      = 10 * time.Second
+	livePongWait       = 60 * time.Second
+	livePingPeriod     = (livePongWait * 9) / 10
+	liveMaxMessageSize = 1024 // bytes accepted per client message
+	liveSendBuffer     = 32   // queued events per connection before it is dropped
+	liveRateBurst      = 10   // client messages allowed in a burst
+	liveRatePerSecond  = 2    // client messages refilled per second
+)
+
+// liveTypingTimeout is how long a typing indicator lasts without another
+// "typing" message.
+var liveTypingTimeout = 5 * time.Second
+
+const (
+	liveCommentCreated = service.CommentCreated
+	liveCommentUpdated = service.CommentUpdated
+	liveCommentDeleted = service.CommentDeleted
+	livePresence       = "presence"
+	liveTyping         = "typing"
+)
+
+var upgrader = websocket.Upgrader{
+	ReadBufferSize:  1024,
+	WriteBufferSize: 1024,
+	CheckOrigin:     checkLiveOrigin,
+}
+
+// live is the process wide hub used by the comment handlers to publish events.
+var live = newLiveHub()
+
+// liveCommentEvent is pushed on comment creates, edits and deletes. Comments
+// are serialized with the same CommentSerializer used by the REST endpoints.
+type liveCommentEvent struct {
+	Type    string                 `json:"type"`
+	Comment models.CommentResponse `json:"comment"`
+}
+
+// livePresenceEvent is pushed whenever the viewer or typing set changes.
+type livePresenceEvent struct {
+	Type    string   `json:"type"`
+	Viewers int      `json:"viewers"`
+	Typing  []string `json:"typing"`
+}
+
+// liveClientMessage is what viewers may send, e.g. {"type":"typing"}.
+type liveClientMessage struct {
+	Type string `json:"type"`
+}
+
+// liveMessage is queued on a client and serialized by its writer, so that
+// viewer specific fields (author "following") are computed per viewer.
+type liveMessage struct {
+	kind    string
+	comment models.Comment
+	viewers int
+	typing  []string
+}
+
+type liveClient struct {
+	room    *liveRoom
+	conn    *websocket.Conn
+	request *http.Request
+	user    models.User
+	send    chan liveMessage
+
+	tokens     float64
+	lastRefill time.Time
+}
+
+type liveRoom struct {
+	articleID uint
+	clients   map[*liveClient]bool
+	typing    map[string]time.Time // username -> typing deadline
+}
+
+type liveHub struct {
+	mu    sync.Mutex
+	rooms map[uint]*liveRoom
+	once  sync.Once
+}
+
+func newLiveHub() *liveHub {
+	return &liveHub{rooms: map[uint]*liveRoom{}}
+}
+
+func checkLiveOrigin(r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		return true
+	}
+	if apiCORS.origins.match(origin) {
+		return true
+	}
+	return strings.TrimPrefix(strings.TrimPrefix(origin, "https://"), "http://") == r.Host
+}
+
+// join registers a client in the room of its article and announces presence.
+func (h *liveHub) join(c *liveClient, articleID uint) {
+	h.once.Do(func() { go h.expireTyping() })
+
+	h.mu.Lock()
+	room, ok := h.rooms[articleID]
+	if !ok {
+		room = &liveRoom{articleID: articleID, clients: map[*liveClient]bool{}, typing: map[string]time.Time{}}
+		h.rooms[articleID] = room
+	}
+	c.room = room
+	room.clients[c] = true
+	h.broadcastPresenceLocked(room)
+	h.mu.Unlock()
+}
+
+// leave removes a client and closes its queue. Safe to call more than once.
+func (h *liveHub) leave(c *liveClient) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.removeLocked(c)
+}
+
+func (h *liveHub) removeLocked(c *liveClient) {
+	room := c.room
+	if room == nil || !room.clients[c] {
+		return
+	}
+	delete(room.clients, c)
+	close(c.send)
+	if !h.stillViewingLocked(room, c.user.Profile.Name) {
+		delete(room.typing, c.user.Profile.Name)
+	}
+	if len(room.clients) == 0 {
+		delete(h.rooms, room.articleID)
+		return
+	}
+	h.broadcastPresenceLocked(room)
+}
+
+// closeAll disconnects every viewer, on shutdown; their writers send a
+// close frame.
+func (h *liveHub) closeAll() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for _, room := range h.rooms {
+		for c := range room.clients {
+			h.removeLocked(c)
+		}
+	}
+}
+
+func (h *liveHub) stillViewingLocked(room *liveRoom, username string) bool {
+	for c := range room.clients {
+		if c.user.Profile.Name == username {
+			return true
+		}
+	}
+	return false
+}
+
+// publish pushes a comment event to every viewer of the article.
+func (h *liveHub) publish(articleID uint, kind string, comment models.Comment) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	room, ok := h.rooms[articleID]
+	if !ok {
+		return
+	}
+	h.broadcastLocked(room, liveMessage{kind: kind, comment: comment})
+	if kind == liveCommentCreated {
+		// posting a comment ends the author's typing indicator
+		for c := range room.clients {
+			if _, typing := room.typing[c.user.Profile.Name]; typing && c.user.ProfileID == comment.AuthorID {
+				delete(room.typing, c.user.Profile.Name)
+				h.broadcastPresenceLocked(room)
+				break
+			}
+		}
+	}
+}
+
+func (h *liveHub) typing(c *liveClient) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	room := c.room
+	_, wasTyping := room.typing[c.user.Profile.Name]
+	room.typing[c.user.Profile.Name] = time.Now().Add(liveTypingTimeout)
+	if !wasTyping {
+		h.broadcastPresenceLocked(room)
+	}
+}
+
+func (h *liveHub) expireTyping() {
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+	for now := range ticker.C {
+		h.mu.Lock()
+		for _, room := range h.rooms {
+			changed := false
+			for username, deadline := range room.typing {
+				if now.After(deadline) {
+					delete(room.typing, username)
+					changed = true
+				}
+			}
+			if changed {
+				h.broadcastPresenceLocked(room)
+			}
+		}
+		h.mu.Unlock()
+	}
+}
+
+func (h *liveHub) broadcastPresenceLocked(room *liveRoom) {
+	viewers := map[uint]bool{}
+	for c := range room.clients {
+		viewers[c.user.ID] = true
+	}
+	typing := []string{}
+	for username := range room.typing {
+		typing = append(typing, username)
+	}
+	sort.Strings(typing)
+	h.broadcastLocked(room, liveMessage{kind: livePresence, viewers: len(viewers), typing: typing})
+}
+
+func (h *liveHub) broadcastLocked(room *liveRoom, message liveMessage) {
+	for c := range room.clients {
+		select {
+		case c.send <- message:
+		default:
+			// slow consumer; drop it rather than buffering without bound
+			logging.FromContext(c.request.Context()).Warn("live: dropping slow client", "user", c.user.Profile.Name)
+			h.removeLocked(c)
+		}
+	}
+}
+
+// allow implements a per connection token bucket for client messages.
+func (c *liveClient) allow() bool {
+	now := time.Now()
+	c.tokens += now.Sub(c.lastRefill).Seconds() * liveRatePerSecond
+	if c.tokens > liveRateBurst {
+		c.tokens = liveRateBurst
+	}
+	c.lastRefill = now
+	if c.tokens < 1 {
+		return false
+	}
+	c.tokens--
+	return true
+}
+
+func (c *liveClient) readPump(hub *liveHub) {
+	defer func() {
+		hub.leave(c)
+		c.conn.Close()
+	}()
+	c.conn.SetReadLimit(liveMaxMessageSize)
+	c.conn.SetReadDeadline(time.Now().Add(livePongWait))
+	c.conn.SetPongHandler(func(string) error {
+		return c.conn.SetReadDeadline(time.Now().Add(livePongWait))
+	})
+	for {
+		var message liveClientMessage
+		if err := c.conn.ReadJSON(&message); err != nil {
+			if websocket.IsUnexpectedCloseError(err, websocket.CloseGoingAway, websocket.CloseNormalClosure) {
+				logging.FromContext(c.request.Context()).Warn("live: read failed", "error", err)
+			}
+			return
+		}
+		if !c.allow() {
+			closeMessage := websocket.FormatCloseMessage(websocket.ClosePolicyViolation, "rate limit exceeded")
+			c.conn.WriteControl(websocket.CloseMessage, closeMessage, time.Now().Add(liveWriteWait))
+			return
+		}
+		if message.Type == liveTyping {
+			hub.typing(c)
+		}
+	}
+}
+
+func (c *liveClient) writePump() {
+	ticker := time.NewTicker(livePingPeriod)
+	defer func() {
+		ticker.Stop()
+		c.conn.Close()
+	}()
+	db := database.GetDB()
+	for {
+		select {
+		case message, ok := <-c.send:
+			c.conn.SetWriteDeadline(time.Now().Add(liveWriteWait))
+			if !ok {
+				c.conn.WriteMessage(websocket.CloseMessage, []byte{})
+				return
+			}
+			var event interface{}
+			if message.kind == livePresence {
+				event = livePresenceEvent{Type: message.kind, Viewers: message.viewers, Typing: message.typing}
+			} else {
+				serializer := models.CommentSerializer{Comment: message.comment}
+				event = liveCommentEvent{Type: message.kind, Comment: serializer.Response(db, c.request)}
+			}
+			if err := c.conn.WriteJSON(event); err != nil {
+				return
+			}
+		case <-ticker.C:
+			c.conn.SetWriteDeadline(time.Now().Add(liveWriteWait))
+			if err := c.conn.WriteMessage(websocket.PingMessage, nil); err != nil {
+				return
+			}
+		}
+	}
+}
+
+// LiveComments upgrades to a WebSocket streaming comment events of an article.
+// Browsers cannot set the Authorization header on upgrades, so the JWT may
+// also be passed as the "token" query parameter.
+func LiveComments(w http.ResponseWriter, r *http.Request) {
+	userData, ok := r.Context().Value(utils.ContextKeyUserData).(models.User)
+	if !ok {
+		token := r.URL.Query().Get("token")
+		if token == "" {
+			writeResponse(w, r, map[string]interface{}{"errors": utils.CreateInvalidResponse("Authorization Header")}, http.StatusUnauthorized)
+			return
+		}
+		var err error
+		if userData, err = retrieveUserFromToken(r.Context(), token); err != nil {
+			writeResponse(w, r, map[string]interface{}{"errors": utils.CreateInvalidResponse(err.Error())}, http.StatusUnauthorized)
+			return
+		}
+		r = r.WithContext(contextWithUser(r.Context(), userData))
+	}
+
+	article, err := articleService.Get(r.Context(), mux.Vars(r)["slug"])
+	if err != nil {
+		writeServiceError(w, r, err, "Article")
+		return
+	}
+
+	conn, err := upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		// the upgrader already replied with an HTTP error
+		logInvalid(r, err)
+		return
+	}
+	client := &liveClient{
+		conn:       conn,
+		request:    r,
+		user:       userData,
+		send:       make(chan liveMessage, liveSendBuffer),
+		tokens:     liveRateBurst,
+		lastRefill: time.Now(),
+	}
+	live.join(client, article.ID)
+	go client.writePump()
+	client.readPump(live)
+}
@@ -0,0 +1,156 @@
+package app
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/hy00nc/conduit-go/internal/models"
+	"github.com/hy00nc/conduit-go/internal/service"
+	"github.com/hy00nc/conduit-go/internal/utils"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// liveThread is the live thread of an article by sally, served with
+// MakeWebHandler.
+type liveThread struct {
+	url, slug              string
+	harry                  models.User
+	sallyToken, harryToken string
+}
+
+func liveServer(t *testing.T) liveThread {
+	useTempDB(t)
+	sally, err := userService.Register(t.Context(), service.RegisterInput{Username: "sally", Email: "sally@example.com", Password: "secret"})
+	require.NoError(t, err)
+	harry, err := userService.Register(t.Context(), service.RegisterInput{Username: "harry", Email: "harry@example.com", Password: "secret"})
+	require.NoError(t, err)
+	article, err := articleService.Create(t.Context(), sally, service.ArticleInput{Title: "Live", Description: "d", Body: "b"})
+	require.NoError(t, err)
+	sallyToken, _ := utils.GetToken(sally.ID)
+	harryToken, _ := utils.GetToken(harry.ID)
+
+	server := httptest.NewServer(MakeWebHandler(false))
+	t.Cleanup(server.Close)
+	url := "ws" + strings.TrimPrefix(server.URL, "http") + "/api/articles/" + article.Slug + "/live"
+	return liveThread{url: url, slug: article.Slug, harry: harry, sallyToken: sallyToken, harryToken: harryToken}
+}
+
+func dialLive(t *testing.T, url, token string) *websocket.Conn {
+	conn, _, err := websocket.DefaultDialer.Dial(url+"?token="+token, nil)
+	require.NoError(t, err)
+	t.Cleanup(func() { conn.Close() })
+	return conn
+}
+
+// readLive reads the next event, failing the test if none comes.
+func readLive(t *testing.T, conn *websocket.Conn) map[string]interface{} {
+	conn.SetReadDeadline(time.Now().Add(3 * time.Second))
+	var event map[string]interface{}
+	require.NoError(t, conn.ReadJSON(&event))
+	return event
+}
+
+func TestLiveComments(t *testing.T) {
+	asserts := assert.New(t)
+	thread := liveServer(t)
+	url := thread.url
+
+	_, response, err := websocket.DefaultDialer.Dial(url, nil)
+	asserts.Error(err)
+	asserts.Equal(http.StatusUnauthorized, response.StatusCode, "a token is required")
+	_, response, err = websocket.DefaultDialer.Dial(url+"?token=garbage", nil)
+	asserts.Error(err)
+	asserts.Equal(http.StatusUnauthorized, response.StatusCode)
+
+	sallyConn := dialLive(t, url, thread.sallyToken)
+	asserts.Equal(map[string]interface{}{"type": "presence", "viewers": 1.0, "typing": []interface{}{}}, readLive(t, sallyConn))
+	harryConn := dialLive(t, url, thread.harryToken)
+	asserts.Equal(2.0, readLive(t, sallyConn)["viewers"])
+	asserts.Equal(2.0, readLive(t, harryConn)["viewers"])
+	secondTab := dialLive(t, url, thread.harryToken)
+	asserts.Equal(2.0, readLive(t, sallyConn)["viewers"], "viewers are counted once per user")
+	readLive(t, harryConn)
+	readLive(t, secondTab)
+	secondTab.Close()
+	asserts.Equal(2.0, readLive(t, sallyConn)["viewers"])
+	readLive(t, harryConn)
+
+	asserts.NoError(harryConn.WriteJSON(map[string]string{"type": "typing"}))
+	asserts.Equal([]interface{}{"harry"}, readLive(t, sallyConn)["typing"])
+	readLive(t, harryConn)
+
+	_, err = commentService.Add(t.Context(), thread.harry, thread.slug, "Hello")
+	asserts.NoError(err)
+	event := readLive(t, sallyConn)
+	asserts.Equal(liveCommentCreated, event["type"])
+	asserts.Equal("Hello", event["comment"].(map[string]interface{})["body"])
+	asserts.Equal("harry", event["comment"].(map[string]interface{})["author"].(map[string]interface{})["username"])
+	asserts.Equal([]interface{}{}, readLive(t, sallyConn)["typing"], "commenting ends typing")
+
+	harryConn.Close()
+	asserts.Equal(1.0, readLive(t, sallyConn)["viewers"])
+}
+
+func TestLiveTypingExpires(t *testing.T) {
+	asserts := assert.New(t)
+	previous := liveTypingTimeout
+	liveTypingTimeout = 100 * time.Millisecond
+	t.Cleanup(func() { liveTypingTimeout = previous })
+	thread := liveServer(t)
+
+	conn := dialLive(t, thread.url, thread.sallyToken)
+	readLive(t, conn)
+	asserts.NoError(conn.WriteJSON(map[string]string{"type": "typing"}))
+	asserts.Equal([]interface{}{"sally"}, readLive(t, conn)["typing"])
+	asserts.Equal([]interface{}{}, readLive(t, conn)["typing"], "typing expires without further messages")
+}
+
+func TestLiveRateLimit(t *testing.T) {
+	asserts := assert.New(t)
+	thread := liveServer(t)
+
+	conn := dialLive(t, thread.url, thread.sallyToken)
+	readLive(t, conn)
+	for i := 0; i <= liveRateBurst; i++ {
+		if conn.WriteJSON(map[string]string{"type": "ping"}) != nil {
+			break
+		}
+	}
+	conn.SetReadDeadline(time.Now().Add(3 * time.Second))
+	var err error
+	for err == nil {
+		_, _, err = conn.ReadMessage()
+	}
+	asserts.True(websocket.IsCloseError(err, websocket.ClosePolicyViolation), "clients over the rate are closed: %v", err)
+}
+
+func TestLiveDropsSlowClients(t *testing.T) {
+	asserts := assert.New(t)
+	hub := newLiveHub()
+	request := httptest.NewRequest("GET", "/", nil)
+	slow := &liveClient{request: request, user: models.User{Profile: models.Profile{Name: "slow"}}, send: make(chan liveMessage, 1)}
+	slow.user.ID = 1
+	fast := &liveClient{request: request, user: models.User{Profile: models.Profile{Name: "fast"}}, send: make(chan liveMessage, liveSendBuffer)}
+	fast.user.ID = 2
+
+	hub.join(slow, 1) // presence fills the queue of slow
+	hub.join(fast, 1)
+	_, open := <-slow.send
+	asserts.True(open)
+	_, open = <-slow.send
+	asserts.False(open, "the queue of a slow client is closed")
+
+	hub.publish(1, liveCommentCreated, models.Comment{Body: "Hello"})
+	kinds := []string{}
+	for len(fast.send) > 0 {
+		kinds = append(kinds, (<-fast.send).kind)
+	}
+	asserts.Equal([]string{livePresence, livePresence, liveCommentCreated}, kinds, "others keep receiving")
+	hub.leave(fast)
+	asserts.Empty(hub.rooms)
+}
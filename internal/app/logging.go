@@ -0,0 +1,184 @@
+package app
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"log/slog"
+	"net"
+	"net/http"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+	"github.com/hy00nc/conduit-go/internal/logging"
+)
+
+const requestIDHeader = "X-Request-ID"
+
+// Request IDs from clients are kept if they look like one, so they can be
+// followed across services.
+var validRequestID = regexp.MustCompile(`^[A-Za-z0-9._:-]{1,128}$`)
+
+// requestID returns the valid ID a client sent, or a new one.
+func requestID(sent string) string {
+	if validRequestID.MatchString(sent) {
+		return sent
+	}
+	return uuid.NewString()
+}
+
+// requestContext returns ctx with a logger for the request id.
+func requestContext(ctx context.Context, id string) context.Context {
+	return logging.NewContext(ctx, slog.Default().With("requestId", id))
+}
+
+// requestIDMiddleware gives every request an ID, echoed in the
+// X-Request-ID response header, and a logger that includes it.
+func requestIDMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := requestID(r.Header.Get(requestIDHeader))
+		w.Header().Set(requestIDHeader, id)
+		next.ServeHTTP(w, r.WithContext(requestContext(r.Context(), id)))
+	})
+}
+
+// accessInfo is filled in by routeMiddleware for the access log and the
+// metrics.
+type accessInfo struct {
+	path  string
+	route string // template, empty if no route matched
+}
+
+type accessInfoKey struct{}
+
+// withAccessInfo returns the accessInfo of r, adding one if there is none
+// yet.
+func withAccessInfo(r *http.Request) (*http.Request, *accessInfo) {
+	if info, ok := r.Context().Value(accessInfoKey{}).(*accessInfo); ok {
+		return r, info
+	}
+	info := &accessInfo{path: logging.URL(r.URL)}
+	return r.WithContext(context.WithValue(r.Context(), accessInfoKey{}, info)), info
+}
+
+// routeMiddleware adds the route template to the request's logger and
+// notes it, and the path with the secrets in it, such as feed tokens,
+// redacted.
+func routeMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		info, _ := r.Context().Value(accessInfoKey{}).(*accessInfo)
+		if route := mux.CurrentRoute(r); route != nil {
+			if template, err := route.GetPathTemplate(); err == nil {
+				logging.With(r.Context(), "route", template)
+				if info != nil {
+					info.route = template
+				}
+			}
+		}
+		if info != nil {
+			for name, value := range mux.Vars(r) {
+				if logging.Sensitive(name) {
+					info.path = strings.Replace(info.path, value, logging.Redacted, 1)
+				}
+			}
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// statusRecorder notes the status and size of a response.
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+	bytes  int
+}
+
+func (r *statusRecorder) WriteHeader(status int) {
+	if r.status == 0 {
+		r.status = status
+	}
+	r.ResponseWriter.WriteHeader(status)
+}
+
+func (r *statusRecorder) Write(b []byte) (int, error) {
+	if r.status == 0 {
+		r.status = http.StatusOK
+	}
+	n, err := r.ResponseWriter.Write(b)
+	r.bytes += n
+	return n, err
+}
+
+// Status is the status sent, http.StatusOK if the handler did not set one.
+func (r *statusRecorder) Status() int {
+	if r.status == 0 {
+		return http.StatusOK
+	}
+	return r.status
+}
+
+func (r *statusRecorder) Flush() {
+	if flusher, ok := r.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+// Hijack lets WebSocket upgrades through.
+func (r *statusRecorder) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	hijacker, ok := r.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, errors.New("hijacking is not supported")
+	}
+	r.status = http.StatusSwitchingProtocols
+	return hijacker.Hijack()
+}
+
+func (r *statusRecorder) Unwrap() http.ResponseWriter {
+	return r.ResponseWriter
+}
+
+// accessLogMiddleware logs every request once it is served, with its
+// headers at debug level.
+func accessLogMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		r, info := withAccessInfo(r)
+		recorder := &statusRecorder{ResponseWriter: w}
+		next.ServeHTTP(recorder, r)
+
+		level := slog.LevelInfo
+		if recorder.Status() >= http.StatusInternalServerError {
+			level = slog.LevelError
+		}
+		logger := logging.FromContext(r.Context())
+		if !logger.Enabled(r.Context(), level) {
+			return
+		}
+		attrs := []slog.Attr{
+			slog.String("method", r.Method),
+			slog.String("path", info.path),
+			slog.Int("status", recorder.Status()),
+			slog.Int("bytes", recorder.bytes),
+			slog.Duration("duration", time.Since(start)),
+			slog.String("remote", r.RemoteAddr),
+			slog.String("userAgent", r.UserAgent()),
+		}
+		if logger.Enabled(r.Context(), slog.LevelDebug) {
+			attrs = append(attrs, slog.Any("headers", logging.Headers(r.Header)))
+		}
+		logger.LogAttrs(r.Context(), level, "request", attrs...)
+	})
+}
+
+// logError logs a failure to serve r.
+func logError(r *http.Request, err error) {
+	logging.FromContext(r.Context()).Error("request failed", "error", err)
+}
+
+// logInvalid logs why r was rejected as invalid, for debugging clients.
+func logInvalid(r *http.Request, err error) {
+	logging.FromContext(r.Context()).Debug("invalid request", "error", err)
+}
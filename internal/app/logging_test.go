@@ -0,0 +1,104 @@
+package app
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/hy00nc/conduit-go/internal/cache"
+	"github.com/hy00nc/conduit-go/internal/database"
+	"github.com/hy00nc/conduit-go/internal/logging"
+	"github.com/hy00nc/conduit-go/internal/service"
+	"github.com/hy00nc/conduit-go/internal/utils"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// useTempDB makes the database package use a new database for the test.
+func useTempDB(t *testing.T) *gorm.DB {
+	db, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "app.db")), &gorm.Config{
+		Logger: logger.Default.LogMode(logger.Silent),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	database.MigrateDB(db)
+	clearCache()
+	previous := database.DB
+	database.DB = db
+	t.Cleanup(func() {
+		database.DB = previous
+		database.CloseDB(db)
+	})
+	return db
+}
+
+// clearCache forgets what appCache holds about the databases of other tests.
+func clearCache() {
+	if lru, ok := appCache.(*cache.LRU); ok {
+		lru.Clear()
+	}
+}
+
+func TestRequestLogging(t *testing.T) {
+	asserts := assert.New(t)
+	useTempDB(t)
+	previousLogger := slog.Default()
+	var logs bytes.Buffer
+	slog.SetDefault(logging.New(&logs, slog.LevelDebug))
+	t.Cleanup(func() { slog.SetDefault(previousLogger) })
+
+	user, err := userService.Register(t.Context(), service.RegisterInput{Username: "sally", Email: "sally@example.com", Password: "secret"})
+	asserts.NoError(err)
+	token, err := utils.GetToken(user.ID)
+	asserts.NoError(err)
+	handler := MakeWebHandler(true)
+
+	serve := func(path string, header http.Header) (*httptest.ResponseRecorder, map[string]interface{}) {
+		logs.Reset()
+		req := httptest.NewRequest("GET", path, nil)
+		for name, values := range header {
+			req.Header[name] = values
+		}
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+		lines := strings.Split(strings.TrimSpace(logs.String()), "\n")
+		var entry map[string]interface{}
+		asserts.NoError(json.Unmarshal([]byte(lines[len(lines)-1]), &entry))
+		return w, entry
+	}
+
+	w, entry := serve("/api/user", http.Header{"Authorization": {"Token " + token}, "X-Request-Id": {"client-id:42"}})
+	asserts.Equal(http.StatusOK, w.Code)
+	asserts.Equal("client-id:42", w.Header().Get("X-Request-ID"), "a valid request ID is kept")
+	asserts.Equal("request", entry["msg"])
+	asserts.Equal("client-id:42", entry["requestId"])
+	asserts.Equal("/api/user", entry["route"])
+	asserts.EqualValues(user.ID, entry["userId"])
+	asserts.EqualValues(http.StatusOK, entry["status"])
+	asserts.Equal(logging.Redacted, entry["headers"].(map[string]interface{})["Authorization"])
+	asserts.NotContains(logs.String(), token)
+
+	w, entry = serve("/feeds/private/s3cret.atom?limit=5&token=s3cret", http.Header{"X-Request-Id": {"not validé"}})
+	asserts.Equal(http.StatusNotFound, w.Code)
+	_, err = uuid.Parse(w.Header().Get("X-Request-ID"))
+	asserts.NoError(err, "an invalid request ID is replaced")
+	asserts.Equal(w.Header().Get("X-Request-ID"), entry["requestId"])
+	asserts.Equal("/feeds/private/{token}.atom", entry["route"])
+	asserts.Equal("/feeds/private/[REDACTED].atom?limit=5&token=%5BREDACTED%5D", entry["path"])
+	asserts.NotContains(logs.String(), "s3cret")
+
+	w, entry = serve("/no/such/page", nil)
+	asserts.Equal(http.StatusNotFound, w.Code)
+	asserts.NotEmpty(w.Header().Get("X-Request-ID"), "unrouted requests get an ID too")
+	asserts.Equal("/no/such/page", entry["path"])
+	asserts.NotContains(entry, "route")
+}
@@ -0,0 +1,22 @@
+package app
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/hy00nc/conduit-go/internal/metrics"
+)
+
+// metricsMiddleware counts and times requests by the route template
+// routeMiddleware notes.
+func metricsMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		metrics.HTTPInFlight.Inc()
+		defer metrics.HTTPInFlight.Dec()
+		start := time.Now()
+		r, info := withAccessInfo(r)
+		recorder := &statusRecorder{ResponseWriter: w}
+		next.ServeHTTP(recorder, r)
+		metrics.ObserveRequest(r.Method, info.route, recorder.Status(), recorder.bytes, time.Since(start))
+	})
+}
@@ -0,0 +1,28 @@
+package app
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMetricsEndpoint(t *testing.T) {
+	asserts := assert.New(t)
+	handler := MakeWebHandler(false)
+	for _, path := range []string{"/api/openapi.json", "/api/openapi.json", "/no/such/page"} {
+		handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", path, nil))
+	}
+
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest("GET", "/metrics", nil))
+	asserts.Equal(http.StatusOK, w.Code)
+	body := w.Body.String()
+	asserts.Contains(body, `conduit_http_requests_total{method="GET",route="/api/openapi.json",status="200"}`)
+	asserts.Contains(body, `conduit_http_requests_total{method="GET",route="unmatched",status="404"}`)
+	asserts.Contains(body, `conduit_http_request_duration_seconds_bucket{method="GET",route="/api/openapi.json",le="0.005"}`)
+	asserts.Contains(body, `conduit_http_response_size_bytes_count{method="GET",route="/api/openapi.json"} `)
+	asserts.Contains(body, "conduit_http_requests_in_flight 1", "the scrape itself is in flight")
+	asserts.NotContains(body, "/no/such/page", "paths are not labels")
+}
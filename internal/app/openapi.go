@@ -0,0 +1,57 @@
+package app
+
+import (
+	"crypto/sha256"
+	_ "embed"
+	"encoding/base64"
+	"net/http"
+)
+
+// openAPISpec documents every route registered in routers.go. The test in
+// openapi_test.go fails when a route or a response struct drifts from it.
+//
+//go:embed openapi.json
+var openAPISpec []byte
+
+// swaggerUIScript starts Swagger UI on the spec, inline in swaggerUIPage.
+const swaggerUIScript = `
+    window.onload = function () {
+      window.ui = SwaggerUIBundle({ url: "/api/openapi.json", dom_id: "#swagger-ui" });
+    };
+  `
+
+const swaggerUIPage = `<!DOCTYPE html>
+<html lang="en">
+<head>
+  <meta charset="utf-8">
+  <title>Conduit API</title>
+  <link rel="stylesheet" href="https://unpkg.com/swagger-ui-dist@5/swagger-ui.css">
+</head>
+<body>
+  <div id="swagger-ui"></div>
+  <script src="https://unpkg.com/swagger-ui-dist@5/swagger-ui-bundle.js" crossorigin></script>
+  <script>` + swaggerUIScript + `</script>
+</body>
+</html>
+`
+
+// swaggerUIPolicy lets the page load Swagger UI from unpkg, run its own
+// inline script and fetch the spec, and nothing else.
+var swaggerUIPolicy = "default-src 'none'; script-src https://unpkg.com '" + scriptHash(swaggerUIScript) + "'; style-src https://unpkg.com 'unsafe-inline'; img-src 'self' data:; connect-src 'self'; frame-ancestors 'none'"
+
+// scriptHash is the source of an inline script in a Content-Security-Policy.
+func scriptHash(script string) string {
+	sum := sha256.Sum256([]byte(script))
+	return "sha256-" + base64.StdEncoding.EncodeToString(sum[:])
+}
+
+func GetOpenAPI(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.Write(openAPISpec)
+}
+
+func GetSwaggerUI(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Content-Security-Policy", swaggerUIPolicy)
+	w.Write([]byte(swaggerUIPage))
+}
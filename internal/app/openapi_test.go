@@ -0,0 +1,198 @@
+package app
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/hy00nc/conduit-go/internal/markdown"
+	"github.com/hy00nc/conduit-go/internal/models"
+	"github.com/stretchr/testify/assert"
+)
+
+type openAPIDocument struct {
+	Paths      map[string]map[string]json.RawMessage `json:"paths"`
+	Components struct {
+		Schemas map[string]openAPISchema `json:"schemas"`
+	} `json:"components"`
+}
+
+type openAPISchema struct {
+	Ref        string                   `json:"$ref"`
+	Type       string                   `json:"type"`
+	Items      *openAPISchema           `json:"items"`
+	Properties map[string]openAPISchema `json:"properties"`
+	Required   []string                 `json:"required"`
+}
+
+var pathVariable = regexp.MustCompile(`\{([^}:]+)(:[^}]+)?\}`)
+
+func loadOpenAPI(t *testing.T) openAPIDocument {
+	var document openAPIDocument
+	if err := json.Unmarshal(openAPISpec, &document); err != nil {
+		t.Fatalf("openapi.json is not valid JSON: %v", err)
+	}
+	return document
+}
+
+// registeredOperations lists "method path" for every route, with mux
+// variable patterns reduced to OpenAPI style "{name}".
+func registeredOperations(t *testing.T) []string {
+	operations := []string{}
+	router := newRouter()
+	err := router.Walk(func(route *mux.Route, router *mux.Router, ancestors []*mux.Route) error {
+		template, err := route.GetPathTemplate()
+		if err != nil {
+			return nil
+		}
+		methods, err := route.GetMethods()
+		if err != nil {
+			return nil // subrouter
+		}
+		for _, method := range methods {
+			operations = append(operations, strings.ToLower(method)+" "+pathVariable.ReplaceAllString(template, "{$1}"))
+		}
+		return nil
+	})
+	assert.NoError(t, err)
+	return operations
+}
+
+func TestOpenAPICoversRoutes(t *testing.T) {
+	document := loadOpenAPI(t)
+	registered := map[string]bool{}
+	for _, operation := range registeredOperations(t) {
+		registered[operation] = true
+		fields := strings.SplitN(operation, " ", 2)
+		_, ok := document.Paths[fields[1]][fields[0]]
+		assert.True(t, ok, "route %q is missing from openapi.json", operation)
+	}
+	for path, item := range document.Paths {
+		for method := range item {
+			if method == "parameters" {
+				continue
+			}
+			assert.True(t, registered[method+" "+path], "openapi.json documents %q which is not registered", method+" "+path)
+		}
+	}
+}
+
+func jsonType(t reflect.Type) string {
+	switch t.Kind() {
+	case reflect.String:
+		return "string"
+	case reflect.Bool:
+		return "boolean"
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return "integer"
+	case reflect.Float32, reflect.Float64:
+		return "number"
+	case reflect.Slice, reflect.Array:
+		return "array"
+	}
+	return "object"
+}
+
+func resolveSchema(document openAPIDocument, schema openAPISchema) openAPISchema {
+	if schema.Ref != "" {
+		return document.Components.Schemas[strings.TrimPrefix(schema.Ref, "#/components/schemas/")]
+	}
+	return schema
+}
+
+// compareSchema checks that a struct's JSON fields, their types and which
+// of them are required match the named component schema.
+func compareSchema(t *testing.T, document openAPIDocument, name string, structType reflect.Type, isRequired func(reflect.StructField) bool) {
+	schema, ok := document.Components.Schemas[name]
+	if !assert.True(t, ok, "schema %s is missing", name) {
+		return
+	}
+	fields := []string{}
+	required := []string{}
+	for i := 0; i < structType.NumField(); i++ {
+		field := structType.Field(i)
+		tag := strings.Split(field.Tag.Get("json"), ",")
+		if tag[0] == "" || tag[0] == "-" {
+			continue
+		}
+		fields = append(fields, tag[0])
+		if isRequired(field) {
+			required = append(required, tag[0])
+		}
+
+		property, ok := schema.Properties[tag[0]]
+		if !assert.True(t, ok, "%s.%s is not in the schema", name, tag[0]) {
+			continue
+		}
+		property = resolveSchema(document, property)
+		assert.Equal(t, jsonType(field.Type), property.Type, "type of %s.%s", name, tag[0])
+		if field.Type.Kind() == reflect.Slice && property.Items != nil {
+			items := resolveSchema(document, *property.Items)
+			assert.Equal(t, jsonType(field.Type.Elem()), items.Type, "item type of %s.%s", name, tag[0])
+		}
+	}
+	properties := []string{}
+	for property := range schema.Properties {
+		properties = append(properties, property)
+	}
+	sort.Strings(fields)
+	sort.Strings(properties)
+	sort.Strings(required)
+	specRequired := append([]string{}, schema.Required...)
+	sort.Strings(specRequired)
+	assert.Equal(t, fields, properties, "properties of %s", name)
+	assert.Equal(t, fmt.Sprint(required), fmt.Sprint(specRequired), "required properties of %s", name)
+}
+
+func TestOpenAPISchemasMatchStructs(t *testing.T) {
+	document := loadOpenAPI(t)
+
+	// responses: everything not omitted when empty is required
+	notOmitted := func(field reflect.StructField) bool {
+		return !strings.Contains(field.Tag.Get("json"), "omitempty")
+	}
+	responses := map[string]interface{}{
+		"Profile":         models.ProfileResponse{},
+		"Article":         models.ArticleResponse{},
+		"Comment":         models.CommentResponse{},
+		"User":            models.UserResponse{},
+		"Upload":          models.UploadResponse{},
+		"Webhook":         models.WebhookResponse{},
+		"WebhookDelivery": models.WebhookDeliveryResponse{},
+		"TrashedArticle":  models.TrashedArticleResponse{},
+		"TrashedComment":  models.TrashedCommentResponse{},
+		"Trash":           models.TrashResponse{},
+		"ExportedAccount": models.ExportedAccountResponse{},
+		"ExportedArticle": models.ExportedArticleResponse{},
+		"ExportedComment": models.ExportedCommentResponse{},
+		"Export":          models.ExportResponse{},
+		"Heading":         markdown.Heading{},
+	}
+	for name, response := range responses {
+		compareSchema(t, document, name, reflect.TypeOf(response), notOmitted)
+	}
+
+	// requests: the validators' wrapped struct, required when validated so
+	validated := func(field reflect.StructField) bool {
+		return strings.Contains(field.Tag.Get("validate"), "required")
+	}
+	requests := map[string]interface{}{
+		"NewUser":       models.RegisterValidator{},
+		"LoginUser":     models.LoginValidator{},
+		"UpdateUser":    models.UserRequest{},
+		"NewArticle":    models.ArticleValidator{},
+		"UpdateArticle": models.ArticleRequest{},
+		"DeleteUser":    models.DeleteUserValidator{},
+		"NewComment":    models.CommentValidator{},
+		"NewWebhook":    models.WebhookValidator{},
+	}
+	for name, request := range requests {
+		compareSchema(t, document, name, reflect.TypeOf(request).Field(0).Type, validated)
+	}
+}
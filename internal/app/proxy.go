@@ -0,0 +1,109 @@
+package app
+
+import (
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/netip"
+	"os"
+	"strings"
+)
+
+// trustedProxies are the addresses of the reverse proxies in front of the
+// server, from CONDUIT_TRUSTED_PROXIES (e.g. "10.0.0.0/8,192.168.1.10").
+// Only their X-Forwarded-Proto and X-Forwarded-For are believed; anyone
+// else could claim HTTPS and have it written into absolute links, or any
+// address to get around rate limits.
+var trustedProxies = trustedProxiesFromEnv()
+
+func trustedProxiesFromEnv() []netip.Prefix {
+	proxies, err := parseProxies(os.Getenv("CONDUIT_TRUSTED_PROXIES"))
+	if err != nil {
+		slog.Error("CONDUIT_TRUSTED_PROXIES ignored", "error", err)
+		return nil
+	}
+	return proxies
+}
+
+// parseProxies parses a comma separated list of addresses and networks.
+func parseProxies(s string) ([]netip.Prefix, error) {
+	var proxies []netip.Prefix
+	for _, proxy := range splitList(s) {
+		if prefix, err := netip.ParsePrefix(proxy); err == nil {
+			proxies = append(proxies, prefix.Masked())
+			continue
+		}
+		addr, err := netip.ParseAddr(proxy)
+		if err != nil {
+			return nil, fmt.Errorf("proxy %q is neither an address nor a network", proxy)
+		}
+		proxies = append(proxies, netip.PrefixFrom(addr.Unmap(), addr.Unmap().BitLen()))
+	}
+	return proxies, nil
+}
+
+func trustedProxy(addr netip.Addr) bool {
+	for _, proxy := range trustedProxies {
+		if proxy.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+// remoteIP is the address r came from, which may be a proxy.
+func remoteIP(r *http.Request) (netip.Addr, error) {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return netip.Addr{}, err
+	}
+	addr, err := netip.ParseAddr(host)
+	return addr.Unmap(), err
+}
+
+// fromTrustedProxy reports whether r was forwarded by a trusted proxy.
+func fromTrustedProxy(r *http.Request) bool {
+	addr, err := remoteIP(r)
+	return err == nil && trustedProxy(addr)
+}
+
+// clientIP is the IP address of the client r was made by. Behind trusted
+// proxies it is the right-most X-Forwarded-For entry that is not itself a
+// trusted proxy; the entries left of it are sent by the client and could
+// be anything.
+func clientIP(r *http.Request) string {
+	addr, err := remoteIP(r)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	if !trustedProxy(addr) {
+		return addr.String()
+	}
+	var hops []string
+	for _, header := range r.Header.Values("X-Forwarded-For") {
+		hops = append(hops, strings.Split(header, ",")...)
+	}
+	for i := len(hops) - 1; i >= 0 && trustedProxy(addr); i-- {
+		hop, err := netip.ParseAddr(strings.TrimSpace(hops[i]))
+		if err != nil {
+			break // not added by a proxy
+		}
+		addr = hop.Unmap()
+	}
+	return addr.String()
+}
+
+// requestScheme is the scheme r was made with, by the client of the proxy
+// in front of the server if it is trusted.
+func requestScheme(r *http.Request) string {
+	if r.TLS != nil {
+		return "https"
+	}
+	if fromTrustedProxy(r) {
+		if proto := r.Header.Get("X-Forwarded-Proto"); proto == "http" || proto == "https" {
+			return proto
+		}
+	}
+	return "http"
+}
@@ -0,0 +1,115 @@
+package app
+
+import (
+	"context"
+	"log/slog"
+	"math"
+	"net"
+	"net/http"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/hy00nc/conduit-go/internal/logging"
+	"github.com/hy00nc/conduit-go/internal/metrics"
+	"github.com/hy00nc/conduit-go/internal/models"
+	"github.com/hy00nc/conduit-go/internal/ratelimit"
+	"github.com/hy00nc/conduit-go/internal/service"
+	"github.com/hy00nc/conduit-go/internal/utils"
+)
+
+// Rate limits of the routes that create content or check passwords.
+var defaultRatePolicies = []ratelimit.Policy{
+	{Name: "login", Limit: 10, Period: time.Minute},
+	{Name: "signup", Limit: 5, Period: time.Hour},
+	{Name: "articles", Limit: 20, Period: time.Hour},
+	{Name: "comments", Limit: 30, Period: 10 * time.Minute},
+}
+
+// ratePolicies are the default policies changed by CONDUIT_RATE_LIMITS,
+// e.g. "login=20/1m,comments=0/1m" (a limit of 0 turns a policy off).
+func ratePolicies() []ratelimit.Policy {
+	policies, err := ratelimit.ParsePolicies(os.Getenv("CONDUIT_RATE_LIMITS"), defaultRatePolicies...)
+	if err != nil {
+		slog.Error("CONDUIT_RATE_LIMITS ignored", "error", err)
+		return defaultRatePolicies
+	}
+	return policies
+}
+
+// ipKey identifies clients by the IP address of remoteAddr, a host:port.
+func ipKey(remoteAddr string) string {
+	host, _, err := net.SplitHostPort(remoteAddr)
+	if err != nil {
+		return "ip:" + remoteAddr
+	}
+	return "ip:" + host
+}
+
+// userKey identifies the signed in user of ctx, or anonymous clients as
+// anonymous.
+func userKey(ctx context.Context, anonymous string) string {
+	if userData, ok := ctx.Value(utils.ContextKeyUserData).(models.User); ok {
+		return "user:" + strconv.FormatUint(uint64(userData.ID), 10)
+	}
+	return anonymous
+}
+
+// byIP identifies clients by IP address, as forwarded by trusted proxies.
+func byIP(r *http.Request) string {
+	return "ip:" + clientIP(r)
+}
+
+// byUser identifies signed in clients by user, others by IP address.
+func byUser(r *http.Request) string {
+	return userKey(r.Context(), byIP(r))
+}
+
+func ceilSeconds(d time.Duration) int {
+	return max(1, int(math.Ceil(d.Seconds())))
+}
+
+// rateLimited serves handler under the named policy, per client as told
+// by identify. Responses carry the RateLimit headers of the policy, and
+// those over the limit are refused with 429 and Retry-After. Requests are
+// let through if the limiter fails.
+func rateLimited(policy string, identify func(*http.Request) string, handler http.HandlerFunc) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		result, err := rateLimiter.Allow(r.Context(), policy, identify(r))
+		if err != nil {
+			logError(r, err)
+			handler(w, r)
+			return
+		}
+		if result.Limit > 0 {
+			header := w.Header()
+			header.Set("RateLimit-Limit", strconv.Itoa(result.Limit))
+			header.Set("RateLimit-Remaining", strconv.Itoa(result.Remaining))
+			header.Set("RateLimit-Reset", strconv.Itoa(int(math.Ceil(result.Reset.Seconds()))))
+			header.Set("RateLimit-Policy", strconv.Itoa(result.Limit)+";w="+strconv.Itoa(ceilSeconds(rateLimiter.Policy(policy).Period)))
+		}
+		if !result.Allowed {
+			metrics.RateLimited.WithLabelValues(policy).Inc()
+			writeServiceError(w, r, service.Limited("requests", result.RetryAfter), "")
+			return
+		}
+		handler(w, r)
+	})
+}
+
+// allowRate counts a request of client under the named policy, for the
+// APIs without headers to report limits in, GraphQL and gRPC. Requests
+// over the limit get a Limited error; requests are let through if the
+// limiter fails.
+func allowRate(ctx context.Context, policy, client string) error {
+	result, err := rateLimiter.Allow(ctx, policy, client)
+	if err != nil {
+		logging.FromContext(ctx).Error("request failed", "error", err)
+		return nil
+	}
+	if !result.Allowed {
+		metrics.RateLimited.WithLabelValues(policy).Inc()
+		return service.Limited("requests", result.RetryAfter)
+	}
+	return nil
+}
@@ -0,0 +1,107 @@
+package app
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hy00nc/conduit-go/internal/metrics"
+	"github.com/hy00nc/conduit-go/internal/ratelimit"
+	"github.com/hy00nc/conduit-go/internal/service"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRateLimit(t *testing.T) {
+	asserts := assert.New(t)
+	useTempDB(t)
+	previous := rateLimiter
+	rateLimiter = ratelimit.NewLimiter(ratelimit.NewMemoryStore(), ratelimit.Policy{Name: "login", Limit: 2, Period: time.Minute})
+	t.Cleanup(func() { rateLimiter = previous })
+	handler := MakeWebHandler(false)
+	login := func(remoteAddr string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest("POST", "/api/users/login", strings.NewReader(`{"user":{"email":"nobody@example.com","password":"secret"}}`))
+		r.RemoteAddr = remoteAddr
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+		return w
+	}
+	limited := testutil.ToFloat64(metrics.RateLimited.WithLabelValues("login"))
+
+	w := login("10.0.0.1:1234")
+	asserts.Equal(http.StatusForbidden, w.Code)
+	asserts.Equal("2", w.Header().Get("RateLimit-Limit"))
+	asserts.Equal("1", w.Header().Get("RateLimit-Remaining"))
+	asserts.Equal("30", w.Header().Get("RateLimit-Reset"))
+	asserts.Equal("2;w=60", w.Header().Get("RateLimit-Policy"))
+	asserts.Empty(w.Header().Get("Retry-After"))
+
+	login("10.0.0.1:1235")
+	w = login("10.0.0.1:1236")
+	asserts.Equal(http.StatusTooManyRequests, w.Code, "clients are identified by IP, whatever their port")
+	asserts.Equal("0", w.Header().Get("RateLimit-Remaining"))
+	asserts.Equal("30", w.Header().Get("Retry-After"))
+	asserts.JSONEq(`{"errors":{"requests":"is invalid"}}`, w.Body.String())
+	asserts.Equal(limited+1, testutil.ToFloat64(metrics.RateLimited.WithLabelValues("login")))
+
+	w = login("10.0.0.2:1234")
+	asserts.Equal(http.StatusForbidden, w.Code, "other clients are not limited")
+
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest("GET", "/api/tags", nil))
+	asserts.Empty(w.Header().Get("RateLimit-Limit"), "routes without a policy carry no headers")
+}
+
+func TestRateLimitBehindProxy(t *testing.T) {
+	asserts := assert.New(t)
+	useTempDB(t)
+	useRateLimits(t, ratelimit.Policy{Name: "login", Limit: 1, Period: time.Minute})
+	trustedProxies, _ = parseProxies("192.0.2.0/24")
+	t.Cleanup(func() { trustedProxies = nil })
+	handler := MakeWebHandler(false)
+	login := func(remoteAddr string, forwardedFor ...string) int {
+		r := httptest.NewRequest("POST", "/api/users/login", strings.NewReader(`{"user":{"email":"proxied@example.com","password":"secret"}}`))
+		r.RemoteAddr = remoteAddr
+		for _, header := range forwardedFor {
+			r.Header.Add("X-Forwarded-For", header)
+		}
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+		return w.Code
+	}
+
+	asserts.Equal(http.StatusForbidden, login("192.0.2.1:1234", "203.0.113.1"))
+	asserts.Equal(http.StatusForbidden, login("192.0.2.1:1234", "203.0.113.2"), "clients behind the proxy are limited apart")
+	asserts.Equal(http.StatusTooManyRequests, login("192.0.2.2:1234", "203.0.113.1"))
+	asserts.Equal(http.StatusTooManyRequests, login("192.0.2.1:1234", "198.51.100.9, 203.0.113.1"), "entries left of the proxy's are the client's say")
+	asserts.Equal(http.StatusTooManyRequests, login("192.0.2.1:1234", "203.0.113.1, 192.0.2.7"), "chained proxies are skipped")
+	asserts.Equal(http.StatusTooManyRequests, login("192.0.2.1:1234", "198.51.100.9", "203.0.113.1"), "headers are read in order")
+
+	asserts.Equal(http.StatusForbidden, login("198.51.100.1:1234", "203.0.113.3"))
+	asserts.Equal(http.StatusTooManyRequests, login("198.51.100.1:1234", "203.0.113.4"), "only trusted proxies may forward addresses")
+}
+
+// useRateLimits replaces the rate limits for the test.
+func useRateLimits(t *testing.T, policies ...ratelimit.Policy) {
+	previous := rateLimiter
+	rateLimiter = ratelimit.NewLimiter(ratelimit.NewMemoryStore(), policies...)
+	t.Cleanup(func() { rateLimiter = previous })
+}
+
+func TestGraphQLRateLimit(t *testing.T) {
+	asserts := assert.New(t)
+	useTempDB(t)
+	useRateLimits(t, ratelimit.Policy{Name: "login", Limit: 2, Period: time.Minute})
+	handler := MakeWebHandler(false)
+	login := `mutation{login(user:{email:"nobody@example.com",password:"secret"}){username}}`
+
+	for i := 0; i < 2; i++ {
+		w := postGraphQL(handler, "", login)
+		asserts.Equal(http.StatusOK, w.Code)
+		asserts.NotContains(w.Body.String(), "requests")
+	}
+	w := postGraphQL(handler, "", login)
+	asserts.Contains(w.Body.String(), `"message":"requests `+service.ErrLimited.Error()+`"`, "the login mutation is limited like its REST route")
+}
@@ -2,37 +2,24 @@ package app
 
 import (
 	"context"
-	"encoding/json"
+	"errors"
 	"net/http"
-	"os"
 	"regexp"
 	"strings"
 
 	"github.com/golang-jwt/jwt/v5"
-	"github.com/gorilla/handlers"
 	"github.com/gorilla/mux"
+	"github.com/hy00nc/conduit-go/internal/cache"
 	"github.com/hy00nc/conduit-go/internal/database"
+	"github.com/hy00nc/conduit-go/internal/logging"
+	"github.com/hy00nc/conduit-go/internal/metrics"
 	"github.com/hy00nc/conduit-go/internal/models"
 	"github.com/hy00nc/conduit-go/internal/utils"
 	"gorm.io/gorm/clause"
 )
 
-func loggingMiddleware(next http.Handler) http.Handler {
-	return handlers.LoggingHandler(os.Stdout, next)
-}
-
-func ignoreOptionsMiddleware(next http.Handler) http.Handler {
-	// Handling OPTIONS.... why is it not supported by IgnoreOptions in CORS? why should it return nil??
-	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		if r.Method == "OPTIONS" {
-			return
-		}
-		next.ServeHTTP(w, r)
-	})
-}
-
 func matchAuthOptionalRoutes(url string) (matched bool, err error) {
-	regexExp := [...]string{"/api/articles", "/api/profiles/([a-zA-z]+$)"}
+	regexExp := [...]string{"/api/articles", "/api/profiles/([a-zA-z]+$)", "^/graphql$"}
 	for i := 0; i < len(regexExp); i++ {
 		if matched, err = regexp.MatchString(regexExp[i], url); matched {
 			return
@@ -41,6 +28,44 @@ func matchAuthOptionalRoutes(url string) (matched bool, err error) {
 	return false, nil
 }
 
+var errInvalidToken = errors.New("JWT Token")
+var errInvalidUser = errors.New("User data")
+
+// retrieveUserFromToken validates a JWT and loads the user it was issued for,
+// cached for userTTL without its password hash; the tokens of suspended
+// users are rejected. The returned error text doubles as the key of the
+// "is invalid" response.
+func retrieveUserFromToken(ctx context.Context, tokenString string) (models.User, error) {
+	claims, err := utils.CheckToken(tokenString)
+	if err != nil {
+		return models.User{}, errInvalidToken
+	}
+	userId, ok := claims.(jwt.MapClaims)["id"].(float64)
+	if !ok {
+		return models.User{}, errInvalidToken
+	}
+	id := uint(userId)
+
+	userData, err := cache.Fetch(ctx, appCache, "users", cache.UserKey(id), userTTL, func(ctx context.Context) (models.User, error) {
+		var userData models.User
+		database.GetDB().WithContext(ctx).Model(&userData).Preload(clause.Associations).First(&userData, id)
+		if userData.ID == 0 {
+			return userData, errInvalidUser
+		}
+		// the services checking passwords load the hash themselves
+		userData.Hash = ""
+		return userData, nil
+	})
+	if err != nil || userData.Suspended() {
+		return userData, errInvalidUser
+	}
+	return userData, nil
+}
+
+func contextWithUser(ctx context.Context, userData models.User) context.Context {
+	return context.WithValue(ctx, utils.ContextKeyUserData, userData)
+}
+
 func jwtMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		tokenString := r.Header.Get("Authorization")
@@ -51,39 +76,23 @@ func jwtMiddleware(next http.Handler) http.Handler {
 				next.ServeHTTP(w, r)
 				return
 			}
-			writeResponse(w, map[string]interface{}{"errors": utils.CreateInvalidResponse("Authorization Header")}, http.StatusUnauthorized)
+			writeResponse(w, r, map[string]interface{}{"errors": utils.CreateInvalidResponse("Authorization Header")}, http.StatusUnauthorized)
 			return
 		}
 		tokenString = strings.Replace(tokenString, "Token ", "", 1)
-		claims, err := utils.CheckToken(tokenString)
+		userData, err := retrieveUserFromToken(r.Context(), tokenString)
 		if err != nil {
-			writeResponse(w, map[string]interface{}{"errors": utils.CreateInvalidResponse("JWT Token")}, http.StatusUnauthorized)
-			return
-		}
-		userId := claims.(jwt.MapClaims)["id"]
-
-		var userData models.User
-		db := database.GetDB()
-		db.Model(&userData).Preload(clause.Associations).First(&userData, userId)
-
-		if userData.ID == 0 {
-			writeResponse(w, map[string]interface{}{"errors": utils.CreateInvalidResponse("User data")}, http.StatusUnauthorized)
+			writeResponse(w, r, map[string]interface{}{"errors": utils.CreateInvalidResponse(err.Error())}, http.StatusUnauthorized)
 			return
 		}
 
 		// Update context
-		ctx := context.WithValue(r.Context(), utils.ContextKeyUserData, userData)
-		r = r.WithContext(ctx)
+		logging.With(r.Context(), "userId", userData.ID)
+		r = r.WithContext(contextWithUser(r.Context(), userData))
 		next.ServeHTTP(w, r)
 	})
 }
 
-func writeResponse(w http.ResponseWriter, data map[string]interface{}, statusCode int) {
-	w.Header().Set("Content-Type", "application/json; charset=utf-8")
-	w.WriteHeader(statusCode)
-	json.NewEncoder(w).Encode(data)
-}
-
 func RegisterArticles(router *mux.Router) {
 	router.HandleFunc("/{slug}", GetArticle).Methods("GET")
 	router.HandleFunc("/{slug}/comments", GetComments).Methods("GET")
@@ -93,11 +102,15 @@ func RegisterArticlesAuthenticated(router *mux.Router) {
 	// with authentication
 	router.Use(jwtMiddleware)
 	router.HandleFunc("", GetArticles).Methods("GET")
-	router.HandleFunc("", CreateArticle).Methods("POST")
+	router.Handle("", rateLimited("articles", byUser, CreateArticle)).Methods("POST")
 	router.HandleFunc("/feed", GetFeed).Methods("GET")
 	router.HandleFunc("/{slug}", ArticleSlugEndpointAuthenticated).Methods("PUT", "DELETE")
-	router.HandleFunc("/{slug}/comments", AddComments).Methods("POST")
+	router.HandleFunc("/{slug}/restore", RestoreArticle).Methods("POST")
+	router.Handle("/{slug}/comments", rateLimited("comments", byUser, AddComments)).Methods("POST")
+	router.HandleFunc("/{slug}/comments/{id}", UpdateComment).Methods("PUT")
 	router.HandleFunc("/{slug}/comments/{id}", DeleteComment).Methods("DELETE")
+	router.HandleFunc("/{slug}/comments/{id}/restore", RestoreComment).Methods("POST")
+	router.HandleFunc("/{slug}/live", LiveComments).Methods("GET")
 	router.HandleFunc("/{slug}/favorite", FavoriteArticleEndpoint).Methods("POST", "DELETE")
 }
 
@@ -107,14 +120,20 @@ func RegisterTags(router *mux.Router) {
 
 func RegisterUsers(router *mux.Router) {
 	// without authentication
-	router.HandleFunc("", CreateUser).Methods("POST")
-	router.HandleFunc("/login", LoginUser).Methods("POST")
+	router.Handle("", rateLimited("signup", byIP, CreateUser)).Methods("POST")
+	router.Handle("/login", rateLimited("login", byIP, LoginUser)).Methods("POST")
 }
 
 func RegisterUser(router *mux.Router) {
 	router.Use(jwtMiddleware)
 	router.HandleFunc("", GetUser).Methods("GET")
 	router.HandleFunc("", UpdateUser).Methods("PUT")
+	router.HandleFunc("", DeleteUser).Methods("DELETE")
+	router.HandleFunc("/export", ExportUser).Methods("GET")
+	router.HandleFunc("/feed-token", GetFeedToken).Methods("GET")
+	router.HandleFunc("/feed-token", CreateFeedToken).Methods("POST")
+	router.HandleFunc("/feed-token", RevokeFeedToken).Methods("DELETE")
+	router.HandleFunc("/trash", GetTrash).Methods("GET")
 }
 
 func RegisterProfiles(router *mux.Router) {
@@ -123,9 +142,48 @@ func RegisterProfiles(router *mux.Router) {
 	router.HandleFunc("/{username}/follow", FollowUserEndpoint).Methods("POST", "DELETE")
 }
 
+func RegisterWebhooks(router *mux.Router) {
+	router.Use(jwtMiddleware)
+	router.HandleFunc("", GetWebhooks).Methods("GET")
+	router.HandleFunc("", CreateWebhook).Methods("POST")
+	router.HandleFunc("/{id}", DeleteWebhook).Methods("DELETE")
+	router.HandleFunc("/{id}/deliveries", GetWebhookDeliveries).Methods("GET")
+	router.HandleFunc("/{id}/deliveries/{deliveryId}/redeliver", RedeliverWebhook).Methods("POST")
+}
+
+func RegisterUploads(router *mux.Router) {
+	router.Use(jwtMiddleware)
+	router.HandleFunc("", CreateUpload).Methods("POST")
+}
+
+func RegisterFeeds(router *mux.Router) {
+	router.HandleFunc("/articles.{format:atom|rss}", GetArticlesFeed).Methods("GET", "HEAD")
+	router.HandleFunc("/authors/{username}.atom", GetAuthorFeed).Methods("GET", "HEAD")
+	router.HandleFunc("/tags/{tag}.atom", GetTagFeed).Methods("GET", "HEAD")
+	router.HandleFunc("/private/{token}.atom", GetPrivateFeed).Methods("GET", "HEAD")
+}
+
+func RegisterGraphQL(router *mux.Router) {
+	// authentication is optional, resolvers check it where needed
+	router.Use(jwtMiddleware)
+	router.HandleFunc("", GraphQLEndpoint).Methods("GET", "POST")
+}
+
+// MakeWebHandler returns the handler of the HTTP API; log turns on access
+// logs.
 func MakeWebHandler(log bool) http.Handler {
+	router := newRouter()
+	handler := metricsMiddleware(tracingMiddleware(securityHeadersMiddleware(corsMiddleware(router)(compressMiddleware(router)))))
+	if log {
+		handler = accessLogMiddleware(handler)
+	}
+	return requestIDMiddleware(handler)
+}
+
+func newRouter() *mux.Router {
 	// Create new router
-	router := mux.NewRouter().PathPrefix("/api").Subrouter()
+	root := mux.NewRouter()
+	router := root.PathPrefix("/api").Subrouter()
 
 	RegisterArticlesAuthenticated(router.PathPrefix("/articles").Subrouter())
 	RegisterArticles(router.PathPrefix("/articles").Subrouter())
@@ -133,15 +191,23 @@ func MakeWebHandler(log bool) http.Handler {
 	RegisterUsers(router.PathPrefix("/users").Subrouter())
 	RegisterUser(router.PathPrefix("/user").Subrouter())
 	RegisterProfiles(router.PathPrefix("/profiles").Subrouter())
+	RegisterWebhooks(router.PathPrefix("/webhooks").Subrouter())
+	RegisterUploads(router.PathPrefix("/uploads").Subrouter())
+	RegisterFeeds(root.PathPrefix("/feeds").Subrouter())
+	root.HandleFunc("/uploads/{key:.+}", ServeUpload).Methods("GET", "HEAD")
+	RegisterGraphQL(root.PathPrefix("/graphql").Subrouter())
+
+	// Monitoring
+	root.Handle("/metrics", metrics.Handler()).Methods("GET")
+	root.HandleFunc("/healthz", GetHealth).Methods("GET", "HEAD")
+	root.HandleFunc("/readyz", GetReadiness).Methods("GET", "HEAD")
 
-	// TODO: Add Swagger?
-	// router.PathPrefix("/swagger").Hander(httpSwagger.WrapHandler)
+	// API documentation
+	router.HandleFunc("/openapi.json", GetOpenAPI).Methods("GET")
+	router.HandleFunc("/docs", GetSwaggerUI).Methods("GET")
 
 	// Add middleware
-	if log {
-		router.Use(loggingMiddleware)
-	}
-	router.Use(ignoreOptionsMiddleware)
+	root.Use(routeMiddleware)
 
-	return router
+	return root
 }
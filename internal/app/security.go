@@ -0,0 +1,52 @@
+package app
+
+import (
+	"log/slog"
+	"net/http"
+	"os"
+	"strconv"
+	"time"
+)
+
+// apiContentSecurityPolicy keeps browsers from running or framing anything
+// the API returns, such as an uploaded SVG; HTML pages set a policy of
+// their own.
+const apiContentSecurityPolicy = "default-src 'none'; frame-ancestors 'none'"
+
+// defaultHSTSMaxAge is how long browsers are told to use HTTPS only,
+// changed with CONDUIT_HSTS_MAX_AGE.
+const defaultHSTSMaxAge = 365 * 24 * time.Hour
+
+var hstsMaxAge = hstsMaxAgeFromEnv()
+
+// hstsMaxAgeFromEnv is defaultHSTSMaxAge or CONDUIT_HSTS_MAX_AGE (e.g.
+// "720h"), where 0 turns HSTS off.
+func hstsMaxAgeFromEnv() time.Duration {
+	value := os.Getenv("CONDUIT_HSTS_MAX_AGE")
+	if value == "" {
+		return defaultHSTSMaxAge
+	}
+	maxAge, err := time.ParseDuration(value)
+	if err != nil || maxAge < 0 {
+		slog.Error("CONDUIT_HSTS_MAX_AGE ignored", "value", value)
+		return defaultHSTSMaxAge
+	}
+	return maxAge
+}
+
+// securityHeadersMiddleware sets the headers hardening responses in
+// browsers. Handlers may override them.
+func securityHeadersMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		header := w.Header()
+		header.Set("X-Content-Type-Options", "nosniff")
+		header.Set("Referrer-Policy", "no-referrer")
+		header.Set("X-Frame-Options", "DENY")
+		header.Set("Content-Security-Policy", apiContentSecurityPolicy)
+		// browsers ignore HSTS over plain HTTP
+		if hstsMaxAge > 0 && requestScheme(r) == "https" {
+			header.Set("Strict-Transport-Security", "max-age="+strconv.Itoa(int(hstsMaxAge.Seconds()))+"; includeSubDomains")
+		}
+		next.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,42 @@
+package app
+
+import (
+	"net/http/httptest"
+	"regexp"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSecurityHeaders(t *testing.T) {
+	asserts := assert.New(t)
+	useTempDB(t)
+	handler := MakeWebHandler(false)
+
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest("GET", "/api/tags", nil))
+	asserts.Equal("nosniff", w.Header().Get("X-Content-Type-Options"))
+	asserts.Equal("no-referrer", w.Header().Get("Referrer-Policy"))
+	asserts.Equal("DENY", w.Header().Get("X-Frame-Options"))
+	asserts.Equal(apiContentSecurityPolicy, w.Header().Get("Content-Security-Policy"))
+	asserts.Empty(w.Header().Get("Strict-Transport-Security"), "not over plain HTTP")
+
+	req := httptest.NewRequest("GET", "/api/tags", nil)
+	req.Header.Set("X-Forwarded-Proto", "https")
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+	asserts.Empty(w.Header().Get("Strict-Transport-Security"), "only trusted proxies tell the scheme")
+	trustedProxies, _ = parseProxies("192.0.2.0/24")
+	t.Cleanup(func() { trustedProxies = nil })
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+	asserts.Equal("max-age=31536000; includeSubDomains", w.Header().Get("Strict-Transport-Security"))
+
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest("GET", "/api/docs", nil))
+	policy := w.Header().Get("Content-Security-Policy")
+	asserts.Contains(policy, "script-src https://unpkg.com")
+	scripts := regexp.MustCompile(`(?s)<script>(.*?)</script>`).FindAllStringSubmatch(w.Body.String(), -1)
+	asserts.Len(scripts, 1)
+	asserts.Contains(policy, "'"+scriptHash(scripts[0][1])+"'", "the inline script of the page may run")
+}
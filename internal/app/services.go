@@ -0,0 +1,144 @@
+package app
+
+import (
+	"errors"
+	"net/http"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/hy00nc/conduit-go/internal/cache"
+	"github.com/hy00nc/conduit-go/internal/logging"
+	"github.com/hy00nc/conduit-go/internal/models"
+	"github.com/hy00nc/conduit-go/internal/ratelimit"
+	"github.com/hy00nc/conduit-go/internal/service"
+	"github.com/hy00nc/conduit-go/internal/utils"
+)
+
+// The services behind the REST, GraphQL and gRPC transports.
+var (
+	store          = service.GormStore{}
+	userService    = &service.UserService{Store: store, ImageURL: profileImageURL, Lockout: ratelimit.NewLockout(rateStore), Cache: appCache}
+	profileService = service.NewProfileService(store)
+	articleService = &service.ArticleService{Store: store, Notifier: liveNotifier{}, Cache: appCache}
+	commentService = service.NewCommentService(store, liveNotifier{})
+	trashService   = service.NewTrashService(store, liveNotifier{}, trashRetention())
+	accountService = &service.AccountService{Store: store, Policy: deletionPolicy(), DeleteUpload: deleteUploadFiles, Cache: appCache}
+	webhookService = service.NewWebhookService(store)
+)
+
+// appCache caches hot reads in the process.
+var appCache = newCache()
+
+// How long cached users and article responses are served. Changes made
+// through the API invalidate them at once, those of the admin CLI show
+// once they expire.
+const (
+	userTTL    = 30 * time.Second
+	articleTTL = 5 * time.Minute
+)
+
+// newCache returns an LRU of CONDUIT_CACHE_SIZE values, 10000 by default;
+// 0 turns caching off.
+func newCache() cache.Cache {
+	size, err := strconv.Atoi(os.Getenv("CONDUIT_CACHE_SIZE"))
+	if err != nil || size < 0 {
+		size = 10000
+	}
+	if size == 0 {
+		return nil
+	}
+	return cache.NewLRU(size)
+}
+
+// Rate limits and login lockouts, kept in the process.
+var (
+	rateStore   = ratelimit.NewMemoryStore()
+	rateLimiter = ratelimit.NewLimiter(rateStore, ratePolicies()...)
+)
+
+// trashRetention is how long deleted articles and comments can be restored,
+// from CONDUIT_TRASH_RETENTION (e.g. "720h").
+func trashRetention() time.Duration {
+	retention, err := time.ParseDuration(os.Getenv("CONDUIT_TRASH_RETENTION"))
+	if err != nil || retention <= 0 {
+		return service.DefaultRetention
+	}
+	return retention
+}
+
+// deletionPolicy is what happens to the articles and comments of deleted
+// accounts, from CONDUIT_ACCOUNT_DELETION: "anonymize" (the default) or
+// "remove".
+func deletionPolicy() service.DeletionPolicy {
+	if os.Getenv("CONDUIT_ACCOUNT_DELETION") == string(service.RemoveContent) {
+		return service.RemoveContent
+	}
+	return service.AnonymizeContent
+}
+
+var defaultImage = service.DefaultImage
+
+func profileImageURL(upload models.Upload) string {
+	return mediaStorage.URL(upload.ObjectKey("medium"))
+}
+
+// liveNotifier pushes committed changes to the article stream and the live
+// comment hub.
+type liveNotifier struct{}
+
+func (liveNotifier) ArticleCreated(article models.Article) {
+	newArticles.publish(article)
+}
+
+func (liveNotifier) CommentChanged(event string, comment models.Comment) {
+	live.publish(comment.ArticleID, event, comment)
+}
+
+// serviceStatus maps a domain error to its HTTP status.
+func serviceStatus(err error) int {
+	switch {
+	case errors.Is(err, service.ErrValidation):
+		return http.StatusBadRequest
+	case errors.Is(err, service.ErrNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, service.ErrForbidden):
+		return http.StatusForbidden
+	case errors.Is(err, service.ErrConflict):
+		return http.StatusConflict
+	case errors.Is(err, service.ErrLimited):
+		return http.StatusTooManyRequests
+	case errors.Is(err, service.ErrPrecondition):
+		return http.StatusPreconditionFailed
+	case errors.Is(err, errTooLarge):
+		return http.StatusRequestEntityTooLarge
+	case errors.Is(err, errUnsupportedContent):
+		return http.StatusUnsupportedMediaType
+	}
+	return http.StatusInternalServerError
+}
+
+// writeServiceError writes err in the errors envelope keyed by the field it
+// is about. Errors that are not domain errors are logged and reported under
+// fallback.
+func writeServiceError(w http.ResponseWriter, r *http.Request, err error, fallback string) {
+	status := serviceStatus(err)
+	field := service.Field(err)
+	if status == http.StatusInternalServerError || field == "" {
+		logging.FromContext(r.Context()).Error("request failed", "error", err)
+		field = fallback
+	}
+	if retryAfter := service.RetryAfter(err); retryAfter > 0 {
+		w.Header().Set("Retry-After", strconv.Itoa(ceilSeconds(retryAfter)))
+	}
+	writeResponse(w, r, map[string]interface{}{"errors": utils.CreateInvalidResponse(field)}, status)
+}
+
+// queryInt reads an integer query parameter; absent or malformed values are 0.
+func queryInt(r *http.Request, name string) int {
+	value, err := strconv.Atoi(r.URL.Query().Get(name))
+	if err != nil {
+		return 0
+	}
+	return value
+}
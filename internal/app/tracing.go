@@ -0,0 +1,53 @@
+package app
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/hy00nc/conduit-go/internal/logging"
+	"github.com/hy00nc/conduit-go/internal/utils"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	semconv "go.opentelemetry.io/otel/semconv/v1.37.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+var tracer = otel.Tracer(utils.InstrumentationName)
+
+// tracingMiddleware serves every request in a span, continuing the trace
+// of its traceparent header if it has one. The span is named by the route
+// template routeMiddleware notes, and its trace ID is logged with the
+// request.
+func tracingMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := otel.GetTextMapPropagator().Extract(r.Context(), propagation.HeaderCarrier(r.Header))
+		ctx, span := tracer.Start(ctx, r.Method, trace.WithSpanKind(trace.SpanKindServer), trace.WithAttributes(
+			semconv.HTTPRequestMethodKey.String(r.Method),
+			semconv.ClientAddress(r.RemoteAddr),
+			semconv.UserAgentOriginal(r.UserAgent()),
+		))
+		defer span.End()
+		if span.SpanContext().IsValid() {
+			logging.With(ctx, "traceId", span.SpanContext().TraceID().String())
+		}
+
+		r, info := withAccessInfo(r.WithContext(ctx))
+		recorder := &statusRecorder{ResponseWriter: w}
+		next.ServeHTTP(recorder, r)
+
+		if info.route != "" {
+			span.SetName(r.Method + " " + info.route)
+			span.SetAttributes(semconv.HTTPRoute(info.route))
+		}
+		// with the secrets in it redacted
+		path, query, _ := strings.Cut(info.path, "?")
+		span.SetAttributes(semconv.URLPath(path), semconv.HTTPResponseStatusCode(recorder.Status()))
+		if query != "" {
+			span.SetAttributes(semconv.URLQuery(query))
+		}
+		if recorder.Status() >= http.StatusInternalServerError {
+			span.SetStatus(codes.Error, http.StatusText(recorder.Status()))
+		}
+	})
+}
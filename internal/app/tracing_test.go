@@ -0,0 +1,66 @@
+package app
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/hy00nc/conduit-go/internal/service"
+	"github.com/hy00nc/conduit-go/internal/tracing"
+	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func TestRequestTracing(t *testing.T) {
+	asserts := assert.New(t)
+	db := useTempDB(t)
+	user, err := userService.Register(t.Context(), service.RegisterInput{Username: "sally", Email: "sally@example.com", Password: "secret"})
+	asserts.NoError(err)
+	_, err = articleService.Create(t.Context(), user, service.ArticleInput{Title: "Tracing", Description: "Spans", Body: "Where the time goes", TagList: []string{"go"}})
+	asserts.NoError(err)
+
+	recorder := tracetest.NewSpanRecorder()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	asserts.NoError(tracing.InstrumentDB(db))
+
+	const traceID, parentID = "4bf92f3577b34da6a3ce929d0e0e4736", "00f067aa0ba902b7"
+	req := httptest.NewRequest("GET", "/api/articles?tag=go", nil)
+	req.Header.Set("traceparent", "00-"+traceID+"-"+parentID+"-01")
+	w := httptest.NewRecorder()
+	MakeWebHandler(false).ServeHTTP(w, req)
+	asserts.Equal(http.StatusOK, w.Code)
+
+	spans := map[string]sdktrace.ReadOnlySpan{}
+	children := map[trace.SpanID][]string{}
+	for _, span := range recorder.Ended() {
+		asserts.Equal(traceID, span.SpanContext().TraceID().String(), "the trace of the traceparent header is continued")
+		spans[span.Name()] = span
+		children[span.Parent().SpanID()] = append(children[span.Parent().SpanID()], span.Name())
+	}
+	server, ok := spans["GET /api/articles"]
+	if !asserts.True(ok, "the request span is named by route") {
+		return
+	}
+	asserts.Equal(parentID, server.Parent().SpanID().String())
+	asserts.Equal(trace.SpanKindServer, server.SpanKind())
+	attributes := map[string]string{}
+	for _, attribute := range server.Attributes() {
+		attributes[string(attribute.Key)] = attribute.Value.Emit()
+	}
+	asserts.Equal("/api/articles", attributes["http.route"])
+	asserts.Equal("tag=go", attributes["url.query"])
+	asserts.Equal("200", attributes["http.response.status_code"])
+
+	asserts.Contains(children[server.SpanContext().SpanID()], "SELECT articles", "the queries listing articles")
+	asserts.Contains(children[server.SpanContext().SpanID()], "ArticlesSerializer.Response")
+	asserts.Equal([]string{"ArticleSerializer.Response"}, children[spans["ArticlesSerializer.Response"].SpanContext().SpanID()])
+	article := children[spans["ArticleSerializer.Response"].SpanContext().SpanID()]
+	asserts.Contains(article, "SELECT profiles", "the queries made for each article")
+	asserts.Contains(article, "SELECT favorites")
+	asserts.Contains(article, "ProfileSerializer.Response")
+}
@@ -0,0 +1,50 @@
+package app
+
+import (
+	"net/http"
+
+	"github.com/gorilla/mux"
+	"github.com/hy00nc/conduit-go/internal/database"
+	"github.com/hy00nc/conduit-go/internal/models"
+	"github.com/hy00nc/conduit-go/internal/utils"
+)
+
+// GetTrash lists the articles and comments the user deleted that can still
+// be restored.
+func GetTrash(w http.ResponseWriter, r *http.Request) {
+	userData := r.Context().Value(utils.ContextKeyUserData).(models.User)
+	trash, err := trashService.List(r.Context(), userData)
+	if err != nil {
+		writeServiceError(w, r, err, "Trash")
+		return
+	}
+	serializer := models.TrashSerializer{Articles: trash.Articles, Comments: trash.Comments, PurgeAt: trashService.PurgeAt}
+	writeResponse(w, r, map[string]interface{}{"trash": serializer.Response()}, http.StatusOK)
+}
+
+func RestoreArticle(w http.ResponseWriter, r *http.Request) {
+	userData := r.Context().Value(utils.ContextKeyUserData).(models.User)
+	article, err := trashService.RestoreArticle(r.Context(), userData, mux.Vars(r)["slug"])
+	if err != nil {
+		writeServiceError(w, r, err, "Article")
+		return
+	}
+	serializer := models.ArticleSerializer{Article: article}
+	writeResponse(w, r, map[string]interface{}{"article": serializer.Response(database.GetDB(), r)}, http.StatusOK)
+}
+
+func RestoreComment(w http.ResponseWriter, r *http.Request) {
+	userData := r.Context().Value(utils.ContextKeyUserData).(models.User)
+	id, err := commentID(r)
+	if err != nil {
+		writeServiceError(w, r, err, "Comment")
+		return
+	}
+	comment, err := trashService.RestoreComment(r.Context(), userData, id)
+	if err != nil {
+		writeServiceError(w, r, err, "Comment")
+		return
+	}
+	serializer := models.CommentSerializer{Comment: comment}
+	writeResponse(w, r, map[string]interface{}{"comment": serializer.Response(database.GetDB(), r)}, http.StatusOK)
+}
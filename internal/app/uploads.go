@@ -0,0 +1,139 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"io"
+	"mime"
+	"net/http"
+	"path"
+	"sort"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+	"github.com/hy00nc/conduit-go/internal/database"
+	"github.com/hy00nc/conduit-go/internal/media"
+	"github.com/hy00nc/conduit-go/internal/models"
+	"github.com/hy00nc/conduit-go/internal/storage"
+	"github.com/hy00nc/conduit-go/internal/utils"
+)
+
+// mediaStorage holds uploaded images; RunServer replaces it from the environment.
+var mediaStorage storage.Storage = storage.NewLocal("uploads", "/uploads")
+
+func thumbnailNames() []string {
+	names := []string{}
+	for name := range media.ThumbnailSizes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func RetrieveUpload(ownerID, id uint) (models.Upload, error) {
+	db := database.GetDB()
+	var upload models.Upload
+	err := db.Model(&upload).Where("owner_id = ?", ownerID).First(&upload, id).Error
+	return upload, err
+}
+
+// CreateUpload accepts a multipart form with the image in the "file" field.
+func CreateUpload(w http.ResponseWriter, r *http.Request) {
+	userData := r.Context().Value(utils.ContextKeyUserData).(models.User)
+
+	r.Body = http.MaxBytesReader(w, r.Body, media.MaxUploadSize+1<<20) // room for the multipart envelope
+	file, _, err := r.FormFile("file")
+	if err != nil {
+		logInvalid(r, err)
+		writeResponse(w, r, map[string]interface{}{"errors": utils.CreateInvalidResponse("file")}, http.StatusBadRequest)
+		return
+	}
+	defer file.Close()
+	data, err := io.ReadAll(io.LimitReader(file, media.MaxUploadSize+1))
+	if err != nil {
+		logInvalid(r, err)
+		writeResponse(w, r, map[string]interface{}{"errors": utils.CreateInvalidResponse("file")}, http.StatusBadRequest)
+		return
+	}
+	if len(data) > media.MaxUploadSize {
+		writeResponse(w, r, map[string]interface{}{"errors": map[string]interface{}{"file": "is too large"}}, http.StatusRequestEntityTooLarge)
+		return
+	}
+
+	processed, err := media.Process(data)
+	if errors.Is(err, media.ErrUnsupportedType) || errors.Is(err, media.ErrImageTooLarge) {
+		writeResponse(w, r, map[string]interface{}{"errors": map[string]interface{}{"file": err.Error()}}, http.StatusUnsupportedMediaType)
+		return
+	}
+	if err != nil {
+		logError(r, err)
+		writeResponse(w, r, map[string]interface{}{"errors": utils.CreateInvalidResponse("file")}, http.StatusInternalServerError)
+		return
+	}
+
+	upload := models.Upload{
+		OwnerID:     userData.ProfileID,
+		Key:         uuid.NewString(),
+		Ext:         processed.Original.Ext,
+		ContentType: processed.Original.ContentType,
+		Size:        len(processed.Original.Data),
+		Width:       processed.Original.Width,
+		Height:      processed.Original.Height,
+	}
+	images := map[string]media.Image{"original": processed.Original}
+	for name, thumbnail := range processed.Thumbnails {
+		images[name] = thumbnail
+	}
+	for name, image := range images {
+		if err := mediaStorage.Put(r.Context(), upload.ObjectKey(name), image.Data, image.ContentType); err != nil {
+			logError(r, err)
+			// nothing refers to what was stored
+			if err := deleteUploadFiles(context.WithoutCancel(r.Context()), upload); err != nil {
+				logError(r, err)
+			}
+			writeResponse(w, r, map[string]interface{}{"errors": utils.CreateInvalidResponse("Storage")}, http.StatusInternalServerError)
+			return
+		}
+	}
+	if err := database.GetDB().Create(&upload).Error; err != nil {
+		logError(r, err)
+		// nothing refers to what was stored
+		if err := deleteUploadFiles(context.WithoutCancel(r.Context()), upload); err != nil {
+			logError(r, err)
+		}
+		writeResponse(w, r, map[string]interface{}{"errors": utils.CreateInvalidResponse("Upload")}, http.StatusInternalServerError)
+		return
+	}
+	serializer := models.UploadSerializer{Upload: upload}
+	writeResponse(w, r, map[string]interface{}{"upload": serializer.Response(mediaStorage, thumbnailNames())}, http.StatusCreated)
+}
+
+// deleteUploadFiles removes the original and the thumbnails of an upload.
+func deleteUploadFiles(ctx context.Context, upload models.Upload) error {
+	var errs []error
+	for _, name := range append(thumbnailNames(), "original") {
+		errs = append(errs, mediaStorage.Delete(ctx, upload.ObjectKey(name)))
+	}
+	return errors.Join(errs...)
+}
+
+// ServeUpload streams stored media for storages without their own public URL.
+func ServeUpload(w http.ResponseWriter, r *http.Request) {
+	key := mux.Vars(r)["key"]
+	object, err := mediaStorage.Get(r.Context(), key)
+	if errors.Is(err, storage.ErrNotFound) || errors.Is(err, storage.ErrInvalidKey) {
+		http.NotFound(w, r)
+		return
+	}
+	if err != nil {
+		logError(r, err)
+		http.Error(w, "failed to read upload", http.StatusInternalServerError)
+		return
+	}
+	defer object.Close()
+	// keys are random and never rewritten, so they can be cached forever
+	w.Header().Set("Cache-Control", "public, max-age=31536000, immutable")
+	w.Header().Set("Content-Type", mime.TypeByExtension(path.Ext(key)))
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	io.Copy(w, object)
+}
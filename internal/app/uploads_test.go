@@ -0,0 +1,66 @@
+package app
+
+import (
+	"bytes"
+	"image"
+	"image/png"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+
+	"github.com/hy00nc/conduit-go/internal/models"
+	"github.com/hy00nc/conduit-go/internal/service"
+	"github.com/hy00nc/conduit-go/internal/storage"
+	"github.com/hy00nc/conduit-go/internal/utils"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func uploadRequest(t *testing.T, token string) *http.Request {
+	var img bytes.Buffer
+	require.NoError(t, png.Encode(&img, image.NewRGBA(image.Rect(0, 0, 16, 16))))
+	var body bytes.Buffer
+	form := multipart.NewWriter(&body)
+	part, err := form.CreateFormFile("file", "avatar.png")
+	require.NoError(t, err)
+	part.Write(img.Bytes())
+	require.NoError(t, form.Close())
+	req := httptest.NewRequest("POST", "/api/uploads", &body)
+	req.Header.Set("Content-Type", form.FormDataContentType())
+	req.Header.Set("Authorization", "Token "+token)
+	return req
+}
+
+// useTempStorage stores uploads in a temporary directory, returning it.
+func useTempStorage(t *testing.T) string {
+	dir := t.TempDir()
+	previous := mediaStorage
+	mediaStorage = storage.NewLocal(dir, "/uploads")
+	t.Cleanup(func() { mediaStorage = previous })
+	return dir
+}
+
+func TestCreateUploadRollsBackFiles(t *testing.T) {
+	asserts := assert.New(t)
+	db := useTempDB(t)
+	dir := useTempStorage(t)
+	user, err := userService.Register(t.Context(), service.RegisterInput{Username: "sally", Email: "sally@example.com", Password: "secret"})
+	require.NoError(t, err)
+	token, _ := utils.GetToken(user.ID)
+	handler := MakeWebHandler(false)
+
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, uploadRequest(t, token))
+	asserts.Equal(http.StatusCreated, w.Code)
+	stored, _ := filepath.Glob(filepath.Join(dir, "*", "*"))
+	asserts.Len(stored, 1+len(thumbnailNames()))
+
+	require.NoError(t, db.Migrator().DropTable(&models.Upload{}))
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, uploadRequest(t, token))
+	asserts.Equal(http.StatusInternalServerError, w.Code)
+	after, _ := filepath.Glob(filepath.Join(dir, "*", "*"))
+	asserts.Equal(stored, after, "the files of uploads that could not be saved are deleted")
+}
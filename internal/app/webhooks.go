@@ -0,0 +1,115 @@
+package app
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+	"github.com/hy00nc/conduit-go/internal/models"
+	"github.com/hy00nc/conduit-go/internal/service"
+	"github.com/hy00nc/conduit-go/internal/utils"
+)
+
+// routeID parses the name route variable; malformed ids match no record,
+// reported as field.
+func routeID(r *http.Request, name, field string) (uint, error) {
+	id, err := strconv.ParseUint(mux.Vars(r)[name], 10, 0)
+	if err != nil {
+		return 0, service.NotFound(field)
+	}
+	return uint(id), nil
+}
+
+func GetWebhooks(w http.ResponseWriter, r *http.Request) {
+	userData := r.Context().Value(utils.ContextKeyUserData).(models.User)
+	hooks, err := webhookService.List(r.Context(), userData)
+	if err != nil {
+		writeServiceError(w, r, err, "Parameter")
+		return
+	}
+	response := []models.WebhookResponse{}
+	for _, hook := range hooks {
+		serializer := models.WebhookSerializer{Webhook: hook}
+		response = append(response, serializer.Response())
+	}
+	writeResponse(w, r, map[string]interface{}{"webhooks": response}, http.StatusOK)
+}
+
+func CreateWebhook(w http.ResponseWriter, r *http.Request) {
+	userData := r.Context().Value(utils.ContextKeyUserData).(models.User)
+	var webhookValidator models.WebhookValidator
+	if err := decodeRequest(w, r, &webhookValidator, maxBodySize); err != nil {
+		writeServiceError(w, r, err, "Data")
+		return
+	}
+	webhook, err := webhookService.Create(r.Context(), userData, service.WebhookInput{
+		URL:      webhookValidator.Webhook.URL,
+		Secret:   webhookValidator.Webhook.Secret,
+		Events:   webhookValidator.Webhook.Events,
+		SiteWide: webhookValidator.Webhook.SiteWide,
+	})
+	if err != nil {
+		writeServiceError(w, r, err, "Data")
+		return
+	}
+	serializer := models.WebhookSerializer{Webhook: webhook}
+	response := serializer.Response()
+	response.Secret = webhook.Secret
+	writeResponse(w, r, map[string]interface{}{"webhook": response}, http.StatusCreated)
+}
+
+func DeleteWebhook(w http.ResponseWriter, r *http.Request) {
+	userData := r.Context().Value(utils.ContextKeyUserData).(models.User)
+	id, err := routeID(r, "id", "webhook")
+	if err == nil {
+		err = webhookService.Delete(r.Context(), userData, id)
+	}
+	if err != nil {
+		writeServiceError(w, r, err, "Webhook")
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func GetWebhookDeliveries(w http.ResponseWriter, r *http.Request) {
+	userData := r.Context().Value(utils.ContextKeyUserData).(models.User)
+	id, err := routeID(r, "id", "webhook")
+	if err != nil {
+		writeServiceError(w, r, err, "Webhook")
+		return
+	}
+	deliveries, count, err := webhookService.Deliveries(r.Context(), userData, id, queryInt(r, "limit"), queryInt(r, "offset"))
+	if err != nil {
+		writeServiceError(w, r, err, "Parameter")
+		return
+	}
+	response := []models.WebhookDeliveryResponse{}
+	for _, delivery := range deliveries {
+		serializer := models.WebhookDeliverySerializer{WebhookDelivery: delivery}
+		response = append(response, serializer.Response())
+	}
+	writeResponse(w, r, map[string]interface{}{"deliveries": response, "deliveriesCount": count}, http.StatusOK)
+}
+
+// RedeliverWebhook queues a fresh delivery of the same event; the original
+// delivery is kept in the log untouched.
+func RedeliverWebhook(w http.ResponseWriter, r *http.Request) {
+	userData := r.Context().Value(utils.ContextKeyUserData).(models.User)
+	id, err := routeID(r, "id", "webhook")
+	if err != nil {
+		writeServiceError(w, r, err, "Webhook")
+		return
+	}
+	deliveryID, err := routeID(r, "deliveryId", "delivery")
+	if err != nil {
+		writeServiceError(w, r, err, "Delivery")
+		return
+	}
+	delivery, err := webhookService.Redeliver(r.Context(), userData, id, deliveryID)
+	if err != nil {
+		writeServiceError(w, r, err, "Delivery")
+		return
+	}
+	serializer := models.WebhookDeliverySerializer{WebhookDelivery: delivery}
+	writeResponse(w, r, map[string]interface{}{"delivery": serializer.Response()}, http.StatusAccepted)
+}
@@ -0,0 +1,139 @@
+// Package backup writes the content of a site to a portable archive and
+// restores it into any database the application supports.
+//
+// An archive is JSON lines: a Header followed by one Record per row, with
+// every row after the rows it refers to. Profiles come first, then users,
+// tags, articles, comments, follows and favorites; soft-deleted rows are
+// included. Uploads, webhooks and feed tokens are not part of an archive.
+package backup
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Format and Version identify archives; Restore refuses newer versions.
+const (
+	Format  = "conduit-backup"
+	Version = 1
+)
+
+type Header struct {
+	Format    string    `json:"format"`
+	Version   int       `json:"version"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+// Record types, in archive order.
+const (
+	TypeProfile  = "profile"
+	TypeUser     = "user"
+	TypeTag      = "tag"
+	TypeArticle  = "article"
+	TypeComment  = "comment"
+	TypeFollow   = "follow"
+	TypeFavorite = "favorite"
+)
+
+// Record is one line after the header; Data is one of the *Record types
+// below, depending on Type.
+type Record struct {
+	Type string          `json:"type"`
+	Data json.RawMessage `json:"data"`
+}
+
+// Meta holds the columns every row has.
+type Meta struct {
+	ID        uint       `json:"id"`
+	CreatedAt time.Time  `json:"createdAt"`
+	UpdatedAt time.Time  `json:"updatedAt"`
+	DeletedAt *time.Time `json:"deletedAt,omitempty"`
+}
+
+func metaOf(model gorm.Model) Meta {
+	meta := Meta{ID: model.ID, CreatedAt: model.CreatedAt, UpdatedAt: model.UpdatedAt}
+	if model.DeletedAt.Valid {
+		deletedAt := model.DeletedAt.Time
+		meta.DeletedAt = &deletedAt
+	}
+	return meta
+}
+
+// model returns the gorm.Model with id, for a preserved or remapped ID.
+func (m Meta) model(id uint) gorm.Model {
+	model := gorm.Model{ID: id, CreatedAt: m.CreatedAt, UpdatedAt: m.UpdatedAt}
+	if m.DeletedAt != nil {
+		model.DeletedAt = gorm.DeletedAt{Time: *m.DeletedAt, Valid: true}
+	}
+	return model
+}
+
+type ProfileRecord struct {
+	Meta
+	Name  string `json:"name"`
+	Bio   string `json:"bio"`
+	Image string `json:"image"`
+}
+
+type UserRecord struct {
+	Meta
+	ProfileID uint   `json:"profileId"`
+	Email     string `json:"email"`
+	Hash      string `json:"hash"` // bcrypt hash of the password
+	// Role and SuspendedAt are omitted for regular users in good standing.
+	Role        string     `json:"role,omitempty"`
+	SuspendedAt *time.Time `json:"suspendedAt,omitempty"`
+}
+
+type TagRecord struct {
+	Meta
+	Name string `json:"name"`
+}
+
+type ArticleRecord struct {
+	Meta
+	Slug        string `json:"slug"`
+	Title       string `json:"title"`
+	Description string `json:"description"`
+	Body        string `json:"body"`
+	AuthorID    uint   `json:"authorId"`
+	TagIDs      []uint `json:"tagIds"`
+}
+
+type CommentRecord struct {
+	Meta
+	Body      string `json:"body"`
+	ArticleID uint   `json:"articleId"`
+	AuthorID  uint   `json:"authorId"`
+}
+
+type FollowRecord struct {
+	Meta
+	UserID      uint `json:"userId"` // the follower's profile
+	FollowingID uint `json:"followingId"`
+}
+
+type FavoriteRecord struct {
+	Meta
+	ArticleID     uint `json:"articleId"`
+	FavoritedByID uint `json:"favoritedById"`
+}
+
+// Stats counts the rows of each record type written or restored.
+type Stats map[string]int
+
+// types lists the record types in archive order.
+var types = []string{TypeProfile, TypeUser, TypeTag, TypeArticle, TypeComment, TypeFollow, TypeFavorite}
+
+// String lists the counts in archive order, e.g. "2 profiles, 2 users, ...".
+func (s Stats) String() string {
+	parts := make([]string, 0, len(types))
+	for _, recordType := range types {
+		parts = append(parts, fmt.Sprintf("%d %ss", s[recordType], recordType))
+	}
+	return strings.Join(parts, ", ")
+}
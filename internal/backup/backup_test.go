@@ -0,0 +1,183 @@
+package backup
+
+import (
+	"bytes"
+	"context"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/hy00nc/conduit-go/internal/database"
+	"github.com/hy00nc/conduit-go/internal/models"
+	"github.com/hy00nc/conduit-go/internal/service"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"gorm.io/gorm/logger"
+)
+
+func openDB(t *testing.T, name string) *gorm.DB {
+	db, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), name)), &gorm.Config{
+		Logger: logger.Default.LogMode(logger.Silent),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	database.MigrateDB(db)
+	t.Cleanup(func() { database.CloseDB(db) })
+	return db
+}
+
+// seed fills db through the services: two users who follow each other,
+// articles with tags, one of them deleted, a comment and a favorite.
+func seed(t *testing.T, db *gorm.DB) {
+	must := func(err error) {
+		t.Helper()
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	ctx := context.Background()
+	store := service.GormStore{DB: db}
+	users := service.NewUserService(store, nil)
+	articles := service.NewArticleService(store, nil)
+	profiles := service.NewProfileService(store)
+	sally, err := users.Register(ctx, service.RegisterInput{Username: "sally", Email: "sally@example.com", Password: "secret"})
+	must(err)
+	harry, err := users.Register(ctx, service.RegisterInput{Username: "harry", Email: "harry@example.com", Password: "secret"})
+	must(err)
+	first, err := articles.Create(ctx, sally, service.ArticleInput{Title: "First", Description: "d", Body: "b", TagList: []string{"go", "web"}})
+	must(err)
+	second, err := articles.Create(ctx, harry, service.ArticleInput{Title: "Second", Description: "d", Body: "b", TagList: []string{"go"}})
+	must(err)
+	must(articles.Delete(ctx, harry, second.Slug))
+	_, err = service.NewCommentService(store, nil).Add(ctx, harry, first.Slug, "nice")
+	must(err)
+	_, err = articles.Favorite(ctx, harry, first.Slug)
+	must(err)
+	_, err = profiles.Follow(ctx, sally, "harry")
+	must(err)
+	_, err = profiles.Follow(ctx, harry, "sally")
+	must(err)
+}
+
+// body drops the header line, which has the time of the export.
+func body(archive []byte) string {
+	_, rest, _ := bytes.Cut(archive, []byte("\n"))
+	return string(rest)
+}
+
+func TestExportRestoreRoundTrip(t *testing.T) {
+	asserts := assert.New(t)
+	ctx := context.Background()
+	source := openDB(t, "source.db")
+	seed(t, source)
+
+	var archive bytes.Buffer
+	stats, err := Export(ctx, source, &archive)
+	asserts.NoError(err)
+	asserts.Equal("2 profiles, 2 users, 2 tags, 2 articles, 1 comments, 2 follows, 1 favorites", stats.String())
+	asserts.True(strings.HasPrefix(archive.String(), `{"format":"conduit-backup","version":1,`))
+
+	target := openDB(t, "target.db")
+	restored, err := Restore(ctx, target, bytes.NewReader(archive.Bytes()), RestoreOptions{})
+	asserts.NoError(err)
+	asserts.Equal(stats, restored)
+
+	var again bytes.Buffer
+	_, err = Export(ctx, target, &again)
+	asserts.NoError(err)
+	asserts.Equal(body(archive.Bytes()), body(again.Bytes()), "rows, IDs, dates and deletions are preserved")
+
+	// the restored users can log in
+	_, err = service.NewUserService(service.GormStore{DB: target}, nil).Login(ctx, "sally@example.com", "secret")
+	asserts.NoError(err)
+
+	_, err = Restore(ctx, target, bytes.NewReader(archive.Bytes()), RestoreOptions{})
+	asserts.ErrorIs(err, ErrNotEmpty)
+}
+
+func TestExportIsSnapshot(t *testing.T) {
+	asserts := assert.New(t)
+	ctx := context.Background()
+	// in WAL mode writers go on while the export reads, as on a live server
+	source := openDB(t, "live.db?_journal_mode=WAL")
+	seed(t, source)
+	written := false
+	write := func(tx *gorm.DB) {
+		if tx.Statement.Table != "articles" || written {
+			return
+		}
+		written = true
+		article := models.Article{Slug: "late", Title: "Late", Description: "d", Body: "b", AuthorID: 1}
+		asserts.NoError(source.Omit(clause.Associations).Create(&article).Error)
+		asserts.NoError(source.Omit(clause.Associations).Create(&models.Comment{Body: "late", ArticleID: article.ID, AuthorID: 1}).Error)
+		asserts.NoError(source.Omit(clause.Associations).Create(&models.Favorite{ArticleID: article.ID, FavoritedByID: 2}).Error)
+	}
+	asserts.NoError(source.Callback().Query().After("gorm:query").Register("test:write", write))
+
+	var archive bytes.Buffer
+	stats, err := Export(ctx, source, &archive)
+	asserts.NoError(err)
+	asserts.True(written, "rows were written between the steps of the export")
+	asserts.Equal("2 profiles, 2 users, 2 tags, 2 articles, 1 comments, 2 follows, 1 favorites", stats.String(), "what was written meanwhile is left out")
+	_, err = Restore(ctx, openDB(t, "target.db"), bytes.NewReader(archive.Bytes()), RestoreOptions{})
+	asserts.NoError(err)
+}
+
+func TestRestoreRemapsIDs(t *testing.T) {
+	asserts := assert.New(t)
+	ctx := context.Background()
+	source := openDB(t, "source.db")
+	seed(t, source)
+	var archive bytes.Buffer
+	_, err := Export(ctx, source, &archive)
+	asserts.NoError(err)
+
+	target := openDB(t, "target.db")
+	store := service.GormStore{DB: target}
+	tom, err := service.NewUserService(store, nil).Register(ctx, service.RegisterInput{Username: "tom", Email: "tom@example.com", Password: "secret"})
+	asserts.NoError(err)
+	_, err = service.NewArticleService(store, nil).Create(ctx, tom, service.ArticleInput{Title: "Mine", Description: "d", Body: "b", TagList: []string{"web"}})
+	asserts.NoError(err)
+
+	_, err = Restore(ctx, target, bytes.NewReader(archive.Bytes()), RestoreOptions{RemapIDs: true})
+	asserts.NoError(err)
+	var tags, articles int64
+	target.Model(&models.Tag{}).Count(&tags)
+	target.Unscoped().Model(&models.Article{}).Count(&articles)
+	asserts.EqualValues(2, tags, "tags are matched by name")
+	asserts.EqualValues(3, articles)
+
+	var comment models.Comment
+	asserts.NoError(target.Preload("Author").Preload("Article.Author").First(&comment).Error)
+	asserts.Equal("harry", comment.Author.Name)
+	asserts.Equal("sally", comment.Article.Author.Name)
+	var first models.Article
+	asserts.NoError(target.Preload("Tags").First(&first, "title = ?", "First").Error)
+	asserts.Len(first.Tags, 2)
+}
+
+func TestRestoreChecksReferences(t *testing.T) {
+	asserts := assert.New(t)
+	ctx := context.Background()
+	db := openDB(t, "target.db")
+	archive := `{"format":"conduit-backup","version":1,"createdAt":"2024-01-01T00:00:00Z"}
+{"type":"profile","data":{"id":1,"createdAt":"2024-01-01T00:00:00Z","updatedAt":"2024-01-01T00:00:00Z","name":"sally","bio":"","image":""}}
+{"type":"comment","data":{"id":1,"createdAt":"2024-01-01T00:00:00Z","updatedAt":"2024-01-01T00:00:00Z","body":"hi","articleId":9,"authorId":1}}
+`
+	_, err := Restore(ctx, db, strings.NewReader(archive), RestoreOptions{})
+	asserts.EqualError(err, "record 2 (comment): article 9 does not exist")
+	var profiles int64
+	db.Unscoped().Model(&models.Profile{}).Count(&profiles)
+	asserts.Zero(profiles, "nothing is restored from a broken archive")
+
+	_, err = Restore(ctx, db, strings.NewReader(`{"format":"conduit-backup","version":2}`), RestoreOptions{})
+	asserts.ErrorContains(err, "unsupported archive version 2")
+	_, err = Restore(ctx, db, strings.NewReader(`{"format":"other","version":1}`), RestoreOptions{})
+	asserts.ErrorContains(err, "not a conduit-backup archive")
+	duplicate := strings.Join(strings.Split(archive, "\n")[:2], "\n") + "\n" + strings.Split(archive, "\n")[1]
+	_, err = Restore(ctx, db, strings.NewReader(duplicate), RestoreOptions{})
+	asserts.EqualError(err, "record 2 (profile): duplicate profile 1")
+}
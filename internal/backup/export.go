@@ -0,0 +1,156 @@
+package backup
+
+import (
+	"bufio"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"io"
+	"time"
+
+	"github.com/hy00nc/conduit-go/internal/models"
+	"gorm.io/gorm"
+)
+
+// batchSize is how many rows are loaded at a time while exporting.
+const batchSize = 500
+
+// eachBatch calls fn with the rows of T in ID order, a batch at a time.
+func eachBatch[T any](db *gorm.DB, fn func(rows []T) error) error {
+	var rows []T
+	return db.FindInBatches(&rows, batchSize, func(*gorm.DB, int) error {
+		return fn(rows)
+	}).Error
+}
+
+// snapshotOptions are those of a transaction whose reads all see the
+// database as it was when it began. SQLite transactions do already; the
+// default of PostgreSQL, read committed, does not.
+func snapshotOptions(db *gorm.DB) *sql.TxOptions {
+	if db.Dialector.Name() == "postgres" {
+		return &sql.TxOptions{Isolation: sql.LevelRepeatableRead, ReadOnly: true}
+	}
+	return nil
+}
+
+// Export writes every profile, user, tag, article, comment, follow and
+// favorite in db to w, soft-deleted ones included. The rows are read in one
+// transaction, so that a server writing meanwhile cannot leave the archive
+// with comments or favorites of articles it does not have.
+func Export(ctx context.Context, db *gorm.DB, w io.Writer) (Stats, error) {
+	var stats Stats
+	err := db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var err error
+		stats, err = export(tx.Unscoped().Session(&gorm.Session{}), w)
+		return err
+	}, snapshotOptions(db))
+	return stats, err
+}
+
+func export(db *gorm.DB, w io.Writer) (Stats, error) {
+	buffered := bufio.NewWriter(w)
+	encoder := json.NewEncoder(buffered)
+	stats := Stats{}
+	write := func(recordType string, data interface{}) error {
+		raw, err := json.Marshal(data)
+		if err != nil {
+			return err
+		}
+		stats[recordType]++
+		return encoder.Encode(Record{Type: recordType, Data: raw})
+	}
+
+	if err := encoder.Encode(Header{Format: Format, Version: Version, CreatedAt: time.Now().UTC()}); err != nil {
+		return nil, err
+	}
+	for _, step := range []func() error{
+		func() error {
+			return exportRows(db, write, TypeProfile, func(profile models.Profile) interface{} {
+				return ProfileRecord{Meta: metaOf(profile.Model), Name: profile.Name, Bio: profile.Bio, Image: profile.Image}
+			})
+		},
+		func() error {
+			return exportRows(db, write, TypeUser, func(user models.User) interface{} {
+				record := UserRecord{Meta: metaOf(user.Model), ProfileID: user.ProfileID, Email: user.Email, Hash: user.Hash, SuspendedAt: user.SuspendedAt}
+				if user.Role != models.RoleUser {
+					record.Role = user.Role
+				}
+				return record
+			})
+		},
+		func() error {
+			return exportRows(db, write, TypeTag, func(tag models.Tag) interface{} {
+				return TagRecord{Meta: metaOf(tag.Model), Name: tag.Name}
+			})
+		},
+		func() error { return exportArticles(db, write) },
+		func() error {
+			return exportRows(db, write, TypeComment, func(comment models.Comment) interface{} {
+				return CommentRecord{Meta: metaOf(comment.Model), Body: comment.Body, ArticleID: comment.ArticleID, AuthorID: comment.AuthorID}
+			})
+		},
+		func() error {
+			return exportRows(db, write, TypeFollow, func(follow models.Follow) interface{} {
+				return FollowRecord{Meta: metaOf(follow.Model), UserID: follow.UserID, FollowingID: follow.FollowingID}
+			})
+		},
+		func() error {
+			return exportRows(db, write, TypeFavorite, func(favorite models.Favorite) interface{} {
+				return FavoriteRecord{Meta: metaOf(favorite.Model), ArticleID: favorite.ArticleID, FavoritedByID: favorite.FavoritedByID}
+			})
+		},
+	} {
+		if err := step(); err != nil {
+			return nil, err
+		}
+	}
+	return stats, buffered.Flush()
+}
+
+// exportRows writes a record for every row of T.
+func exportRows[T any](db *gorm.DB, write func(string, interface{}) error, recordType string, record func(T) interface{}) error {
+	return eachBatch(db, func(rows []T) error {
+		for _, row := range rows {
+			if err := write(recordType, record(row)); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// exportArticles writes the articles with the IDs of their tags.
+func exportArticles(db *gorm.DB, write func(string, interface{}) error) error {
+	return eachBatch(db, func(articles []models.Article) error {
+		ids := make([]uint, len(articles))
+		for i, article := range articles {
+			ids[i] = article.ID
+		}
+		var links []struct{ ArticleID, TagID uint }
+		if err := db.Table("article_tags").Where("article_id IN ?", ids).Order("tag_id").Find(&links).Error; err != nil {
+			return err
+		}
+		tagIDs := map[uint][]uint{}
+		for _, link := range links {
+			tagIDs[link.ArticleID] = append(tagIDs[link.ArticleID], link.TagID)
+		}
+		for _, article := range articles {
+			record := ArticleRecord{
+				Meta:        metaOf(article.Model),
+				Slug:        article.Slug,
+				Title:       article.Title,
+				Description: article.Description,
+				Body:        article.Body,
+				AuthorID:    article.AuthorID,
+				TagIDs:      tagIDs[article.ID],
+			}
+			if record.TagIDs == nil {
+				record.TagIDs = []uint{}
+			}
+			if err := write(TypeArticle, record); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
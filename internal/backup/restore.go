@@ -0,0 +1,322 @@
+package backup
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/hy00nc/conduit-go/internal/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// ErrNotEmpty is returned when restoring with preserved IDs into a database
+// that already has content.
+var ErrNotEmpty = errors.New("the database has content; restore into an empty database or remap IDs")
+
+type RestoreOptions struct {
+	// RemapIDs gives the restored rows new IDs, so an archive can be added
+	// to a database with content; tags are then matched by name. Otherwise
+	// the archive IDs are kept and the database must be empty.
+	RemapIDs bool
+}
+
+// restoredTables are the tables an archive fills, checked for content before
+// restoring with preserved IDs.
+var restoredTables = []interface{}{&models.Profile{}, &models.User{}, &models.Tag{}, &models.Article{}, &models.Comment{}, &models.Follow{}, &models.Favorite{}}
+
+// Restore reads an archive from r into db. Every row must refer to rows
+// earlier in the archive; nothing is restored unless the whole archive is.
+func Restore(ctx context.Context, db *gorm.DB, r io.Reader, options RestoreOptions) (Stats, error) {
+	decoder := json.NewDecoder(bufio.NewReader(r))
+	var header Header
+	if err := decoder.Decode(&header); err != nil {
+		return nil, fmt.Errorf("read header: %w", err)
+	}
+	if header.Format != Format {
+		return nil, fmt.Errorf("not a %s archive", Format)
+	}
+	if header.Version < 1 || header.Version > Version {
+		return nil, fmt.Errorf("unsupported archive version %d, this build reads up to %d", header.Version, Version)
+	}
+
+	stats := Stats{}
+	// a plain transaction rather than database.Transaction: the archive is
+	// consumed as it is read, so a failed attempt cannot be retried
+	err := db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		tx = tx.Session(&gorm.Session{})
+		if !options.RemapIDs {
+			for _, model := range restoredTables {
+				var count int64
+				if err := tx.Unscoped().Model(model).Count(&count).Error; err != nil {
+					return err
+				}
+				if count > 0 {
+					return ErrNotEmpty
+				}
+			}
+		}
+		restorer := &restorer{tx: tx, remap: options.RemapIDs, ids: map[string]map[uint]uint{}}
+		for n := 1; ; n++ {
+			var record Record
+			err := decoder.Decode(&record)
+			if errors.Is(err, io.EOF) {
+				break
+			}
+			if err != nil {
+				return fmt.Errorf("record %d: %w", n, err)
+			}
+			if err := restorer.restore(record); err != nil {
+				return fmt.Errorf("record %d (%s): %w", n, record.Type, err)
+			}
+			stats[record.Type]++
+		}
+		if !options.RemapIDs {
+			return resetSequences(tx)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return stats, nil
+}
+
+// resetSequences moves the ID sequences of PostgreSQL past the restored IDs;
+// other databases continue after the highest ID by themselves.
+func resetSequences(tx *gorm.DB) error {
+	if tx.Dialector.Name() != "postgres" {
+		return nil
+	}
+	for _, table := range []string{"profiles", "users", "tags", "articles", "comments", "follows", "favorites"} {
+		err := tx.Exec(fmt.Sprintf("SELECT setval(pg_get_serial_sequence('%[1]s', 'id'), COALESCE(MAX(id), 0) + 1, false) FROM %[1]s", table)).Error
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// restorer inserts records, tracking the database ID of every archive ID.
+type restorer struct {
+	tx    *gorm.DB
+	remap bool
+	ids   map[string]map[uint]uint // by record type
+}
+
+// id returns the ID to insert the row recordType/id with: the archive ID,
+// or 0 to have the database assign one.
+func (r *restorer) id(recordType string, id uint) (uint, error) {
+	if id == 0 {
+		return 0, errors.New("missing id")
+	}
+	if _, ok := r.ids[recordType][id]; ok {
+		return 0, fmt.Errorf("duplicate %s %d", recordType, id)
+	}
+	if r.remap {
+		return 0, nil
+	}
+	return id, nil
+}
+
+func (r *restorer) restored(recordType string, id, dbID uint) {
+	if r.ids[recordType] == nil {
+		r.ids[recordType] = map[uint]uint{}
+	}
+	r.ids[recordType][id] = dbID
+}
+
+// ref returns the database ID of a row referred to by an archive ID.
+func (r *restorer) ref(recordType string, id uint) (uint, error) {
+	dbID, ok := r.ids[recordType][id]
+	if !ok {
+		return 0, fmt.Errorf("%s %d does not exist", recordType, id)
+	}
+	return dbID, nil
+}
+
+// create inserts a row without its associations.
+func (r *restorer) create(row interface{}) error {
+	return r.tx.Omit(clause.Associations).Create(row).Error
+}
+
+func (r *restorer) restore(record Record) error {
+	switch record.Type {
+	case TypeProfile:
+		var data ProfileRecord
+		if err := json.Unmarshal(record.Data, &data); err != nil {
+			return err
+		}
+		id, err := r.id(TypeProfile, data.ID)
+		if err != nil {
+			return err
+		}
+		profile := models.Profile{Model: data.model(id), Name: data.Name, Bio: data.Bio, Image: data.Image}
+		if err := r.create(&profile); err != nil {
+			return err
+		}
+		r.restored(TypeProfile, data.ID, profile.ID)
+
+	case TypeUser:
+		var data UserRecord
+		if err := json.Unmarshal(record.Data, &data); err != nil {
+			return err
+		}
+		id, err := r.id(TypeUser, data.ID)
+		if err != nil {
+			return err
+		}
+		profileID, err := r.ref(TypeProfile, data.ProfileID)
+		if err != nil {
+			return err
+		}
+		role := data.Role
+		if role == "" {
+			role = models.RoleUser
+		}
+		user := models.User{Model: data.model(id), ProfileID: profileID, Email: data.Email, Hash: data.Hash, Role: role, SuspendedAt: data.SuspendedAt}
+		if err := r.create(&user); err != nil {
+			return err
+		}
+		r.restored(TypeUser, data.ID, user.ID)
+
+	case TypeTag:
+		var data TagRecord
+		if err := json.Unmarshal(record.Data, &data); err != nil {
+			return err
+		}
+		id, err := r.id(TypeTag, data.ID)
+		if err != nil {
+			return err
+		}
+		if r.remap && data.DeletedAt == nil {
+			var existing models.Tag
+			err := r.tx.Where("name = ?", data.Name).Limit(1).Find(&existing).Error
+			if err != nil {
+				return err
+			}
+			if existing.ID != 0 {
+				r.restored(TypeTag, data.ID, existing.ID)
+				return nil
+			}
+		}
+		tag := models.Tag{Model: data.model(id), Name: data.Name}
+		if err := r.create(&tag); err != nil {
+			return err
+		}
+		r.restored(TypeTag, data.ID, tag.ID)
+
+	case TypeArticle:
+		var data ArticleRecord
+		if err := json.Unmarshal(record.Data, &data); err != nil {
+			return err
+		}
+		id, err := r.id(TypeArticle, data.ID)
+		if err != nil {
+			return err
+		}
+		authorID, err := r.ref(TypeProfile, data.AuthorID)
+		if err != nil {
+			return err
+		}
+		tagIDs := make([]uint, len(data.TagIDs))
+		for i, tagID := range data.TagIDs {
+			if tagIDs[i], err = r.ref(TypeTag, tagID); err != nil {
+				return err
+			}
+		}
+		article := models.Article{
+			Model:       data.model(id),
+			Slug:        data.Slug,
+			Title:       data.Title,
+			Description: data.Description,
+			Body:        data.Body,
+			AuthorID:    authorID,
+		}
+		if err := r.create(&article); err != nil {
+			return err
+		}
+		for _, tagID := range tagIDs {
+			if err := r.tx.Exec("INSERT INTO article_tags (article_id, tag_id) VALUES (?, ?)", article.ID, tagID).Error; err != nil {
+				return err
+			}
+		}
+		r.restored(TypeArticle, data.ID, article.ID)
+
+	case TypeComment:
+		var data CommentRecord
+		if err := json.Unmarshal(record.Data, &data); err != nil {
+			return err
+		}
+		id, err := r.id(TypeComment, data.ID)
+		if err != nil {
+			return err
+		}
+		articleID, err := r.ref(TypeArticle, data.ArticleID)
+		if err != nil {
+			return err
+		}
+		authorID, err := r.ref(TypeProfile, data.AuthorID)
+		if err != nil {
+			return err
+		}
+		comment := models.Comment{Model: data.model(id), Body: data.Body, ArticleID: articleID, AuthorID: authorID}
+		if err := r.create(&comment); err != nil {
+			return err
+		}
+		r.restored(TypeComment, data.ID, comment.ID)
+
+	case TypeFollow:
+		var data FollowRecord
+		if err := json.Unmarshal(record.Data, &data); err != nil {
+			return err
+		}
+		id, err := r.id(TypeFollow, data.ID)
+		if err != nil {
+			return err
+		}
+		userID, err := r.ref(TypeProfile, data.UserID)
+		if err != nil {
+			return err
+		}
+		followingID, err := r.ref(TypeProfile, data.FollowingID)
+		if err != nil {
+			return err
+		}
+		follow := models.Follow{Model: data.model(id), UserID: userID, FollowingID: followingID}
+		if err := r.create(&follow); err != nil {
+			return err
+		}
+		r.restored(TypeFollow, data.ID, follow.ID)
+
+	case TypeFavorite:
+		var data FavoriteRecord
+		if err := json.Unmarshal(record.Data, &data); err != nil {
+			return err
+		}
+		id, err := r.id(TypeFavorite, data.ID)
+		if err != nil {
+			return err
+		}
+		articleID, err := r.ref(TypeArticle, data.ArticleID)
+		if err != nil {
+			return err
+		}
+		favoritedByID, err := r.ref(TypeProfile, data.FavoritedByID)
+		if err != nil {
+			return err
+		}
+		favorite := models.Favorite{Model: data.model(id), ArticleID: articleID, FavoritedByID: favoritedByID}
+		if err := r.create(&favorite); err != nil {
+			return err
+		}
+		r.restored(TypeFavorite, data.ID, favorite.ID)
+
+	default:
+		return fmt.Errorf("unknown record type %q", record.Type)
+	}
+	return nil
+}
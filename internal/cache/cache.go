@@ -0,0 +1,174 @@
+// Package cache keeps the results of hot reads, such as the tag list or the
+// user a token was issued for, so that they do not hit the database on
+// every request.
+//
+// Values are kept JSON encoded in a Cache. LRU keeps them in the process;
+// instances behind a load balancer share them through a Cache backed by an
+// external store such as Redis instead, so that a key invalidated on a
+// write is gone for all of them.
+package cache
+
+import (
+	"context"
+	"encoding/json"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/hy00nc/conduit-go/internal/logging"
+	"github.com/hy00nc/conduit-go/internal/metrics"
+)
+
+// Cache keeps encoded values by key, each until its TTL has passed.
+type Cache interface {
+	// Get returns the value of key, or false if there is none.
+	Get(ctx context.Context, key string) ([]byte, bool, error)
+	Set(ctx context.Context, key string, value []byte, ttl time.Duration) error
+	Delete(ctx context.Context, keys ...string) error
+}
+
+// Keys of the values cached for the API, shared by the code reading them
+// and the services invalidating them on writes.
+const TagsKey = "tags"
+
+// UserKey is the key of the user with id.
+func UserKey(id uint) string {
+	return "user:" + strconv.FormatUint(uint64(id), 10)
+}
+
+// ArticleKey is the key of the anonymous response of the article with slug,
+// rendered to HTML or not.
+func ArticleKey(slug string, html bool) string {
+	if html {
+		return "article:" + slug + ":html"
+	}
+	return "article:" + slug
+}
+
+// ArticleKeys are the keys of all the responses of the article with slug.
+func ArticleKeys(slug string) []string {
+	return []string{ArticleKey(slug, false), ArticleKey(slug, true)}
+}
+
+// loadTimeout bounds the loads of Fetch, which outlive the request that
+// started them.
+const loadTimeout = 10 * time.Second
+
+// Fetch returns the value cached in c under key, or loads it with load and
+// caches it for ttl. Concurrent misses of a key share a single load, so
+// that an expiring hot key does not send a stampede to the database. The
+// load is not canceled with ctx, as the others waiting for it would get
+// the error of a request given up on. A load that the key is invalidated
+// during may have read what the write changed, so its value is not cached.
+// name labels the lookup in the metrics. Failures of c are logged and fall back to load; a nil c always
+// loads.
+func Fetch[T any](ctx context.Context, c Cache, name, key string, ttl time.Duration, load func(context.Context) (T, error)) (T, error) {
+	if c == nil {
+		return load(ctx)
+	}
+	var value T
+	data, ok, err := c.Get(ctx, key)
+	if err != nil {
+		logging.FromContext(ctx).Error("cache: get failed", "key", key, "error", err)
+	}
+	if ok {
+		if err := json.Unmarshal(data, &value); err == nil {
+			metrics.CacheRequests.WithLabelValues(name, "hit").Inc()
+			return value, nil
+		}
+	}
+	metrics.CacheRequests.WithLabelValues(name, "miss").Inc()
+
+	loaded, err := loads.do(ctx, key, func(stale func() bool) (any, error) {
+		ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), loadTimeout)
+		defer cancel()
+		value, err := load(ctx)
+		if err != nil || stale() {
+			return value, err
+		}
+		if data, err := json.Marshal(value); err != nil {
+			logging.FromContext(ctx).Error("cache: encoding failed", "key", key, "error", err)
+		} else if err := c.Set(ctx, key, data, ttl); err != nil {
+			logging.FromContext(ctx).Error("cache: set failed", "key", key, "error", err)
+		} else if stale() {
+			// invalidated between the check and the set
+			Invalidate(ctx, c, key)
+		}
+		return value, nil
+	})
+	if err != nil {
+		return value, err
+	}
+	return loaded.(T), nil
+}
+
+// Invalidate deletes keys from c, if any. The write they change has been
+// committed by then, so failures are only logged: the keys expire anyway.
+func Invalidate(ctx context.Context, c Cache, keys ...string) {
+	if c == nil || len(keys) == 0 {
+		return
+	}
+	loads.invalidate(keys...)
+	if err := c.Delete(ctx, keys...); err != nil {
+		logging.FromContext(ctx).Error("cache: delete failed", "keys", keys, "error", err)
+	}
+}
+
+// loads are the loads of Fetch in progress, by key.
+var loads = group{calls: map[string]*call{}}
+
+// group runs one call per key at a time, whose result is shared by those
+// asking for the key meanwhile. They stop waiting once their ctx is done.
+type group struct {
+	mu    sync.Mutex
+	calls map[string]*call
+}
+
+type call struct {
+	done  chan struct{}
+	value any
+	err   error
+	stale bool // guarded by the mutex of the group
+}
+
+// invalidate marks the calls in progress for keys stale.
+func (g *group) invalidate(keys ...string) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	for _, key := range keys {
+		if c, ok := g.calls[key]; ok {
+			c.stale = true
+		}
+	}
+}
+
+// do runs fn for key, unless a call for key is in progress. fn is told by
+// stale whether key was invalidated since it started.
+func (g *group) do(ctx context.Context, key string, fn func(stale func() bool) (any, error)) (any, error) {
+	g.mu.Lock()
+	if c, ok := g.calls[key]; ok {
+		g.mu.Unlock()
+		select {
+		case <-c.done:
+			return c.value, c.err
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+	c := &call{done: make(chan struct{})}
+	g.calls[key] = c
+	g.mu.Unlock()
+
+	defer func() {
+		g.mu.Lock()
+		delete(g.calls, key)
+		g.mu.Unlock()
+		close(c.done)
+	}()
+	c.value, c.err = fn(func() bool {
+		g.mu.Lock()
+		defer g.mu.Unlock()
+		return c.stale
+	})
+	return c.value, c.err
+}
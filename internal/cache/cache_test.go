@@ -0,0 +1,183 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/hy00nc/conduit-go/internal/metrics"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLRU(t *testing.T) {
+	asserts := assert.New(t)
+	ctx := context.Background()
+	now := time.Unix(1700000000, 0)
+	c := NewLRU(2)
+	c.Now = func() time.Time { return now }
+
+	c.Set(ctx, "a", []byte("1"), time.Minute)
+	c.Set(ctx, "b", []byte("2"), time.Minute)
+	value, ok, err := c.Get(ctx, "a")
+	asserts.NoError(err)
+	asserts.True(ok)
+	asserts.Equal("1", string(value))
+
+	c.Set(ctx, "c", []byte("3"), time.Minute)
+	_, ok, _ = c.Get(ctx, "b")
+	asserts.False(ok, "the least recently used value is evicted")
+	_, ok, _ = c.Get(ctx, "a")
+	asserts.True(ok)
+	asserts.Equal(2, c.Len())
+
+	c.Set(ctx, "a", []byte("4"), time.Second)
+	value, _, _ = c.Get(ctx, "a")
+	asserts.Equal("4", string(value))
+	now = now.Add(time.Second)
+	_, ok, _ = c.Get(ctx, "a")
+	asserts.False(ok, "values expire after their TTL")
+
+	asserts.NoError(c.Delete(ctx, "c", "missing"))
+	asserts.Zero(c.Len())
+}
+
+func TestFetch(t *testing.T) {
+	asserts := assert.New(t)
+	ctx := context.Background()
+	c := NewLRU(10)
+	hits := testutil.ToFloat64(metrics.CacheRequests.WithLabelValues("tags", "hit"))
+	misses := testutil.ToFloat64(metrics.CacheRequests.WithLabelValues("tags", "miss"))
+	loadsCount := 0
+	load := func(context.Context) ([]string, error) {
+		loadsCount++
+		return []string{"go", "sql"}, nil
+	}
+
+	for i := 0; i < 2; i++ {
+		tags, err := Fetch(ctx, c, "tags", TagsKey, time.Minute, load)
+		asserts.NoError(err)
+		asserts.Equal([]string{"go", "sql"}, tags)
+	}
+	asserts.Equal(1, loadsCount)
+	asserts.Equal(hits+1, testutil.ToFloat64(metrics.CacheRequests.WithLabelValues("tags", "hit")))
+	asserts.Equal(misses+1, testutil.ToFloat64(metrics.CacheRequests.WithLabelValues("tags", "miss")))
+
+	Invalidate(ctx, c, TagsKey)
+	Fetch(ctx, c, "tags", TagsKey, time.Minute, load)
+	asserts.Equal(2, loadsCount, "invalidated values are loaded again")
+
+	failed := errors.New("database is down")
+	_, err := Fetch(ctx, c, "users", UserKey(1), time.Minute, func(context.Context) (string, error) { return "", failed })
+	asserts.Equal(failed, err)
+	_, ok, _ := c.Get(ctx, UserKey(1))
+	asserts.False(ok, "errors are not cached")
+
+	Fetch(ctx, nil, "tags", TagsKey, time.Minute, load)
+	Fetch(ctx, nil, "tags", TagsKey, time.Minute, load)
+	asserts.Equal(4, loadsCount, "a nil cache always loads")
+	Invalidate(ctx, nil, TagsKey)
+}
+
+func TestFetchLoadsOnce(t *testing.T) {
+	asserts := assert.New(t)
+	ctx := context.Background()
+	c := NewLRU(10)
+	release := make(chan struct{})
+	var loadsCount atomic.Int32
+	load := func(context.Context) (int, error) {
+		loadsCount.Add(1)
+		<-release
+		return 42, nil
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			value, err := Fetch(ctx, c, "articles", ArticleKey("hot", false), time.Minute, load)
+			asserts.NoError(err)
+			asserts.Equal(42, value)
+		}()
+	}
+	asserts.Eventually(func() bool { return loadsCount.Load() == 1 }, time.Second, time.Millisecond)
+	time.Sleep(10 * time.Millisecond) // the others are waiting for it
+	close(release)
+	wg.Wait()
+	asserts.Equal(int32(1), loadsCount.Load(), "concurrent misses share one load")
+}
+
+func TestFetchOutlivesCanceledRequests(t *testing.T) {
+	asserts := assert.New(t)
+	c := NewLRU(10)
+	release := make(chan struct{})
+	load := func(ctx context.Context) (int, error) {
+		<-release
+		return 42, ctx.Err()
+	}
+
+	canceled, cancel := context.WithCancel(context.Background())
+	first := make(chan error)
+	go func() {
+		_, err := Fetch(canceled, c, "articles", ArticleKey("hot", false), time.Minute, load)
+		first <- err
+	}()
+	time.Sleep(10 * time.Millisecond) // the first request is loading
+	second := make(chan int)
+	go func() {
+		value, err := Fetch(context.Background(), c, "articles", ArticleKey("hot", false), time.Minute, load)
+		asserts.NoError(err)
+		second <- value
+	}()
+	time.Sleep(10 * time.Millisecond) // the second is waiting for it
+	cancel()
+	close(release)
+	asserts.NoError(<-first, "the load is not canceled with the request that started it")
+	asserts.Equal(42, <-second)
+
+	waiting, cancel := context.WithCancel(context.Background())
+	release = make(chan struct{})
+	go Fetch(context.Background(), c, "articles", ArticleKey("cold", false), time.Minute, load)
+	time.Sleep(10 * time.Millisecond)
+	cancel()
+	_, err := Fetch(waiting, c, "articles", ArticleKey("cold", false), time.Minute, load)
+	asserts.ErrorIs(err, context.Canceled, "requests stop waiting once canceled")
+	close(release)
+}
+
+func TestFetchInvalidatedDuringLoad(t *testing.T) {
+	asserts := assert.New(t)
+	ctx := context.Background()
+	c := NewLRU(10)
+	key := ArticleKey("edited", false)
+	loading, release := make(chan struct{}), make(chan struct{})
+	before := func(context.Context) (string, error) {
+		close(loading)
+		<-release // the row was read before the write committed
+		return "before", nil
+	}
+
+	fetched := make(chan string)
+	go func() {
+		value, err := Fetch(ctx, c, "articles", key, time.Minute, before)
+		asserts.NoError(err)
+		fetched <- value
+	}()
+	<-loading
+	Invalidate(ctx, c, key)
+	close(release)
+	asserts.Equal("before", <-fetched, "the load still answers the request that started it")
+	_, ok, _ := c.Get(ctx, key)
+	asserts.False(ok, "values loaded across an invalidation are not cached")
+
+	value, err := Fetch(ctx, c, "articles", key, time.Minute, func(context.Context) (string, error) { return "after", nil })
+	asserts.NoError(err)
+	asserts.Equal("after", value)
+	value, err = Fetch(ctx, c, "articles", key, time.Minute, func(context.Context) (string, error) { return "", errors.New("not cached") })
+	asserts.NoError(err, "later loads are cached again")
+	asserts.Equal("after", value)
+}
@@ -0,0 +1,34 @@
+package database
+
+import "gorm.io/gorm"
+
+// registrar is a position in one of GORM's callback chains.
+type registrar interface {
+	Register(name string, fn func(*gorm.DB)) error
+}
+
+// AroundStatements registers before and after, as name, to run just around
+// the statement of each of GORM's callback chains. after is made for the
+// chain's operation: "create", "query", "update", "delete", "row" or "raw".
+func AroundStatements(db *gorm.DB, name string, before func(*gorm.DB), after func(operation string) func(*gorm.DB)) error {
+	callback := db.Callback()
+	for _, chain := range []struct {
+		operation     string
+		before, after registrar
+	}{
+		{"create", callback.Create().Before("gorm:create"), callback.Create().After("gorm:create")},
+		{"query", callback.Query().Before("gorm:query"), callback.Query().After("gorm:query")},
+		{"update", callback.Update().Before("gorm:update"), callback.Update().After("gorm:update")},
+		{"delete", callback.Delete().Before("gorm:delete"), callback.Delete().After("gorm:delete")},
+		{"row", callback.Row().Before("gorm:row"), callback.Row().After("gorm:row")},
+		{"raw", callback.Raw().Before("gorm:raw"), callback.Raw().After("gorm:raw")},
+	} {
+		if err := chain.before.Register(name+":before_"+chain.operation, before); err != nil {
+			return err
+		}
+		if err := chain.after.Register(name+":after_"+chain.operation, after(chain.operation)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
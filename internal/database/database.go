@@ -1,6 +1,8 @@
 package database
 
 import (
+	"fmt"
+	"log/slog"
 	"os"
 
 	"github.com/hy00nc/conduit-go/internal/models"
@@ -43,14 +45,54 @@ func RemoveDB(db *gorm.DB) error {
 }
 
 func MigrateDB(db *gorm.DB) {
+	// Columns that used to be unique over soft-deleted rows as well
+	for _, column := range softUniqueColumns {
+		if err := dropColumnUnique(db, column.model, column.table, column.name, column.index); err != nil {
+			slog.Error("migrate: drop unique index", "table", column.table, "column", column.name, "error", err)
+		}
+	}
+
 	// Migrate the schema
-	db.AutoMigrate(&models.Article{})
-	db.AutoMigrate(&models.Profile{})
-	db.AutoMigrate(&models.User{})
-	db.AutoMigrate(&models.Tag{})
-	db.AutoMigrate(&models.Comment{})
-	db.AutoMigrate(&models.Follow{})
-	db.AutoMigrate(&models.Favorite{})
+	for _, model := range schema {
+		db.AutoMigrate(model)
+	}
+}
+
+// schema lists the models MigrateDB creates tables for, in order.
+var schema = []interface{}{
+	&models.Article{},
+	&models.Profile{},
+	&models.User{},
+	&models.Tag{},
+	&models.Comment{},
+	&models.Follow{},
+	&models.Favorite{},
+	&models.Upload{},
+	&models.FeedToken{},
+	&models.Webhook{},
+	&models.OutboxEvent{},
+	&models.WebhookDelivery{},
+}
+
+// Migrated returns an error naming the first table or column of the
+// models that MigrateDB has not created yet.
+func Migrated(db *gorm.DB) error {
+	migrator := db.Migrator()
+	for _, model := range schema {
+		statement := &gorm.Statement{DB: db}
+		if err := statement.Parse(model); err != nil {
+			return err
+		}
+		if !migrator.HasTable(model) {
+			return fmt.Errorf("table %s is missing", statement.Schema.Table)
+		}
+		for _, column := range statement.Schema.DBNames {
+			if !migrator.HasColumn(model, column) {
+				return fmt.Errorf("column %s.%s is missing", statement.Schema.Table, column)
+			}
+		}
+	}
+	return nil
 }
 
 func GetDB() *gorm.DB {
@@ -0,0 +1,23 @@
+package database
+
+import (
+	"testing"
+
+	"github.com/hy00nc/conduit-go/internal/models"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMigrated(t *testing.T) {
+	asserts := assert.New(t)
+	db := openTestDB(t)
+	asserts.NoError(Migrated(db))
+
+	asserts.NoError(db.Migrator().DropColumn(&models.User{}, "SuspendedAt"))
+	asserts.EqualError(Migrated(db), "column users.suspended_at is missing")
+
+	MigrateDB(db)
+	asserts.NoError(Migrated(db))
+
+	asserts.NoError(db.Migrator().DropTable(&models.Webhook{}))
+	asserts.EqualError(Migrated(db), "table webhooks is missing")
+}
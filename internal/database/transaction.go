@@ -0,0 +1,79 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"math/rand/v2"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Retry policy of Transaction. Variables so tests can shorten them.
+var (
+	TransactionAttempts = 5
+	TransactionBackoff  = 10 * time.Millisecond
+)
+
+// Transaction runs fn inside a transaction on db, committing if fn returns
+// nil and rolling back otherwise. When the database reports a transient
+// conflict (SQLite "database is locked", PostgreSQL serialization failures
+// and deadlocks) the whole transaction is retried with exponential backoff,
+// so fn must not have side effects outside tx.
+//
+// Called with a db that is already in a transaction, fn runs in a savepoint
+// and conflicts are left to the outermost Transaction to retry.
+func Transaction(ctx context.Context, db *gorm.DB, fn func(tx *gorm.DB) error) error {
+	db = db.WithContext(ctx)
+	if inTransaction(db) {
+		return db.Transaction(fn)
+	}
+	backoff := TransactionBackoff
+	for attempt := 1; ; attempt++ {
+		err := db.Transaction(fn)
+		if err == nil || attempt >= TransactionAttempts || !IsRetryable(err) {
+			return err
+		}
+		// jitter keeps competing writers from retrying in lockstep
+		wait := time.Duration(rand.Int64N(int64(backoff))) + backoff/2
+		select {
+		case <-ctx.Done():
+			return errors.Join(ctx.Err(), err)
+		case <-time.After(wait):
+		}
+		backoff *= 2
+	}
+}
+
+func inTransaction(db *gorm.DB) bool {
+	_, ok := db.Statement.ConnPool.(gorm.TxCommitter)
+	return ok
+}
+
+// sqlStateError is implemented by PostgreSQL driver errors (pgconn.PgError).
+type sqlStateError interface {
+	SQLState() string
+}
+
+// IsRetryable reports whether err is a transient conflict after which the
+// transaction may succeed if run again.
+func IsRetryable(err error) bool {
+	if err == nil {
+		return false
+	}
+	var stateErr sqlStateError
+	if errors.As(err, &stateErr) {
+		switch stateErr.SQLState() {
+		case "40001", "40P01": // serialization_failure, deadlock_detected
+			return true
+		}
+	}
+	message := err.Error()
+	for _, transient := range []string{"database is locked", "database table is locked", "SQLSTATE 40001", "SQLSTATE 40P01"} {
+		if strings.Contains(message, transient) {
+			return true
+		}
+	}
+	return false
+}
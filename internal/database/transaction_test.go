@@ -0,0 +1,155 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/hy00nc/conduit-go/internal/models"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+func openTestDB(t *testing.T) *gorm.DB {
+	db, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "tx.db")), &gorm.Config{
+		Logger: logger.Default.LogMode(logger.Silent),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	MigrateDB(db)
+	t.Cleanup(func() { CloseDB(db) })
+	TransactionBackoff = time.Millisecond
+	return db
+}
+
+func countTags(db *gorm.DB) int64 {
+	var count int64
+	db.Model(&models.Tag{}).Count(&count)
+	return count
+}
+
+type pgError struct{ code string }
+
+func (e pgError) Error() string    { return "pg error " + e.code }
+func (e pgError) SQLState() string { return e.code }
+
+func TestTransactionRollsBackMidway(t *testing.T) {
+	asserts := assert.New(t)
+	db := openTestDB(t)
+	failure := errors.New("second write failed")
+
+	err := Transaction(context.Background(), db, func(tx *gorm.DB) error {
+		if err := tx.Create(&models.Tag{Name: "first"}).Error; err != nil {
+			return err
+		}
+		return failure
+	})
+	asserts.ErrorIs(err, failure)
+	asserts.Zero(countTags(db))
+
+	err = Transaction(context.Background(), db, func(tx *gorm.DB) error {
+		if err := tx.Create(&models.Tag{Name: "first"}).Error; err != nil {
+			return err
+		}
+		return tx.Create(&models.Tag{Name: "second"}).Error
+	})
+	asserts.NoError(err)
+	asserts.EqualValues(2, countTags(db))
+}
+
+func TestTransactionRetriesConflicts(t *testing.T) {
+	asserts := assert.New(t)
+	db := openTestDB(t)
+
+	attempts := 0
+	err := Transaction(context.Background(), db, func(tx *gorm.DB) error {
+		attempts++
+		if err := tx.Create(&models.Tag{Name: fmt.Sprint("attempt ", attempts)}).Error; err != nil {
+			return err
+		}
+		if attempts < 3 {
+			return errors.New("database is locked")
+		}
+		return nil
+	})
+	asserts.NoError(err)
+	asserts.Equal(3, attempts)
+	// only the writes of the successful attempt are kept
+	var tags []models.Tag
+	db.Find(&tags)
+	asserts.Len(tags, 1)
+	asserts.Equal("attempt 3", tags[0].Name)
+
+	attempts = 0
+	err = Transaction(context.Background(), db, func(tx *gorm.DB) error {
+		attempts++
+		return pgError{"40001"}
+	})
+	asserts.ErrorAs(err, &pgError{})
+	asserts.Equal(TransactionAttempts, attempts)
+
+	attempts = 0
+	err = Transaction(context.Background(), db, func(tx *gorm.DB) error {
+		attempts++
+		return pgError{"23505"} // unique_violation
+	})
+	asserts.Error(err)
+	asserts.Equal(1, attempts)
+}
+
+func TestTransactionStopsRetryingOnCancel(t *testing.T) {
+	asserts := assert.New(t)
+	db := openTestDB(t)
+	ctx, cancel := context.WithCancel(context.Background())
+
+	attempts := 0
+	err := Transaction(ctx, db, func(tx *gorm.DB) error {
+		attempts++
+		cancel()
+		return errors.New("database is locked")
+	})
+	asserts.ErrorIs(err, context.Canceled)
+	asserts.Equal(1, attempts)
+}
+
+func TestNestedTransaction(t *testing.T) {
+	asserts := assert.New(t)
+	db := openTestDB(t)
+	ctx := context.Background()
+
+	inner := 0
+	err := Transaction(ctx, db, func(tx *gorm.DB) error {
+		if err := tx.Create(&models.Tag{Name: "outer"}).Error; err != nil {
+			return err
+		}
+		// a failed savepoint is not retried and leaves the outer writes alone
+		nestedErr := Transaction(ctx, tx, func(tx *gorm.DB) error {
+			inner++
+			tx.Create(&models.Tag{Name: "inner"})
+			return errors.New("database is locked")
+		})
+		asserts.Error(nestedErr)
+		return nil
+	})
+	asserts.NoError(err)
+	asserts.Equal(1, inner)
+	var tags []models.Tag
+	db.Find(&tags)
+	asserts.Len(tags, 1)
+	asserts.Equal("outer", tags[0].Name)
+}
+
+func TestIsRetryable(t *testing.T) {
+	asserts := assert.New(t)
+	asserts.True(IsRetryable(errors.New("database is locked (5) (SQLITE_BUSY)")))
+	asserts.True(IsRetryable(fmt.Errorf("commit: %w", pgError{"40P01"})))
+	asserts.True(IsRetryable(errors.New("ERROR: could not serialize access (SQLSTATE 40001)")))
+	asserts.False(IsRetryable(errors.New("UNIQUE constraint failed: tags.name")))
+	asserts.False(IsRetryable(nil))
+}
@@ -0,0 +1,67 @@
+package database
+
+import (
+	"fmt"
+	"regexp"
+
+	"github.com/hy00nc/conduit-go/internal/models"
+	"gorm.io/gorm"
+)
+
+// softUniqueColumns are unique only among rows that are not soft-deleted,
+// through a partial unique index. Databases created before that carry a
+// plain UNIQUE constraint, which MigrateDB drops.
+var softUniqueColumns = []struct {
+	model              interface{}
+	table, name, index string
+}{
+	{&models.User{}, "users", "email", "idx_users_email"},
+	{&models.Profile{}, "profiles", "name", "idx_profiles_name"},
+	{&models.Tag{}, "tags", "name", "idx_tags_name"},
+}
+
+func dropColumnUnique(db *gorm.DB, model interface{}, table, column, index string) error {
+	if !db.Migrator().HasTable(model) {
+		return nil
+	}
+	switch db.Dialector.Name() {
+	case "sqlite":
+		return rebuildWithoutUnique(db, model, table, column, index)
+	case "postgres":
+		return db.Exec(fmt.Sprintf(`ALTER TABLE %q DROP CONSTRAINT IF EXISTS %q`, table, table+"_"+column+"_key")).Error
+	}
+	return nil
+}
+
+// rebuildWithoutUnique copies an SQLite table into one created without the
+// column's UNIQUE clause, the way SQLite recommends altering constraints.
+//
+// The partial index is created right away: gorm's SQLite migrator reads a
+// unique index as a unique column and would otherwise put the clause back.
+// The table's other indexes are recreated by AutoMigrate.
+func rebuildWithoutUnique(db *gorm.DB, model interface{}, table, column, index string) error {
+	var createSQL string
+	if err := db.Raw("SELECT sql FROM sqlite_master WHERE type = 'table' AND name = ?", table).Row().Scan(&createSQL); err != nil {
+		return err
+	}
+	inlineUnique := regexp.MustCompile("(`" + column + "` [^,]*?) UNIQUE")
+	if !inlineUnique.MatchString(createSQL) {
+		return nil
+	}
+	temporary := table + "__rebuild"
+	createSQL = inlineUnique.ReplaceAllString(createSQL, "$1")
+	createSQL = regexp.MustCompile("^CREATE TABLE [`\"]?"+table+"[`\"]?").ReplaceAllString(createSQL, "CREATE TABLE `"+temporary+"`")
+	return db.Transaction(func(tx *gorm.DB) error {
+		for _, statement := range []string{
+			createSQL,
+			fmt.Sprintf("INSERT INTO `%s` SELECT * FROM `%s`", temporary, table),
+			fmt.Sprintf("DROP TABLE `%s`", table),
+			fmt.Sprintf("ALTER TABLE `%s` RENAME TO `%s`", temporary, table),
+		} {
+			if err := tx.Exec(statement).Error; err != nil {
+				return err
+			}
+		}
+		return tx.Migrator().CreateIndex(model, index)
+	})
+}
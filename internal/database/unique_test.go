@@ -0,0 +1,56 @@
+package database
+
+import (
+	"testing"
+
+	"github.com/hy00nc/conduit-go/internal/models"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/gorm"
+)
+
+// legacyTag is models.Tag as created before soft-deleted rows were left out
+// of the unique constraint.
+type legacyTag struct {
+	gorm.Model
+	Name string `gorm:"unique"`
+}
+
+func (legacyTag) TableName() string { return "tags" }
+
+func TestUniqueIgnoresSoftDeleted(t *testing.T) {
+	asserts := assert.New(t)
+	db := openTestDB(t)
+
+	tag := models.Tag{Name: "go"}
+	asserts.NoError(db.Create(&tag).Error)
+	asserts.Error(db.Create(&models.Tag{Name: "go"}).Error)
+	asserts.NoError(db.Delete(&tag).Error)
+	asserts.NoError(db.Create(&models.Tag{Name: "go"}).Error)
+}
+
+func TestMigrateDropsLegacyUnique(t *testing.T) {
+	asserts := assert.New(t)
+	db := openTestDB(t)
+	asserts.NoError(db.Migrator().DropTable(&models.Tag{}))
+	asserts.NoError(db.AutoMigrate(&legacyTag{}))
+	legacy := legacyTag{Name: "go"}
+	asserts.NoError(db.Create(&legacy).Error)
+	asserts.NoError(db.Delete(&legacy).Error)
+	asserts.Error(db.Create(&legacyTag{Name: "go"}).Error)
+
+	MigrateDB(db)
+
+	var tags []models.Tag
+	db.Unscoped().Find(&tags)
+	asserts.Len(tags, 1, "rows are kept")
+	asserts.NoError(db.Create(&models.Tag{Name: "go"}).Error)
+	asserts.Error(db.Create(&models.Tag{Name: "go"}).Error)
+	asserts.True(db.Migrator().HasIndex(&models.Tag{}, "idx_tags_deleted_at"))
+
+	// the constraint stays gone on later migrations
+	MigrateDB(db)
+	var createSQL string
+	db.Raw("SELECT sql FROM sqlite_master WHERE type = 'table' AND name = 'tags'").Row().Scan(&createSQL)
+	asserts.NotContains(createSQL, "UNIQUE")
+	asserts.True(db.Migrator().HasIndex(&models.Tag{}, "idx_tags_name"))
+}
@@ -0,0 +1,70 @@
+// Package importer creates articles from Markdown files with YAML front
+// matter, as written by static site generators such as Jekyll or Hugo.
+package importer
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+var errNoFrontMatter = errors.New("no front matter")
+
+// FrontMatter is the metadata block at the top of a file, between "---"
+// lines.
+type FrontMatter struct {
+	Title       string    `yaml:"title"`
+	Description string    `yaml:"description"`
+	Tags        Tags      `yaml:"tags"`
+	Author      string    `yaml:"author"` // username
+	Date        time.Time `yaml:"date"`
+	Slug        string    `yaml:"slug"`
+}
+
+// Tags accepts a YAML list as well as a comma separated string.
+type Tags []string
+
+func (t *Tags) UnmarshalYAML(value *yaml.Node) error {
+	if value.Kind == yaml.ScalarNode {
+		*t = nil
+		for _, tag := range strings.Split(value.Value, ",") {
+			if tag = strings.TrimSpace(tag); tag != "" {
+				*t = append(*t, tag)
+			}
+		}
+		return nil
+	}
+	var tags []string
+	if err := value.Decode(&tags); err != nil {
+		return err
+	}
+	*t = tags
+	return nil
+}
+
+// Parse splits a file into its front matter and Markdown body.
+func Parse(data []byte) (FrontMatter, string, error) {
+	var front FrontMatter
+	data = bytes.ReplaceAll(data, []byte("\r\n"), []byte("\n"))
+	rest, ok := bytes.CutPrefix(data, []byte("---\n"))
+	if !ok {
+		return front, "", errNoFrontMatter
+	}
+	header, body, ok := bytes.Cut(rest, []byte("\n---\n"))
+	if empty, found := bytes.CutPrefix(rest, []byte("---\n")); found {
+		header, body, ok = nil, empty, true
+	}
+	if !ok {
+		// a file may end right after the closing line
+		if header, ok = bytes.CutSuffix(rest, []byte("\n---")); !ok {
+			return front, "", errNoFrontMatter
+		}
+	}
+	if err := yaml.Unmarshal(header, &front); err != nil {
+		return front, "", err
+	}
+	return front, strings.TrimSpace(string(body)), nil
+}
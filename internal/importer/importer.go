@@ -0,0 +1,158 @@
+package importer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/gosimple/slug"
+	"github.com/hy00nc/conduit-go/internal/models"
+	"github.com/hy00nc/conduit-go/internal/service"
+)
+
+// Outcomes of importing a file.
+const (
+	Created = "created"
+	Skipped = "skipped" // an article with the slug exists, maybe in the trash, e.g. from an earlier run
+	Failed  = "failed"
+)
+
+// errDryRun rolls back the transaction of a dry run.
+var errDryRun = errors.New("dry run")
+
+type Options struct {
+	// DryRun checks every file as an import would without saving anything.
+	DryRun bool
+	// Author is the username for files without an author.
+	Author string
+}
+
+type Result struct {
+	File    string
+	Slug    string
+	Outcome string
+	Err     error
+}
+
+type Importer struct {
+	Store   service.Store
+	Options Options
+	files   map[string]string // imported file by slug, to catch duplicates within a run
+}
+
+func New(store service.Store, options Options) *Importer {
+	return &Importer{Store: store, Options: options, files: map[string]string{}}
+}
+
+// isMarkdown reports whether name has a Markdown extension.
+func isMarkdown(name string) bool {
+	switch strings.ToLower(filepath.Ext(name)) {
+	case ".md", ".markdown":
+		return true
+	}
+	return false
+}
+
+// Dir imports the Markdown files below dir in lexical order. Errors are
+// reported per file; the error returned is about walking dir itself.
+func (im *Importer) Dir(ctx context.Context, dir string) ([]Result, error) {
+	var results []Result
+	err := filepath.WalkDir(dir, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if entry.IsDir() || !isMarkdown(path) {
+			return nil
+		}
+		results = append(results, im.File(ctx, path))
+		return ctx.Err()
+	})
+	return results, err
+}
+
+// File imports one file. Articles are created through the article service,
+// so tags are reused or created like for the API; the slug and date from
+// the front matter are kept. The slug defaults to the file name.
+func (im *Importer) File(ctx context.Context, path string) Result {
+	result := Result{File: path, Outcome: Failed}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		result.Err = err
+		return result
+	}
+	front, body, err := Parse(data)
+	if err != nil {
+		result.Err = err
+		return result
+	}
+	result.Slug = front.Slug
+	if result.Slug == "" {
+		result.Slug = slug.Make(strings.TrimSuffix(filepath.Base(path), filepath.Ext(path)))
+	} else if canonical := slug.Make(result.Slug); canonical != result.Slug {
+		// a slug the API could not have made would not round-trip in URLs
+		result.Err = fmt.Errorf("slug %q is not canonical, use %q", result.Slug, canonical)
+		return result
+	}
+	if other, ok := im.files[result.Slug]; ok {
+		result.Err = fmt.Errorf("slug %q is also used by %s", result.Slug, other)
+		return result
+	}
+	im.files[result.Slug] = path
+	username := front.Author
+	if username == "" {
+		username = im.Options.Author
+	}
+	if username == "" {
+		result.Err = errors.New("no author")
+		return result
+	}
+
+	err = im.Store.Transaction(ctx, func(tx service.Store) error {
+		profile, err := tx.ProfileByName(ctx, username)
+		if errors.Is(err, service.ErrNotFound) {
+			return fmt.Errorf("unknown author %q", username)
+		}
+		if err != nil {
+			return err
+		}
+		if _, err := tx.ArticleBySlug(ctx, result.Slug); err == nil {
+			result.Outcome = Skipped
+			return nil
+		} else if !errors.Is(err, service.ErrNotFound) {
+			return err
+		}
+		// articles in the trash keep their slug until purged
+		if _, err := tx.DeletedArticleBySlug(ctx, result.Slug); err == nil {
+			result.Outcome = Skipped
+			return nil
+		} else if !errors.Is(err, service.ErrNotFound) {
+			return err
+		}
+		author := models.User{ProfileID: profile.ID, Profile: profile}
+		_, err = service.NewArticleService(tx, nil).Create(ctx, author, service.ArticleInput{
+			Title:       front.Title,
+			Description: front.Description,
+			Body:        body,
+			TagList:     front.Tags,
+			Slug:        result.Slug,
+			CreatedAt:   front.Date,
+		})
+		if err != nil {
+			return err
+		}
+		result.Outcome = Created
+		if im.Options.DryRun {
+			return errDryRun
+		}
+		return nil
+	})
+	if err != nil && !errors.Is(err, errDryRun) {
+		result.Outcome = Failed
+		result.Err = err
+	}
+	return result
+}
@@ -0,0 +1,166 @@
+package importer
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/hy00nc/conduit-go/internal/database"
+	"github.com/hy00nc/conduit-go/internal/models"
+	"github.com/hy00nc/conduit-go/internal/service"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+func TestParse(t *testing.T) {
+	asserts := assert.New(t)
+	front, body, err := Parse([]byte("---\r\ntitle: Hello\r\ntags: go, web\r\ndate: 2019-05-01\r\n---\r\n\r\n# Hi\r\n"))
+	asserts.NoError(err)
+	asserts.Equal("Hello", front.Title)
+	asserts.Equal(Tags{"go", "web"}, front.Tags)
+	asserts.Equal(time.Date(2019, 5, 1, 0, 0, 0, 0, time.UTC), front.Date)
+	asserts.Equal("# Hi", body)
+
+	front, body, err = Parse([]byte("---\ntags: [go]\n---"))
+	asserts.NoError(err)
+	asserts.Equal(Tags{"go"}, front.Tags)
+	asserts.Empty(body)
+
+	_, body, err = Parse([]byte("---\n---\nbody"))
+	asserts.NoError(err)
+	asserts.Equal("body", body)
+
+	_, _, err = Parse([]byte("# No front matter"))
+	asserts.ErrorIs(err, errNoFrontMatter)
+	_, _, err = Parse([]byte("---\ntitle: [unclosed\n---\n"))
+	asserts.Error(err)
+}
+
+func openStore(t *testing.T) service.GormStore {
+	db, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "import.db")), &gorm.Config{
+		Logger: logger.Default.LogMode(logger.Silent),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	database.MigrateDB(db)
+	t.Cleanup(func() { database.CloseDB(db) })
+	return service.GormStore{DB: db}
+}
+
+func writeFiles(t *testing.T, files map[string]string) string {
+	dir := t.TempDir()
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+func TestImportDir(t *testing.T) {
+	asserts := assert.New(t)
+	ctx := context.Background()
+	store := openStore(t)
+	_, err := service.NewUserService(store, nil).Register(ctx, service.RegisterInput{Username: "sally", Email: "sally@example.com", Password: "secret"})
+	asserts.NoError(err)
+	dir := writeFiles(t, map[string]string{
+		"2019/hello-world.md":  "---\ntitle: Hello World\ndescription: First\ntags: [go, web]\ndate: 2019-05-01T10:00:00Z\n---\nHello.\n",
+		"2019/second.markdown": "---\ntitle: Second\ndescription: Another\nauthor: sally\nslug: the-second\ntags: go\n---\nAgain.\n",
+		"2020/stranger.md":     "---\ntitle: Stranger\ndescription: d\nauthor: nobody\n---\nbody\n",
+		"2020/untitled.md":     "---\ndescription: d\n---\nbody\n",
+		"notes.txt":            "not markdown",
+	})
+	options := Options{Author: "sally", DryRun: true}
+
+	outcomes := func(results []Result) map[string]string {
+		byFile := map[string]string{}
+		for _, result := range results {
+			rel, _ := filepath.Rel(dir, result.File)
+			byFile[filepath.ToSlash(rel)] = result.Outcome
+		}
+		return byFile
+	}
+	results, err := New(store, options).Dir(ctx, dir)
+	asserts.NoError(err)
+	asserts.Equal(map[string]string{"2019/hello-world.md": Created, "2019/second.markdown": Created, "2020/stranger.md": Failed, "2020/untitled.md": Failed}, outcomes(results))
+	asserts.EqualError(results[2].Err, `unknown author "nobody"`)
+	asserts.EqualError(results[3].Err, "title is invalid")
+	var count int64
+	store.DB.Model(&models.Article{}).Count(&count)
+	asserts.Zero(count, "a dry run saves nothing")
+	store.DB.Model(&models.Tag{}).Count(&count)
+	asserts.Zero(count)
+
+	options.DryRun = false
+	results, err = New(store, options).Dir(ctx, dir)
+	asserts.NoError(err)
+	asserts.Equal(map[string]string{"2019/hello-world.md": Created, "2019/second.markdown": Created, "2020/stranger.md": Failed, "2020/untitled.md": Failed}, outcomes(results))
+	article, err := store.ArticleBySlug(ctx, "hello-world")
+	asserts.NoError(err)
+	asserts.Equal("sally", article.Author.Name)
+	asserts.Equal("Hello.", article.Body)
+	asserts.Len(article.Tags, 2)
+	asserts.True(article.CreatedAt.Equal(time.Date(2019, 5, 1, 10, 0, 0, 0, time.UTC)))
+	_, err = store.ArticleBySlug(ctx, "the-second")
+	asserts.NoError(err)
+	store.DB.Model(&models.Tag{}).Count(&count)
+	asserts.EqualValues(2, count, "tags are shared between articles")
+
+	// re-running skips what was imported
+	results, err = New(store, options).Dir(ctx, dir)
+	asserts.NoError(err)
+	asserts.Equal(map[string]string{"2019/hello-world.md": Skipped, "2019/second.markdown": Skipped, "2020/stranger.md": Failed, "2020/untitled.md": Failed}, outcomes(results))
+	store.DB.Model(&models.Article{}).Count(&count)
+	asserts.EqualValues(2, count)
+}
+
+func TestImportDuplicateSlug(t *testing.T) {
+	asserts := assert.New(t)
+	store := openStore(t)
+	dir := writeFiles(t, map[string]string{
+		"a.md": "---\ntitle: A\nslug: same\n---\nbody\n",
+		"b.md": "---\ntitle: B\nslug: same\n---\nbody\n",
+	})
+	results, err := New(store, Options{}).Dir(context.Background(), dir)
+	asserts.NoError(err)
+	asserts.Len(results, 2)
+	asserts.EqualError(results[0].Err, "no author")
+	asserts.Contains(results[1].Err.Error(), "also used by")
+}
+
+func TestImportSlugs(t *testing.T) {
+	asserts := assert.New(t)
+	ctx := context.Background()
+	store := openStore(t)
+	sally, err := service.NewUserService(store, nil).Register(ctx, service.RegisterInput{Username: "sally", Email: "sally@example.com", Password: "secret"})
+	asserts.NoError(err)
+	articles := service.NewArticleService(store, nil)
+	trashed, err := articles.Create(ctx, sally, service.ArticleInput{Title: "Trashed", Description: "d", Body: "b", Slug: "trashed"})
+	asserts.NoError(err)
+	asserts.NoError(articles.Delete(ctx, sally, trashed.Slug))
+	dir := writeFiles(t, map[string]string{
+		"a.md": "---\ntitle: A\nslug: Not Canonical\n---\nbody\n",
+		"b.md": "---\ntitle: B\nslug: ../admin\n---\nbody\n",
+		"c.md": "---\ntitle: C\nslug: trashed\n---\nbody\n",
+		"d.md": "---\ntitle: D\ndescription: d\nslug: fine-slug\n---\nbody\n",
+	})
+
+	results, err := New(store, Options{Author: "sally"}).Dir(ctx, dir)
+	asserts.NoError(err)
+	asserts.Len(results, 4)
+	asserts.Equal(Failed, results[0].Outcome)
+	asserts.EqualError(results[0].Err, `slug "Not Canonical" is not canonical, use "not-canonical"`)
+	asserts.Equal(Failed, results[1].Outcome)
+	asserts.Equal(Skipped, results[2].Outcome, "slugs of trashed articles are taken")
+	asserts.NoError(results[2].Err)
+	asserts.Equal(Created, results[3].Outcome)
+}
@@ -0,0 +1,125 @@
+// Package logging writes structured JSON logs with log/slog. Requests carry
+// their own logger in the context, with attributes such as the request ID
+// that every line logged for the request repeats.
+//
+// Secrets are never logged: the values of attributes, headers and query
+// parameters with a sensitive name, such as Authorization or password, are
+// replaced by Redacted.
+package logging
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+)
+
+// Redacted replaces the values of secrets.
+const Redacted = "[REDACTED]"
+
+// sensitive names, lower case, of attributes, headers and query parameters.
+var sensitive = map[string]bool{
+	"authorization": true,
+	"cookie":        true,
+	"set-cookie":    true,
+	"password":      true,
+	"hash":          true,
+	"secret":        true,
+	"token":         true,
+}
+
+// Sensitive reports whether values named name must not be logged.
+func Sensitive(name string) bool {
+	return sensitive[strings.ToLower(name)]
+}
+
+func redact(groups []string, attr slog.Attr) slog.Attr {
+	if Sensitive(attr.Key) {
+		return slog.String(attr.Key, Redacted)
+	}
+	return attr
+}
+
+// ParseLevel reads "debug", "info", "warn" or "error"; anything else is
+// info.
+func ParseLevel(s string) slog.Level {
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(s)); err != nil {
+		return slog.LevelInfo
+	}
+	return level
+}
+
+// New returns a logger writing JSON lines to w at level and above.
+func New(w io.Writer, level slog.Leveler) *slog.Logger {
+	return slog.New(slog.NewJSONHandler(w, &slog.HandlerOptions{Level: level, ReplaceAttr: redact}))
+}
+
+// Setup makes a logger writing to standard error at the level of
+// CONDUIT_LOG_LEVEL the default, also for the log package.
+func Setup() {
+	slog.SetDefault(New(os.Stderr, ParseLevel(os.Getenv("CONDUIT_LOG_LEVEL"))))
+}
+
+// Headers returns h for logging, with secrets redacted.
+func Headers(h http.Header) slog.Value {
+	attrs := make([]slog.Attr, 0, len(h))
+	for name, values := range h {
+		attrs = append(attrs, slog.String(name, strings.Join(values, ", ")))
+	}
+	return slog.GroupValue(attrs...)
+}
+
+// URL returns the path and query of u for logging, with secrets redacted.
+func URL(u *url.URL) string {
+	if u.RawQuery == "" {
+		return u.Path
+	}
+	query := u.Query()
+	for name := range query {
+		if Sensitive(name) {
+			query[name] = []string{Redacted}
+		}
+	}
+	return u.Path + "?" + query.Encode()
+}
+
+// scope is the logger of a request, which handlers further in add
+// attributes to.
+type scope struct {
+	mu     sync.Mutex
+	logger *slog.Logger
+}
+
+type contextKey struct{}
+
+// NewContext returns ctx with logger as the logger of the request.
+func NewContext(ctx context.Context, logger *slog.Logger) context.Context {
+	return context.WithValue(ctx, contextKey{}, &scope{logger: logger})
+}
+
+// FromContext returns the logger of the request ctx belongs to, or the
+// default logger.
+func FromContext(ctx context.Context) *slog.Logger {
+	if s, ok := ctx.Value(contextKey{}).(*scope); ok {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		return s.logger
+	}
+	return slog.Default()
+}
+
+// With adds attributes to the logger of the request ctx belongs to, for
+// everything logged for the request from then on, also by the handlers
+// that passed ctx in.
+func With(ctx context.Context, args ...any) {
+	if s, ok := ctx.Value(contextKey{}).(*scope); ok {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		s.logger = s.logger.With(args...)
+	}
+}
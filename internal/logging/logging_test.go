@@ -0,0 +1,56 @@
+package logging
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRedaction(t *testing.T) {
+	asserts := assert.New(t)
+	var buf bytes.Buffer
+	logger := New(&buf, slog.LevelInfo)
+	logger.Info("login", "email", "sally@example.com", "password", "secret", slog.Group("user", "Token", "abc"),
+		"headers", Headers(http.Header{"Authorization": {"Token abc"}, "Accept": {"text/html"}}))
+
+	var entry map[string]interface{}
+	asserts.NoError(json.Unmarshal(buf.Bytes(), &entry))
+	asserts.Equal("sally@example.com", entry["email"])
+	asserts.Equal(Redacted, entry["password"])
+	asserts.Equal(Redacted, entry["user"].(map[string]interface{})["Token"])
+	asserts.Equal(map[string]interface{}{"Authorization": Redacted, "Accept": "text/html"}, entry["headers"])
+
+	u, _ := url.Parse("/feeds?token=abc&limit=1")
+	asserts.Equal("/feeds?limit=1&token=%5BREDACTED%5D", URL(u))
+	u, _ = url.Parse("/api/articles")
+	asserts.Equal("/api/articles", URL(u))
+}
+
+func TestParseLevel(t *testing.T) {
+	asserts := assert.New(t)
+	asserts.Equal(slog.LevelDebug, ParseLevel("debug"))
+	asserts.Equal(slog.LevelWarn, ParseLevel("WARN"))
+	asserts.Equal(slog.LevelInfo, ParseLevel(""))
+	asserts.Equal(slog.LevelInfo, ParseLevel("loud"))
+}
+
+func TestContext(t *testing.T) {
+	asserts := assert.New(t)
+	var buf bytes.Buffer
+	ctx := NewContext(context.Background(), New(&buf, slog.LevelInfo).With("requestId", "1"))
+	inner := context.WithValue(ctx, struct{}{}, true)
+	With(inner, "userId", 7)
+	FromContext(ctx).Info("done")
+
+	var entry map[string]interface{}
+	asserts.NoError(json.Unmarshal(buf.Bytes(), &entry))
+	asserts.Equal("1", entry["requestId"])
+	asserts.EqualValues(7, entry["userId"], "attributes added further in are seen outside")
+	asserts.Equal(slog.Default(), FromContext(context.Background()))
+}
@@ -0,0 +1,59 @@
+package markdown
+
+import (
+	"sync"
+	"time"
+)
+
+// Upper bound on rendered articles kept in memory
+const defaultCacheSize = 1024
+
+type cacheEntry struct {
+	updatedAt time.Time
+	rendered  Rendered
+}
+
+// Cache keeps rendered articles keyed by ID. An entry is only served while
+// its UpdatedAt matches the article, so an edit is never shown stale even
+// if Invalidate is missed.
+type Cache struct {
+	mu      sync.Mutex
+	size    int
+	entries map[uint]cacheEntry
+}
+
+// DefaultCache is shared by the serializers and invalidated by the handlers.
+var DefaultCache = NewCache(defaultCacheSize)
+
+func NewCache(size int) *Cache {
+	return &Cache{size: size, entries: map[uint]cacheEntry{}}
+}
+
+func (c *Cache) Render(id uint, updatedAt time.Time, source string) Rendered {
+	c.mu.Lock()
+	entry, ok := c.entries[id]
+	c.mu.Unlock()
+	if ok && entry.updatedAt.Equal(updatedAt) {
+		return entry.rendered
+	}
+
+	rendered := Render(source)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if _, exists := c.entries[id]; !exists && len(c.entries) >= c.size {
+		// evict an arbitrary entry to stay within bounds
+		for key := range c.entries {
+			delete(c.entries, key)
+			break
+		}
+	}
+	c.entries[id] = cacheEntry{updatedAt: updatedAt, rendered: rendered}
+	return rendered
+}
+
+func (c *Cache) Invalidate(id uint) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.entries, id)
+}
@@ -0,0 +1,100 @@
+package markdown
+
+import (
+	"bytes"
+	"log/slog"
+	"math"
+	"regexp"
+	"strings"
+	"unicode"
+
+	"github.com/microcosm-cc/bluemonday"
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/ast"
+	"github.com/yuin/goldmark/parser"
+	"github.com/yuin/goldmark/text"
+)
+
+// Reading speed used for the reading time estimate
+const wordsPerMinute = 200
+
+type Heading struct {
+	Level int    `json:"level"`
+	Text  string `json:"text"`
+	ID    string `json:"id"`
+}
+
+type Rendered struct {
+	HTML string
+	TOC  []Heading
+}
+
+var converter = goldmark.New(
+	goldmark.WithParserOptions(parser.WithAutoHeadingID()),
+)
+
+var policy = newPolicy()
+
+func newPolicy() *bluemonday.Policy {
+	p := bluemonday.UGCPolicy()
+	// keep the language hint of fenced code blocks for client side highlighting
+	p.AllowAttrs("class").Matching(regexp.MustCompile(`^language-[a-zA-Z0-9_+#-]+$`)).OnElements("code")
+	return p
+}
+
+// Render converts CommonMark to sanitized HTML. Headings get stable ids,
+// which the returned table of contents links to.
+func Render(source string) Rendered {
+	src := []byte(source)
+	doc := converter.Parser().Parse(text.NewReader(src))
+
+	toc := []Heading{}
+	ast.Walk(doc, func(node ast.Node, entering bool) (ast.WalkStatus, error) {
+		heading, ok := node.(*ast.Heading)
+		if !ok || !entering {
+			return ast.WalkContinue, nil
+		}
+		id, _ := heading.AttributeString("id")
+		idBytes, _ := id.([]byte)
+		toc = append(toc, Heading{Level: heading.Level, Text: nodeText(heading, src), ID: string(idBytes)})
+		return ast.WalkSkipChildren, nil
+	})
+
+	var buf bytes.Buffer
+	if err := converter.Renderer().Render(&buf, src, doc); err != nil {
+		slog.Error("markdown: render failed", "error", err)
+	}
+	return Rendered{
+		HTML: policy.Sanitize(buf.String()),
+		TOC:  toc,
+	}
+}
+
+func nodeText(node ast.Node, source []byte) string {
+	var sb strings.Builder
+	for child := node.FirstChild(); child != nil; child = child.NextSibling() {
+		switch n := child.(type) {
+		case *ast.Text:
+			sb.Write(n.Segment.Value(source))
+			if n.SoftLineBreak() {
+				sb.WriteByte(' ')
+			}
+		case *ast.String:
+			sb.Write(n.Value)
+		default:
+			sb.WriteString(nodeText(child, source))
+		}
+	}
+	return sb.String()
+}
+
+// ReadingTime estimates the minutes needed to read the markdown source.
+func ReadingTime(source string) int {
+	words := len(strings.FieldsFunc(source, func(r rune) bool {
+		return unicode.IsSpace(r)
+	}))
+	if words == 0 {
+		return 0
+	}
+	return int(math.Ceil(float64(words) / wordsPerMinute))
+}
@@ -0,0 +1,74 @@
+package markdown
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRenderSanitizes(t *testing.T) {
+	asserts := assert.New(t)
+	rendered := Render("Hello <script>alert(1)</script>\n\n" +
+		"<img src=\"x.png\" onerror=\"alert(1)\">\n\n" +
+		"[click](javascript:alert(1)) <a href=\"javascript:alert(1)\" onclick=\"alert(1)\">raw</a>\n")
+	asserts.Contains(rendered.HTML, "Hello")
+	asserts.NotContains(rendered.HTML, "<script")
+	asserts.NotContains(rendered.HTML, "javascript:")
+	asserts.NotContains(rendered.HTML, "onerror")
+	asserts.NotContains(rendered.HTML, "onclick")
+
+	// the policy holds even where goldmark would let raw HTML through
+	sanitized := policy.Sanitize(`<p onclick="alert(1)">p</p><a href="javascript:alert(1)">a</a><script>alert(1)</script><a href="https://example.com">b</a>`)
+	asserts.NotContains(sanitized, "onclick")
+	asserts.NotContains(sanitized, "javascript:")
+	asserts.NotContains(sanitized, "<script")
+	asserts.Contains(sanitized, `href="https://example.com"`)
+}
+
+func TestRenderCodeClasses(t *testing.T) {
+	asserts := assert.New(t)
+	rendered := Render("```go\nfmt.Println()\n```\n")
+	asserts.Contains(rendered.HTML, `<code class="language-go">`)
+
+	sanitized := policy.Sanitize(`<code class="highlight language-go">a</code><code class="language-c++">b</code><pre class="language-go">c</pre>`)
+	asserts.Equal(`<code>a</code><code class="language-c++">b</code><pre>c</pre>`, sanitized, "only language-* classes of code survive")
+}
+
+func TestRenderTOC(t *testing.T) {
+	asserts := assert.New(t)
+	rendered := Render("# Getting started\n\nIntro\n\n## Install *it*\n\n## Install *it*\n\n### Done\n")
+	asserts.Equal([]Heading{
+		{Level: 1, Text: "Getting started", ID: "getting-started"},
+		{Level: 2, Text: "Install it", ID: "install-it"},
+		{Level: 2, Text: "Install it", ID: "install-it-1"},
+		{Level: 3, Text: "Done", ID: "done"},
+	}, rendered.TOC)
+	asserts.Contains(rendered.HTML, `<h1 id="getting-started">`)
+	asserts.Contains(rendered.HTML, `<h2 id="install-it-1">`)
+
+	asserts.Equal([]Heading{}, Render("No headings").TOC)
+}
+
+func TestReadingTime(t *testing.T) {
+	asserts := assert.New(t)
+	asserts.Equal(0, ReadingTime(""))
+	asserts.Equal(0, ReadingTime(" \n\t"))
+	asserts.Equal(1, ReadingTime("one word"))
+	asserts.Equal(1, ReadingTime(strings.Repeat("word ", wordsPerMinute)))
+	asserts.Equal(2, ReadingTime(strings.Repeat("word\n", wordsPerMinute+1)))
+}
+
+func TestCache(t *testing.T) {
+	asserts := assert.New(t)
+	cache := NewCache(1)
+	updated := time.Now()
+	asserts.Contains(cache.Render(1, updated, "first").HTML, "first")
+	asserts.Contains(cache.Render(1, updated, "edited").HTML, "first", "entries are kept while UpdatedAt matches")
+	asserts.Contains(cache.Render(1, updated.Add(time.Second), "edited").HTML, "edited")
+	cache.Invalidate(1)
+	asserts.Contains(cache.Render(1, updated.Add(time.Second), "again").HTML, "again")
+	cache.Render(2, updated, "other")
+	asserts.Len(cache.entries, 1)
+}
@@ -0,0 +1,130 @@
+package media
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	"image/gif"
+	"image/jpeg"
+	"image/png"
+
+	"github.com/gabriel-vasile/mimetype"
+	"golang.org/x/image/draw"
+)
+
+const (
+	MaxUploadSize = 8 << 20  // bytes
+	maxPixels     = 16 << 20 // decoded pixels (64 MiB as RGBA), guards against decompression bombs
+	maxDimension  = 2048     // originals larger than this are scaled down
+	jpegQuality   = 85
+)
+
+var ErrUnsupportedType = errors.New("unsupported image type")
+var ErrImageTooLarge = errors.New("image dimensions too large")
+
+// ThumbnailSizes maps thumbnail names to their maximum width/height.
+var ThumbnailSizes = map[string]int{
+	"small":  128,
+	"medium": 512,
+}
+
+// extensions of the accepted, sniffed MIME types
+var allowedTypes = map[string]string{
+	"image/jpeg": ".jpg",
+	"image/png":  ".png",
+	"image/gif":  ".gif",
+}
+
+type Image struct {
+	ContentType string
+	Ext         string
+	Width       int
+	Height      int
+	Data        []byte
+}
+
+type Processed struct {
+	Original   Image
+	Thumbnails map[string]Image
+}
+
+// Process validates an uploaded image by its content (never the client
+// supplied type), re-encodes it, which drops EXIF and any other metadata,
+// and produces the thumbnails in ThumbnailSizes.
+func Process(data []byte) (Processed, error) {
+	contentType := mimetype.Detect(data).String()
+	ext, ok := allowedTypes[contentType]
+	if !ok {
+		return Processed{}, ErrUnsupportedType
+	}
+
+	config, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return Processed{}, ErrUnsupportedType
+	}
+	if config.Width*config.Height > maxPixels {
+		return Processed{}, ErrImageTooLarge
+	}
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return Processed{}, ErrUnsupportedType
+	}
+
+	original, err := encode(fit(img, maxDimension), contentType, ext)
+	if err != nil {
+		return Processed{}, err
+	}
+	processed := Processed{Original: original, Thumbnails: map[string]Image{}}
+	for name, size := range ThumbnailSizes {
+		thumbnail, err := encode(fit(img, size), contentType, ext)
+		if err != nil {
+			return Processed{}, err
+		}
+		processed.Thumbnails[name] = thumbnail
+	}
+	return processed, nil
+}
+
+// fit scales img down to fit in a size x size box, keeping its aspect ratio.
+func fit(img image.Image, size int) image.Image {
+	bounds := img.Bounds()
+	width, height := bounds.Dx(), bounds.Dy()
+	if width <= size && height <= size {
+		return img
+	}
+	if width > height {
+		height = height * size / width
+		width = size
+	} else {
+		width = width * size / height
+		height = size
+	}
+	if width < 1 {
+		width = 1
+	}
+	if height < 1 {
+		height = 1
+	}
+	dst := image.NewRGBA(image.Rect(0, 0, width, height))
+	draw.CatmullRom.Scale(dst, dst.Bounds(), img, bounds, draw.Over, nil)
+	return dst
+}
+
+func encode(img image.Image, contentType, ext string) (Image, error) {
+	var buf bytes.Buffer
+	var err error
+	switch contentType {
+	case "image/jpeg":
+		err = jpeg.Encode(&buf, img, &jpeg.Options{Quality: jpegQuality})
+	case "image/png":
+		err = png.Encode(&buf, img)
+	case "image/gif":
+		// animated GIFs are flattened to their first frame
+		err = gif.Encode(&buf, img, nil)
+	}
+	if err != nil {
+		return Image{}, err
+	}
+	bounds := img.Bounds()
+	return Image{ContentType: contentType, Ext: ext, Width: bounds.Dx(), Height: bounds.Dy(), Data: buf.Bytes()}, nil
+}
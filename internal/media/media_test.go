@@ -0,0 +1,126 @@
+package media
+
+import (
+	"bytes"
+	"encoding/binary"
+	"hash/crc32"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func testImage(width, height int) image.Image {
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+	for x := 0; x < width; x++ {
+		for y := 0; y < height; y++ {
+			img.Set(x, y, color.RGBA{uint8(x), uint8(y), 128, 255})
+		}
+	}
+	return img
+}
+
+// withExif inserts an APP1 segment with EXIF data (a GPS position, say)
+// right after the SOI marker of a JPEG.
+func withExif(jpg []byte) []byte {
+	payload := append([]byte("Exif\x00\x00"), []byte("MM\x00\x2a secret location")...)
+	segment := []byte{0xFF, 0xE1, 0, 0}
+	binary.BigEndian.PutUint16(segment[2:], uint16(len(payload)+2))
+	segment = append(segment, payload...)
+	return append(append(append([]byte{}, jpg[:2]...), segment...), jpg[2:]...)
+}
+
+// pngHeader is the start of a PNG claiming the given dimensions, enough
+// for its type to be sniffed and its config decoded.
+func pngHeader(width, height uint32) []byte {
+	ihdr := make([]byte, 13)
+	binary.BigEndian.PutUint32(ihdr[0:], width)
+	binary.BigEndian.PutUint32(ihdr[4:], height)
+	ihdr[8], ihdr[9] = 8, 6 // 8 bit RGBA
+	chunk := append([]byte("IHDR"), ihdr...)
+	var buf bytes.Buffer
+	buf.WriteString("\x89PNG\r\n\x1a\n")
+	binary.Write(&buf, binary.BigEndian, uint32(len(ihdr)))
+	buf.Write(chunk)
+	binary.Write(&buf, binary.BigEndian, crc32.ChecksumIEEE(chunk))
+	return buf.Bytes()
+}
+
+func TestProcess(t *testing.T) {
+	asserts := assert.New(t)
+	var buf bytes.Buffer
+	asserts.NoError(png.Encode(&buf, testImage(600, 300)))
+
+	processed, err := Process(buf.Bytes())
+	asserts.NoError(err)
+	asserts.Equal("image/png", processed.Original.ContentType)
+	asserts.Equal(".png", processed.Original.Ext)
+	asserts.Equal(600, processed.Original.Width)
+	asserts.Equal(300, processed.Original.Height)
+	asserts.Equal(128, processed.Thumbnails["small"].Width)
+	asserts.Equal(64, processed.Thumbnails["small"].Height)
+	asserts.Equal(512, processed.Thumbnails["medium"].Width)
+	asserts.Equal(256, processed.Thumbnails["medium"].Height)
+	thumbnail, err := png.Decode(bytes.NewReader(processed.Thumbnails["small"].Data))
+	asserts.NoError(err)
+	asserts.Equal(image.Rect(0, 0, 128, 64), thumbnail.Bounds())
+}
+
+func TestProcessRejectsTypes(t *testing.T) {
+	asserts := assert.New(t)
+	for name, data := range map[string][]byte{
+		"text":      []byte("hello"),
+		"html":      []byte("<html><script>alert(1)</script></html>"),
+		"svg":       []byte(`<svg xmlns="http://www.w3.org/2000/svg"><script>alert(1)</script></svg>`),
+		"pdf":       []byte("%PDF-1.4\n"),
+		"truncated": pngHeader(10, 10)[:12],
+	} {
+		_, err := Process(data)
+		asserts.ErrorIs(err, ErrUnsupportedType, name)
+	}
+
+	// only the content counts: a PNG body is a PNG whatever the client says
+	var buf bytes.Buffer
+	asserts.NoError(png.Encode(&buf, testImage(8, 8)))
+	processed, err := Process(buf.Bytes())
+	asserts.NoError(err)
+	asserts.Equal("image/png", processed.Original.ContentType)
+}
+
+func TestProcessStripsExif(t *testing.T) {
+	asserts := assert.New(t)
+	var buf bytes.Buffer
+	asserts.NoError(jpeg.Encode(&buf, testImage(64, 64), nil))
+	data := withExif(buf.Bytes())
+	asserts.Contains(string(data), "secret location")
+
+	processed, err := Process(data)
+	asserts.NoError(err)
+	asserts.Equal("image/jpeg", processed.Original.ContentType)
+	asserts.NotContains(string(processed.Original.Data), "Exif")
+	asserts.NotContains(string(processed.Original.Data), "secret location")
+	for name, thumbnail := range processed.Thumbnails {
+		asserts.NotContains(string(thumbnail.Data), "Exif", name)
+	}
+}
+
+func TestProcessPixelLimit(t *testing.T) {
+	asserts := assert.New(t)
+	_, err := Process(pngHeader(1<<15, 1<<15))
+	asserts.ErrorIs(err, ErrImageTooLarge, "huge images are refused before they are decoded")
+	_, err = Process(pngHeader(maxPixels+1, 1))
+	asserts.ErrorIs(err, ErrImageTooLarge)
+	_, err = Process(pngHeader(maxPixels, 1))
+	asserts.ErrorIs(err, ErrUnsupportedType, "within the limit the image is decoded, and this one is truncated")
+}
+
+func TestFit(t *testing.T) {
+	asserts := assert.New(t)
+	img := testImage(100, 50)
+	asserts.Same(img, fit(img, 100))
+	asserts.Equal(image.Rect(0, 0, 10, 5), fit(img, 10).Bounds())
+	asserts.Equal(image.Rect(0, 0, 1, 2), fit(testImage(1, 100), 2).Bounds(), "sides are at least a pixel")
+}
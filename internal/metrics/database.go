@@ -0,0 +1,62 @@
+package metrics
+
+import (
+	"time"
+
+	"github.com/hy00nc/conduit-go/internal/database"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"gorm.io/gorm"
+)
+
+var dbQueryDuration = factory.NewHistogramVec(prometheus.HistogramOpts{
+	Namespace: namespace, Subsystem: "db", Name: "query_duration_seconds",
+	Help:    "Time taken by database statements, by table and operation.",
+	Buckets: []float64{.0005, .001, .0025, .005, .01, .025, .05, .1, .25, .5, 1, 2.5},
+}, []string{"table", "operation"})
+
+// InstrumentDB times the statements run through db, and exposes the
+// connection pool statistics of its sql.DB: open, in use and idle
+// connections, and the time spent waiting for one.
+func InstrumentDB(db *gorm.DB) error {
+	sqlDB, err := db.DB()
+	if err != nil {
+		return err
+	}
+	if err := Registry.Register(collectors.NewDBStatsCollector(sqlDB, namespace)); err != nil {
+		return err
+	}
+	return db.Use(gormPlugin{})
+}
+
+const startKey = "metrics:start"
+
+// gormPlugin observes dbQueryDuration around every statement.
+type gormPlugin struct{}
+
+func (gormPlugin) Name() string {
+	return "metrics"
+}
+
+func (gormPlugin) Initialize(db *gorm.DB) error {
+	return database.AroundStatements(db, "metrics", startTimer, observe)
+}
+
+func startTimer(db *gorm.DB) {
+	db.InstanceSet(startKey, time.Now())
+}
+
+// observe returns the callback recording the time since startTimer.
+func observe(operation string) func(*gorm.DB) {
+	return func(db *gorm.DB) {
+		start, ok := db.InstanceGet(startKey)
+		if !ok {
+			return
+		}
+		table := db.Statement.Table
+		if table == "" {
+			table = "unknown" // raw SQL
+		}
+		dbQueryDuration.WithLabelValues(table, operation).Observe(time.Since(start.(time.Time)).Seconds())
+	}
+}
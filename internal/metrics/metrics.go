@@ -0,0 +1,118 @@
+// Package metrics holds the Prometheus metrics of the server: HTTP
+// traffic, the database and business events. They are served from
+// /metrics in the text exposition format.
+package metrics
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const namespace = "conduit"
+
+// Registry holds the metrics of this package and of the Go runtime.
+var Registry = prometheus.NewRegistry()
+
+var factory = promauto.With(Registry)
+
+func init() {
+	Registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+	)
+}
+
+// HTTP traffic, by route template rather than path so that the number of
+// series stays bounded.
+var (
+	httpRequests = factory.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace, Subsystem: "http", Name: "requests_total",
+		Help: "HTTP requests served, by method, route and status.",
+	}, []string{"method", "route", "status"})
+	httpDuration = factory.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace, Subsystem: "http", Name: "request_duration_seconds",
+		Help:    "Time taken to serve HTTP requests, by method and route.",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"method", "route"})
+	httpResponseSize = factory.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace, Subsystem: "http", Name: "response_size_bytes",
+		Help:    "Size of HTTP response bodies, by method and route.",
+		Buckets: prometheus.ExponentialBuckets(128, 4, 8), // 128B to 2MiB
+	}, []string{"method", "route"})
+
+	// HTTPInFlight counts the requests being served.
+	HTTPInFlight = factory.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace, Subsystem: "http", Name: "requests_in_flight",
+		Help: "HTTP requests being served.",
+	})
+)
+
+// Unmatched is the route of requests that matched none.
+const Unmatched = "unmatched"
+
+// Methods outside the HTTP standard ones are counted as "other", as
+// clients may send anything.
+var knownMethods = map[string]bool{
+	http.MethodGet: true, http.MethodHead: true, http.MethodPost: true, http.MethodPut: true,
+	http.MethodPatch: true, http.MethodDelete: true, http.MethodOptions: true,
+}
+
+// ObserveRequest records a served request; route is its template, or
+// empty if it matched none.
+func ObserveRequest(method, route string, status, size int, duration time.Duration) {
+	if !knownMethods[method] {
+		method = "other"
+	}
+	if route == "" {
+		route = Unmatched
+	}
+	httpRequests.WithLabelValues(method, route, strconv.Itoa(status)).Inc()
+	httpDuration.WithLabelValues(method, route).Observe(duration.Seconds())
+	httpResponseSize.WithLabelValues(method, route).Observe(float64(size))
+}
+
+// RateLimited counts the requests refused by a rate limit, by policy.
+var RateLimited = factory.NewCounterVec(prometheus.CounterOpts{
+	Namespace: namespace, Subsystem: "http", Name: "rate_limited_total",
+	Help: "HTTP requests refused by a rate limit, by policy.",
+}, []string{"policy"})
+
+// CacheRequests counts the lookups of the application cache, by cache and
+// result, "hit" or "miss".
+var CacheRequests = factory.NewCounterVec(prometheus.CounterOpts{
+	Namespace: namespace, Subsystem: "cache", Name: "requests_total",
+	Help: "Application cache lookups, by cache and result.",
+}, []string{"cache", "result"})
+
+// Business events, counted by the service layer whichever API they come
+// through.
+var (
+	Signups = factory.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace, Name: "signups_total",
+		Help: "Users registered.",
+	})
+	ArticlesCreated = factory.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace, Name: "articles_created_total",
+		Help: "Articles created.",
+	})
+	CommentsPosted = factory.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace, Name: "comments_posted_total",
+		Help: "Comments posted.",
+	})
+	// LoginsFailed is by reason, "credentials", "locked" or "suspended".
+	LoginsFailed = factory.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace, Name: "logins_failed_total",
+		Help: "Failed logins, by reason.",
+	}, []string{"reason"})
+)
+
+// Handler serves the metrics.
+func Handler() http.Handler {
+	return promhttp.HandlerFor(Registry, promhttp.HandlerOpts{Registry: Registry})
+}
@@ -0,0 +1,72 @@
+package metrics
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+func TestObserveRequest(t *testing.T) {
+	asserts := assert.New(t)
+	ObserveRequest("GET", "/api/articles/{slug}", 200, 512, 10*time.Millisecond)
+	ObserveRequest("GET", "/api/articles/{slug}", 200, 2048, 20*time.Millisecond)
+	ObserveRequest("BREW", "", 404, 0, time.Millisecond)
+
+	asserts.Equal(2.0, testutil.ToFloat64(httpRequests.WithLabelValues("GET", "/api/articles/{slug}", "200")))
+	asserts.Equal(1.0, testutil.ToFloat64(httpRequests.WithLabelValues("other", Unmatched, "404")))
+	asserts.Equal(uint64(2), sampleCount(t, httpDuration.WithLabelValues("GET", "/api/articles/{slug}")))
+	asserts.Equal(uint64(2), sampleCount(t, httpResponseSize.WithLabelValues("GET", "/api/articles/{slug}")))
+}
+
+// sampleCount returns how many times a histogram observed.
+func sampleCount(t *testing.T, observer prometheus.Observer) uint64 {
+	var metric dto.Metric
+	if err := observer.(prometheus.Metric).Write(&metric); err != nil {
+		t.Fatal(err)
+	}
+	return metric.GetHistogram().GetSampleCount()
+}
+
+type widget struct {
+	ID   uint
+	Name string
+}
+
+func TestInstrumentDB(t *testing.T) {
+	asserts := assert.New(t)
+	db, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "metrics.db")), &gorm.Config{
+		Logger: logger.Default.LogMode(logger.Silent),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	asserts.NoError(db.AutoMigrate(&widget{}))
+	asserts.NoError(InstrumentDB(db))
+
+	asserts.NoError(db.Create(&widget{Name: "gear"}).Error)
+	var widgets []widget
+	asserts.NoError(db.Where("name = ?", "gear").Find(&widgets).Error)
+	asserts.NoError(db.Model(&widget{}).Where("id = ?", widgets[0].ID).Update("name", "cog").Error)
+	asserts.NoError(db.Exec("DELETE FROM widgets").Error)
+
+	for _, labels := range [][2]string{{"widgets", "create"}, {"widgets", "query"}, {"widgets", "update"}, {"unknown", "raw"}} {
+		asserts.Equal(uint64(1), sampleCount(t, dbQueryDuration.WithLabelValues(labels[0], labels[1])), "%v", labels)
+	}
+
+	families, err := Registry.Gather()
+	asserts.NoError(err)
+	names := map[string]bool{}
+	for _, family := range families {
+		names[family.GetName()] = true
+	}
+	asserts.True(names["go_sql_open_connections"], "pool statistics are exposed")
+	asserts.True(names["go_sql_wait_duration_seconds_total"])
+}
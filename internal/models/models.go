@@ -1,6 +1,9 @@
 package models
 
 import (
+	"strings"
+	"time"
+
 	"golang.org/x/crypto/bcrypt"
 	"gorm.io/gorm"
 )
@@ -16,19 +19,38 @@ type Article struct {
 	Tags        []Tag `gorm:"many2many:article_tags;"`
 }
 
+// Profile names, like user emails and tag names, are unique among the rows
+// that are not soft-deleted, so deleting one frees its name.
 type Profile struct {
 	gorm.Model
-	Name  string `gorm:"unique"`
+	Name  string `gorm:"uniqueIndex:idx_profiles_name,where:deleted_at IS NULL"`
 	Bio   string
 	Image string
 }
 
+// Roles of users. Admins may delete the articles and comments of others.
+const (
+	RoleUser  = "user"
+	RoleAdmin = "admin"
+)
+
 type User struct {
 	gorm.Model
-	Email     string `gorm:"unique"`
+	Email     string `gorm:"uniqueIndex:idx_users_email,where:deleted_at IS NULL"`
 	Profile   Profile
 	ProfileID uint
 	Hash      string
+	Role      string `gorm:"not null;default:user"`
+	// SuspendedAt is set while the user may not log in or use their tokens.
+	SuspendedAt *time.Time
+}
+
+func (u *User) IsAdmin() bool {
+	return u.Role == RoleAdmin
+}
+
+func (u *User) Suspended() bool {
+	return u.SuspendedAt != nil
 }
 
 func (u *User) CheckPassword(password string) error {
@@ -48,7 +70,7 @@ type Comment struct {
 
 type Tag struct {
 	gorm.Model
-	Name string `gorm:"unique"`
+	Name string `gorm:"uniqueIndex:idx_tags_name,where:deleted_at IS NULL"`
 }
 
 type Follow struct {
@@ -66,3 +88,84 @@ type Favorite struct {
 	FavoritedBy   Profile
 	FavoritedByID uint
 }
+
+// Upload is an image stored through the media storage. Thumbnails are stored
+// next to the original as <Key>/<name><Ext>.
+type Upload struct {
+	gorm.Model
+	Owner       Profile
+	OwnerID     uint
+	Key         string `gorm:"uniqueIndex"`
+	Ext         string
+	ContentType string
+	Size        int
+	Width       int
+	Height      int
+}
+
+func (u *Upload) ObjectKey(name string) string {
+	return u.Key + "/" + name + u.Ext
+}
+
+// FeedToken grants read access to a user's private article feed. Deleting
+// it revokes every URL built from it.
+type FeedToken struct {
+	gorm.Model
+	User   User
+	UserID uint   `gorm:"uniqueIndex"`
+	Token  string `gorm:"uniqueIndex"`
+}
+
+type Webhook struct {
+	gorm.Model
+	Owner   Profile
+	OwnerID uint
+	URL     string
+	Secret  string
+	Events  string // comma separated event types
+	Active  bool
+	// SiteWide webhooks receive the events about every profile, not only
+	// their owner's; only admins may have them.
+	SiteWide bool
+}
+
+func (w *Webhook) EventList() []string {
+	if w.Events == "" {
+		return []string{}
+	}
+	return strings.Split(w.Events, ",")
+}
+
+func (w *Webhook) Subscribed(eventType string) bool {
+	for _, event := range w.EventList() {
+		if event == eventType {
+			return true
+		}
+	}
+	return false
+}
+
+// OutboxEvent is written in the same transaction as the change it describes
+// and later fanned out to webhook deliveries. ProfileID is the profile the
+// event is about, the author of the article or the one followed, whose
+// webhooks receive it.
+type OutboxEvent struct {
+	gorm.Model
+	Type        string
+	ProfileID   uint
+	Payload     string
+	ProcessedAt *time.Time
+}
+
+type WebhookDelivery struct {
+	gorm.Model
+	Webhook       Webhook
+	WebhookID     uint
+	Event         OutboxEvent
+	EventID       uint
+	Status        string
+	Attempts      int
+	NextAttemptAt time.Time
+	ResponseCode  int
+	LastError     string
+}
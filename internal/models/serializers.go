@@ -2,11 +2,25 @@ package models
 
 import (
 	"net/http"
+	"time"
 
+	"github.com/hy00nc/conduit-go/internal/markdown"
+	"github.com/hy00nc/conduit-go/internal/storage"
 	"github.com/hy00nc/conduit-go/internal/utils"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/trace"
 	"gorm.io/gorm"
 )
 
+var tracer = otel.Tracer(utils.InstrumentationName)
+
+// traced starts the span of a serializer, and returns db and r in its
+// context so that the queries made for the response are its children.
+func traced(db *gorm.DB, r *http.Request, name string) (*gorm.DB, *http.Request, trace.Span) {
+	ctx, span := tracer.Start(r.Context(), name)
+	return db.WithContext(ctx), r.WithContext(ctx), span
+}
+
 type ArticleSerializer struct {
 	Article
 }
@@ -39,17 +53,49 @@ type CommentsSerializer struct {
 	Comments []Comment
 }
 
+type UploadSerializer struct {
+	Upload
+}
+
+type WebhookSerializer struct {
+	Webhook
+}
+
+type WebhookDeliverySerializer struct {
+	WebhookDelivery
+}
+
+// TrashSerializer lists deleted articles and comments; PurgeAt tells when
+// each will be gone for good.
+type TrashSerializer struct {
+	Articles []Article
+	Comments []Comment
+	PurgeAt  func(deletedAt time.Time) time.Time
+}
+
+// ExportSerializer is the data export of a user.
+type ExportSerializer struct {
+	User      User
+	Articles  []Article
+	Comments  []Comment // with their article
+	Following []Profile
+	Favorites []Article
+}
+
 type ArticleResponse struct {
-	Title          string          `json:"title"`
-	Slug           string          `json:"slug"`
-	Description    string          `json:"description"`
-	Body           string          `json:"body"`
-	CreatedAt      string          `json:"createdAt"`
-	UpdatedAt      string          `json:"updatedAt"`
-	Author         ProfileResponse `json:"author"`
-	Tags           []string        `json:"tagList"`
-	Favorite       bool            `json:"favorited"`
-	FavoritesCount uint            `json:"favoritesCount"`
+	Title              string             `json:"title"`
+	Slug               string             `json:"slug"`
+	Description        string             `json:"description"`
+	Body               string             `json:"body"`
+	BodyHTML           string             `json:"bodyHtml,omitempty"`
+	TOC                []markdown.Heading `json:"toc,omitempty"`
+	ReadingTimeMinutes int                `json:"readingTimeMinutes"`
+	CreatedAt          string             `json:"createdAt"`
+	UpdatedAt          string             `json:"updatedAt"`
+	Author             ProfileResponse    `json:"author"`
+	Tags               []string           `json:"tagList"`
+	Favorite           bool               `json:"favorited"`
+	FavoritesCount     uint               `json:"favoritesCount"`
 }
 
 type ProfileResponse struct {
@@ -75,25 +121,118 @@ type UserResponse struct {
 	Image    string `json:"image"`
 }
 
+type UploadResponse struct {
+	ID          uint              `json:"id"`
+	URL         string            `json:"url"`
+	ContentType string            `json:"contentType"`
+	Size        int               `json:"size"`
+	Width       int               `json:"width"`
+	Height      int               `json:"height"`
+	Thumbnails  map[string]string `json:"thumbnails"`
+	CreatedAt   string            `json:"createdAt"`
+}
+
+type WebhookResponse struct {
+	ID        uint     `json:"id"`
+	URL       string   `json:"url"`
+	Events    []string `json:"events"`
+	Active    bool     `json:"active"`
+	SiteWide  bool     `json:"siteWide"`
+	Secret    string   `json:"secret,omitempty"`
+	CreatedAt string   `json:"createdAt"`
+}
+
+type WebhookDeliveryResponse struct {
+	ID            uint   `json:"id"`
+	EventID       uint   `json:"eventId"`
+	Event         string `json:"event"`
+	Status        string `json:"status"`
+	Attempts      int    `json:"attempts"`
+	ResponseCode  int    `json:"responseCode"`
+	LastError     string `json:"lastError"`
+	NextAttemptAt string `json:"nextAttemptAt"`
+	CreatedAt     string `json:"createdAt"`
+	UpdatedAt     string `json:"updatedAt"`
+}
+
+type TrashedArticleResponse struct {
+	Slug      string `json:"slug"`
+	Title     string `json:"title"`
+	DeletedAt string `json:"deletedAt"`
+	PurgeAt   string `json:"purgeAt"`
+}
+
+type TrashedCommentResponse struct {
+	ID          uint   `json:"id"`
+	Body        string `json:"body"`
+	ArticleSlug string `json:"articleSlug"`
+	DeletedAt   string `json:"deletedAt"`
+	PurgeAt     string `json:"purgeAt"`
+}
+
+type TrashResponse struct {
+	Articles []TrashedArticleResponse `json:"articles"`
+	Comments []TrashedCommentResponse `json:"comments"`
+}
+
+type ExportedAccountResponse struct {
+	Email     string `json:"email"`
+	Username  string `json:"username"`
+	Bio       string `json:"bio"`
+	Image     string `json:"image"`
+	CreatedAt string `json:"createdAt"`
+}
+
+type ExportedArticleResponse struct {
+	Slug        string   `json:"slug"`
+	Title       string   `json:"title"`
+	Description string   `json:"description"`
+	Body        string   `json:"body"`
+	Tags        []string `json:"tagList"`
+	CreatedAt   string   `json:"createdAt"`
+	UpdatedAt   string   `json:"updatedAt"`
+}
+
+type ExportedCommentResponse struct {
+	ID          uint   `json:"id"`
+	Body        string `json:"body"`
+	ArticleSlug string `json:"articleSlug"`
+	CreatedAt   string `json:"createdAt"`
+	UpdatedAt   string `json:"updatedAt"`
+}
+
+// ExportResponse lists followed profiles and favorited articles by username
+// and slug.
+type ExportResponse struct {
+	Account   ExportedAccountResponse   `json:"account"`
+	Articles  []ExportedArticleResponse `json:"articles"`
+	Comments  []ExportedCommentResponse `json:"comments"`
+	Following []string                  `json:"following"`
+	Favorites []string                  `json:"favorites"`
+}
+
 type UserRequest struct {
 	User struct {
-		Email    string `json:"email"`
-		Bio      string `json:"bio"`
-		Image    string `json:"image"`
-		Username string `json:"username"`
-		Password string `json:"password"`
+		Email    string `json:"email" validate:"max=254"`
+		Bio      string `json:"bio" validate:"max=1000"`
+		Image    string `json:"image" validate:"max=2048"`
+		Username string `json:"username" validate:"max=40"`
+		Password string `json:"password" validate:"max=72"`
+		ImageID  uint   `json:"imageId"`
 	} `json:"user"`
 }
 
 type ArticleRequest struct {
 	Article struct {
-		Title       string `json:"title"`
-		Description string `json:"description"`
-		Body        string `json:"body"`
+		Title       string `json:"title" validate:"max=200"`
+		Description string `json:"description" validate:"max=500"`
+		Body        string `json:"body" validate:"max=65536"`
 	} `json:"article"`
 }
 
 func (s *ArticleSerializer) Response(db *gorm.DB, r *http.Request) ArticleResponse {
+	db, r, span := traced(db, r, "ArticleSerializer.Response")
+	defer span.End()
 	var userProfile Profile
 	db.Where("id = ?", s.AuthorID).First(&userProfile)
 	authorSerializer := ProfileSerializer{userProfile}
@@ -112,15 +251,22 @@ func (s *ArticleSerializer) Response(db *gorm.DB, r *http.Request) ArticleRespon
 	favoritesCount := len(favorites)
 
 	response := ArticleResponse{
-		Slug:           s.Slug,
-		Title:          s.Title,
-		Description:    s.Description,
-		Body:           s.Body,
-		CreatedAt:      s.CreatedAt.UTC().Format("2006-01-02T15:04:05.999Z"),
-		UpdatedAt:      s.UpdatedAt.UTC().Format("2006-01-02T15:04:05.999Z"),
-		Author:         authorSerializer.Response(db, r),
-		Favorite:       favorited,
-		FavoritesCount: uint(favoritesCount),
+		Slug:               s.Slug,
+		Title:              s.Title,
+		Description:        s.Description,
+		Body:               s.Body,
+		ReadingTimeMinutes: markdown.ReadingTime(s.Body),
+		CreatedAt:          s.CreatedAt.UTC().Format("2006-01-02T15:04:05.999Z"),
+		UpdatedAt:          s.UpdatedAt.UTC().Format("2006-01-02T15:04:05.999Z"),
+		Author:             authorSerializer.Response(db, r),
+		Favorite:           favorited,
+		FavoritesCount:     uint(favoritesCount),
+	}
+	// ?render=html adds the sanitized HTML body and its table of contents
+	if r.URL != nil && r.URL.Query().Get("render") == "html" {
+		rendered := markdown.DefaultCache.Render(s.Article.ID, s.UpdatedAt, s.Body)
+		response.BodyHTML = rendered.HTML
+		response.TOC = rendered.TOC
 	}
 	tagList := s.Article.Tags
 	tagLen := len(tagList)
@@ -135,6 +281,8 @@ func (s *ArticleSerializer) Response(db *gorm.DB, r *http.Request) ArticleRespon
 }
 
 func (s *ArticlesSerializer) Response(db *gorm.DB, r *http.Request) []ArticleResponse {
+	db, r, span := traced(db, r, "ArticlesSerializer.Response")
+	defer span.End()
 	response := []ArticleResponse{}
 	for _, article := range s.Articles {
 		serializer := ArticleSerializer{article}
@@ -144,6 +292,8 @@ func (s *ArticlesSerializer) Response(db *gorm.DB, r *http.Request) []ArticleRes
 }
 
 func (s *ProfileSerializer) Response(db *gorm.DB, r *http.Request) ProfileResponse {
+	db, r, span := traced(db, r, "ProfileSerializer.Response")
+	defer span.End()
 	userData := r.Context().Value(utils.ContextKeyUserData)
 	following := false
 	if userData != nil {
@@ -188,6 +338,8 @@ func (s *TagsSerializer) Response() []string {
 }
 
 func (s *CommentSerializer) Response(db *gorm.DB, r *http.Request) CommentResponse {
+	db, r, span := traced(db, r, "CommentSerializer.Response")
+	defer span.End()
 	var userProfile Profile
 	db.Where("id = ?", s.AuthorID).First(&userProfile)
 	authorSerializer := ProfileSerializer{userProfile}
@@ -202,6 +354,8 @@ func (s *CommentSerializer) Response(db *gorm.DB, r *http.Request) CommentRespon
 }
 
 func (s *CommentsSerializer) Response(db *gorm.DB, r *http.Request) []CommentResponse {
+	db, r, span := traced(db, r, "CommentsSerializer.Response")
+	defer span.End()
 	response := []CommentResponse{}
 	for _, comment := range s.Comments {
 		serializer := CommentSerializer{comment}
@@ -209,3 +363,118 @@ func (s *CommentsSerializer) Response(db *gorm.DB, r *http.Request) []CommentRes
 	}
 	return response
 }
+
+func (s *UploadSerializer) Response(store storage.Storage, thumbnails []string) UploadResponse {
+	response := UploadResponse{
+		ID:          s.ID,
+		URL:         store.URL(s.ObjectKey("original")),
+		ContentType: s.ContentType,
+		Size:        s.Size,
+		Width:       s.Width,
+		Height:      s.Height,
+		Thumbnails:  map[string]string{},
+		CreatedAt:   s.CreatedAt.UTC().Format("2006-01-02T15:04:05.999Z"),
+	}
+	for _, name := range thumbnails {
+		response.Thumbnails[name] = store.URL(s.ObjectKey(name))
+	}
+	return response
+}
+
+// Response omits the secret; it is only returned once, when the webhook is created.
+func (s *WebhookSerializer) Response() WebhookResponse {
+	return WebhookResponse{
+		ID:        s.ID,
+		URL:       s.URL,
+		Events:    s.EventList(),
+		Active:    s.Active,
+		SiteWide:  s.SiteWide,
+		CreatedAt: s.CreatedAt.UTC().Format("2006-01-02T15:04:05.999Z"),
+	}
+}
+
+func (s *WebhookDeliverySerializer) Response() WebhookDeliveryResponse {
+	return WebhookDeliveryResponse{
+		ID:            s.ID,
+		EventID:       s.EventID,
+		Event:         s.Event.Type,
+		Status:        s.Status,
+		Attempts:      s.Attempts,
+		ResponseCode:  s.ResponseCode,
+		LastError:     s.LastError,
+		NextAttemptAt: s.NextAttemptAt.UTC().Format("2006-01-02T15:04:05.999Z"),
+		CreatedAt:     s.CreatedAt.UTC().Format("2006-01-02T15:04:05.999Z"),
+		UpdatedAt:     s.UpdatedAt.UTC().Format("2006-01-02T15:04:05.999Z"),
+	}
+}
+
+func (s *TrashSerializer) Response() TrashResponse {
+	response := TrashResponse{Articles: []TrashedArticleResponse{}, Comments: []TrashedCommentResponse{}}
+	for _, article := range s.Articles {
+		deletedAt := article.DeletedAt.Time
+		response.Articles = append(response.Articles, TrashedArticleResponse{
+			Slug:      article.Slug,
+			Title:     article.Title,
+			DeletedAt: deletedAt.UTC().Format("2006-01-02T15:04:05.999Z"),
+			PurgeAt:   s.PurgeAt(deletedAt).UTC().Format("2006-01-02T15:04:05.999Z"),
+		})
+	}
+	for _, comment := range s.Comments {
+		deletedAt := comment.DeletedAt.Time
+		response.Comments = append(response.Comments, TrashedCommentResponse{
+			ID:          comment.ID,
+			Body:        comment.Body,
+			ArticleSlug: comment.Article.Slug,
+			DeletedAt:   deletedAt.UTC().Format("2006-01-02T15:04:05.999Z"),
+			PurgeAt:     s.PurgeAt(deletedAt).UTC().Format("2006-01-02T15:04:05.999Z"),
+		})
+	}
+	return response
+}
+
+func (s *ExportSerializer) Response() ExportResponse {
+	response := ExportResponse{
+		Account: ExportedAccountResponse{
+			Email:     s.User.Email,
+			Username:  s.User.Profile.Name,
+			Bio:       s.User.Profile.Bio,
+			Image:     s.User.Profile.Image,
+			CreatedAt: s.User.CreatedAt.UTC().Format("2006-01-02T15:04:05.999Z"),
+		},
+		Articles:  []ExportedArticleResponse{},
+		Comments:  []ExportedCommentResponse{},
+		Following: []string{},
+		Favorites: []string{},
+	}
+	for _, article := range s.Articles {
+		tags := []string{}
+		for _, tag := range article.Tags {
+			tags = append(tags, tag.Name)
+		}
+		response.Articles = append(response.Articles, ExportedArticleResponse{
+			Slug:        article.Slug,
+			Title:       article.Title,
+			Description: article.Description,
+			Body:        article.Body,
+			Tags:        tags,
+			CreatedAt:   article.CreatedAt.UTC().Format("2006-01-02T15:04:05.999Z"),
+			UpdatedAt:   article.UpdatedAt.UTC().Format("2006-01-02T15:04:05.999Z"),
+		})
+	}
+	for _, comment := range s.Comments {
+		response.Comments = append(response.Comments, ExportedCommentResponse{
+			ID:          comment.ID,
+			Body:        comment.Body,
+			ArticleSlug: comment.Article.Slug,
+			CreatedAt:   comment.CreatedAt.UTC().Format("2006-01-02T15:04:05.999Z"),
+			UpdatedAt:   comment.UpdatedAt.UTC().Format("2006-01-02T15:04:05.999Z"),
+		})
+	}
+	for _, profile := range s.Following {
+		response.Following = append(response.Following, profile.Name)
+	}
+	for _, article := range s.Favorites {
+		response.Favorites = append(response.Favorites, article.Slug)
+	}
+	return response
+}
@@ -1,31 +1,53 @@
 package models
 
+// Validators are the bodies of API requests, checked against their
+// validate tags once decoded. Length limits count characters; passwords
+// are also refused by the services past 72 bytes, the most bcrypt hashes,
+// which non-ASCII passwords reach in fewer characters.
+
 type LoginValidator struct {
 	User struct {
-		Email    string `json:"email" validate:"required"`
-		Password string `json:"password" validate:"required"`
+		Email    string `json:"email" validate:"required,max=254"`
+		Password string `json:"password" validate:"required,max=72"`
 	} `json:"user"`
 }
 
 type RegisterValidator struct {
 	User struct {
-		Username string `json:"username" validate:"required"`
-		Email    string `json:"email" validate:"required"`
-		Password string `json:"password" validate:"required"`
+		Username string `json:"username" validate:"required,max=40"`
+		Email    string `json:"email" validate:"required,max=254"`
+		Password string `json:"password" validate:"required,max=72"`
+	} `json:"user"`
+}
+
+// DeleteUserValidator confirms an account deletion with the password.
+type DeleteUserValidator struct {
+	User struct {
+		Password string `json:"password" validate:"required,max=72"`
 	} `json:"user"`
 }
 
 type ArticleValidator struct {
 	Article struct {
-		Title       string   `json:"title" validate:"required"`
-		Description string   `json:"description" validate:"required"`
-		Body        string   `json:"body" validate:"required"`
-		TagList     []string `json:"tagList"`
+		Title       string   `json:"title" validate:"required,max=200"`
+		Description string   `json:"description" validate:"required,max=500"`
+		Body        string   `json:"body" validate:"required,max=65536"`
+		TagList     []string `json:"tagList" validate:"max=10,dive,max=40"`
 	} `json:"article"`
 }
 
 type CommentValidator struct {
 	Comment struct {
-		Body string `json:"body" validate:"required"`
+		Body string `json:"body" validate:"required,max=5000"`
 	} `json:"comment"`
 }
+
+type WebhookValidator struct {
+	Webhook struct {
+		URL    string   `json:"url" validate:"required,url,max=2048"`
+		Secret string   `json:"secret" validate:"max=256"`
+		Events []string `json:"events" validate:"required,min=1,dive,oneof=article.created article.updated article.deleted comment.created user.followed article.favorited"`
+		// SiteWide asks for the events about every profile; only admins may.
+		SiteWide bool `json:"siteWide"`
+	} `json:"webhook"`
+}
@@ -0,0 +1,60 @@
+package ratelimit
+
+import (
+	"context"
+	"time"
+)
+
+// Lockout locks a key out once it failed Threshold times, for Base, and
+// for twice as long after each further failure, up to Max. Failures are
+// forgotten on success, or Window after the last one.
+type Lockout struct {
+	Store     Store
+	Threshold int
+	Base      time.Duration
+	Max       time.Duration
+	Window    time.Duration
+	Now       func() time.Time
+}
+
+// NewLockout returns a Lockout locking keys for a minute after 5 failures,
+// up to an hour, forgetting failures after a day.
+func NewLockout(store Store) *Lockout {
+	return &Lockout{Store: store, Threshold: 5, Base: time.Minute, Max: time.Hour, Window: 24 * time.Hour, Now: time.Now}
+}
+
+// duration is how long a key is locked out after count failures.
+func (l *Lockout) duration(count int) time.Duration {
+	if count < l.Threshold {
+		return 0
+	}
+	lock := l.Base
+	for i := l.Threshold; i < count && lock < l.Max; i++ {
+		lock *= 2
+	}
+	return min(lock, l.Max)
+}
+
+// Locked returns how long key is still locked out, or 0.
+func (l *Lockout) Locked(ctx context.Context, key string) (time.Duration, error) {
+	now := l.Now()
+	count, last, err := l.Store.Failures(ctx, key, now, l.Window)
+	if err != nil || count < l.Threshold {
+		return 0, err
+	}
+	return max(0, last.Add(l.duration(count)).Sub(now)), nil
+}
+
+// Fail counts a failure of key and returns how long it is now locked out.
+func (l *Lockout) Fail(ctx context.Context, key string) (time.Duration, error) {
+	count, err := l.Store.AddFailure(ctx, key, l.Now(), l.Window)
+	if err != nil {
+		return 0, err
+	}
+	return l.duration(count), nil
+}
+
+// Succeed forgets the failures of key.
+func (l *Lockout) Succeed(ctx context.Context, key string) error {
+	return l.Store.ResetFailures(ctx, key)
+}
@@ -0,0 +1,97 @@
+package ratelimit
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// sweepInterval is how often MemoryStore forgets full buckets and old
+// failures, so that it does not grow with every client ever seen.
+const sweepInterval = time.Minute
+
+// MemoryStore keeps buckets and failures in the process.
+type MemoryStore struct {
+	mu        sync.Mutex
+	buckets   map[string]*memoryBucket
+	failures  map[string]*failures
+	lastSweep time.Time
+}
+
+type memoryBucket struct {
+	bucket
+	policy Policy
+}
+
+type failures struct {
+	count  int
+	last   time.Time
+	window time.Duration
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{buckets: map[string]*memoryBucket{}, failures: map[string]*failures{}}
+}
+
+func (s *MemoryStore) Take(ctx context.Context, key string, policy Policy, now time.Time) (Result, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.sweep(now)
+	b, ok := s.buckets[key]
+	if !ok || b.policy != policy {
+		b = &memoryBucket{policy: policy}
+		s.buckets[key] = b
+	}
+	return b.take(policy, now), nil
+}
+
+// current returns the failures of key, reset if the last is older than window.
+func (s *MemoryStore) current(key string, now time.Time, window time.Duration) *failures {
+	f, ok := s.failures[key]
+	if !ok || now.Sub(f.last) > window {
+		return &failures{window: window}
+	}
+	return f
+}
+
+func (s *MemoryStore) AddFailure(ctx context.Context, key string, now time.Time, window time.Duration) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.sweep(now)
+	f := s.current(key, now, window)
+	f.count++
+	f.last, f.window = now, window
+	s.failures[key] = f
+	return f.count, nil
+}
+
+func (s *MemoryStore) Failures(ctx context.Context, key string, now time.Time, window time.Duration) (int, time.Time, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	f := s.current(key, now, window)
+	return f.count, f.last, nil
+}
+
+func (s *MemoryStore) ResetFailures(ctx context.Context, key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.failures, key)
+	return nil
+}
+
+func (s *MemoryStore) sweep(now time.Time) {
+	if now.Sub(s.lastSweep) < sweepInterval {
+		return
+	}
+	s.lastSweep = now
+	for key, b := range s.buckets {
+		if b.full(b.policy, now) {
+			delete(s.buckets, key)
+		}
+	}
+	for key, f := range s.failures {
+		if now.Sub(f.last) > f.window {
+			delete(s.failures, key)
+		}
+	}
+}
@@ -0,0 +1,161 @@
+// Package ratelimit throttles actions with token buckets, and locks out
+// keys, such as the email of an account, after repeated failures.
+//
+// Buckets and failures are kept in a Store. MemoryStore keeps them in the
+// process; instances behind a load balancer share them through a Store
+// backed by a shared database instead.
+package ratelimit
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Policy allows Limit actions per Period, in bursts of up to Limit: a
+// bucket holds Limit tokens and refills at Limit per Period. A zero Limit
+// turns the policy off.
+type Policy struct {
+	Name   string
+	Limit  int
+	Period time.Duration
+}
+
+func (p Policy) String() string {
+	return fmt.Sprintf("%s=%d/%s", p.Name, p.Limit, p.Period)
+}
+
+// rate is the tokens refilled per second.
+func (p Policy) rate() float64 {
+	return float64(p.Limit) / p.Period.Seconds()
+}
+
+// Result is the state of a bucket after taking a token from it.
+type Result struct {
+	Allowed   bool
+	Limit     int
+	Remaining int
+	// Reset is how long until the bucket is full again.
+	Reset time.Duration
+	// RetryAfter is how long until the next token, when not allowed.
+	RetryAfter time.Duration
+}
+
+// Store keeps the buckets of policies and the failures of keys. Its
+// methods must be atomic per key, as several instances may share it.
+type Store interface {
+	// Take takes a token from the bucket of key under policy.
+	Take(ctx context.Context, key string, policy Policy, now time.Time) (Result, error)
+	// AddFailure counts a failure of key, forgetting those before the
+	// last one if it is older than window, and returns the count.
+	AddFailure(ctx context.Context, key string, now time.Time, window time.Duration) (int, error)
+	// Failures returns the count of failures of key and when the last was,
+	// as left by AddFailure.
+	Failures(ctx context.Context, key string, now time.Time, window time.Duration) (int, time.Time, error)
+	ResetFailures(ctx context.Context, key string) error
+}
+
+// bucket is a token bucket, for stores to keep.
+type bucket struct {
+	tokens  float64
+	updated time.Time
+}
+
+// take refills b for the time since it was updated and takes a token.
+func (b *bucket) take(policy Policy, now time.Time) Result {
+	capacity, rate := float64(policy.Limit), policy.rate()
+	if b.updated.IsZero() {
+		b.tokens = capacity
+	} else if elapsed := now.Sub(b.updated).Seconds(); elapsed > 0 {
+		b.tokens = math.Min(capacity, b.tokens+elapsed*rate)
+	}
+	b.updated = now
+
+	result := Result{Limit: policy.Limit}
+	if b.tokens >= 1 {
+		b.tokens--
+		result.Allowed = true
+	} else {
+		result.RetryAfter = seconds((1 - b.tokens) / rate)
+	}
+	result.Remaining = int(b.tokens)
+	result.Reset = seconds((capacity - b.tokens) / rate)
+	return result
+}
+
+// full reports whether b has refilled by now, so a store may forget it.
+func (b *bucket) full(policy Policy, now time.Time) bool {
+	return b.tokens+now.Sub(b.updated).Seconds()*policy.rate() >= float64(policy.Limit)
+}
+
+func seconds(s float64) time.Duration {
+	return time.Duration(math.Ceil(s * float64(time.Second)))
+}
+
+// Limiter applies named policies to identities.
+type Limiter struct {
+	Store    Store
+	Policies map[string]Policy
+	Now      func() time.Time
+}
+
+func NewLimiter(store Store, policies ...Policy) *Limiter {
+	limiter := &Limiter{Store: store, Policies: map[string]Policy{}, Now: time.Now}
+	for _, policy := range policies {
+		limiter.Policies[policy.Name] = policy
+	}
+	return limiter
+}
+
+// Allow takes a token for identity under the named policy. Unknown
+// policies and those turned off allow everything, with a zero Limit.
+func (l *Limiter) Allow(ctx context.Context, name, identity string) (Result, error) {
+	policy, ok := l.Policies[name]
+	if !ok || policy.Limit <= 0 {
+		return Result{Allowed: true}, nil
+	}
+	return l.Store.Take(ctx, name+":"+identity, policy, l.Now())
+}
+
+// Policy returns the named policy.
+func (l *Limiter) Policy(name string) Policy {
+	return l.Policies[name]
+}
+
+// ParsePolicies overrides defaults with a spec such as
+// "login=10/1m,comments=30/10m"; a limit of 0 turns a policy off.
+func ParsePolicies(spec string, defaults ...Policy) ([]Policy, error) {
+	policies := append([]Policy(nil), defaults...)
+	for _, item := range strings.Split(spec, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		name, value, ok := strings.Cut(item, "=")
+		limit, period, ok2 := strings.Cut(value, "/")
+		if !ok || !ok2 {
+			return nil, fmt.Errorf("rate limit %q: want name=limit/period", item)
+		}
+		policy := Policy{Name: strings.TrimSpace(name)}
+		var err error
+		if policy.Limit, err = strconv.Atoi(limit); err != nil || policy.Limit < 0 {
+			return nil, fmt.Errorf("rate limit %q: bad limit", item)
+		}
+		if policy.Period, err = time.ParseDuration(period); err != nil || policy.Period <= 0 {
+			return nil, fmt.Errorf("rate limit %q: bad period", item)
+		}
+		replaced := false
+		for i := range policies {
+			if policies[i].Name == policy.Name {
+				policies[i], replaced = policy, true
+			}
+		}
+		if !replaced {
+			return nil, fmt.Errorf("rate limit %q: unknown policy", item)
+		}
+	}
+	return policies, nil
+}
@@ -0,0 +1,131 @@
+package ratelimit
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// clock is a Now that only moves when told to.
+type clock struct{ now time.Time }
+
+func (c *clock) Now() time.Time { return c.now }
+
+func (c *clock) Advance(d time.Duration) { c.now = c.now.Add(d) }
+
+func TestLimiter(t *testing.T) {
+	asserts := assert.New(t)
+	ctx := context.Background()
+	c := &clock{now: time.Unix(1700000000, 0)}
+	limiter := NewLimiter(NewMemoryStore(), Policy{Name: "login", Limit: 3, Period: time.Minute}, Policy{Name: "off", Period: time.Minute})
+	limiter.Now = c.Now
+
+	for i := 2; i >= 0; i-- {
+		result, err := limiter.Allow(ctx, "login", "ip:1.2.3.4")
+		asserts.NoError(err)
+		asserts.True(result.Allowed)
+		asserts.Equal(3, result.Limit)
+		asserts.Equal(i, result.Remaining)
+	}
+	result, err := limiter.Allow(ctx, "login", "ip:1.2.3.4")
+	asserts.NoError(err)
+	asserts.False(result.Allowed)
+	asserts.Equal(0, result.Remaining)
+	asserts.Equal(20*time.Second, result.RetryAfter, "a token refills every 20s")
+	asserts.Equal(time.Minute, result.Reset)
+
+	result, _ = limiter.Allow(ctx, "login", "ip:5.6.7.8")
+	asserts.True(result.Allowed, "identities have their own buckets")
+
+	c.Advance(20 * time.Second)
+	result, _ = limiter.Allow(ctx, "login", "ip:1.2.3.4")
+	asserts.True(result.Allowed)
+	result, _ = limiter.Allow(ctx, "login", "ip:1.2.3.4")
+	asserts.False(result.Allowed)
+
+	result, _ = limiter.Allow(ctx, "off", "ip:1.2.3.4")
+	asserts.Equal(Result{Allowed: true}, result, "a zero limit turns a policy off")
+	result, _ = limiter.Allow(ctx, "unknown", "ip:1.2.3.4")
+	asserts.True(result.Allowed)
+}
+
+func TestMemoryStoreSweeps(t *testing.T) {
+	asserts := assert.New(t)
+	ctx := context.Background()
+	store := NewMemoryStore()
+	now := time.Unix(1700000000, 0)
+	policy := Policy{Name: "login", Limit: 1, Period: time.Minute}
+
+	store.Take(ctx, "login:a", policy, now)
+	store.AddFailure(ctx, "login:a@example.com", now, time.Hour)
+	store.Take(ctx, "login:b", policy, now.Add(2*time.Minute))
+	asserts.Len(store.buckets, 1, "full buckets are forgotten")
+	asserts.Len(store.failures, 1)
+
+	store.Take(ctx, "login:b", policy, now.Add(2*time.Hour))
+	asserts.Empty(store.failures, "old failures are forgotten")
+}
+
+func TestLockout(t *testing.T) {
+	asserts := assert.New(t)
+	ctx := context.Background()
+	c := &clock{now: time.Unix(1700000000, 0)}
+	lockout := NewLockout(NewMemoryStore())
+	lockout.Threshold, lockout.Max = 3, 3*time.Minute
+	lockout.Now = c.Now
+	key := "login:sally@example.com"
+
+	for i := 0; i < 2; i++ {
+		locked, err := lockout.Fail(ctx, key)
+		asserts.NoError(err)
+		asserts.Zero(locked)
+	}
+	locked, _ := lockout.Locked(ctx, key)
+	asserts.Zero(locked)
+
+	locked, _ = lockout.Fail(ctx, key)
+	asserts.Equal(time.Minute, locked)
+	c.Advance(15 * time.Second)
+	locked, _ = lockout.Locked(ctx, key)
+	asserts.Equal(45*time.Second, locked)
+
+	c.Advance(time.Minute)
+	locked, _ = lockout.Locked(ctx, key)
+	asserts.Zero(locked)
+	locked, _ = lockout.Fail(ctx, key)
+	asserts.Equal(2*time.Minute, locked, "each further failure doubles the lockout")
+	locked, _ = lockout.Fail(ctx, key)
+	asserts.Equal(3*time.Minute, locked, "up to Max")
+
+	asserts.NoError(lockout.Succeed(ctx, key))
+	locked, _ = lockout.Locked(ctx, key)
+	asserts.Zero(locked)
+
+	for i := 0; i < 3; i++ {
+		lockout.Fail(ctx, key)
+	}
+	c.Advance(25 * time.Hour)
+	locked, _ = lockout.Fail(ctx, key)
+	asserts.Zero(locked, "failures are forgotten after the window")
+}
+
+func TestParsePolicies(t *testing.T) {
+	asserts := assert.New(t)
+	defaults := []Policy{{Name: "login", Limit: 10, Period: time.Minute}, {Name: "comments", Limit: 30, Period: 10 * time.Minute}}
+
+	policies, err := ParsePolicies("", defaults...)
+	asserts.NoError(err)
+	asserts.Equal(defaults, policies)
+
+	policies, err = ParsePolicies(" login=20/30s, comments=0/1m", defaults...)
+	asserts.NoError(err)
+	asserts.Equal([]Policy{{Name: "login", Limit: 20, Period: 30 * time.Second}, {Name: "comments", Limit: 0, Period: time.Minute}}, policies)
+	asserts.Equal(10, defaults[0].Limit, "defaults are not changed")
+
+	for _, spec := range []string{"login", "login=10", "login=x/1m", "login=-1/1m", "login=10/0s", "signup=5/1h"} {
+		_, err := ParsePolicies(spec, defaults...)
+		asserts.Error(err, spec)
+	}
+}
@@ -0,0 +1,329 @@
+// Package seed fills a database with generated users, follows, articles,
+// tags, comments and favorites, for local development and load testing.
+//
+// Popularity is skewed the way it is on real sites: a few users attract
+// most followers and write most articles, and a few articles get most
+// comments and favorites. Runs with the same seed generate the same names
+// and text.
+package seed
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"slices"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/gosimple/slug"
+	"github.com/hy00nc/conduit-go/internal/models"
+	"github.com/hy00nc/conduit-go/internal/service"
+	"golang.org/x/crypto/bcrypt"
+)
+
+type Options struct {
+	Users    int
+	Articles int
+	Comments int
+	Tags     int // distinct tags, of which articles have up to four
+	// Follows and Favorites are the averages per user.
+	Follows   int
+	Favorites int
+	// Password is the password of every generated user.
+	Password string
+	Seed     int64
+}
+
+// Stats counts the generated rows.
+type Stats struct {
+	Users, Follows, Tags, Articles, Comments, Favorites int
+}
+
+func (s Stats) String() string {
+	return fmt.Sprintf("%d users, %d follows, %d tags, %d articles, %d comments, %d favorites",
+		s.Users, s.Follows, s.Tags, s.Articles, s.Comments, s.Favorites)
+}
+
+// batchSize is how many rows are written per transaction.
+const batchSize = 200
+
+// Dates are spread over the year before now.
+const span = 365 * 24 * time.Hour
+
+type seeder struct {
+	store   service.Store
+	options Options
+	rand    *rand.Rand
+	text    text
+	now     time.Time
+	stats   Stats
+
+	users    []models.User
+	tags     []models.Tag
+	articles []models.Article
+}
+
+// Run generates the data and writes it to store, a batch per transaction.
+// Users get fresh names, so a database can be seeded more than once.
+func Run(ctx context.Context, store service.Store, options Options) (Stats, error) {
+	if options.Users < 1 && (options.Articles > 0 || options.Comments > 0) {
+		return Stats{}, errors.New("articles and comments need users")
+	}
+	if options.Tags > len(tagNames) {
+		return Stats{}, fmt.Errorf("at most %d tags", len(tagNames))
+	}
+	r := rand.New(rand.NewSource(options.Seed))
+	s := &seeder{store: store, options: options, rand: r, text: text{rand: r}, now: time.Now()}
+	for _, step := range []func(context.Context) error{s.createUsers, s.createFollows, s.createTags, s.createArticles, s.createComments, s.createFavorites} {
+		if err := step(ctx); err != nil {
+			return s.stats, err
+		}
+	}
+	return s.stats, nil
+}
+
+// mark is what the seeder has written before a transaction.
+type mark struct {
+	stats           Stats
+	users, articles int
+	tags            []models.Tag
+}
+
+func (s *seeder) mark() mark {
+	return mark{stats: s.stats, users: len(s.users), articles: len(s.articles), tags: slices.Clone(s.tags)}
+}
+
+// reset forgets what was written after m, when its transaction is retried.
+func (s *seeder) reset(m mark) {
+	s.stats, s.users, s.articles, s.tags = m.stats, s.users[:m.users], s.articles[:m.articles], slices.Clone(m.tags)
+}
+
+// batches calls fn for n items in transactions of batchSize.
+func (s *seeder) batches(ctx context.Context, n int, fn func(tx service.Store, i int) error) error {
+	for start := 0; start < n; start += batchSize {
+		end := min(start+batchSize, n)
+		before := s.mark()
+		err := s.store.Transaction(ctx, func(tx service.Store) error {
+			s.reset(before)
+			for i := start; i < end; i++ {
+				if err := fn(tx, i); err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// popular returns a picker of indexes below n where low ranks come up far
+// more often; which indexes rank low is random.
+func (s *seeder) popular(n int) func() int {
+	ranks := s.rand.Perm(n)
+	if n == 1 {
+		return func() int { return 0 }
+	}
+	zipf := rand.NewZipf(s.rand, 1.2, 1, uint64(n-1))
+	return func() int { return ranks[zipf.Uint64()] }
+}
+
+// around returns a count averaging mean, between 0 and 2*mean.
+func (s *seeder) around(mean int) int {
+	return s.rand.Intn(2*mean + 1)
+}
+
+// since returns a random time between t and now.
+func (s *seeder) since(t time.Time) time.Time {
+	return t.Add(time.Duration(s.rand.Int63n(int64(s.now.Sub(t)) + 1)))
+}
+
+// available reports whether a username and its email are still free.
+func available(ctx context.Context, store service.Store, name string) (bool, error) {
+	_, err := store.ProfileByName(ctx, name)
+	if err == nil {
+		return false, nil
+	}
+	if !errors.Is(err, service.ErrNotFound) {
+		return false, err
+	}
+	_, err = store.UserByEmail(ctx, name+"@example.com")
+	if err == nil {
+		return false, nil
+	}
+	if !errors.Is(err, service.ErrNotFound) {
+		return false, err
+	}
+	return true, nil
+}
+
+func (s *seeder) createUsers(ctx context.Context) error {
+	// one hash for everybody; hashing is deliberately slow
+	hash, err := bcrypt.GenerateFromPassword([]byte(s.options.Password), bcrypt.DefaultCost)
+	if err != nil {
+		return err
+	}
+	return s.batches(ctx, s.options.Users, func(tx service.Store, i int) error {
+		base := s.text.pick(firstNames) + "_" + s.text.pick(lastNames)
+		name := base
+		for n := 2; ; n++ {
+			ok, err := available(ctx, tx, name)
+			if err != nil {
+				return err
+			}
+			if ok {
+				break
+			}
+			name = fmt.Sprintf("%s%d", base, n)
+		}
+		user := models.User{
+			Email:   name + "@example.com",
+			Profile: models.Profile{Name: name, Bio: s.text.pick(bios), Image: service.DefaultImage},
+			Hash:    string(hash),
+			Role:    models.RoleUser,
+		}
+		user.CreatedAt = s.since(s.now.Add(-span))
+		user.UpdatedAt = user.CreatedAt
+		user.Profile.CreatedAt, user.Profile.UpdatedAt = user.CreatedAt, user.CreatedAt
+		if err := tx.CreateUser(ctx, &user); err != nil {
+			return err
+		}
+		s.users = append(s.users, user)
+		s.stats.Users++
+		return nil
+	})
+}
+
+func (s *seeder) createFollows(ctx context.Context) error {
+	if len(s.users) < 2 || s.options.Follows < 1 {
+		return nil
+	}
+	followed := s.popular(len(s.users))
+	return s.batches(ctx, len(s.users), func(tx service.Store, i int) error {
+		user := s.users[i]
+		for n := min(s.around(s.options.Follows), len(s.users)-1); n > 0; n-- {
+			target := s.users[followed()]
+			if target.ID == user.ID {
+				continue
+			}
+			changed, err := tx.SetFollow(ctx, user.ProfileID, target.ProfileID, true)
+			if err != nil {
+				return err
+			}
+			if changed {
+				s.stats.Follows++
+			}
+		}
+		return nil
+	})
+}
+
+func (s *seeder) createTags(ctx context.Context) error {
+	if s.options.Tags < 1 {
+		return nil
+	}
+	names := tagNames[:s.options.Tags]
+	existing, err := s.store.TagsByName(ctx, names)
+	if err != nil {
+		return err
+	}
+	byName := map[string]models.Tag{}
+	for _, tag := range existing {
+		byName[tag.Name] = tag
+	}
+	for _, name := range names {
+		tag, ok := byName[name]
+		if !ok {
+			tag = models.Tag{Name: name} // created with the first article using it
+		}
+		s.tags = append(s.tags, tag)
+	}
+	return nil
+}
+
+func (s *seeder) createArticles(ctx context.Context) error {
+	if s.options.Articles < 1 {
+		return nil
+	}
+	author := s.popular(len(s.users))
+	var tag func() int
+	if len(s.tags) > 0 {
+		tag = s.popular(len(s.tags))
+	}
+	return s.batches(ctx, s.options.Articles, func(tx service.Store, i int) error {
+		user := s.users[author()]
+		title := s.text.title()
+		article := models.Article{
+			Slug:        slug.Make(title + " " + uuid.NewString()),
+			Title:       title,
+			Description: s.text.sentence(),
+			Body:        s.text.body(),
+			AuthorID:    user.ProfileID,
+			Tags:        []models.Tag{},
+		}
+		var picked []int // indexes in s.tags
+		if tag != nil {
+			for n := s.rand.Intn(5); n > 0; n-- {
+				if j := tag(); !slices.Contains(picked, j) {
+					picked = append(picked, j)
+					article.Tags = append(article.Tags, s.tags[j])
+				}
+			}
+		}
+		article.CreatedAt = s.since(user.CreatedAt)
+		article.UpdatedAt = article.CreatedAt
+		if err := tx.CreateArticle(ctx, &article); err != nil {
+			return err
+		}
+		// later articles reuse the tags created with this one
+		for k, j := range picked {
+			if s.tags[j].ID == 0 {
+				s.tags[j] = article.Tags[k]
+				s.stats.Tags++
+			}
+		}
+		s.articles = append(s.articles, article)
+		s.stats.Articles++
+		return nil
+	})
+}
+
+func (s *seeder) createComments(ctx context.Context) error {
+	if s.options.Comments < 1 || len(s.articles) == 0 {
+		return nil
+	}
+	article, author := s.popular(len(s.articles)), s.popular(len(s.users))
+	return s.batches(ctx, s.options.Comments, func(tx service.Store, i int) error {
+		commented := s.articles[article()]
+		comment := models.Comment{Body: s.text.paragraph(), ArticleID: commented.ID, AuthorID: s.users[author()].ProfileID}
+		comment.CreatedAt = s.since(commented.CreatedAt)
+		comment.UpdatedAt = comment.CreatedAt
+		if err := tx.CreateComment(ctx, &comment); err != nil {
+			return err
+		}
+		s.stats.Comments++
+		return nil
+	})
+}
+
+func (s *seeder) createFavorites(ctx context.Context) error {
+	if s.options.Favorites < 1 || len(s.articles) == 0 {
+		return nil
+	}
+	favorite := s.popular(len(s.articles))
+	return s.batches(ctx, len(s.users), func(tx service.Store, i int) error {
+		for n := min(s.around(s.options.Favorites), len(s.articles)); n > 0; n-- {
+			changed, err := tx.SetFavorite(ctx, s.users[i].ProfileID, s.articles[favorite()].ID, true)
+			if err != nil {
+				return err
+			}
+			if changed {
+				s.stats.Favorites++
+			}
+		}
+		return nil
+	})
+}
@@ -0,0 +1,59 @@
+package seed
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+
+	"github.com/hy00nc/conduit-go/internal/database"
+	"github.com/hy00nc/conduit-go/internal/models"
+	"github.com/hy00nc/conduit-go/internal/service"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+func TestRun(t *testing.T) {
+	asserts := assert.New(t)
+	ctx := context.Background()
+	db, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "seed.db")), &gorm.Config{
+		Logger: logger.Default.LogMode(logger.Silent),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	database.MigrateDB(db)
+	t.Cleanup(func() { database.CloseDB(db) })
+	store := service.GormStore{DB: db}
+	count := func(model interface{}) int {
+		var n int64
+		db.Model(model).Count(&n)
+		return int(n)
+	}
+
+	options := Options{Users: 30, Articles: 250, Comments: 300, Tags: 8, Follows: 4, Favorites: 5, Password: "secret", Seed: 1}
+	stats, err := Run(ctx, store, options)
+	asserts.NoError(err)
+	asserts.Equal(30, stats.Users)
+	asserts.Equal(250, stats.Articles)
+	asserts.Equal(300, stats.Comments)
+	asserts.Equal(8, stats.Tags)
+	asserts.Equal(stats.Follows, count(&models.Follow{}))
+	asserts.Equal(stats.Favorites, count(&models.Favorite{}))
+	asserts.NotZero(stats.Follows)
+	asserts.NotZero(stats.Favorites)
+
+	var user models.User
+	asserts.NoError(db.First(&user).Error)
+	_, err = service.NewUserService(store, nil).Login(ctx, user.Email, "secret")
+	asserts.NoError(err)
+
+	// the same seed again adds new users and reuses the tags
+	stats, err = Run(ctx, store, options)
+	asserts.NoError(err)
+	asserts.Zero(stats.Tags)
+	asserts.Equal(60, count(&models.User{}))
+	asserts.Equal(500, count(&models.Article{}))
+	asserts.Equal(8, count(&models.Tag{}))
+}
@@ -0,0 +1,109 @@
+package seed
+
+import (
+	"fmt"
+	"math/rand"
+	"strings"
+)
+
+var firstNames = []string{
+	"alice", "bob", "carol", "dave", "erin", "frank", "grace", "heidi", "ivan", "judy",
+	"mallory", "niaj", "olivia", "peggy", "rupert", "sybil", "trent", "victor", "walter", "yasmin",
+	"amir", "bea", "chen", "dmitri", "elena", "femi", "gus", "hana", "ines", "jonas",
+}
+
+var lastNames = []string{
+	"smith", "jones", "garcia", "miller", "davis", "lopez", "wilson", "anderson", "thomas", "moore",
+	"martin", "lee", "perez", "white", "harris", "clark", "lewis", "young", "walker", "hall",
+	"kim", "nguyen", "novak", "sato", "okafor", "silva", "rossi", "berg", "kowalski", "dubois",
+}
+
+var tagNames = []string{
+	"go", "web", "databases", "testing", "design", "performance", "security", "devops", "frontend", "backend",
+	"career", "tutorial", "opinion", "architecture", "cloud", "linux", "open-source", "productivity", "api", "tooling",
+	"rust", "javascript", "python", "kubernetes", "observability", "ux", "accessibility", "mobile", "ai", "data",
+}
+
+var words = strings.Fields(`
+	the a an of to in and or but for with on at by from about into over after before
+	code system service request response server client query cache index queue worker
+	build deploy release test bug fix feature design pattern interface module package
+	simple fast small large better careful clear robust modern legacy shared local remote
+	write read measure improve refactor document review ship learn explain compare avoid
+	team project user data network storage memory latency throughput error failure
+	today really often never always sometimes usually quickly slowly finally
+`)
+
+var bios = []string{
+	"Writes about software, coffee and the space between them.",
+	"Backend engineer. Opinions are cached and may be stale.",
+	"Learning in public.",
+	"Building things for the web since the dial-up days.",
+	"",
+}
+
+// text generates the prose of articles and comments.
+type text struct {
+	rand *rand.Rand
+}
+
+func (t text) pick(list []string) string {
+	return list[t.rand.Intn(len(list))]
+}
+
+func (t text) words(n int) []string {
+	picked := make([]string, n)
+	for i := range picked {
+		picked[i] = t.pick(words)
+	}
+	return picked
+}
+
+func capitalize(s string) string {
+	if s == "" {
+		return s
+	}
+	return strings.ToUpper(s[:1]) + s[1:]
+}
+
+func (t text) sentence() string {
+	return capitalize(strings.Join(t.words(6+t.rand.Intn(10)), " ")) + "."
+}
+
+func (t text) paragraph() string {
+	sentences := make([]string, 2+t.rand.Intn(4))
+	for i := range sentences {
+		sentences[i] = t.sentence()
+	}
+	return strings.Join(sentences, " ")
+}
+
+func (t text) title() string {
+	title := t.words(3 + t.rand.Intn(5))
+	for i, word := range title {
+		title[i] = capitalize(word)
+	}
+	return strings.Join(title, " ")
+}
+
+// body is a few paragraphs of Markdown, sometimes with a heading, a list
+// or a code block.
+func (t text) body() string {
+	var blocks []string
+	for i := 0; i < 2+t.rand.Intn(5); i++ {
+		switch t.rand.Intn(6) {
+		case 0:
+			blocks = append(blocks, "## "+t.title())
+		case 1:
+			items := make([]string, 2+t.rand.Intn(3))
+			for j := range items {
+				items[j] = "- " + t.sentence()
+			}
+			blocks = append(blocks, strings.Join(items, "\n"))
+		case 2:
+			blocks = append(blocks, fmt.Sprintf("```go\nfunc %s() error {\n\treturn nil\n}\n```", t.pick(words)))
+		}
+		blocks = append(blocks, t.paragraph())
+	}
+	return strings.Join(blocks, "\n\n")
+}
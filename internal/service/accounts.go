@@ -0,0 +1,115 @@
+package service
+
+import (
+	"context"
+
+	"github.com/google/uuid"
+	"github.com/hy00nc/conduit-go/internal/cache"
+	"github.com/hy00nc/conduit-go/internal/logging"
+	"github.com/hy00nc/conduit-go/internal/models"
+)
+
+// DeletionPolicy is what happens to the articles and comments of a deleted
+// account.
+type DeletionPolicy string
+
+const (
+	// AnonymizeContent keeps them under the profile, renamed and without
+	// bio or image.
+	AnonymizeContent DeletionPolicy = "anonymize"
+	// RemoveContent permanently deletes them with the profile.
+	RemoveContent DeletionPolicy = "remove"
+)
+
+// AccountData is what is stored about a user, as exported to them.
+type AccountData struct {
+	User      models.User
+	Articles  []models.Article
+	Comments  []models.Comment // with their article
+	Following []models.Profile
+	Favorites []models.Article
+}
+
+type AccountService struct {
+	Store  Store
+	Policy DeletionPolicy
+	// DeleteUpload removes the stored files of an upload once the account
+	// is gone; failures are logged.
+	DeleteUpload func(ctx context.Context, upload models.Upload) error
+	// Cache, if set, has the cached user and responses of its articles
+	// invalidated on deletion.
+	Cache cache.Cache
+}
+
+func NewAccountService(store Store, policy DeletionPolicy, deleteUpload func(context.Context, models.Upload) error) *AccountService {
+	return &AccountService{Store: store, Policy: policy, DeleteUpload: deleteUpload}
+}
+
+func (s *AccountService) Export(ctx context.Context, user models.User) (AccountData, error) {
+	data := AccountData{User: user}
+	var err error
+	if data.Articles, err = s.Store.ArticlesByAuthor(ctx, user.ProfileID); err != nil {
+		return AccountData{}, err
+	}
+	if data.Comments, err = s.Store.CommentsByAuthor(ctx, user.ProfileID); err != nil {
+		return AccountData{}, err
+	}
+	if data.Following, err = s.Store.Following(ctx, user.ProfileID); err != nil {
+		return AccountData{}, err
+	}
+	if data.Favorites, err = s.Store.FavoriteArticles(ctx, user.ProfileID); err != nil {
+		return AccountData{}, err
+	}
+	return data, nil
+}
+
+// Delete permanently deletes the account of user, confirmed by its password.
+// Follows, favorites, uploads, webhooks and the feed token go with it; the
+// articles and comments are handled according to the policy.
+func (s *AccountService) Delete(ctx context.Context, user models.User, password string) error {
+	if password == "" {
+		return Invalid("password")
+	}
+	// the user of a request is cached without its password hash
+	stored, err := s.Store.UserByID(ctx, user.ID)
+	if err != nil {
+		return err
+	}
+	if stored.CheckPassword(password) != nil {
+		return Forbidden("password")
+	}
+	uploads, err := s.Store.UploadsByOwner(ctx, user.ProfileID)
+	if err != nil {
+		return err
+	}
+	keys := userKeys(ctx, s.Cache, s.Store, user)
+	err = s.Store.Transaction(ctx, func(tx Store) error {
+		if err := tx.DeleteAccountData(ctx, user); err != nil {
+			return err
+		}
+		if s.Policy == RemoveContent {
+			if err := tx.DeleteAuthored(ctx, user.ProfileID); err != nil {
+				return err
+			}
+			return tx.DeleteUser(ctx, user, true)
+		}
+		profile := user.Profile
+		profile.Name = "deleted-" + uuid.NewString()
+		profile.Bio = ""
+		profile.Image = DefaultImage
+		if err := tx.UpdateProfile(ctx, &profile); err != nil {
+			return err
+		}
+		return tx.DeleteUser(ctx, user, false)
+	})
+	if err != nil {
+		return err
+	}
+	cache.Invalidate(ctx, s.Cache, keys...)
+	for _, upload := range uploads {
+		if err := s.DeleteUpload(ctx, upload); err != nil {
+			logging.FromContext(ctx).Error("account: delete upload failed", "upload", upload.Key, "error", err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,122 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/hy00nc/conduit-go/internal/models"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/gorm"
+)
+
+// accountFixture registers sally, who wrote article "a", favorited and
+// commented on harry's article "b" and follows harry, who follows her back.
+func accountFixture(t *testing.T, store *fakeStore) (sally, harry models.User) {
+	ctx := context.Background()
+	sally, err := NewUserService(store, nil).Register(ctx, RegisterInput{Username: "sally", Email: "sally@example.com", Password: "secret"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	harry = store.addUser("harry")
+	store.addArticle(sally, "a")
+	b := store.addArticle(harry, "b")
+	store.uploads[99] = models.Upload{Model: gorm.Model{ID: 99}, OwnerID: sally.ProfileID, Key: "k"}
+	store.follows[[2]uint{sally.ProfileID, harry.ProfileID}] = true
+	store.follows[[2]uint{harry.ProfileID, sally.ProfileID}] = true
+	store.favorites[[2]uint{sally.ProfileID, b.ID}] = true
+	store.favorites[[2]uint{harry.ProfileID, b.ID}] = true
+	if _, err := NewCommentService(store, nil).Add(ctx, sally, "b", "hi"); err != nil {
+		t.Fatal(err)
+	}
+	return sally, harry
+}
+
+func TestExportAccount(t *testing.T) {
+	asserts := assert.New(t)
+	store := newFakeStore()
+	sally, _ := accountFixture(t, store)
+
+	data, err := NewAccountService(store, AnonymizeContent, nil).Export(context.Background(), sally)
+	asserts.NoError(err)
+	asserts.Equal("sally", data.User.Profile.Name)
+	asserts.Len(data.Articles, 1)
+	asserts.Len(data.Comments, 1)
+	asserts.Equal("b", data.Comments[0].Article.Slug)
+	asserts.Len(data.Following, 1)
+	asserts.Equal("harry", data.Following[0].Name)
+	asserts.Len(data.Favorites, 1)
+}
+
+func TestDeleteAccountAnonymizes(t *testing.T) {
+	asserts := assert.New(t)
+	ctx := context.Background()
+	store := newFakeStore()
+	sally, harry := accountFixture(t, store)
+	var deleted []string
+	accounts := NewAccountService(store, AnonymizeContent, func(ctx context.Context, upload models.Upload) error {
+		deleted = append(deleted, upload.Key)
+		return nil
+	})
+
+	err := accounts.Delete(ctx, sally, "")
+	asserts.ErrorIs(err, ErrValidation)
+	err = accounts.Delete(ctx, sally, "wrong")
+	asserts.ErrorIs(err, ErrForbidden)
+	asserts.Equal("password", Field(err))
+
+	asserts.NoError(accounts.Delete(ctx, sally, "secret"))
+	_, err = store.UserByEmail(ctx, "sally@example.com")
+	asserts.ErrorIs(err, ErrNotFound)
+	profile := store.profiles[sally.ProfileID]
+	asserts.True(strings.HasPrefix(profile.Name, "deleted-"))
+	asserts.Empty(profile.Bio)
+	asserts.Len(store.articles, 2, "content is kept")
+	asserts.Len(store.comments, 1)
+	asserts.Empty(store.follows)
+	b, _ := store.ArticleBySlug(ctx, "b")
+	asserts.Equal(map[[2]uint]bool{{harry.ProfileID, b.ID}: true}, store.favorites)
+	asserts.Empty(store.uploads)
+	asserts.Equal([]string{"k"}, deleted)
+
+	// the username is free again
+	_, err = NewUserService(store, nil).Register(ctx, RegisterInput{Username: "sally", Email: "sally@example.com", Password: "secret"})
+	asserts.NoError(err)
+}
+
+func TestDeleteAccountRemovesContent(t *testing.T) {
+	asserts := assert.New(t)
+	ctx := context.Background()
+	store := newFakeStore()
+	sally, harry := accountFixture(t, store)
+	accounts := NewAccountService(store, RemoveContent, func(context.Context, models.Upload) error { return nil })
+	store.addArticle(harry, "c")
+	asserts.NoError(NewArticleService(store, nil).Delete(ctx, harry, "c"))
+
+	asserts.NoError(accounts.Delete(ctx, sally, "secret"))
+	asserts.NotContains(store.profiles, sally.ProfileID)
+	asserts.Len(store.articles, 1)
+	asserts.Equal("b", store.articles[6].Slug)
+	asserts.Empty(store.comments)
+	asserts.Len(store.trashed, 1, "the trash of others is kept")
+}
+
+func TestDeleteAccountRollsBack(t *testing.T) {
+	asserts := assert.New(t)
+	ctx := context.Background()
+	store := newFakeStore()
+	sally, _ := accountFixture(t, store)
+	store.fail["DeleteUser"] = errors.New("disk full")
+	called := false
+	accounts := NewAccountService(store, RemoveContent, func(context.Context, models.Upload) error {
+		called = true
+		return nil
+	})
+
+	asserts.Error(accounts.Delete(ctx, sally, "secret"))
+	asserts.Len(store.articles, 2)
+	asserts.Len(store.follows, 2)
+	asserts.Len(store.uploads, 1)
+	asserts.False(called, "files are only removed once the account is gone")
+}
@@ -0,0 +1,136 @@
+package service
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/hy00nc/conduit-go/internal/models"
+)
+
+func validRole(role string) bool {
+	return role == models.RoleUser || role == models.RoleAdmin
+}
+
+// AdminService manages users and content from the conduit command; none of
+// it is exposed over the API.
+type AdminService struct {
+	Store    Store
+	users    *UserService
+	articles *ArticleService
+}
+
+func NewAdminService(store Store) *AdminService {
+	return &AdminService{Store: store, users: NewUserService(store, nil), articles: NewArticleService(store, nil)}
+}
+
+// User loads a user by email if ref has an @, and by username otherwise.
+func (s *AdminService) User(ctx context.Context, ref string) (models.User, error) {
+	if strings.Contains(ref, "@") {
+		return s.Store.UserByEmail(ctx, ref)
+	}
+	return s.Store.UserByName(ctx, ref)
+}
+
+func (s *AdminService) CreateUser(ctx context.Context, input RegisterInput) (models.User, error) {
+	return s.users.Register(ctx, input)
+}
+
+func (s *AdminService) ResetPassword(ctx context.Context, ref, password string) (models.User, error) {
+	if password == "" {
+		return models.User{}, Invalid("password")
+	}
+	user, err := s.User(ctx, ref)
+	if err != nil {
+		return user, err
+	}
+	return s.users.Update(ctx, user, UserUpdate{Password: password})
+}
+
+func (s *AdminService) SetRole(ctx context.Context, ref, role string) (models.User, error) {
+	if !validRole(role) {
+		return models.User{}, Invalid("role")
+	}
+	user, err := s.User(ctx, ref)
+	if err != nil {
+		return user, err
+	}
+	user.Role = role
+	return user, s.Store.UpdateUser(ctx, &user)
+}
+
+// Suspend keeps the user from logging in and using their tokens until
+// lifted with suspend false.
+func (s *AdminService) Suspend(ctx context.Context, ref string, suspend bool) (models.User, error) {
+	user, err := s.User(ctx, ref)
+	if err != nil {
+		return user, err
+	}
+	if suspend == user.Suspended() {
+		return user, nil
+	}
+	user.SuspendedAt = nil
+	if suspend {
+		now := time.Now()
+		user.SuspendedAt = &now
+	}
+	return user, s.Store.UpdateUser(ctx, &user)
+}
+
+// DeleteArticle moves the article to its author's trash.
+func (s *AdminService) DeleteArticle(ctx context.Context, slug string) error {
+	article, err := s.Store.ArticleBySlug(ctx, slug)
+	if err != nil {
+		return err
+	}
+	return s.articles.remove(ctx, article)
+}
+
+// tag loads the tag named name.
+func (s *AdminService) tag(ctx context.Context, store Store, name string) (models.Tag, error) {
+	tags, err := store.TagsByName(ctx, []string{name})
+	if err != nil {
+		return models.Tag{}, err
+	}
+	if len(tags) == 0 {
+		return models.Tag{}, NotFound("tag")
+	}
+	return tags[0], nil
+}
+
+// RenameTag renames a tag; to merge it into an existing tag, use MergeTags.
+func (s *AdminService) RenameTag(ctx context.Context, name, newName string) (models.Tag, error) {
+	if newName == "" {
+		return models.Tag{}, Invalid("name")
+	}
+	tag, err := s.tag(ctx, s.Store, name)
+	if err != nil {
+		return tag, err
+	}
+	tag.Name = newName
+	return tag, s.Store.UpdateTag(ctx, &tag)
+}
+
+// MergeTags moves the articles tagged with any of names to the tag into,
+// which must exist, and removes the merged tags.
+func (s *AdminService) MergeTags(ctx context.Context, into string, names ...string) error {
+	return s.Store.Transaction(ctx, func(tx Store) error {
+		target, err := s.tag(ctx, tx, into)
+		if err != nil {
+			return err
+		}
+		for _, name := range names {
+			if name == into {
+				continue
+			}
+			tag, err := s.tag(ctx, tx, name)
+			if err != nil {
+				return err
+			}
+			if err := tx.MergeTag(ctx, tag, target); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
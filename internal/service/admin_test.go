@@ -0,0 +1,102 @@
+package service
+
+import (
+	"context"
+	"testing"
+
+	"github.com/hy00nc/conduit-go/internal/models"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAdminUsers(t *testing.T) {
+	asserts := assert.New(t)
+	ctx := context.Background()
+	store := newFakeStore()
+	admin := NewAdminService(store)
+	users := NewUserService(store, nil)
+
+	_, err := admin.CreateUser(ctx, RegisterInput{Username: "root", Email: "root@example.com", Password: "secret", Role: "owner"})
+	asserts.ErrorIs(err, ErrValidation)
+	asserts.Equal("role", Field(err))
+	root, err := admin.CreateUser(ctx, RegisterInput{Username: "root", Email: "root@example.com", Password: "secret", Role: models.RoleAdmin})
+	asserts.NoError(err)
+	asserts.True(root.IsAdmin())
+	sally, err := users.Register(ctx, RegisterInput{Username: "sally", Email: "sally@example.com", Password: "secret"})
+	asserts.NoError(err)
+	asserts.Equal(models.RoleUser, sally.Role)
+
+	user, err := admin.User(ctx, "sally@example.com")
+	asserts.NoError(err)
+	asserts.Equal("sally", user.Profile.Name)
+	_, err = admin.User(ctx, "nobody")
+	asserts.ErrorIs(err, ErrNotFound)
+
+	_, err = admin.ResetPassword(ctx, "sally", "changed")
+	asserts.NoError(err)
+	_, err = users.Login(ctx, "sally@example.com", "secret")
+	asserts.ErrorIs(err, ErrCredentials)
+
+	user, err = admin.SetRole(ctx, "sally", models.RoleAdmin)
+	asserts.NoError(err)
+	asserts.Equal(models.RoleAdmin, store.users[user.ID].Role)
+
+	_, err = admin.Suspend(ctx, "sally", true)
+	asserts.NoError(err)
+	_, err = users.Login(ctx, "sally@example.com", "wrong")
+	asserts.ErrorIs(err, ErrCredentials, "a wrong password does not reveal the suspension")
+	_, err = users.Login(ctx, "sally@example.com", "changed")
+	asserts.ErrorIs(err, ErrSuspended)
+	_, err = admin.Suspend(ctx, "sally", false)
+	asserts.NoError(err)
+	_, err = users.Login(ctx, "sally@example.com", "changed")
+	asserts.NoError(err)
+}
+
+func TestAdminsModerate(t *testing.T) {
+	asserts := assert.New(t)
+	ctx := context.Background()
+	store := newFakeStore()
+	sally, harry := store.addUser("sally"), store.addUser("harry")
+	article := store.addArticle(sally, "mine")
+	comment, err := NewCommentService(store, nil).Add(ctx, sally, "mine", "hi")
+	asserts.NoError(err)
+
+	asserts.ErrorIs(NewCommentService(store, nil).Delete(ctx, harry, comment.ID), ErrForbidden)
+	asserts.ErrorIs(NewArticleService(store, nil).Delete(ctx, harry, "mine"), ErrForbidden)
+	harry.Role = models.RoleAdmin
+	asserts.NoError(NewCommentService(store, nil).Delete(ctx, harry, comment.ID))
+	asserts.NoError(NewArticleService(store, nil).Delete(ctx, harry, "mine"))
+	asserts.Contains(store.trashed, article.ID, "moderated articles go to the author's trash")
+}
+
+func TestAdminTags(t *testing.T) {
+	asserts := assert.New(t)
+	ctx := context.Background()
+	store := newFakeStore()
+	admin := NewAdminService(store)
+	sally := store.addUser("sally")
+	articles := NewArticleService(store, nil)
+	both, err := articles.Create(ctx, sally, ArticleInput{Title: "both", Description: "d", Body: "b", TagList: []string{"go", "golang"}})
+	asserts.NoError(err)
+	one, err := articles.Create(ctx, sally, ArticleInput{Title: "one", Description: "d", Body: "b", TagList: []string{"golang", "web"}})
+	asserts.NoError(err)
+
+	_, err = admin.RenameTag(ctx, "web", "go")
+	asserts.ErrorIs(err, ErrConflict)
+	_, err = admin.RenameTag(ctx, "web", "www")
+	asserts.NoError(err)
+
+	asserts.ErrorIs(admin.MergeTags(ctx, "go", "golang", "missing"), ErrNotFound)
+	asserts.Len(store.tags, 3, "a failed merge changes nothing")
+	asserts.NoError(admin.MergeTags(ctx, "go", "golang"))
+	names := func(article models.Article) []string {
+		var names []string
+		for _, tag := range store.articles[article.ID].Tags {
+			names = append(names, store.tags[tag.ID].Name)
+		}
+		return names
+	}
+	asserts.Equal([]string{"go"}, names(both))
+	asserts.ElementsMatch([]string{"go", "www"}, names(one))
+	asserts.Len(store.tags, 2)
+}
@@ -0,0 +1,259 @@
+package service
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/gosimple/slug"
+	"github.com/hy00nc/conduit-go/internal/cache"
+	"github.com/hy00nc/conduit-go/internal/markdown"
+	"github.com/hy00nc/conduit-go/internal/metrics"
+	"github.com/hy00nc/conduit-go/internal/models"
+	"github.com/hy00nc/conduit-go/internal/webhooks"
+)
+
+type ArticleInput struct {
+	Title       string
+	Description string
+	Body        string
+	TagList     []string
+	// Slug and CreatedAt keep the original values of imported articles.
+	// By default the slug is derived from the title and the article is
+	// dated now.
+	Slug      string
+	CreatedAt time.Time
+}
+
+// ArticleUpdate changes the non-empty fields.
+type ArticleUpdate struct {
+	Title       string
+	Description string
+	Body        string
+	// IfUpdatedAt, if not empty, lists when the editor saw the article last
+	// updated. The update fails with ErrPrecondition if it has been updated
+	// at another time since, so that concurrent edits are not lost.
+	IfUpdatedAt []time.Time
+}
+
+// TagsTTL is how long the tag list is cached.
+const TagsTTL = time.Minute
+
+type ArticleService struct {
+	Store    Store
+	Notifier Notifier
+	// Cache, if set, caches the tag list and has the cached responses of
+	// articles invalidated on writes.
+	Cache cache.Cache
+}
+
+func NewArticleService(store Store, notifier Notifier) *ArticleService {
+	return &ArticleService{Store: store, Notifier: notifierOrNop(notifier)}
+}
+
+// makeSlug derives a unique slug from the title.
+func makeSlug(title string) string {
+	return slug.Make(title + " " + uuid.NewString())
+}
+
+func (s *ArticleService) Get(ctx context.Context, slug string) (models.Article, error) {
+	return s.Store.ArticleBySlug(ctx, slug)
+}
+
+func (s *ArticleService) List(ctx context.Context, filter ArticleFilter) ([]models.Article, int64, error) {
+	if filter.Limit < 0 || filter.Offset < 0 {
+		return nil, 0, Invalid("limit")
+	}
+	return s.Store.ListArticles(ctx, filter)
+}
+
+// Feed lists the articles of the authors user follows.
+func (s *ArticleService) Feed(ctx context.Context, user models.User, limit, offset int) ([]models.Article, int64, error) {
+	if limit < 0 || offset < 0 {
+		return nil, 0, Invalid("limit")
+	}
+	return s.Store.FeedArticles(ctx, user.ProfileID, limit, offset)
+}
+
+func (s *ArticleService) Tags(ctx context.Context) ([]models.Tag, error) {
+	return cache.Fetch(ctx, s.Cache, "tags", cache.TagsKey, TagsTTL, s.Store.ListTags)
+}
+
+// tags returns the named tags, reusing existing ones; new tags are created
+// along with the article.
+func (s *ArticleService) tags(ctx context.Context, store Store, names []string) ([]models.Tag, error) {
+	existing, err := store.TagsByName(ctx, names)
+	if err != nil {
+		return nil, err
+	}
+	byName := map[string]models.Tag{}
+	for _, tag := range existing {
+		byName[tag.Name] = tag
+	}
+	tags := []models.Tag{}
+	seen := map[string]bool{}
+	for _, name := range names {
+		if seen[name] {
+			continue
+		}
+		seen[name] = true
+		tag, ok := byName[name]
+		if !ok {
+			tag = models.Tag{Name: name}
+		}
+		tags = append(tags, tag)
+	}
+	return tags, nil
+}
+
+func (s *ArticleService) Create(ctx context.Context, author models.User, input ArticleInput) (models.Article, error) {
+	switch {
+	case input.Title == "":
+		return models.Article{}, Invalid("title")
+	case input.Description == "":
+		return models.Article{}, Invalid("description")
+	case input.Body == "":
+		return models.Article{}, Invalid("body")
+	}
+	slug := input.Slug
+	if slug == "" {
+		slug = makeSlug(input.Title)
+	}
+	article := models.Article{
+		Slug:        slug,
+		Title:       input.Title,
+		Description: input.Description,
+		Body:        input.Body,
+		AuthorID:    author.ProfileID,
+	}
+	article.CreatedAt, article.UpdatedAt = input.CreatedAt, input.CreatedAt
+	err := s.Store.Transaction(ctx, func(tx Store) error {
+		tags, err := s.tags(ctx, tx, input.TagList)
+		if err != nil {
+			return err
+		}
+		article.Tags = tags
+		if err := tx.CreateArticle(ctx, &article); err != nil {
+			return err
+		}
+		return tx.Enqueue(ctx, webhooks.ArticleCreated, article.AuthorID, map[string]interface{}{"article": article})
+	})
+	if err != nil {
+		return models.Article{}, err
+	}
+	metrics.ArticlesCreated.Inc()
+	cache.Invalidate(ctx, s.Cache, cache.TagsKey)
+	article.Author = author.Profile
+	s.Notifier.ArticleCreated(article)
+	return article, nil
+}
+
+// editable loads the article user wants to change; only its author may.
+func (s *ArticleService) editable(ctx context.Context, user models.User, slug string) (models.Article, error) {
+	article, err := s.Store.ArticleBySlug(ctx, slug)
+	if err != nil {
+		return article, err
+	}
+	if article.AuthorID != user.ProfileID {
+		return article, Forbidden("article")
+	}
+	return article, nil
+}
+
+func (s *ArticleService) Update(ctx context.Context, user models.User, slug string, input ArticleUpdate) (models.Article, error) {
+	article, err := s.editable(ctx, user, slug)
+	if err != nil {
+		return article, err
+	}
+	if input.Title != "" && input.Title != article.Title {
+		article.Title = input.Title
+		article.Slug = makeSlug(input.Title)
+	}
+	if input.Description != "" {
+		article.Description = input.Description
+	}
+	if input.Body != "" {
+		article.Body = input.Body
+	}
+	err = s.Store.Transaction(ctx, func(tx Store) error {
+		if len(input.IfUpdatedAt) > 0 {
+			current, err := tx.ArticleBySlug(ctx, slug)
+			if err != nil {
+				return err
+			}
+			if !updatedAtAny(current, input.IfUpdatedAt) {
+				return Changed("article")
+			}
+		}
+		if err := tx.UpdateArticle(ctx, &article); err != nil {
+			return err
+		}
+		return tx.Enqueue(ctx, webhooks.ArticleUpdated, article.AuthorID, map[string]interface{}{"article": article})
+	})
+	markdown.DefaultCache.Invalidate(article.ID)
+	if err != nil {
+		return models.Article{}, err
+	}
+	cache.Invalidate(ctx, s.Cache, append(cache.ArticleKeys(slug), cache.ArticleKeys(article.Slug)...)...)
+	return article, nil
+}
+
+// updatedAtAny reports whether article was last updated at one of times,
+// to the microsecond as databases keep them.
+func updatedAtAny(article models.Article, times []time.Time) bool {
+	for _, t := range times {
+		if article.UpdatedAt.UnixMicro() == t.UnixMicro() {
+			return true
+		}
+	}
+	return false
+}
+
+// Delete moves the article to its author's trash; authors may delete their
+// own articles and admins any.
+func (s *ArticleService) Delete(ctx context.Context, user models.User, slug string) error {
+	article, err := s.Store.ArticleBySlug(ctx, slug)
+	if err != nil {
+		return err
+	}
+	if article.AuthorID != user.ProfileID && !user.IsAdmin() {
+		return Forbidden("article")
+	}
+	return s.remove(ctx, article)
+}
+
+func (s *ArticleService) remove(ctx context.Context, article models.Article) error {
+	err := s.Store.Transaction(ctx, func(tx Store) error {
+		if err := tx.Enqueue(ctx, webhooks.ArticleDeleted, article.AuthorID, map[string]interface{}{"article": article}); err != nil {
+			return err
+		}
+		return tx.DeleteArticle(ctx, &article)
+	})
+	markdown.DefaultCache.Invalidate(article.ID)
+	cache.Invalidate(ctx, s.Cache, cache.ArticleKeys(article.Slug)...)
+	return err
+}
+
+func (s *ArticleService) Favorite(ctx context.Context, user models.User, slug string) (models.Article, error) {
+	return s.setFavorite(ctx, user, slug, true)
+}
+
+func (s *ArticleService) Unfavorite(ctx context.Context, user models.User, slug string) (models.Article, error) {
+	return s.setFavorite(ctx, user, slug, false)
+}
+
+func (s *ArticleService) setFavorite(ctx context.Context, user models.User, slug string, favorite bool) (models.Article, error) {
+	article, err := s.Store.ArticleBySlug(ctx, slug)
+	if err != nil {
+		return article, err
+	}
+	err = s.Store.Transaction(ctx, func(tx Store) error {
+		changed, err := tx.SetFavorite(ctx, user.ProfileID, article.ID, favorite)
+		if err != nil || !favorite || !changed {
+			return err
+		}
+		return tx.Enqueue(ctx, webhooks.ArticleFavorited, article.AuthorID, map[string]interface{}{"article": article, "profile": user.Profile})
+	})
+	cache.Invalidate(ctx, s.Cache, cache.ArticleKeys(article.Slug)...)
+	return article, err
+}
@@ -0,0 +1,200 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/hy00nc/conduit-go/internal/cache"
+	"github.com/hy00nc/conduit-go/internal/models"
+	"github.com/hy00nc/conduit-go/internal/webhooks"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/gorm"
+)
+
+func TestCreateArticle(t *testing.T) {
+	asserts := assert.New(t)
+	ctx := context.Background()
+	store := newFakeStore()
+	notifier := &recordingNotifier{}
+	articles := NewArticleService(store, notifier)
+	sally := store.addUser("sally")
+	store.tags[50] = models.Tag{Model: gorm.Model{ID: 50}, Name: "go"}
+
+	article, err := articles.Create(ctx, sally, ArticleInput{Title: "Hello World", Description: "d", Body: "b", TagList: []string{"go", "web", "go"}})
+	asserts.NoError(err)
+	asserts.Contains(article.Slug, "hello-world-")
+	asserts.Equal("sally", article.Author.Name)
+	asserts.Len(article.Tags, 2)
+	asserts.Len(store.tags, 2)
+	asserts.Equal([]string{webhooks.ArticleCreated}, store.events)
+	asserts.Len(notifier.articles, 1)
+
+	_, err = articles.Create(ctx, sally, ArticleInput{Title: "No body", Description: "d"})
+	asserts.ErrorIs(err, ErrValidation)
+	asserts.Equal("body", Field(err))
+
+	// imports keep the original slug and date
+	published := time.Date(2019, 5, 1, 0, 0, 0, 0, time.UTC)
+	article, err = articles.Create(ctx, sally, ArticleInput{Title: "Old", Description: "d", Body: "b", Slug: "old-post", CreatedAt: published})
+	asserts.NoError(err)
+	asserts.Equal("old-post", article.Slug)
+	asserts.Equal(published, article.CreatedAt)
+}
+
+func TestCreateArticleRollsBack(t *testing.T) {
+	asserts := assert.New(t)
+	store := newFakeStore()
+	notifier := &recordingNotifier{}
+	sally := store.addUser("sally")
+	store.fail["Enqueue"] = errors.New("outbox unavailable")
+
+	_, err := NewArticleService(store, notifier).Create(context.Background(), sally, ArticleInput{Title: "t", Description: "d", Body: "b", TagList: []string{"go"}})
+	asserts.Error(err)
+	asserts.Empty(store.articles)
+	asserts.Empty(store.tags)
+	asserts.Empty(notifier.articles)
+}
+
+func TestUpdateAndDeleteArticle(t *testing.T) {
+	asserts := assert.New(t)
+	ctx := context.Background()
+	store := newFakeStore()
+	articles := NewArticleService(store, nil)
+	sally := store.addUser("sally")
+	harry := store.addUser("harry")
+	store.addArticle(sally, "first")
+
+	_, err := articles.Update(ctx, harry, "first", ArticleUpdate{Body: "mine now"})
+	asserts.ErrorIs(err, ErrForbidden)
+	asserts.Equal("article", Field(err))
+
+	article, err := articles.Update(ctx, sally, "first", ArticleUpdate{Title: "Second", Body: "new"})
+	asserts.NoError(err)
+	asserts.Contains(article.Slug, "second-")
+	asserts.Equal("new", store.articles[article.ID].Body)
+
+	_, err = articles.Get(ctx, "first")
+	asserts.ErrorIs(err, ErrNotFound)
+
+	asserts.ErrorIs(articles.Delete(ctx, harry, article.Slug), ErrForbidden)
+	store.fail["DeleteArticle"] = errors.New("disk full")
+	asserts.Error(articles.Delete(ctx, sally, article.Slug))
+	asserts.Equal([]string{webhooks.ArticleUpdated}, store.events)
+	delete(store.fail, "DeleteArticle")
+	asserts.NoError(articles.Delete(ctx, sally, article.Slug))
+	asserts.Empty(store.articles)
+	asserts.Equal([]string{webhooks.ArticleUpdated, webhooks.ArticleDeleted}, store.events)
+}
+
+func TestUpdateArticleIfUpdatedAt(t *testing.T) {
+	asserts := assert.New(t)
+	ctx := context.Background()
+	store := newFakeStore()
+	articles := NewArticleService(store, nil)
+	sally := store.addUser("sally")
+	seen := store.addArticle(sally, "first").UpdatedAt
+
+	article, err := articles.Update(ctx, sally, "first", ArticleUpdate{Body: "mine", IfUpdatedAt: []time.Time{seen}})
+	asserts.NoError(err)
+	asserts.NotEqual(seen, article.UpdatedAt)
+
+	_, err = articles.Update(ctx, sally, "first", ArticleUpdate{Body: "lost", IfUpdatedAt: []time.Time{seen}})
+	asserts.ErrorIs(err, ErrPrecondition, "the article was updated since")
+	asserts.Equal("article", Field(err))
+	asserts.Equal("mine", store.articles[article.ID].Body)
+	asserts.Equal([]string{webhooks.ArticleUpdated}, store.events)
+
+	_, err = articles.Update(ctx, sally, "first", ArticleUpdate{Body: "kept", IfUpdatedAt: []time.Time{seen, article.UpdatedAt}})
+	asserts.NoError(err)
+}
+
+func TestListArticles(t *testing.T) {
+	asserts := assert.New(t)
+	ctx := context.Background()
+	store := newFakeStore()
+	articles := NewArticleService(store, nil)
+	sally := store.addUser("sally")
+	harry := store.addUser("harry")
+	store.addArticle(sally, "a")
+	store.addArticle(harry, "b")
+	store.addArticle(sally, "c")
+
+	list, count, err := articles.List(ctx, ArticleFilter{Author: "sally", Limit: 1})
+	asserts.NoError(err)
+	asserts.EqualValues(2, count)
+	asserts.Equal("c", list[0].Slug)
+
+	_, _, err = articles.List(ctx, ArticleFilter{Limit: -1})
+	asserts.ErrorIs(err, ErrValidation)
+
+	store.follows[[2]uint{sally.ProfileID, harry.ProfileID}] = true
+	feed, count, err := articles.Feed(ctx, sally, 0, 0)
+	asserts.NoError(err)
+	asserts.EqualValues(1, count)
+	asserts.Equal("b", feed[0].Slug)
+}
+
+func TestFavorite(t *testing.T) {
+	asserts := assert.New(t)
+	ctx := context.Background()
+	store := newFakeStore()
+	articles := NewArticleService(store, nil)
+	sally := store.addUser("sally")
+	article := store.addArticle(sally, "a")
+
+	_, err := articles.Favorite(ctx, sally, "a")
+	asserts.NoError(err)
+	_, err = articles.Favorite(ctx, sally, "a")
+	asserts.NoError(err)
+	asserts.True(store.favorites[[2]uint{sally.ProfileID, article.ID}])
+	asserts.Equal([]string{webhooks.ArticleFavorited}, store.events)
+
+	_, err = articles.Unfavorite(ctx, sally, "a")
+	asserts.NoError(err)
+	asserts.Empty(store.favorites)
+
+	_, err = articles.Favorite(ctx, sally, "missing")
+	asserts.ErrorIs(err, ErrNotFound)
+}
+
+func TestArticleCache(t *testing.T) {
+	asserts := assert.New(t)
+	ctx := context.Background()
+	store := newFakeStore()
+	articles := NewArticleService(store, nil)
+	articles.Cache = cache.NewLRU(10)
+	sally := store.addUser("sally")
+	store.addArticle(sally, "a")
+
+	tags, err := articles.Tags(ctx)
+	asserts.NoError(err)
+	asserts.Empty(tags)
+	store.tags[store.id()] = models.Tag{Name: "sneaky"}
+	tags, _ = articles.Tags(ctx)
+	asserts.Empty(tags, "the tag list is cached")
+	_, err = articles.Create(ctx, sally, ArticleInput{Title: "T", Description: "D", Body: "B", TagList: []string{"go"}})
+	asserts.NoError(err)
+	tags, _ = articles.Tags(ctx)
+	asserts.Len(tags, 2, "creating an article invalidates it")
+
+	cached := func(slug string) bool {
+		_, ok, _ := articles.Cache.Get(ctx, cache.ArticleKey(slug, false))
+		return ok
+	}
+	articles.Cache.Set(ctx, cache.ArticleKey("a", false), []byte("{}"), time.Minute)
+	_, err = articles.Favorite(ctx, sally, "a")
+	asserts.NoError(err)
+	asserts.False(cached("a"), "favorites change the favorites count")
+
+	articles.Cache.Set(ctx, cache.ArticleKey("a", false), []byte("{}"), time.Minute)
+	article, err := articles.Update(ctx, sally, "a", ArticleUpdate{Title: "New title"})
+	asserts.NoError(err)
+	asserts.False(cached("a"))
+
+	articles.Cache.Set(ctx, cache.ArticleKey(article.Slug, true), []byte("{}"), time.Minute)
+	asserts.NoError(articles.Delete(ctx, sally, article.Slug))
+	_, ok, _ := articles.Cache.Get(ctx, cache.ArticleKey(article.Slug, true))
+	asserts.False(ok)
+}
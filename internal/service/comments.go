@@ -0,0 +1,101 @@
+package service
+
+import (
+	"context"
+
+	"github.com/hy00nc/conduit-go/internal/metrics"
+	"github.com/hy00nc/conduit-go/internal/models"
+	"github.com/hy00nc/conduit-go/internal/webhooks"
+)
+
+type CommentService struct {
+	Store    Store
+	Notifier Notifier
+}
+
+func NewCommentService(store Store, notifier Notifier) *CommentService {
+	return &CommentService{Store: store, Notifier: notifierOrNop(notifier)}
+}
+
+// List returns the comments on an article, oldest first.
+func (s *CommentService) List(ctx context.Context, slug string) ([]models.Comment, error) {
+	article, err := s.Store.ArticleBySlug(ctx, slug)
+	if err != nil {
+		return nil, err
+	}
+	return s.Store.CommentsByArticle(ctx, article.ID)
+}
+
+func (s *CommentService) Add(ctx context.Context, user models.User, slug, body string) (models.Comment, error) {
+	if body == "" {
+		return models.Comment{}, Invalid("body")
+	}
+	article, err := s.Store.ArticleBySlug(ctx, slug)
+	if err != nil {
+		return models.Comment{}, err
+	}
+	comment := models.Comment{
+		Body:      body,
+		ArticleID: article.ID,
+		AuthorID:  user.ProfileID,
+	}
+	err = s.Store.Transaction(ctx, func(tx Store) error {
+		if err := tx.CreateComment(ctx, &comment); err != nil {
+			return err
+		}
+		return tx.Enqueue(ctx, webhooks.CommentCreated, article.AuthorID, map[string]interface{}{"article": article.Slug, "comment": comment})
+	})
+	if err != nil {
+		return models.Comment{}, err
+	}
+	metrics.CommentsPosted.Inc()
+	comment.Author = user.Profile
+	s.Notifier.CommentChanged(CommentCreated, comment)
+	return comment, nil
+}
+
+// editable loads the comment user wants to change; only its author may.
+func (s *CommentService) editable(ctx context.Context, user models.User, id uint) (models.Comment, error) {
+	comment, err := s.Store.CommentByID(ctx, id)
+	if err != nil {
+		return comment, err
+	}
+	if comment.AuthorID != user.ProfileID {
+		return comment, Forbidden("comment")
+	}
+	comment.Author = user.Profile
+	return comment, nil
+}
+
+func (s *CommentService) Update(ctx context.Context, user models.User, id uint, body string) (models.Comment, error) {
+	if body == "" {
+		return models.Comment{}, Invalid("body")
+	}
+	comment, err := s.editable(ctx, user, id)
+	if err != nil {
+		return comment, err
+	}
+	comment.Body = body
+	if err := s.Store.UpdateComment(ctx, &comment); err != nil {
+		return models.Comment{}, err
+	}
+	s.Notifier.CommentChanged(CommentUpdated, comment)
+	return comment, nil
+}
+
+// Delete moves the comment to its author's trash; authors may delete their
+// own comments and admins any.
+func (s *CommentService) Delete(ctx context.Context, user models.User, id uint) error {
+	comment, err := s.Store.CommentByID(ctx, id)
+	if err != nil {
+		return err
+	}
+	if comment.AuthorID != user.ProfileID && !user.IsAdmin() {
+		return Forbidden("comment")
+	}
+	if err := s.Store.DeleteComment(ctx, &comment); err != nil {
+		return err
+	}
+	s.Notifier.CommentChanged(CommentDeleted, comment)
+	return nil
+}
@@ -0,0 +1,63 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/hy00nc/conduit-go/internal/webhooks"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestComments(t *testing.T) {
+	asserts := assert.New(t)
+	ctx := context.Background()
+	store := newFakeStore()
+	notifier := &recordingNotifier{}
+	comments := NewCommentService(store, notifier)
+	sally := store.addUser("sally")
+	harry := store.addUser("harry")
+	store.addArticle(sally, "a")
+
+	comment, err := comments.Add(ctx, harry, "a", "first")
+	asserts.NoError(err)
+	asserts.Equal("harry", comment.Author.Name)
+	asserts.Equal([]string{webhooks.CommentCreated}, store.events)
+
+	_, err = comments.Add(ctx, harry, "a", "")
+	asserts.ErrorIs(err, ErrValidation)
+	_, err = comments.Add(ctx, harry, "missing", "first")
+	asserts.ErrorIs(err, ErrNotFound)
+
+	_, err = comments.Update(ctx, sally, comment.ID, "edited")
+	asserts.ErrorIs(err, ErrForbidden)
+	asserts.Equal("comment", Field(err))
+	comment, err = comments.Update(ctx, harry, comment.ID, "edited")
+	asserts.NoError(err)
+	asserts.Equal("edited", store.comments[comment.ID].Body)
+
+	list, err := comments.List(ctx, "a")
+	asserts.NoError(err)
+	asserts.Len(list, 1)
+
+	asserts.ErrorIs(comments.Delete(ctx, sally, comment.ID), ErrForbidden)
+	asserts.NoError(comments.Delete(ctx, harry, comment.ID))
+	asserts.ErrorIs(comments.Delete(ctx, harry, comment.ID), ErrNotFound)
+	asserts.Empty(store.comments)
+
+	asserts.Equal([]string{CommentCreated, CommentUpdated, CommentDeleted}, notifier.comments)
+}
+
+func TestAddCommentRollsBack(t *testing.T) {
+	asserts := assert.New(t)
+	store := newFakeStore()
+	notifier := &recordingNotifier{}
+	sally := store.addUser("sally")
+	store.addArticle(sally, "a")
+	store.fail["Enqueue"] = errors.New("outbox unavailable")
+
+	_, err := NewCommentService(store, notifier).Add(context.Background(), sally, "a", "first")
+	asserts.Error(err)
+	asserts.Empty(store.comments)
+	asserts.Empty(notifier.comments)
+}
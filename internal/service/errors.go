@@ -0,0 +1,102 @@
+package service
+
+import (
+	"errors"
+	"time"
+)
+
+// Kinds of domain errors. Callers match them with errors.Is and map them to
+// their transport, e.g. ErrNotFound to HTTP 404.
+var (
+	ErrNotFound   = errors.New("not found")
+	ErrForbidden  = errors.New("forbidden")
+	ErrConflict   = errors.New("conflict")
+	ErrValidation = errors.New("is invalid")
+	ErrLimited    = errors.New("is limited")
+	// ErrPrecondition is a change refused because what it was made
+	// against has changed since.
+	ErrPrecondition = errors.New("has changed")
+)
+
+// Error is a domain error about one field or resource, such as the "title"
+// of an article failing validation or the "article" not being found.
+type Error struct {
+	Kind  error
+	Field string
+}
+
+func (e *Error) Error() string {
+	return e.Field + " " + e.Kind.Error()
+}
+
+func (e *Error) Unwrap() error {
+	return e.Kind
+}
+
+// LimitedError is a domain error of an action refused until RetryAfter
+// has passed, such as logging in to an account locked out after failed
+// attempts.
+type LimitedError struct {
+	Field      string
+	RetryAfter time.Duration
+}
+
+func (e *LimitedError) Error() string {
+	return e.Field + " " + ErrLimited.Error()
+}
+
+func (e *LimitedError) Unwrap() error {
+	return &Error{Kind: ErrLimited, Field: e.Field}
+}
+
+// ErrCredentials is returned by UserService.Login for an unknown email or a
+// wrong password alike.
+var ErrCredentials = &Error{Kind: ErrForbidden, Field: "email or password"}
+
+// ErrSuspended is returned by UserService.Login for a suspended user with
+// the right password.
+var ErrSuspended = &Error{Kind: ErrForbidden, Field: "account"}
+
+func NotFound(field string) error {
+	return &Error{Kind: ErrNotFound, Field: field}
+}
+
+func Forbidden(field string) error {
+	return &Error{Kind: ErrForbidden, Field: field}
+}
+
+func Conflict(field string) error {
+	return &Error{Kind: ErrConflict, Field: field}
+}
+
+func Invalid(field string) error {
+	return &Error{Kind: ErrValidation, Field: field}
+}
+
+func Changed(field string) error {
+	return &Error{Kind: ErrPrecondition, Field: field}
+}
+
+func Limited(field string, retryAfter time.Duration) error {
+	return &LimitedError{Field: field, RetryAfter: retryAfter}
+}
+
+// RetryAfter returns how long until the action refused with a
+// LimitedError may be retried, or 0 for any other error.
+func RetryAfter(err error) time.Duration {
+	var limited *LimitedError
+	if errors.As(err, &limited) {
+		return limited.RetryAfter
+	}
+	return 0
+}
+
+// Field returns the field or resource a domain error is about, or "" for
+// any other error.
+func Field(err error) string {
+	var domainErr *Error
+	if errors.As(err, &domainErr) {
+		return domainErr.Field
+	}
+	return ""
+}
@@ -0,0 +1,645 @@
+package service
+
+import (
+	"context"
+	"maps"
+	"slices"
+	"sort"
+	"time"
+
+	"github.com/hy00nc/conduit-go/internal/models"
+	"gorm.io/gorm"
+)
+
+// fakeData is the state of a fakeStore, copied to roll back transactions.
+type fakeData struct {
+	nextID    uint
+	users     map[uint]models.User
+	profiles  map[uint]models.Profile
+	uploads   map[uint]models.Upload
+	articles  map[uint]models.Article
+	tags      map[uint]models.Tag
+	comments  map[uint]models.Comment
+	trashed   map[uint]models.Article // soft-deleted articles
+	binned    map[uint]models.Comment // soft-deleted comments
+	follows   map[[2]uint]bool
+	favorites map[[2]uint]bool
+	events    []string
+
+	webhooks   map[uint]models.Webhook
+	deliveries map[uint]models.WebhookDelivery
+}
+
+func (d fakeData) clone() fakeData {
+	d.users = maps.Clone(d.users)
+	d.profiles = maps.Clone(d.profiles)
+	d.uploads = maps.Clone(d.uploads)
+	d.articles = maps.Clone(d.articles)
+	d.tags = maps.Clone(d.tags)
+	d.comments = maps.Clone(d.comments)
+	d.trashed = maps.Clone(d.trashed)
+	d.binned = maps.Clone(d.binned)
+	d.follows = maps.Clone(d.follows)
+	d.favorites = maps.Clone(d.favorites)
+	d.events = slices.Clone(d.events)
+	d.webhooks = maps.Clone(d.webhooks)
+	d.deliveries = maps.Clone(d.deliveries)
+	return d
+}
+
+// fakeStore is an in-memory Store. Setting fail[method] makes that method
+// return the error, to exercise failures midway through a transaction.
+type fakeStore struct {
+	fakeData
+	fail map[string]error
+}
+
+func newFakeStore() *fakeStore {
+	return &fakeStore{
+		fakeData: fakeData{
+			users:     map[uint]models.User{},
+			profiles:  map[uint]models.Profile{},
+			uploads:   map[uint]models.Upload{},
+			articles:  map[uint]models.Article{},
+			tags:      map[uint]models.Tag{},
+			comments:  map[uint]models.Comment{},
+			trashed:   map[uint]models.Article{},
+			binned:    map[uint]models.Comment{},
+			follows:   map[[2]uint]bool{},
+			favorites: map[[2]uint]bool{},
+
+			webhooks:   map[uint]models.Webhook{},
+			deliveries: map[uint]models.WebhookDelivery{},
+		},
+		fail: map[string]error{},
+	}
+}
+
+func (s *fakeStore) id() uint {
+	s.nextID++
+	return s.nextID
+}
+
+// addUser stores a user with a profile named name and returns it.
+func (s *fakeStore) addUser(name string) models.User {
+	profile := models.Profile{Model: gorm.Model{ID: s.id()}, Name: name}
+	s.profiles[profile.ID] = profile
+	user := models.User{Model: gorm.Model{ID: s.id()}, Email: name + "@example.com", Profile: profile, ProfileID: profile.ID}
+	s.users[user.ID] = user
+	return user
+}
+
+// addArticle stores an article by author and returns it.
+func (s *fakeStore) addArticle(author models.User, slug string) models.Article {
+	article := models.Article{Model: gorm.Model{ID: s.id()}, Slug: slug, Title: slug, AuthorID: author.ProfileID, Author: author.Profile}
+	s.articles[article.ID] = article
+	return article
+}
+
+func (s *fakeStore) Transaction(ctx context.Context, fn func(Store) error) error {
+	snapshot := s.fakeData.clone()
+	if err := fn(s); err != nil {
+		s.fakeData = snapshot
+		return err
+	}
+	return nil
+}
+
+func (s *fakeStore) UserByID(ctx context.Context, id uint) (models.User, error) {
+	user, ok := s.users[id]
+	if !ok {
+		return models.User{}, NotFound("user")
+	}
+	user.Profile = s.profiles[user.ProfileID]
+	return user, nil
+}
+
+func (s *fakeStore) UserByEmail(ctx context.Context, email string) (models.User, error) {
+	for _, user := range s.users {
+		if user.Email == email {
+			user.Profile = s.profiles[user.ProfileID]
+			return user, nil
+		}
+	}
+	return models.User{}, NotFound("user")
+}
+
+func (s *fakeStore) UserByName(ctx context.Context, name string) (models.User, error) {
+	for _, user := range s.users {
+		if s.profiles[user.ProfileID].Name == name {
+			user.Profile = s.profiles[user.ProfileID]
+			return user, nil
+		}
+	}
+	return models.User{}, NotFound("user")
+}
+
+func (s *fakeStore) CreateUser(ctx context.Context, user *models.User) error {
+	if err := s.fail["CreateUser"]; err != nil {
+		return err
+	}
+	user.Profile.ID = s.id()
+	user.ProfileID = user.Profile.ID
+	s.profiles[user.ProfileID] = user.Profile
+	user.ID = s.id()
+	s.users[user.ID] = *user
+	return nil
+}
+
+func (s *fakeStore) UpdateUser(ctx context.Context, user *models.User) error {
+	if err := s.fail["UpdateUser"]; err != nil {
+		return err
+	}
+	s.users[user.ID] = *user
+	return nil
+}
+
+func (s *fakeStore) UploadByID(ctx context.Context, ownerID, id uint) (models.Upload, error) {
+	upload, ok := s.uploads[id]
+	if !ok || upload.OwnerID != ownerID {
+		return models.Upload{}, NotFound("upload")
+	}
+	return upload, nil
+}
+
+func (s *fakeStore) ProfileByName(ctx context.Context, name string) (models.Profile, error) {
+	for _, profile := range s.profiles {
+		if profile.Name == name {
+			return profile, nil
+		}
+	}
+	return models.Profile{}, NotFound("profile")
+}
+
+func (s *fakeStore) UpdateProfile(ctx context.Context, profile *models.Profile) error {
+	s.profiles[profile.ID] = *profile
+	return nil
+}
+
+func (s *fakeStore) SetFollow(ctx context.Context, profileID, targetID uint, follow bool) (bool, error) {
+	key := [2]uint{profileID, targetID}
+	changed := s.follows[key] != follow
+	if follow {
+		s.follows[key] = true
+	} else {
+		delete(s.follows, key)
+	}
+	return changed, nil
+}
+
+func (s *fakeStore) ArticleBySlug(ctx context.Context, slug string) (models.Article, error) {
+	for _, article := range s.articles {
+		if article.Slug == slug {
+			return article, nil
+		}
+	}
+	return models.Article{}, NotFound("article")
+}
+
+// page sorts articles newest (highest id) first and applies limit and offset.
+func page(articles []models.Article, limit, offset int) ([]models.Article, int64) {
+	sort.Slice(articles, func(i, j int) bool { return articles[i].ID > articles[j].ID })
+	count := int64(len(articles))
+	if limit == 0 {
+		limit = DefaultLimit
+	}
+	articles = articles[min(offset, len(articles)):]
+	return articles[:min(limit, len(articles))], count
+}
+
+func (s *fakeStore) ListArticles(ctx context.Context, filter ArticleFilter) ([]models.Article, int64, error) {
+	var articles []models.Article
+	for _, article := range s.articles {
+		if filter.Author != "" && article.Author.Name != filter.Author {
+			continue
+		}
+		if filter.Tag != "" && !slices.ContainsFunc(article.Tags, func(tag models.Tag) bool { return tag.Name == filter.Tag }) {
+			continue
+		}
+		articles = append(articles, article)
+	}
+	articles, count := page(articles, filter.Limit, filter.Offset)
+	return articles, count, nil
+}
+
+func (s *fakeStore) FeedArticles(ctx context.Context, profileID uint, limit, offset int) ([]models.Article, int64, error) {
+	var articles []models.Article
+	for _, article := range s.articles {
+		if s.follows[[2]uint{profileID, article.AuthorID}] {
+			articles = append(articles, article)
+		}
+	}
+	articles, count := page(articles, limit, offset)
+	return articles, count, nil
+}
+
+func (s *fakeStore) CreateArticle(ctx context.Context, article *models.Article) error {
+	if err := s.fail["CreateArticle"]; err != nil {
+		return err
+	}
+	for i, tag := range article.Tags {
+		if tag.ID == 0 {
+			tag.ID = s.id()
+			s.tags[tag.ID] = tag
+			article.Tags[i] = tag
+		}
+	}
+	article.ID = s.id()
+	s.articles[article.ID] = *article
+	return nil
+}
+
+func (s *fakeStore) UpdateArticle(ctx context.Context, article *models.Article) error {
+	if err := s.fail["UpdateArticle"]; err != nil {
+		return err
+	}
+	if stored, ok := s.articles[article.ID]; !ok || !stored.UpdatedAt.Equal(article.UpdatedAt) {
+		return Changed("article")
+	}
+	article.UpdatedAt = time.Now()
+	s.articles[article.ID] = *article
+	return nil
+}
+
+func (s *fakeStore) DeleteArticle(ctx context.Context, article *models.Article) error {
+	if err := s.fail["DeleteArticle"]; err != nil {
+		return err
+	}
+	article.DeletedAt = gorm.DeletedAt{Time: time.Now(), Valid: true}
+	s.trashed[article.ID] = *article
+	delete(s.articles, article.ID)
+	return nil
+}
+
+func (s *fakeStore) SetFavorite(ctx context.Context, profileID, articleID uint, favorite bool) (bool, error) {
+	key := [2]uint{profileID, articleID}
+	changed := s.favorites[key] != favorite
+	if favorite {
+		s.favorites[key] = true
+	} else {
+		delete(s.favorites, key)
+	}
+	return changed, nil
+}
+
+func (s *fakeStore) ListTags(ctx context.Context) ([]models.Tag, error) {
+	return slices.Collect(maps.Values(s.tags)), nil
+}
+
+func (s *fakeStore) TagsByName(ctx context.Context, names []string) ([]models.Tag, error) {
+	var tags []models.Tag
+	for _, tag := range s.tags {
+		if slices.Contains(names, tag.Name) {
+			tags = append(tags, tag)
+		}
+	}
+	return tags, nil
+}
+
+func (s *fakeStore) UpdateTag(ctx context.Context, tag *models.Tag) error {
+	for _, other := range s.tags {
+		if other.Name == tag.Name && other.ID != tag.ID {
+			return Conflict("tag")
+		}
+	}
+	s.tags[tag.ID] = *tag
+	return nil
+}
+
+// retag replaces from with into in tags, without duplicating into.
+func retag(tags []models.Tag, from, into models.Tag) []models.Tag {
+	if !slices.ContainsFunc(tags, func(tag models.Tag) bool { return tag.ID == from.ID }) {
+		return tags
+	}
+	retagged := []models.Tag{}
+	for _, tag := range tags {
+		if tag.ID != from.ID && tag.ID != into.ID {
+			retagged = append(retagged, tag)
+		}
+	}
+	return append(retagged, into)
+}
+
+func (s *fakeStore) MergeTag(ctx context.Context, from, into models.Tag) error {
+	for id, article := range s.articles {
+		article.Tags = retag(article.Tags, from, into)
+		s.articles[id] = article
+	}
+	for id, article := range s.trashed {
+		article.Tags = retag(article.Tags, from, into)
+		s.trashed[id] = article
+	}
+	delete(s.tags, from.ID)
+	return nil
+}
+
+func (s *fakeStore) CommentByID(ctx context.Context, id uint) (models.Comment, error) {
+	comment, ok := s.comments[id]
+	if !ok {
+		return models.Comment{}, NotFound("comment")
+	}
+	comment.Author = s.profiles[comment.AuthorID]
+	return comment, nil
+}
+
+func (s *fakeStore) CommentsByArticle(ctx context.Context, articleID uint) ([]models.Comment, error) {
+	var comments []models.Comment
+	for _, comment := range s.comments {
+		if comment.ArticleID == articleID {
+			comments = append(comments, comment)
+		}
+	}
+	sort.Slice(comments, func(i, j int) bool { return comments[i].ID < comments[j].ID })
+	return comments, nil
+}
+
+func (s *fakeStore) CreateComment(ctx context.Context, comment *models.Comment) error {
+	comment.ID = s.id()
+	s.comments[comment.ID] = *comment
+	return nil
+}
+
+func (s *fakeStore) UpdateComment(ctx context.Context, comment *models.Comment) error {
+	s.comments[comment.ID] = *comment
+	return nil
+}
+
+func (s *fakeStore) DeleteComment(ctx context.Context, comment *models.Comment) error {
+	comment.DeletedAt = gorm.DeletedAt{Time: time.Now(), Valid: true}
+	s.binned[comment.ID] = *comment
+	delete(s.comments, comment.ID)
+	return nil
+}
+
+func (s *fakeStore) DeletedArticles(ctx context.Context, authorID uint) ([]models.Article, error) {
+	var articles []models.Article
+	for _, article := range s.trashed {
+		if article.AuthorID == authorID {
+			articles = append(articles, article)
+		}
+	}
+	return articles, nil
+}
+
+func (s *fakeStore) DeletedArticleBySlug(ctx context.Context, slug string) (models.Article, error) {
+	for _, article := range s.trashed {
+		if article.Slug == slug {
+			return article, nil
+		}
+	}
+	return models.Article{}, NotFound("article")
+}
+
+func (s *fakeStore) RestoreArticle(ctx context.Context, article *models.Article) error {
+	article.DeletedAt = gorm.DeletedAt{}
+	s.articles[article.ID] = *article
+	delete(s.trashed, article.ID)
+	return nil
+}
+
+// withArticle sets the comment's article, which may be in the trash.
+func (s *fakeStore) withArticle(comment models.Comment) models.Comment {
+	article, ok := s.articles[comment.ArticleID]
+	if !ok {
+		article = s.trashed[comment.ArticleID]
+	}
+	comment.Article = article
+	return comment
+}
+
+func (s *fakeStore) DeletedComments(ctx context.Context, authorID uint) ([]models.Comment, error) {
+	var comments []models.Comment
+	for _, comment := range s.binned {
+		if comment.AuthorID == authorID {
+			comments = append(comments, s.withArticle(comment))
+		}
+	}
+	return comments, nil
+}
+
+func (s *fakeStore) DeletedCommentByID(ctx context.Context, id uint) (models.Comment, error) {
+	comment, ok := s.binned[id]
+	if !ok {
+		return models.Comment{}, NotFound("comment")
+	}
+	return s.withArticle(comment), nil
+}
+
+func (s *fakeStore) RestoreComment(ctx context.Context, comment *models.Comment) error {
+	comment.DeletedAt = gorm.DeletedAt{}
+	s.comments[comment.ID] = *comment
+	delete(s.binned, comment.ID)
+	return nil
+}
+
+func (s *fakeStore) Purge(ctx context.Context, cutoff time.Time) (int64, error) {
+	var purged int64
+	for id, article := range s.trashed {
+		if article.DeletedAt.Time.Before(cutoff) {
+			delete(s.trashed, id)
+			purged++
+		}
+	}
+	for id, comment := range s.binned {
+		if comment.DeletedAt.Time.Before(cutoff) {
+			delete(s.binned, id)
+			purged++
+		}
+	}
+	return purged, nil
+}
+
+func (s *fakeStore) ArticlesByAuthor(ctx context.Context, authorID uint) ([]models.Article, error) {
+	var articles []models.Article
+	for _, article := range s.articles {
+		if article.AuthorID == authorID {
+			articles = append(articles, article)
+		}
+	}
+	sort.Slice(articles, func(i, j int) bool { return articles[i].ID < articles[j].ID })
+	return articles, nil
+}
+
+func (s *fakeStore) CommentsByAuthor(ctx context.Context, authorID uint) ([]models.Comment, error) {
+	var comments []models.Comment
+	for _, comment := range s.comments {
+		if comment.AuthorID == authorID {
+			comments = append(comments, s.withArticle(comment))
+		}
+	}
+	sort.Slice(comments, func(i, j int) bool { return comments[i].ID < comments[j].ID })
+	return comments, nil
+}
+
+func (s *fakeStore) Following(ctx context.Context, profileID uint) ([]models.Profile, error) {
+	var profiles []models.Profile
+	for key := range s.follows {
+		if key[0] == profileID {
+			profiles = append(profiles, s.profiles[key[1]])
+		}
+	}
+	return profiles, nil
+}
+
+func (s *fakeStore) FavoriteArticles(ctx context.Context, profileID uint) ([]models.Article, error) {
+	var articles []models.Article
+	for key := range s.favorites {
+		if article, ok := s.articles[key[1]]; ok && key[0] == profileID {
+			articles = append(articles, article)
+		}
+	}
+	return articles, nil
+}
+
+func (s *fakeStore) UploadsByOwner(ctx context.Context, ownerID uint) ([]models.Upload, error) {
+	var uploads []models.Upload
+	for _, upload := range s.uploads {
+		if upload.OwnerID == ownerID {
+			uploads = append(uploads, upload)
+		}
+	}
+	return uploads, nil
+}
+
+func (s *fakeStore) DeleteAccountData(ctx context.Context, user models.User) error {
+	for key := range s.follows {
+		if key[0] == user.ProfileID || key[1] == user.ProfileID {
+			delete(s.follows, key)
+		}
+	}
+	for key := range s.favorites {
+		if key[0] == user.ProfileID {
+			delete(s.favorites, key)
+		}
+	}
+	for id, upload := range s.uploads {
+		if upload.OwnerID == user.ProfileID {
+			delete(s.uploads, id)
+		}
+	}
+	for id, webhook := range s.webhooks {
+		if webhook.OwnerID == user.ProfileID {
+			delete(s.webhooks, id)
+		}
+	}
+	return nil
+}
+
+func (s *fakeStore) DeleteAuthored(ctx context.Context, authorID uint) error {
+	for _, articles := range []map[uint]models.Article{s.articles, s.trashed} {
+		for id, article := range articles {
+			if article.AuthorID != authorID {
+				continue
+			}
+			delete(articles, id)
+			for key := range s.favorites {
+				if key[1] == id {
+					delete(s.favorites, key)
+				}
+			}
+		}
+	}
+	for _, comments := range []map[uint]models.Comment{s.comments, s.binned} {
+		for id, comment := range comments {
+			_, live := s.articles[comment.ArticleID]
+			_, trashed := s.trashed[comment.ArticleID]
+			if comment.AuthorID == authorID || !live && !trashed {
+				delete(comments, id)
+			}
+		}
+	}
+	return nil
+}
+
+func (s *fakeStore) DeleteUser(ctx context.Context, user models.User, deleteProfile bool) error {
+	if err := s.fail["DeleteUser"]; err != nil {
+		return err
+	}
+	delete(s.users, user.ID)
+	if deleteProfile {
+		delete(s.profiles, user.ProfileID)
+	}
+	return nil
+}
+
+func (s *fakeStore) WebhooksByOwner(ctx context.Context, ownerID uint) ([]models.Webhook, error) {
+	var hooks []models.Webhook
+	for _, webhook := range s.webhooks {
+		if webhook.OwnerID == ownerID {
+			hooks = append(hooks, webhook)
+		}
+	}
+	sort.Slice(hooks, func(i, j int) bool { return hooks[i].ID < hooks[j].ID })
+	return hooks, nil
+}
+
+func (s *fakeStore) WebhookByID(ctx context.Context, ownerID, id uint) (models.Webhook, error) {
+	webhook, ok := s.webhooks[id]
+	if !ok || webhook.OwnerID != ownerID {
+		return models.Webhook{}, NotFound("webhook")
+	}
+	return webhook, nil
+}
+
+func (s *fakeStore) CreateWebhook(ctx context.Context, webhook *models.Webhook) error {
+	webhook.ID = s.id()
+	s.webhooks[webhook.ID] = *webhook
+	return nil
+}
+
+func (s *fakeStore) DeleteWebhook(ctx context.Context, webhook *models.Webhook) error {
+	if err := s.fail["DeleteWebhook"]; err != nil {
+		return err
+	}
+	delete(s.webhooks, webhook.ID)
+	return nil
+}
+
+func (s *fakeStore) WebhookDeliveries(ctx context.Context, webhookID uint, limit, offset int) ([]models.WebhookDelivery, int64, error) {
+	var deliveries []models.WebhookDelivery
+	for _, delivery := range s.deliveries {
+		if delivery.WebhookID == webhookID {
+			deliveries = append(deliveries, delivery)
+		}
+	}
+	sort.Slice(deliveries, func(i, j int) bool { return deliveries[i].ID > deliveries[j].ID })
+	count := int64(len(deliveries))
+	deliveries = deliveries[min(offset, len(deliveries)):]
+	return deliveries[:min(limit, len(deliveries))], count, nil
+}
+
+func (s *fakeStore) WebhookDeliveryByID(ctx context.Context, webhookID, id uint) (models.WebhookDelivery, error) {
+	delivery, ok := s.deliveries[id]
+	if !ok || delivery.WebhookID != webhookID {
+		return models.WebhookDelivery{}, NotFound("delivery")
+	}
+	return delivery, nil
+}
+
+func (s *fakeStore) CreateWebhookDelivery(ctx context.Context, delivery *models.WebhookDelivery) error {
+	delivery.ID = s.id()
+	s.deliveries[delivery.ID] = *delivery
+	return nil
+}
+
+func (s *fakeStore) Enqueue(ctx context.Context, eventType string, profileID uint, data map[string]interface{}) error {
+	if err := s.fail["Enqueue"]; err != nil {
+		return err
+	}
+	s.events = append(s.events, eventType)
+	return nil
+}
+
+// recordingNotifier remembers what it was told.
+type recordingNotifier struct {
+	articles []models.Article
+	comments []string
+}
+
+func (n *recordingNotifier) ArticleCreated(article models.Article) {
+	n.articles = append(n.articles, article)
+}
+
+func (n *recordingNotifier) CommentChanged(event string, comment models.Comment) {
+	n.comments = append(n.comments, event)
+}
@@ -0,0 +1,472 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/hy00nc/conduit-go/internal/database"
+	"github.com/hy00nc/conduit-go/internal/models"
+	"github.com/hy00nc/conduit-go/internal/webhooks"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// GormStore implements Store with gorm. A zero GormStore uses the database
+// opened by the database package.
+type GormStore struct {
+	DB *gorm.DB
+}
+
+func (s GormStore) db(ctx context.Context) *gorm.DB {
+	db := s.DB
+	if db == nil {
+		db = database.GetDB()
+	}
+	return db.WithContext(ctx)
+}
+
+// anonymousRequest makes serializers produce the viewer independent
+// representation, as used in webhook payloads.
+var anonymousRequest = &http.Request{URL: &url.URL{}}
+
+func isUniqueViolation(err error) bool {
+	return errors.Is(err, gorm.ErrDuplicatedKey) || strings.Contains(err.Error(), "UNIQUE constraint failed")
+}
+
+// translate maps gorm errors to domain errors about field.
+func translate(err error, field string) error {
+	switch {
+	case err == nil:
+		return nil
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		return NotFound(field)
+	case isUniqueViolation(err):
+		return Conflict(field)
+	}
+	return err
+}
+
+func (s GormStore) Transaction(ctx context.Context, fn func(Store) error) error {
+	return database.Transaction(ctx, s.db(ctx), func(tx *gorm.DB) error {
+		return fn(GormStore{DB: tx})
+	})
+}
+
+func (s GormStore) UserByID(ctx context.Context, id uint) (models.User, error) {
+	var user models.User
+	err := s.db(ctx).Preload(clause.Associations).First(&user, id).Error
+	return user, translate(err, "user")
+}
+
+func (s GormStore) UserByEmail(ctx context.Context, email string) (models.User, error) {
+	var user models.User
+	err := s.db(ctx).Preload(clause.Associations).First(&user, "email = ?", email).Error
+	return user, translate(err, "user")
+}
+
+func (s GormStore) UserByName(ctx context.Context, name string) (models.User, error) {
+	db := s.db(ctx)
+	var user models.User
+	profile := db.Model(&models.Profile{}).Select("id").Where("name = ?", name)
+	err := db.Preload(clause.Associations).First(&user, "profile_id IN (?)", profile).Error
+	return user, translate(err, "user")
+}
+
+func (s GormStore) CreateUser(ctx context.Context, user *models.User) error {
+	return translate(s.db(ctx).Create(user).Error, "user")
+}
+
+func (s GormStore) UpdateUser(ctx context.Context, user *models.User) error {
+	return translate(s.db(ctx).Omit(clause.Associations).Save(user).Error, "email")
+}
+
+func (s GormStore) UploadByID(ctx context.Context, ownerID, id uint) (models.Upload, error) {
+	var upload models.Upload
+	err := s.db(ctx).Where("owner_id = ?", ownerID).First(&upload, id).Error
+	return upload, translate(err, "upload")
+}
+
+func (s GormStore) ProfileByName(ctx context.Context, name string) (models.Profile, error) {
+	var profile models.Profile
+	err := s.db(ctx).First(&profile, "name = ?", name).Error
+	return profile, translate(err, "profile")
+}
+
+func (s GormStore) UpdateProfile(ctx context.Context, profile *models.Profile) error {
+	return translate(s.db(ctx).Save(profile).Error, "username")
+}
+
+func (s GormStore) SetFollow(ctx context.Context, profileID, targetID uint, follow bool) (bool, error) {
+	db := s.db(ctx)
+	condition := models.Follow{UserID: profileID, FollowingID: targetID}
+	if follow {
+		var followData models.Follow
+		result := db.Where(condition).FirstOrCreate(&followData)
+		return result.RowsAffected > 0, result.Error
+	}
+	result := db.Where(condition).Delete(&models.Follow{})
+	return result.RowsAffected > 0, result.Error
+}
+
+func (s GormStore) ArticleBySlug(ctx context.Context, slug string) (models.Article, error) {
+	var article models.Article
+	err := s.db(ctx).Preload(clause.Associations).First(&article, "slug = ?", slug).Error
+	return article, translate(err, "article")
+}
+
+func (s GormStore) listArticles(query *gorm.DB, limit, offset int) ([]models.Article, int64, error) {
+	if limit == 0 {
+		limit = DefaultLimit
+	}
+	var count int64
+	if err := query.Session(&gorm.Session{}).Count(&count).Error; err != nil {
+		return nil, 0, err
+	}
+	var articles []models.Article
+	err := query.Order("created_at desc").Offset(offset).Limit(limit).Preload(clause.Associations).Find(&articles).Error
+	return articles, count, err
+}
+
+func (s GormStore) ListArticles(ctx context.Context, filter ArticleFilter) ([]models.Article, int64, error) {
+	db := s.db(ctx)
+	query := db.Model(&models.Article{})
+	if filter.Tag != "" {
+		tagged := db.Table("article_tags").Select("article_tags.article_id").
+			Joins("JOIN tags ON tags.id = article_tags.tag_id").Where("tags.name = ?", filter.Tag)
+		query = query.Where("id IN (?)", tagged)
+	}
+	if filter.Author != "" {
+		query = query.Where("author_id IN (?)", db.Model(&models.Profile{}).Select("id").Where("name = ?", filter.Author))
+	}
+	if filter.Favorited != "" {
+		favorited := db.Model(&models.Favorite{}).Select("favorites.article_id").
+			Joins("JOIN profiles ON profiles.id = favorites.favorited_by_id").Where("profiles.name = ?", filter.Favorited)
+		query = query.Where("id IN (?)", favorited)
+	}
+	return s.listArticles(query, filter.Limit, filter.Offset)
+}
+
+func (s GormStore) FeedArticles(ctx context.Context, profileID uint, limit, offset int) ([]models.Article, int64, error) {
+	db := s.db(ctx)
+	following := db.Model(&models.Follow{}).Select("following_id").Where("user_id = ?", profileID)
+	return s.listArticles(db.Model(&models.Article{}).Where("author_id IN (?)", following), limit, offset)
+}
+
+func (s GormStore) CreateArticle(ctx context.Context, article *models.Article) error {
+	return translate(s.db(ctx).Create(article).Error, "article")
+}
+
+func (s GormStore) UpdateArticle(ctx context.Context, article *models.Article) error {
+	result := s.db(ctx).Model(article).Where("updated_at = ?", article.UpdatedAt).
+		Select("*").Omit(clause.Associations).Updates(article)
+	if err := translate(result.Error, "article"); err != nil {
+		return err
+	}
+	if result.RowsAffected == 0 {
+		return Changed("article")
+	}
+	return nil
+}
+
+func (s GormStore) DeleteArticle(ctx context.Context, article *models.Article) error {
+	return s.db(ctx).Delete(article).Error
+}
+
+func (s GormStore) SetFavorite(ctx context.Context, profileID, articleID uint, favorite bool) (bool, error) {
+	db := s.db(ctx)
+	condition := models.Favorite{ArticleID: articleID, FavoritedByID: profileID}
+	if favorite {
+		var favoriteData models.Favorite
+		result := db.Where(condition).FirstOrCreate(&favoriteData)
+		return result.RowsAffected > 0, result.Error
+	}
+	result := db.Where(condition).Delete(&models.Favorite{})
+	return result.RowsAffected > 0, result.Error
+}
+
+func (s GormStore) ListTags(ctx context.Context) ([]models.Tag, error) {
+	var tags []models.Tag
+	err := s.db(ctx).Find(&tags).Error
+	return tags, err
+}
+
+func (s GormStore) TagsByName(ctx context.Context, names []string) ([]models.Tag, error) {
+	var tags []models.Tag
+	if len(names) == 0 {
+		return tags, nil
+	}
+	err := s.db(ctx).Where("name IN ?", names).Find(&tags).Error
+	return tags, err
+}
+
+func (s GormStore) UpdateTag(ctx context.Context, tag *models.Tag) error {
+	return translate(s.db(ctx).Save(tag).Error, "tag")
+}
+
+func (s GormStore) MergeTag(ctx context.Context, from, into models.Tag) error {
+	return database.Transaction(ctx, s.db(ctx), func(tx *gorm.DB) error {
+		// articles tagged with both keep their link to into
+		err := tx.Exec("DELETE FROM article_tags WHERE tag_id = ? AND article_id IN (SELECT article_id FROM article_tags WHERE tag_id = ?)", from.ID, into.ID).Error
+		if err != nil {
+			return err
+		}
+		if err := tx.Exec("UPDATE article_tags SET tag_id = ? WHERE tag_id = ?", into.ID, from.ID).Error; err != nil {
+			return err
+		}
+		return tx.Unscoped().Delete(&from).Error
+	})
+}
+
+func (s GormStore) CommentByID(ctx context.Context, id uint) (models.Comment, error) {
+	var comment models.Comment
+	err := s.db(ctx).Preload("Author").First(&comment, id).Error
+	return comment, translate(err, "comment")
+}
+
+func (s GormStore) CommentsByArticle(ctx context.Context, articleID uint) ([]models.Comment, error) {
+	var comments []models.Comment
+	err := s.db(ctx).Preload("Author").Order("id").Find(&comments, "article_id = ?", articleID).Error
+	return comments, err
+}
+
+func (s GormStore) CreateComment(ctx context.Context, comment *models.Comment) error {
+	return s.db(ctx).Omit(clause.Associations).Create(comment).Error
+}
+
+func (s GormStore) UpdateComment(ctx context.Context, comment *models.Comment) error {
+	return s.db(ctx).Omit(clause.Associations).Save(comment).Error
+}
+
+func (s GormStore) DeleteComment(ctx context.Context, comment *models.Comment) error {
+	return s.db(ctx).Delete(comment).Error
+}
+
+func (s GormStore) DeletedArticles(ctx context.Context, authorID uint) ([]models.Article, error) {
+	var articles []models.Article
+	err := s.db(ctx).Unscoped().Where("author_id = ? AND deleted_at IS NOT NULL", authorID).Order("deleted_at desc").Find(&articles).Error
+	return articles, err
+}
+
+func (s GormStore) DeletedArticleBySlug(ctx context.Context, slug string) (models.Article, error) {
+	var article models.Article
+	err := s.db(ctx).Unscoped().Preload(clause.Associations).Where("deleted_at IS NOT NULL").First(&article, "slug = ?", slug).Error
+	return article, translate(err, "article")
+}
+
+func (s GormStore) RestoreArticle(ctx context.Context, article *models.Article) error {
+	article.DeletedAt = gorm.DeletedAt{}
+	return s.db(ctx).Unscoped().Model(article).Update("deleted_at", nil).Error
+}
+
+// unscoped makes a preload include soft-deleted rows, for the article of a
+// trashed comment that may be in the trash itself.
+func unscoped(db *gorm.DB) *gorm.DB {
+	return db.Unscoped()
+}
+
+func (s GormStore) DeletedComments(ctx context.Context, authorID uint) ([]models.Comment, error) {
+	var comments []models.Comment
+	err := s.db(ctx).Unscoped().Preload("Article", unscoped).
+		Where("author_id = ? AND deleted_at IS NOT NULL", authorID).Order("deleted_at desc").Find(&comments).Error
+	return comments, err
+}
+
+func (s GormStore) DeletedCommentByID(ctx context.Context, id uint) (models.Comment, error) {
+	var comment models.Comment
+	err := s.db(ctx).Unscoped().Preload("Article", unscoped).Where("deleted_at IS NOT NULL").First(&comment, id).Error
+	return comment, translate(err, "comment")
+}
+
+func (s GormStore) RestoreComment(ctx context.Context, comment *models.Comment) error {
+	comment.DeletedAt = gorm.DeletedAt{}
+	return s.db(ctx).Unscoped().Model(comment).Update("deleted_at", nil).Error
+}
+
+// deleteSteps runs deletes in order and sums the rows they removed.
+func deleteSteps(steps ...func() *gorm.DB) (int64, error) {
+	var deleted int64
+	for _, step := range steps {
+		statement := step()
+		if statement.Error != nil {
+			return deleted, statement.Error
+		}
+		deleted += statement.RowsAffected
+	}
+	return deleted, nil
+}
+
+func (s GormStore) Purge(ctx context.Context, cutoff time.Time) (int64, error) {
+	var purged int64
+	err := database.Transaction(ctx, s.db(ctx), func(tx *gorm.DB) error {
+		// a session, so the subqueries and deletes below do not share a statement
+		tx = tx.Unscoped().Session(&gorm.Session{})
+		expiredArticles := tx.Model(&models.Article{}).Select("id").Where("deleted_at < ?", cutoff)
+		expiredWebhooks := tx.Model(&models.Webhook{}).Select("id").Where("deleted_at < ?", cutoff)
+		// dependents go before the rows they point to
+		var err error
+		purged, err = deleteSteps(
+			func() *gorm.DB {
+				return tx.Where("deleted_at < ? OR article_id IN (?)", cutoff, expiredArticles).Delete(&models.Comment{})
+			},
+			func() *gorm.DB {
+				return tx.Where("deleted_at < ? OR article_id IN (?)", cutoff, expiredArticles).Delete(&models.Favorite{})
+			},
+			func() *gorm.DB {
+				return tx.Exec("DELETE FROM article_tags WHERE article_id IN (?)", expiredArticles)
+			},
+			func() *gorm.DB { return tx.Where("deleted_at < ?", cutoff).Delete(&models.Article{}) },
+			func() *gorm.DB { return tx.Where("deleted_at < ?", cutoff).Delete(&models.Follow{}) },
+			func() *gorm.DB {
+				return tx.Where("deleted_at < ? OR webhook_id IN (?)", cutoff, expiredWebhooks).Delete(&models.WebhookDelivery{})
+			},
+			func() *gorm.DB { return tx.Where("deleted_at < ?", cutoff).Delete(&models.Webhook{}) },
+		)
+		return err
+	})
+	if err != nil {
+		return 0, err
+	}
+	return purged, nil
+}
+
+func (s GormStore) ArticlesByAuthor(ctx context.Context, authorID uint) ([]models.Article, error) {
+	var articles []models.Article
+	err := s.db(ctx).Preload("Tags").Order("id").Find(&articles, "author_id = ?", authorID).Error
+	return articles, err
+}
+
+func (s GormStore) CommentsByAuthor(ctx context.Context, authorID uint) ([]models.Comment, error) {
+	var comments []models.Comment
+	err := s.db(ctx).Preload("Article").Order("id").Find(&comments, "author_id = ?", authorID).Error
+	return comments, err
+}
+
+func (s GormStore) Following(ctx context.Context, profileID uint) ([]models.Profile, error) {
+	db := s.db(ctx)
+	var profiles []models.Profile
+	following := db.Model(&models.Follow{}).Select("following_id").Where("user_id = ?", profileID)
+	err := db.Where("id IN (?)", following).Order("name").Find(&profiles).Error
+	return profiles, err
+}
+
+func (s GormStore) FavoriteArticles(ctx context.Context, profileID uint) ([]models.Article, error) {
+	db := s.db(ctx)
+	var articles []models.Article
+	favorited := db.Model(&models.Favorite{}).Select("article_id").Where("favorited_by_id = ?", profileID)
+	err := db.Where("id IN (?)", favorited).Order("id").Find(&articles).Error
+	return articles, err
+}
+
+func (s GormStore) UploadsByOwner(ctx context.Context, ownerID uint) ([]models.Upload, error) {
+	var uploads []models.Upload
+	err := s.db(ctx).Unscoped().Find(&uploads, "owner_id = ?", ownerID).Error
+	return uploads, err
+}
+
+func (s GormStore) DeleteAccountData(ctx context.Context, user models.User) error {
+	tx := s.db(ctx).Unscoped().Session(&gorm.Session{})
+	profileID := user.ProfileID
+	owned := tx.Model(&models.Webhook{}).Select("id").Where("owner_id = ?", profileID)
+	_, err := deleteSteps(
+		func() *gorm.DB {
+			return tx.Where("user_id = ? OR following_id = ?", profileID, profileID).Delete(&models.Follow{})
+		},
+		func() *gorm.DB { return tx.Where("favorited_by_id = ?", profileID).Delete(&models.Favorite{}) },
+		func() *gorm.DB { return tx.Where("owner_id = ?", profileID).Delete(&models.Upload{}) },
+		func() *gorm.DB { return tx.Where("webhook_id IN (?)", owned).Delete(&models.WebhookDelivery{}) },
+		func() *gorm.DB { return tx.Where("owner_id = ?", profileID).Delete(&models.Webhook{}) },
+		func() *gorm.DB { return tx.Where("user_id = ?", user.ID).Delete(&models.FeedToken{}) },
+	)
+	return err
+}
+
+func (s GormStore) DeleteAuthored(ctx context.Context, authorID uint) error {
+	tx := s.db(ctx).Unscoped().Session(&gorm.Session{})
+	articles := tx.Model(&models.Article{}).Select("id").Where("author_id = ?", authorID)
+	_, err := deleteSteps(
+		func() *gorm.DB {
+			return tx.Where("author_id = ? OR article_id IN (?)", authorID, articles).Delete(&models.Comment{})
+		},
+		func() *gorm.DB { return tx.Where("article_id IN (?)", articles).Delete(&models.Favorite{}) },
+		func() *gorm.DB { return tx.Exec("DELETE FROM article_tags WHERE article_id IN (?)", articles) },
+		func() *gorm.DB { return tx.Where("author_id = ?", authorID).Delete(&models.Article{}) },
+	)
+	return err
+}
+
+func (s GormStore) DeleteUser(ctx context.Context, user models.User, deleteProfile bool) error {
+	db := s.db(ctx).Unscoped().Session(&gorm.Session{})
+	if err := db.Delete(&models.User{}, user.ID).Error; err != nil {
+		return err
+	}
+	if deleteProfile {
+		return db.Delete(&models.Profile{}, user.ProfileID).Error
+	}
+	return nil
+}
+
+func (s GormStore) WebhooksByOwner(ctx context.Context, ownerID uint) ([]models.Webhook, error) {
+	var hooks []models.Webhook
+	err := s.db(ctx).Where("owner_id = ?", ownerID).Order("id").Find(&hooks).Error
+	return hooks, err
+}
+
+func (s GormStore) WebhookByID(ctx context.Context, ownerID, id uint) (models.Webhook, error) {
+	var webhook models.Webhook
+	err := s.db(ctx).Where("owner_id = ?", ownerID).First(&webhook, id).Error
+	return webhook, translate(err, "webhook")
+}
+
+func (s GormStore) CreateWebhook(ctx context.Context, webhook *models.Webhook) error {
+	return s.db(ctx).Omit(clause.Associations).Create(webhook).Error
+}
+
+func (s GormStore) DeleteWebhook(ctx context.Context, webhook *models.Webhook) error {
+	return s.db(ctx).Delete(webhook).Error
+}
+
+func (s GormStore) WebhookDeliveries(ctx context.Context, webhookID uint, limit, offset int) ([]models.WebhookDelivery, int64, error) {
+	query := s.db(ctx).Model(&models.WebhookDelivery{}).Where("webhook_id = ?", webhookID)
+	var count int64
+	if err := query.Session(&gorm.Session{}).Count(&count).Error; err != nil {
+		return nil, 0, err
+	}
+	var deliveries []models.WebhookDelivery
+	err := query.Preload("Event").Order("id desc").Offset(offset).Limit(limit).Find(&deliveries).Error
+	return deliveries, count, err
+}
+
+func (s GormStore) WebhookDeliveryByID(ctx context.Context, webhookID, id uint) (models.WebhookDelivery, error) {
+	var delivery models.WebhookDelivery
+	err := s.db(ctx).Preload("Event").Where("webhook_id = ?", webhookID).First(&delivery, id).Error
+	return delivery, translate(err, "delivery")
+}
+
+func (s GormStore) CreateWebhookDelivery(ctx context.Context, delivery *models.WebhookDelivery) error {
+	return s.db(ctx).Omit(clause.Associations).Create(delivery).Error
+}
+
+func (s GormStore) Enqueue(ctx context.Context, eventType string, profileID uint, data map[string]interface{}) error {
+	db := s.db(ctx)
+	payload := map[string]interface{}{}
+	for key, value := range data {
+		switch value := value.(type) {
+		case models.Article:
+			serializer := models.ArticleSerializer{Article: value}
+			payload[key] = serializer.Response(db, anonymousRequest)
+		case models.Comment:
+			serializer := models.CommentSerializer{Comment: value}
+			payload[key] = serializer.Response(db, anonymousRequest)
+		case models.Profile:
+			serializer := models.ProfileSerializer{Profile: value}
+			payload[key] = serializer.Response(db, anonymousRequest)
+		default:
+			payload[key] = value
+		}
+	}
+	return webhooks.Enqueue(db, eventType, profileID, payload)
+}
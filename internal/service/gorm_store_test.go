@@ -0,0 +1,253 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/hy00nc/conduit-go/internal/database"
+	"github.com/hy00nc/conduit-go/internal/models"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// failingStore makes one Store method fail, also inside transactions.
+type failingStore struct {
+	Store
+	method string
+	err    error
+}
+
+func (s failingStore) Transaction(ctx context.Context, fn func(Store) error) error {
+	return s.Store.Transaction(ctx, func(tx Store) error {
+		return fn(failingStore{Store: tx, method: s.method, err: s.err})
+	})
+}
+
+func (s failingStore) Enqueue(ctx context.Context, eventType string, profileID uint, data map[string]interface{}) error {
+	if s.method == "Enqueue" {
+		return s.err
+	}
+	return s.Store.Enqueue(ctx, eventType, profileID, data)
+}
+
+func (s failingStore) UpdateUser(ctx context.Context, user *models.User) error {
+	if s.method == "UpdateUser" {
+		return s.err
+	}
+	return s.Store.UpdateUser(ctx, user)
+}
+
+func openGormStore(t *testing.T) GormStore {
+	db, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "service.db")), &gorm.Config{
+		Logger: logger.Default.LogMode(logger.Silent),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	database.MigrateDB(db)
+	t.Cleanup(func() { database.CloseDB(db) })
+	return GormStore{DB: db}
+}
+
+func TestGormStoreRollsBackCreateArticle(t *testing.T) {
+	asserts := assert.New(t)
+	ctx := context.Background()
+	store := openGormStore(t)
+	user, err := NewUserService(store, nil).Register(ctx, RegisterInput{Username: "sally", Email: "sally@example.com", Password: "secret"})
+	asserts.NoError(err)
+
+	// the outbox write comes after the tags and the article
+	failing := failingStore{Store: store, method: "Enqueue", err: errors.New("outbox unavailable")}
+	_, err = NewArticleService(failing, nil).Create(ctx, user, ArticleInput{Title: "t", Description: "d", Body: "b", TagList: []string{"go", "web"}})
+	asserts.Error(err)
+
+	var articles, tags int64
+	store.DB.Model(&models.Article{}).Count(&articles)
+	store.DB.Model(&models.Tag{}).Count(&tags)
+	asserts.Zero(articles)
+	asserts.Zero(tags)
+
+	article, err := NewArticleService(store, nil).Create(ctx, user, ArticleInput{Title: "t", Description: "d", Body: "b", TagList: []string{"go", "web"}})
+	asserts.NoError(err)
+	asserts.Len(article.Tags, 2)
+	var events int64
+	store.DB.Model(&models.OutboxEvent{}).Count(&events)
+	asserts.EqualValues(1, events)
+}
+
+func TestGormStoreRollsBackUpdateUser(t *testing.T) {
+	asserts := assert.New(t)
+	ctx := context.Background()
+	store := openGormStore(t)
+	user, err := NewUserService(store, nil).Register(ctx, RegisterInput{Username: "sally", Email: "sally@example.com", Password: "secret"})
+	asserts.NoError(err)
+
+	// the profile is written before the user
+	failing := failingStore{Store: store, method: "UpdateUser", err: errors.New("disk full")}
+	_, err = NewUserService(failing, nil).Update(ctx, user, UserUpdate{Username: "harry", Email: "harry@example.com"})
+	asserts.Error(err)
+
+	profile, err := store.ProfileByName(ctx, "sally")
+	asserts.NoError(err)
+	asserts.Equal(user.ProfileID, profile.ID)
+	_, err = store.UserByEmail(ctx, "sally@example.com")
+	asserts.NoError(err)
+}
+
+func TestGormStorePurge(t *testing.T) {
+	asserts := assert.New(t)
+	ctx := context.Background()
+	store := openGormStore(t)
+	user, err := NewUserService(store, nil).Register(ctx, RegisterInput{Username: "sally", Email: "sally@example.com", Password: "secret"})
+	asserts.NoError(err)
+	articles := NewArticleService(store, nil)
+	old, err := articles.Create(ctx, user, ArticleInput{Title: "old", Description: "d", Body: "b", TagList: []string{"go"}})
+	asserts.NoError(err)
+	recent, err := articles.Create(ctx, user, ArticleInput{Title: "recent", Description: "d", Body: "b"})
+	asserts.NoError(err)
+	_, err = NewCommentService(store, nil).Add(ctx, user, old.Slug, "first")
+	asserts.NoError(err)
+	_, err = articles.Favorite(ctx, user, old.Slug)
+	asserts.NoError(err)
+	asserts.NoError(articles.Delete(ctx, user, old.Slug))
+	asserts.NoError(articles.Delete(ctx, user, recent.Slug))
+	store.DB.Unscoped().Model(&models.Article{}).Where("id = ?", old.ID).Update("deleted_at", time.Now().Add(-48*time.Hour))
+
+	_, err = store.Purge(ctx, time.Now().Add(-24*time.Hour))
+	asserts.NoError(err)
+
+	var remaining []models.Article
+	store.DB.Unscoped().Find(&remaining)
+	asserts.Len(remaining, 1)
+	asserts.Equal(recent.ID, remaining[0].ID)
+	var comments, favorites, tagged int64
+	store.DB.Unscoped().Model(&models.Comment{}).Count(&comments)
+	store.DB.Unscoped().Model(&models.Favorite{}).Count(&favorites)
+	store.DB.Table("article_tags").Count(&tagged)
+	asserts.Zero(comments)
+	asserts.Zero(favorites)
+	asserts.Zero(tagged)
+}
+
+func TestGormStoreDeleteAccount(t *testing.T) {
+	asserts := assert.New(t)
+	ctx := context.Background()
+	store := openGormStore(t)
+	users := NewUserService(store, nil)
+	sally, err := users.Register(ctx, RegisterInput{Username: "sally", Email: "sally@example.com", Password: "secret"})
+	asserts.NoError(err)
+	harry, err := users.Register(ctx, RegisterInput{Username: "harry", Email: "harry@example.com", Password: "secret"})
+	asserts.NoError(err)
+	articles := NewArticleService(store, nil)
+	mine, err := articles.Create(ctx, sally, ArticleInput{Title: "mine", Description: "d", Body: "b", TagList: []string{"go"}})
+	asserts.NoError(err)
+	theirs, err := articles.Create(ctx, harry, ArticleInput{Title: "theirs", Description: "d", Body: "b"})
+	asserts.NoError(err)
+	comments := NewCommentService(store, nil)
+	for _, comment := range []struct {
+		author models.User
+		slug   string
+	}{{harry, mine.Slug}, {sally, theirs.Slug}, {harry, theirs.Slug}} {
+		_, err := comments.Add(ctx, comment.author, comment.slug, "hi")
+		asserts.NoError(err)
+	}
+	_, err = articles.Favorite(ctx, harry, mine.Slug)
+	asserts.NoError(err)
+	_, err = articles.Favorite(ctx, sally, theirs.Slug)
+	asserts.NoError(err)
+	_, err = NewProfileService(store).Follow(ctx, harry, "sally")
+	asserts.NoError(err)
+	asserts.NoError(store.DB.Create(&models.Webhook{OwnerID: sally.ProfileID, URL: "http://example.com"}).Error)
+	asserts.NoError(store.DB.Create(&models.FeedToken{UserID: sally.ID, Token: "t"}).Error)
+
+	data, err := NewAccountService(store, RemoveContent, nil).Export(ctx, sally)
+	asserts.NoError(err)
+	asserts.Len(data.Articles, 1)
+	asserts.Equal([]string{"go"}, []string{data.Articles[0].Tags[0].Name})
+	asserts.Len(data.Comments, 1)
+	asserts.Equal(theirs.Slug, data.Comments[0].Article.Slug)
+	asserts.Len(data.Favorites, 1)
+
+	asserts.NoError(NewAccountService(store, RemoveContent, nil).Delete(ctx, sally, "secret"))
+	count := func(model interface{}) int64 {
+		var n int64
+		store.DB.Unscoped().Model(model).Count(&n)
+		return n
+	}
+	asserts.EqualValues(1, count(&models.User{}))
+	asserts.EqualValues(1, count(&models.Profile{}))
+	asserts.EqualValues(1, count(&models.Article{}))
+	asserts.EqualValues(1, count(&models.Comment{}), "only harry's comment on his own article is left")
+	asserts.Zero(count(&models.Favorite{}))
+	asserts.Zero(count(&models.Follow{}))
+	asserts.Zero(count(&models.Webhook{}))
+	asserts.Zero(count(&models.FeedToken{}))
+	var tagged int64
+	store.DB.Table("article_tags").Count(&tagged)
+	asserts.Zero(tagged)
+}
+
+func TestGormStoreMergeTag(t *testing.T) {
+	asserts := assert.New(t)
+	ctx := context.Background()
+	store := openGormStore(t)
+	sally, err := NewAdminService(store).CreateUser(ctx, RegisterInput{Username: "sally", Email: "sally@example.com", Password: "secret"})
+	asserts.NoError(err)
+	user, err := store.UserByName(ctx, "sally")
+	asserts.NoError(err)
+	asserts.Equal(sally.ID, user.ID)
+	asserts.Equal(models.RoleUser, user.Role)
+
+	articles := NewArticleService(store, nil)
+	both, err := articles.Create(ctx, sally, ArticleInput{Title: "both", Description: "d", Body: "b", TagList: []string{"go", "golang"}})
+	asserts.NoError(err)
+	one, err := articles.Create(ctx, sally, ArticleInput{Title: "one", Description: "d", Body: "b", TagList: []string{"golang"}})
+	asserts.NoError(err)
+
+	asserts.NoError(NewAdminService(store).MergeTags(ctx, "go", "golang"))
+	for _, slug := range []string{both.Slug, one.Slug} {
+		article, err := store.ArticleBySlug(ctx, slug)
+		asserts.NoError(err)
+		asserts.Len(article.Tags, 1)
+		asserts.Equal("go", article.Tags[0].Name)
+	}
+	var tags int64
+	store.DB.Unscoped().Model(&models.Tag{}).Count(&tags)
+	asserts.EqualValues(1, tags)
+}
+
+func TestGormStoreUpdateArticleIsConditional(t *testing.T) {
+	asserts := assert.New(t)
+	ctx := context.Background()
+	store := openGormStore(t)
+	user, err := NewUserService(store, nil).Register(ctx, RegisterInput{Username: "sally", Email: "sally@example.com", Password: "secret"})
+	asserts.NoError(err)
+	created, err := NewArticleService(store, nil).Create(ctx, user, ArticleInput{Title: "t", Description: "d", Body: "b", TagList: []string{"go"}})
+	asserts.NoError(err)
+
+	// two writers load the article, the first one saves
+	first, err := store.ArticleBySlug(ctx, created.Slug)
+	asserts.NoError(err)
+	second, err := store.ArticleBySlug(ctx, created.Slug)
+	asserts.NoError(err)
+	first.Body = "first"
+	asserts.NoError(store.UpdateArticle(ctx, &first))
+	asserts.True(first.UpdatedAt.After(second.UpdatedAt))
+
+	second.Body = "second"
+	err = store.UpdateArticle(ctx, &second)
+	asserts.ErrorIs(err, ErrPrecondition, "the second writer loaded a stale article")
+	asserts.Equal("article", Field(err))
+	saved, err := store.ArticleBySlug(ctx, created.Slug)
+	asserts.NoError(err)
+	asserts.Equal("first", saved.Body)
+	asserts.Len(saved.Tags, 1, "associations are left alone")
+
+	saved.Body = "again"
+	asserts.NoError(store.UpdateArticle(ctx, &saved), "articles loaded after a write can be saved")
+}
@@ -0,0 +1,46 @@
+package service
+
+import (
+	"context"
+
+	"github.com/hy00nc/conduit-go/internal/models"
+	"github.com/hy00nc/conduit-go/internal/webhooks"
+)
+
+type ProfileService struct {
+	Store Store
+}
+
+func NewProfileService(store Store) *ProfileService {
+	return &ProfileService{Store: store}
+}
+
+func (s *ProfileService) Get(ctx context.Context, username string) (models.Profile, error) {
+	return s.Store.ProfileByName(ctx, username)
+}
+
+func (s *ProfileService) Follow(ctx context.Context, user models.User, username string) (models.Profile, error) {
+	return s.setFollow(ctx, user, username, true)
+}
+
+func (s *ProfileService) Unfollow(ctx context.Context, user models.User, username string) (models.Profile, error) {
+	return s.setFollow(ctx, user, username, false)
+}
+
+func (s *ProfileService) setFollow(ctx context.Context, user models.User, username string, follow bool) (models.Profile, error) {
+	target, err := s.Store.ProfileByName(ctx, username)
+	if err != nil {
+		return target, err
+	}
+	if target.ID == user.ProfileID {
+		return target, Invalid("username")
+	}
+	err = s.Store.Transaction(ctx, func(tx Store) error {
+		changed, err := tx.SetFollow(ctx, user.ProfileID, target.ID, follow)
+		if err != nil || !follow || !changed {
+			return err
+		}
+		return tx.Enqueue(ctx, webhooks.UserFollowed, target.ID, map[string]interface{}{"follower": user.Profile, "profile": target})
+	})
+	return target, err
+}
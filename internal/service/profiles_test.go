@@ -0,0 +1,54 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/hy00nc/conduit-go/internal/webhooks"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFollow(t *testing.T) {
+	asserts := assert.New(t)
+	ctx := context.Background()
+	store := newFakeStore()
+	profiles := NewProfileService(store)
+	sally := store.addUser("sally")
+	harry := store.addUser("harry")
+
+	profile, err := profiles.Follow(ctx, sally, "harry")
+	asserts.NoError(err)
+	asserts.Equal(harry.ProfileID, profile.ID)
+	asserts.True(store.follows[[2]uint{sally.ProfileID, harry.ProfileID}])
+	asserts.Equal([]string{webhooks.UserFollowed}, store.events)
+
+	// following again changes nothing and sends no second event
+	_, err = profiles.Follow(ctx, sally, "harry")
+	asserts.NoError(err)
+	asserts.Len(store.events, 1)
+
+	_, err = profiles.Unfollow(ctx, sally, "harry")
+	asserts.NoError(err)
+	asserts.Empty(store.follows)
+	asserts.Len(store.events, 1)
+
+	_, err = profiles.Follow(ctx, sally, "sally")
+	asserts.ErrorIs(err, ErrValidation)
+
+	_, err = profiles.Follow(ctx, sally, "nobody")
+	asserts.ErrorIs(err, ErrNotFound)
+	asserts.Equal("profile", Field(err))
+}
+
+func TestFollowRollsBack(t *testing.T) {
+	asserts := assert.New(t)
+	store := newFakeStore()
+	sally := store.addUser("sally")
+	store.addUser("harry")
+	store.fail["Enqueue"] = errors.New("outbox unavailable")
+
+	_, err := NewProfileService(store).Follow(context.Background(), sally, "harry")
+	asserts.Error(err)
+	asserts.Empty(store.follows)
+}
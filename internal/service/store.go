@@ -0,0 +1,144 @@
+package service
+
+import (
+	"context"
+	"time"
+
+	"github.com/hy00nc/conduit-go/internal/models"
+)
+
+// DefaultLimit is the page size used when a listing does not ask for one.
+const DefaultLimit = 20
+
+// ArticleFilter narrows ListArticles; empty fields do not filter.
+type ArticleFilter struct {
+	Tag       string
+	Author    string // author username
+	Favorited string // username of a user who favorited the article
+	Limit     int    // DefaultLimit when zero
+	Offset    int
+}
+
+// Store is the persistence the services are written against. Lookups of a
+// missing record return a NotFound error; writes violating a uniqueness
+// constraint return a Conflict error.
+type Store interface {
+	// Transaction runs fn with a Store whose writes commit together if fn
+	// returns nil and roll back otherwise.
+	Transaction(ctx context.Context, fn func(Store) error) error
+
+	UserByID(ctx context.Context, id uint) (models.User, error)
+	UserByEmail(ctx context.Context, email string) (models.User, error)
+	// UserByName loads the user whose profile is named name.
+	UserByName(ctx context.Context, name string) (models.User, error)
+	CreateUser(ctx context.Context, user *models.User) error
+	UpdateUser(ctx context.Context, user *models.User) error
+	UploadByID(ctx context.Context, ownerID, id uint) (models.Upload, error)
+
+	ProfileByName(ctx context.Context, name string) (models.Profile, error)
+	UpdateProfile(ctx context.Context, profile *models.Profile) error
+	// SetFollow makes profileID follow (or unfollow) targetID and reports
+	// whether anything changed.
+	SetFollow(ctx context.Context, profileID, targetID uint, follow bool) (bool, error)
+
+	// ArticleBySlug loads the article with its author and tags.
+	ArticleBySlug(ctx context.Context, slug string) (models.Article, error)
+	ListArticles(ctx context.Context, filter ArticleFilter) ([]models.Article, int64, error)
+	// FeedArticles lists articles by the profiles profileID follows.
+	FeedArticles(ctx context.Context, profileID uint, limit, offset int) ([]models.Article, int64, error)
+	CreateArticle(ctx context.Context, article *models.Article) error
+	// UpdateArticle saves article unless it was updated since it was
+	// loaded, as told by its UpdatedAt, and returns Changed then.
+	UpdateArticle(ctx context.Context, article *models.Article) error
+	DeleteArticle(ctx context.Context, article *models.Article) error
+	// SetFavorite makes profileID favorite (or unfavorite) articleID and
+	// reports whether anything changed.
+	SetFavorite(ctx context.Context, profileID, articleID uint, favorite bool) (bool, error)
+
+	ListTags(ctx context.Context) ([]models.Tag, error)
+	// TagsByName returns the existing tags among names.
+	TagsByName(ctx context.Context, names []string) ([]models.Tag, error)
+	UpdateTag(ctx context.Context, tag *models.Tag) error
+	// MergeTag moves the articles tagged from to into and removes from.
+	MergeTag(ctx context.Context, from, into models.Tag) error
+
+	CommentByID(ctx context.Context, id uint) (models.Comment, error)
+	CommentsByArticle(ctx context.Context, articleID uint) ([]models.Comment, error)
+	CreateComment(ctx context.Context, comment *models.Comment) error
+	UpdateComment(ctx context.Context, comment *models.Comment) error
+	DeleteComment(ctx context.Context, comment *models.Comment) error
+
+	// Soft-deleted articles and comments, with their article, stay in the
+	// trash until purged.
+	DeletedArticles(ctx context.Context, authorID uint) ([]models.Article, error)
+	DeletedArticleBySlug(ctx context.Context, slug string) (models.Article, error)
+	RestoreArticle(ctx context.Context, article *models.Article) error
+	DeletedComments(ctx context.Context, authorID uint) ([]models.Comment, error)
+	DeletedCommentByID(ctx context.Context, id uint) (models.Comment, error)
+	RestoreComment(ctx context.Context, comment *models.Comment) error
+	// Purge permanently removes what was soft-deleted before cutoff, along
+	// with the comments, favorites and tag links of purged articles, and
+	// reports the number of rows removed.
+	Purge(ctx context.Context, cutoff time.Time) (int64, error)
+
+	// What a user has, for account exports and deletion.
+	ArticlesByAuthor(ctx context.Context, authorID uint) ([]models.Article, error)
+	// CommentsByAuthor loads the comments with their article.
+	CommentsByAuthor(ctx context.Context, authorID uint) ([]models.Comment, error)
+	Following(ctx context.Context, profileID uint) ([]models.Profile, error)
+	FavoriteArticles(ctx context.Context, profileID uint) ([]models.Article, error)
+	UploadsByOwner(ctx context.Context, ownerID uint) ([]models.Upload, error)
+	// DeleteAccountData permanently removes the follows from and of the
+	// user, its favorites, upload records, webhooks and feed token.
+	DeleteAccountData(ctx context.Context, user models.User) error
+	// DeleteAuthored permanently removes the articles and comments by
+	// authorID, trashed ones included, along with the comments, favorites
+	// and tag links of the articles.
+	DeleteAuthored(ctx context.Context, authorID uint) error
+	// DeleteUser permanently removes the user and, if deleteProfile, its
+	// profile.
+	DeleteUser(ctx context.Context, user models.User, deleteProfile bool) error
+
+	// Webhooks are looked up among those of their owner, so that the
+	// webhooks of others are not found.
+	WebhooksByOwner(ctx context.Context, ownerID uint) ([]models.Webhook, error)
+	WebhookByID(ctx context.Context, ownerID, id uint) (models.Webhook, error)
+	CreateWebhook(ctx context.Context, webhook *models.Webhook) error
+	DeleteWebhook(ctx context.Context, webhook *models.Webhook) error
+	// WebhookDeliveries lists the deliveries of webhookID with their
+	// event, newest first, and counts them all.
+	WebhookDeliveries(ctx context.Context, webhookID uint, limit, offset int) ([]models.WebhookDelivery, int64, error)
+	WebhookDeliveryByID(ctx context.Context, webhookID, id uint) (models.WebhookDelivery, error)
+	CreateWebhookDelivery(ctx context.Context, delivery *models.WebhookDelivery) error
+
+	// Enqueue records a webhook event about the profile profileID in the
+	// outbox. Article, comment and profile values in data are stored in
+	// their API representation.
+	Enqueue(ctx context.Context, eventType string, profileID uint, data map[string]interface{}) error
+}
+
+// Events passed to Notifier.CommentChanged.
+const (
+	CommentCreated = "comment.created"
+	CommentUpdated = "comment.updated"
+	CommentDeleted = "comment.deleted"
+)
+
+// Notifier is told about committed changes, e.g. to push them to connected
+// clients. It must not block.
+type Notifier interface {
+	ArticleCreated(article models.Article)
+	CommentChanged(event string, comment models.Comment)
+}
+
+type nopNotifier struct{}
+
+func (nopNotifier) ArticleCreated(models.Article)         {}
+func (nopNotifier) CommentChanged(string, models.Comment) {}
+
+func notifierOrNop(notifier Notifier) Notifier {
+	if notifier == nil {
+		return nopNotifier{}
+	}
+	return notifier
+}
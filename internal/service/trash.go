@@ -0,0 +1,104 @@
+package service
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/hy00nc/conduit-go/internal/models"
+)
+
+// DefaultRetention is how long deleted articles and comments stay in the
+// trash before they are purged.
+const DefaultRetention = 30 * 24 * time.Hour
+
+// Trash is what a user deleted and may still restore.
+type Trash struct {
+	Articles []models.Article
+	Comments []models.Comment
+}
+
+type TrashService struct {
+	Store     Store
+	Notifier  Notifier
+	Retention time.Duration
+}
+
+func NewTrashService(store Store, notifier Notifier, retention time.Duration) *TrashService {
+	return &TrashService{Store: store, Notifier: notifierOrNop(notifier), Retention: retention}
+}
+
+// PurgeAt is when something deleted at deletedAt is purged.
+func (s *TrashService) PurgeAt(deletedAt time.Time) time.Time {
+	return deletedAt.Add(s.Retention)
+}
+
+func (s *TrashService) List(ctx context.Context, user models.User) (Trash, error) {
+	articles, err := s.Store.DeletedArticles(ctx, user.ProfileID)
+	if err != nil {
+		return Trash{}, err
+	}
+	comments, err := s.Store.DeletedComments(ctx, user.ProfileID)
+	if err != nil {
+		return Trash{}, err
+	}
+	return Trash{Articles: articles, Comments: comments}, nil
+}
+
+func (s *TrashService) RestoreArticle(ctx context.Context, user models.User, slug string) (models.Article, error) {
+	article, err := s.Store.DeletedArticleBySlug(ctx, slug)
+	if err != nil {
+		return article, err
+	}
+	if article.AuthorID != user.ProfileID {
+		return models.Article{}, Forbidden("article")
+	}
+	if err := s.Store.RestoreArticle(ctx, &article); err != nil {
+		return models.Article{}, err
+	}
+	return article, nil
+}
+
+// RestoreComment restores a comment whose article has not been deleted.
+func (s *TrashService) RestoreComment(ctx context.Context, user models.User, id uint) (models.Comment, error) {
+	comment, err := s.Store.DeletedCommentByID(ctx, id)
+	if err != nil {
+		return comment, err
+	}
+	if comment.AuthorID != user.ProfileID {
+		return models.Comment{}, Forbidden("comment")
+	}
+	if comment.Article.DeletedAt.Valid {
+		return models.Comment{}, NotFound("article")
+	}
+	if err := s.Store.RestoreComment(ctx, &comment); err != nil {
+		return models.Comment{}, err
+	}
+	comment.Author = user.Profile
+	s.Notifier.CommentChanged(CommentCreated, comment)
+	return comment, nil
+}
+
+// Purge permanently removes what has been in the trash for longer than the
+// retention period.
+func (s *TrashService) Purge(ctx context.Context, now time.Time) (int64, error) {
+	return s.Store.Purge(ctx, now.Add(-s.Retention))
+}
+
+// RunPurge purges every interval until the context is cancelled.
+func (s *TrashService) RunPurge(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		if purged, err := s.Purge(ctx, time.Now()); err != nil {
+			slog.Error("trash: purge failed", "error", err)
+		} else if purged > 0 {
+			slog.Info("trash: purged", "rows", purged)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,77 @@
+package service
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTrash(t *testing.T) {
+	asserts := assert.New(t)
+	ctx := context.Background()
+	store := newFakeStore()
+	notifier := &recordingNotifier{}
+	articles := NewArticleService(store, nil)
+	comments := NewCommentService(store, nil)
+	trash := NewTrashService(store, notifier, time.Hour)
+	sally := store.addUser("sally")
+	harry := store.addUser("harry")
+	store.addArticle(sally, "a")
+	comment, _ := comments.Add(ctx, harry, "a", "first")
+
+	asserts.NoError(comments.Delete(ctx, harry, comment.ID))
+	asserts.NoError(articles.Delete(ctx, sally, "a"))
+	sallys, err := trash.List(ctx, sally)
+	asserts.NoError(err)
+	asserts.Len(sallys.Articles, 1)
+	asserts.Empty(sallys.Comments)
+	harrys, err := trash.List(ctx, harry)
+	asserts.NoError(err)
+	asserts.Len(harrys.Comments, 1)
+	asserts.Equal("a", harrys.Comments[0].Article.Slug)
+
+	// the comment can only come back with its article
+	_, err = trash.RestoreComment(ctx, harry, comment.ID)
+	asserts.ErrorIs(err, ErrNotFound)
+	asserts.Equal("article", Field(err))
+
+	_, err = trash.RestoreArticle(ctx, harry, "a")
+	asserts.ErrorIs(err, ErrForbidden)
+	article, err := trash.RestoreArticle(ctx, sally, "a")
+	asserts.NoError(err)
+	asserts.False(article.DeletedAt.Valid)
+	_, err = articles.Get(ctx, "a")
+	asserts.NoError(err)
+
+	_, err = trash.RestoreComment(ctx, sally, comment.ID)
+	asserts.ErrorIs(err, ErrForbidden)
+	restored, err := trash.RestoreComment(ctx, harry, comment.ID)
+	asserts.NoError(err)
+	asserts.Equal("harry", restored.Author.Name)
+	asserts.Equal([]string{CommentCreated}, notifier.comments)
+
+	_, err = trash.RestoreArticle(ctx, sally, "a")
+	asserts.ErrorIs(err, ErrNotFound)
+}
+
+func TestPurgeAfterRetention(t *testing.T) {
+	asserts := assert.New(t)
+	ctx := context.Background()
+	store := newFakeStore()
+	trash := NewTrashService(store, nil, time.Hour)
+	sally := store.addUser("sally")
+	store.addArticle(sally, "a")
+	asserts.NoError(NewArticleService(store, nil).Delete(ctx, sally, "a"))
+	deletedAt := store.trashed[store.nextID].DeletedAt.Time
+	asserts.Equal(deletedAt.Add(time.Hour), trash.PurgeAt(deletedAt))
+
+	purged, err := trash.Purge(ctx, time.Now())
+	asserts.NoError(err)
+	asserts.Zero(purged)
+	purged, err = trash.Purge(ctx, time.Now().Add(2*time.Hour))
+	asserts.NoError(err)
+	asserts.EqualValues(1, purged)
+	asserts.Empty(store.trashed)
+}
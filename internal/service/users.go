@@ -0,0 +1,244 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"strings"
+
+	"github.com/hy00nc/conduit-go/internal/cache"
+	"github.com/hy00nc/conduit-go/internal/logging"
+	"github.com/hy00nc/conduit-go/internal/metrics"
+	"github.com/hy00nc/conduit-go/internal/models"
+	"github.com/hy00nc/conduit-go/internal/ratelimit"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// DefaultImage is the profile image of users who have not set one.
+const DefaultImage = "https://static.productionready.io/images/smiley-cyrus.jpg"
+
+type RegisterInput struct {
+	Username string
+	Email    string
+	Password string
+	Role     string // models.RoleUser when empty
+}
+
+// UserUpdate changes the non-empty fields. ImageID, an upload of the user,
+// takes precedence over Image.
+type UserUpdate struct {
+	Email    string
+	Username string
+	Password string
+	Bio      string
+	Image    string
+	ImageID  uint
+}
+
+type UserService struct {
+	Store Store
+	// ImageURL is the public URL of an uploaded profile image.
+	ImageURL func(upload models.Upload) string
+	// Lockout, if set, locks out emails after repeated failed logins.
+	Lockout *ratelimit.Lockout
+	// Cache, if set, has the cached user and responses of its articles
+	// invalidated on updates.
+	Cache cache.Cache
+}
+
+func NewUserService(store Store, imageURL func(models.Upload) string) *UserService {
+	return &UserService{Store: store, ImageURL: imageURL}
+}
+
+// maxPasswordBytes is the length of the longest password bcrypt hashes;
+// it refuses longer ones rather than truncating them.
+const maxPasswordBytes = 72
+
+func hashPassword(password string) (string, error) {
+	if len(password) > maxPasswordBytes {
+		return "", Invalid("password")
+	}
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	return string(hash), err
+}
+
+// checkAvailable returns a Conflict error if email or username is taken.
+func (s *UserService) checkAvailable(ctx context.Context, email, username string) error {
+	if email != "" {
+		if _, err := s.Store.UserByEmail(ctx, email); err == nil {
+			return Conflict("email")
+		} else if !errors.Is(err, ErrNotFound) {
+			return err
+		}
+	}
+	if username != "" {
+		if _, err := s.Store.ProfileByName(ctx, username); err == nil {
+			return Conflict("username")
+		} else if !errors.Is(err, ErrNotFound) {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *UserService) Register(ctx context.Context, input RegisterInput) (models.User, error) {
+	switch {
+	case input.Username == "":
+		return models.User{}, Invalid("username")
+	case input.Email == "":
+		return models.User{}, Invalid("email")
+	case input.Password == "":
+		return models.User{}, Invalid("password")
+	}
+	if err := s.checkAvailable(ctx, input.Email, input.Username); err != nil {
+		return models.User{}, err
+	}
+	role := input.Role
+	if role == "" {
+		role = models.RoleUser
+	} else if !validRole(role) {
+		return models.User{}, Invalid("role")
+	}
+	hash, err := hashPassword(input.Password)
+	if err != nil {
+		return models.User{}, err
+	}
+	user := models.User{
+		Email: input.Email,
+		Profile: models.Profile{
+			Name:  input.Username,
+			Image: DefaultImage,
+		},
+		Hash: hash,
+		Role: role,
+	}
+	if err := s.Store.CreateUser(ctx, &user); err != nil {
+		return models.User{}, err
+	}
+	metrics.Signups.Inc()
+	return user, nil
+}
+
+func (s *UserService) Login(ctx context.Context, email, password string) (models.User, error) {
+	switch {
+	case email == "":
+		return models.User{}, Invalid("email")
+	case password == "":
+		return models.User{}, Invalid("password")
+	}
+	// unknown emails are locked out too, so that lockouts do not tell
+	// which accounts exist
+	key := "login:" + strings.ToLower(email)
+	if s.Lockout != nil {
+		locked, err := s.Lockout.Locked(ctx, key)
+		if err != nil {
+			return models.User{}, err
+		}
+		if locked > 0 {
+			metrics.LoginsFailed.WithLabelValues("locked").Inc()
+			return models.User{}, Limited("email", locked)
+		}
+	}
+	user, err := s.Store.UserByEmail(ctx, email)
+	if err != nil && !errors.Is(err, ErrNotFound) {
+		return models.User{}, err
+	}
+	if err != nil || user.CheckPassword(password) != nil {
+		metrics.LoginsFailed.WithLabelValues("credentials").Inc()
+		if s.Lockout != nil {
+			if _, err := s.Lockout.Fail(ctx, key); err != nil {
+				return models.User{}, err
+			}
+		}
+		return models.User{}, ErrCredentials
+	}
+	if s.Lockout != nil {
+		if err := s.Lockout.Succeed(ctx, key); err != nil {
+			return models.User{}, err
+		}
+	}
+	if user.Suspended() {
+		metrics.LoginsFailed.WithLabelValues("suspended").Inc()
+		return models.User{}, ErrSuspended
+	}
+	return user, nil
+}
+
+// Update changes the account of user. It is reloaded first, as the user of
+// a request comes from a cache and without its password hash.
+func (s *UserService) Update(ctx context.Context, user models.User, input UserUpdate) (models.User, error) {
+	user, err := s.Store.UserByID(ctx, user.ID)
+	if err != nil {
+		return user, err
+	}
+	image := input.Image
+	if input.ImageID != 0 {
+		upload, err := s.Store.UploadByID(ctx, user.ProfileID, input.ImageID)
+		if errors.Is(err, ErrNotFound) {
+			return user, Invalid("imageId")
+		}
+		if err != nil {
+			return user, err
+		}
+		image = s.ImageURL(upload)
+	}
+
+	email, username := "", ""
+	if input.Email != "" && input.Email != user.Email {
+		email = input.Email
+	}
+	if input.Username != "" && input.Username != user.Profile.Name {
+		username = input.Username
+	}
+	if err := s.checkAvailable(ctx, email, username); err != nil {
+		return user, err
+	}
+
+	if input.Email != "" {
+		user.Email = input.Email
+	}
+	if input.Password != "" {
+		hash, err := hashPassword(input.Password)
+		if err != nil {
+			return user, err
+		}
+		user.Hash = hash
+	}
+	if input.Username != "" {
+		user.Profile.Name = input.Username
+	}
+	if input.Bio != "" {
+		user.Profile.Bio = input.Bio
+	}
+	if image != "" {
+		user.Profile.Image = image
+	}
+	err = s.Store.Transaction(ctx, func(tx Store) error {
+		if err := tx.UpdateProfile(ctx, &user.Profile); err != nil {
+			return err
+		}
+		return tx.UpdateUser(ctx, &user)
+	})
+	if err != nil {
+		return models.User{}, err
+	}
+	cache.Invalidate(ctx, s.Cache, userKeys(ctx, s.Cache, s.Store, user)...)
+	return user, nil
+}
+
+// userKeys are the keys of what c caches about user: the user itself, and
+// the responses of its articles, which show its profile. Failing to list
+// the articles is logged, as their responses expire anyway.
+func userKeys(ctx context.Context, c cache.Cache, store Store, user models.User) []string {
+	if c == nil {
+		return nil
+	}
+	keys := []string{cache.UserKey(user.ID)}
+	articles, err := store.ArticlesByAuthor(ctx, user.ProfileID)
+	if err != nil {
+		logging.FromContext(ctx).Error("cache: listing articles failed", "error", err)
+	}
+	for _, article := range articles {
+		keys = append(keys, cache.ArticleKeys(article.Slug)...)
+	}
+	return keys
+}
@@ -0,0 +1,181 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hy00nc/conduit-go/internal/cache"
+	"github.com/hy00nc/conduit-go/internal/metrics"
+	"github.com/hy00nc/conduit-go/internal/models"
+	"github.com/hy00nc/conduit-go/internal/ratelimit"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/gorm"
+)
+
+func TestRegister(t *testing.T) {
+	asserts := assert.New(t)
+	ctx := context.Background()
+	store := newFakeStore()
+	users := NewUserService(store, nil)
+	signups := testutil.ToFloat64(metrics.Signups)
+
+	user, err := users.Register(ctx, RegisterInput{Username: "sally", Email: "sally@example.com", Password: "secret"})
+	asserts.NoError(err)
+	asserts.NotZero(user.ID)
+	asserts.Equal(DefaultImage, user.Profile.Image)
+	asserts.NoError(user.CheckPassword("secret"))
+	asserts.Equal(signups+1, testutil.ToFloat64(metrics.Signups))
+
+	_, err = users.Register(ctx, RegisterInput{Username: "sally", Email: "other@example.com", Password: "secret"})
+	asserts.ErrorIs(err, ErrConflict)
+	asserts.Equal("username", Field(err))
+
+	_, err = users.Register(ctx, RegisterInput{Username: "harry", Email: "sally@example.com", Password: "secret"})
+	asserts.ErrorIs(err, ErrConflict)
+	asserts.Equal("email", Field(err))
+
+	_, err = users.Register(ctx, RegisterInput{Username: "harry", Email: "harry@example.com"})
+	asserts.ErrorIs(err, ErrValidation)
+	asserts.Equal("password", Field(err))
+	asserts.Equal(signups+1, testutil.ToFloat64(metrics.Signups), "failed signups are not counted")
+}
+
+func TestPasswordBytes(t *testing.T) {
+	asserts := assert.New(t)
+	ctx := context.Background()
+	store := newFakeStore()
+	users := NewUserService(store, nil)
+	admin := NewAdminService(store)
+	long := strings.Repeat("é", 40) // 40 characters, 80 bytes
+	fits := strings.Repeat("é", 36)
+
+	_, err := users.Register(ctx, RegisterInput{Username: "sally", Email: "sally@example.com", Password: long})
+	asserts.ErrorIs(err, ErrValidation, "bcrypt refuses passwords over 72 bytes")
+	asserts.Equal("password", Field(err))
+	user, err := users.Register(ctx, RegisterInput{Username: "sally", Email: "sally@example.com", Password: fits})
+	asserts.NoError(err)
+	asserts.NoError(user.CheckPassword(fits))
+
+	_, err = users.Update(ctx, user, UserUpdate{Password: long})
+	asserts.ErrorIs(err, ErrValidation)
+	asserts.Equal("password", Field(err))
+	_, err = admin.ResetPassword(ctx, "sally", long)
+	asserts.ErrorIs(err, ErrValidation)
+	asserts.Equal("password", Field(err))
+}
+
+func TestLogin(t *testing.T) {
+	asserts := assert.New(t)
+	ctx := context.Background()
+	users := NewUserService(newFakeStore(), nil)
+	_, err := users.Register(ctx, RegisterInput{Username: "sally", Email: "sally@example.com", Password: "secret"})
+	asserts.NoError(err)
+
+	failed := testutil.ToFloat64(metrics.LoginsFailed.WithLabelValues("credentials"))
+	user, err := users.Login(ctx, "sally@example.com", "secret")
+	asserts.NoError(err)
+	asserts.Equal("sally", user.Profile.Name)
+
+	_, err = users.Login(ctx, "sally@example.com", "wrong")
+	asserts.Equal(ErrCredentials, err)
+	_, err = users.Login(ctx, "nobody@example.com", "secret")
+	asserts.Equal(ErrCredentials, err)
+	asserts.ErrorIs(err, ErrForbidden)
+	asserts.Equal(failed+2, testutil.ToFloat64(metrics.LoginsFailed.WithLabelValues("credentials")))
+
+	_, err = users.Login(ctx, "", "secret")
+	asserts.ErrorIs(err, ErrValidation)
+}
+
+func TestLoginLockout(t *testing.T) {
+	asserts := assert.New(t)
+	ctx := context.Background()
+	users := NewUserService(newFakeStore(), nil)
+	users.Lockout = ratelimit.NewLockout(ratelimit.NewMemoryStore())
+	users.Lockout.Threshold = 2
+	now := time.Unix(1700000000, 0)
+	users.Lockout.Now = func() time.Time { return now }
+	_, err := users.Register(ctx, RegisterInput{Username: "sally", Email: "sally@example.com", Password: "secret"})
+	asserts.NoError(err)
+
+	for i := 0; i < 2; i++ {
+		_, err = users.Login(ctx, "sally@example.com", "wrong")
+		asserts.Equal(ErrCredentials, err)
+	}
+	_, err = users.Login(ctx, "Sally@example.com", "secret")
+	asserts.ErrorIs(err, ErrLimited, "emails are locked out whatever their case")
+	asserts.Equal("email", Field(err))
+	asserts.Equal(time.Minute, RetryAfter(err))
+
+	for i := 0; i < 2; i++ {
+		users.Login(ctx, "nobody@example.com", "secret")
+	}
+	_, err = users.Login(ctx, "nobody@example.com", "secret")
+	asserts.ErrorIs(err, ErrLimited, "unknown emails are locked out alike")
+
+	now = now.Add(time.Minute)
+	_, err = users.Login(ctx, "sally@example.com", "secret")
+	asserts.NoError(err)
+	_, err = users.Login(ctx, "sally@example.com", "wrong")
+	asserts.Equal(ErrCredentials, err, "a successful login forgets the failures")
+}
+
+func TestUpdateUserInvalidatesCache(t *testing.T) {
+	asserts := assert.New(t)
+	ctx := context.Background()
+	store := newFakeStore()
+	users := NewUserService(store, nil)
+	users.Cache = cache.NewLRU(10)
+	sally := store.addUser("sally")
+	store.addArticle(sally, "a")
+	for _, key := range []string{cache.UserKey(sally.ID), cache.ArticleKey("a", false), cache.ArticleKey("b", false)} {
+		users.Cache.Set(ctx, key, []byte("{}"), time.Minute)
+	}
+
+	_, err := users.Update(ctx, sally, UserUpdate{Bio: "hi"})
+	asserts.NoError(err)
+	_, ok, _ := users.Cache.Get(ctx, cache.UserKey(sally.ID))
+	asserts.False(ok)
+	_, ok, _ = users.Cache.Get(ctx, cache.ArticleKey("a", false))
+	asserts.False(ok, "articles show the profile of their author")
+	_, ok, _ = users.Cache.Get(ctx, cache.ArticleKey("b", false))
+	asserts.True(ok)
+}
+
+func TestUpdateUser(t *testing.T) {
+	asserts := assert.New(t)
+	ctx := context.Background()
+	store := newFakeStore()
+	users := NewUserService(store, func(upload models.Upload) string { return "/media/" + upload.Key })
+	sally := store.addUser("sally")
+	store.addUser("harry")
+	store.uploads[99] = models.Upload{Model: gorm.Model{ID: 99}, OwnerID: sally.ProfileID, Key: "avatar"}
+
+	user, err := users.Update(ctx, sally, UserUpdate{Bio: "hi", Image: "ignored", ImageID: 99})
+	asserts.NoError(err)
+	asserts.Equal("hi", user.Profile.Bio)
+	asserts.Equal("/media/avatar", user.Profile.Image)
+	asserts.Equal("hi", store.profiles[sally.ProfileID].Bio)
+	sally = user
+
+	_, err = users.Update(ctx, sally, UserUpdate{ImageID: 100})
+	asserts.ErrorIs(err, ErrValidation)
+	asserts.Equal("imageId", Field(err))
+
+	_, err = users.Update(ctx, sally, UserUpdate{Username: "harry"})
+	asserts.ErrorIs(err, ErrConflict)
+
+	// keeping one's own username is not a conflict
+	_, err = users.Update(ctx, sally, UserUpdate{Username: "sally", Email: sally.Email})
+	asserts.NoError(err)
+
+	// the profile change rolls back with the failed user write
+	store.fail["UpdateUser"] = errors.New("disk full")
+	_, err = users.Update(ctx, sally, UserUpdate{Bio: "lost"})
+	asserts.Error(err)
+	asserts.Equal("hi", store.profiles[sally.ProfileID].Bio)
+}
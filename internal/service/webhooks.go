@@ -0,0 +1,118 @@
+package service
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/hy00nc/conduit-go/internal/logging"
+	"github.com/hy00nc/conduit-go/internal/models"
+	"github.com/hy00nc/conduit-go/internal/webhooks"
+)
+
+// MaxDeliveryLimit bounds the page size of delivery logs.
+const MaxDeliveryLimit = 100
+
+// WebhookInput registers a webhook; a secret is generated when empty.
+type WebhookInput struct {
+	URL      string
+	Secret   string
+	Events   []string
+	SiteWide bool
+}
+
+type WebhookService struct {
+	Store Store
+	// CheckURL refuses the endpoints the server must not call.
+	CheckURL func(ctx context.Context, url string) error
+}
+
+func NewWebhookService(store Store) *WebhookService {
+	return &WebhookService{Store: store, CheckURL: webhooks.CheckURL}
+}
+
+func (s *WebhookService) List(ctx context.Context, user models.User) ([]models.Webhook, error) {
+	return s.Store.WebhooksByOwner(ctx, user.ProfileID)
+}
+
+func (s *WebhookService) Create(ctx context.Context, user models.User, input WebhookInput) (models.Webhook, error) {
+	// the events of others would expose who follows and favorites whom
+	if input.SiteWide && !user.IsAdmin() {
+		return models.Webhook{}, Forbidden("siteWide")
+	}
+	// endpoints on private networks would let users reach what the server can
+	if err := s.CheckURL(ctx, input.URL); err != nil {
+		logging.FromContext(ctx).Debug("webhooks: endpoint refused", "error", err)
+		return models.Webhook{}, Invalid("url")
+	}
+	secret := input.Secret
+	if secret == "" {
+		var err error
+		if secret, err = webhooks.GenerateSecret(); err != nil {
+			return models.Webhook{}, err
+		}
+	}
+	webhook := models.Webhook{
+		OwnerID:  user.ProfileID,
+		URL:      input.URL,
+		Secret:   secret,
+		Events:   strings.Join(input.Events, ","),
+		Active:   true,
+		SiteWide: input.SiteWide,
+	}
+	if err := s.Store.CreateWebhook(ctx, &webhook); err != nil {
+		return models.Webhook{}, err
+	}
+	return webhook, nil
+}
+
+// Delete removes one of the user's webhooks; its pending deliveries fail
+// when they are next attempted.
+func (s *WebhookService) Delete(ctx context.Context, user models.User, id uint) error {
+	webhook, err := s.Store.WebhookByID(ctx, user.ProfileID, id)
+	if err != nil {
+		return err
+	}
+	return s.Store.DeleteWebhook(ctx, &webhook)
+}
+
+// Deliveries pages through the delivery log of one of the user's webhooks,
+// newest first, returning the total count too.
+func (s *WebhookService) Deliveries(ctx context.Context, user models.User, id uint, limit, offset int) ([]models.WebhookDelivery, int64, error) {
+	if limit < 0 || offset < 0 {
+		return nil, 0, Invalid("limit")
+	}
+	if limit == 0 {
+		limit = DefaultLimit
+	}
+	limit = min(limit, MaxDeliveryLimit)
+	webhook, err := s.Store.WebhookByID(ctx, user.ProfileID, id)
+	if err != nil {
+		return nil, 0, err
+	}
+	return s.Store.WebhookDeliveries(ctx, webhook.ID, limit, offset)
+}
+
+// Redeliver queues a fresh delivery of the same event; the original
+// delivery is kept in the log untouched.
+func (s *WebhookService) Redeliver(ctx context.Context, user models.User, id, deliveryID uint) (models.WebhookDelivery, error) {
+	webhook, err := s.Store.WebhookByID(ctx, user.ProfileID, id)
+	if err != nil {
+		return models.WebhookDelivery{}, err
+	}
+	original, err := s.Store.WebhookDeliveryByID(ctx, webhook.ID, deliveryID)
+	if err != nil {
+		return models.WebhookDelivery{}, err
+	}
+	delivery := models.WebhookDelivery{
+		WebhookID:     webhook.ID,
+		EventID:       original.EventID,
+		Status:        webhooks.StatusPending,
+		NextAttemptAt: time.Now(),
+	}
+	if err := s.Store.CreateWebhookDelivery(ctx, &delivery); err != nil {
+		return models.WebhookDelivery{}, err
+	}
+	delivery.Event = original.Event
+	return delivery, nil
+}
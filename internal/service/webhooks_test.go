@@ -0,0 +1,101 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/hy00nc/conduit-go/internal/models"
+	"github.com/hy00nc/conduit-go/internal/webhooks"
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestWebhookService(store Store) *WebhookService {
+	hooks := NewWebhookService(store)
+	hooks.CheckURL = func(ctx context.Context, url string) error {
+		if url == "http://10.0.0.1/" {
+			return webhooks.ErrPrivateAddress
+		}
+		return nil
+	}
+	return hooks
+}
+
+func TestWebhookCreate(t *testing.T) {
+	asserts := assert.New(t)
+	ctx := context.Background()
+	store := newFakeStore()
+	hooks := newTestWebhookService(store)
+	sally := store.addUser("sally")
+	admin := store.addUser("admin")
+	admin.Role = models.RoleAdmin
+	input := WebhookInput{URL: "https://example.com/", Events: []string{webhooks.UserFollowed}}
+
+	webhook, err := hooks.Create(ctx, sally, input)
+	asserts.NoError(err)
+	asserts.Equal(sally.ProfileID, webhook.OwnerID)
+	asserts.NotEmpty(webhook.Secret, "secrets are generated")
+	asserts.False(webhook.SiteWide)
+
+	input.SiteWide = true
+	_, err = hooks.Create(ctx, sally, input)
+	asserts.ErrorIs(err, ErrForbidden, "only admins may see the events of everyone")
+	webhook, err = hooks.Create(ctx, admin, input)
+	asserts.NoError(err)
+	asserts.True(webhook.SiteWide)
+
+	_, err = hooks.Create(ctx, sally, WebhookInput{URL: "http://10.0.0.1/", Events: []string{webhooks.UserFollowed}})
+	asserts.ErrorIs(err, ErrValidation)
+	asserts.Equal("url", Field(err))
+}
+
+func TestWebhookDelete(t *testing.T) {
+	asserts := assert.New(t)
+	ctx := context.Background()
+	store := newFakeStore()
+	hooks := newTestWebhookService(store)
+	sally := store.addUser("sally")
+	harry := store.addUser("harry")
+	webhook, _ := hooks.Create(ctx, sally, WebhookInput{URL: "https://example.com/", Events: []string{webhooks.UserFollowed}})
+
+	asserts.ErrorIs(hooks.Delete(ctx, harry, webhook.ID), ErrNotFound, "the webhooks of others are not found")
+	store.fail["DeleteWebhook"] = errors.New("disk full")
+	asserts.EqualError(hooks.Delete(ctx, sally, webhook.ID), "disk full")
+	delete(store.fail, "DeleteWebhook")
+	asserts.NoError(hooks.Delete(ctx, sally, webhook.ID))
+	asserts.ErrorIs(hooks.Delete(ctx, sally, webhook.ID), ErrNotFound)
+}
+
+func TestWebhookDeliveries(t *testing.T) {
+	asserts := assert.New(t)
+	ctx := context.Background()
+	store := newFakeStore()
+	hooks := newTestWebhookService(store)
+	sally := store.addUser("sally")
+	harry := store.addUser("harry")
+	webhook, _ := hooks.Create(ctx, sally, WebhookInput{URL: "https://example.com/", Events: []string{webhooks.UserFollowed}})
+	for i := 0; i < MaxDeliveryLimit+5; i++ {
+		store.CreateWebhookDelivery(ctx, &models.WebhookDelivery{WebhookID: webhook.ID, EventID: uint(i + 1), Status: webhooks.StatusSucceeded})
+	}
+
+	deliveries, count, err := hooks.Deliveries(ctx, sally, webhook.ID, 0, 0)
+	asserts.NoError(err)
+	asserts.Len(deliveries, DefaultLimit)
+	asserts.Equal(int64(MaxDeliveryLimit+5), count)
+	deliveries, _, err = hooks.Deliveries(ctx, sally, webhook.ID, 1<<30, 0)
+	asserts.NoError(err)
+	asserts.Len(deliveries, MaxDeliveryLimit, "pages are bounded")
+	_, _, err = hooks.Deliveries(ctx, sally, webhook.ID, -1, 0)
+	asserts.ErrorIs(err, ErrValidation)
+	_, _, err = hooks.Deliveries(ctx, sally, webhook.ID, 10, -1)
+	asserts.ErrorIs(err, ErrValidation)
+	_, _, err = hooks.Deliveries(ctx, harry, webhook.ID, 10, 0)
+	asserts.ErrorIs(err, ErrNotFound)
+
+	redelivered, err := hooks.Redeliver(ctx, sally, webhook.ID, deliveries[0].ID)
+	asserts.NoError(err)
+	asserts.Equal(deliveries[0].EventID, redelivered.EventID)
+	asserts.Equal(webhooks.StatusPending, redelivered.Status)
+	_, err = hooks.Redeliver(ctx, harry, webhook.ID, deliveries[0].ID)
+	asserts.ErrorIs(err, ErrNotFound)
+}
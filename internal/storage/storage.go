@@ -0,0 +1,50 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"io"
+	"os"
+	"path"
+	"strings"
+)
+
+var ErrNotFound = errors.New("object not found")
+var ErrInvalidKey = errors.New("invalid object key")
+
+// Storage persists uploaded media. Keys are slash separated relative paths.
+type Storage interface {
+	Put(ctx context.Context, key string, data []byte, contentType string) error
+	Get(ctx context.Context, key string) (io.ReadCloser, error)
+	Delete(ctx context.Context, key string) error
+	// URL returns where clients can fetch the object from.
+	URL(key string) string
+}
+
+// FromEnv builds the storage selected by CONDUIT_STORAGE ("local" or "s3").
+func FromEnv() Storage {
+	if os.Getenv("CONDUIT_STORAGE") == "s3" {
+		return &S3{
+			Endpoint:  os.Getenv("CONDUIT_S3_ENDPOINT"),
+			Region:    os.Getenv("CONDUIT_S3_REGION"),
+			Bucket:    os.Getenv("CONDUIT_S3_BUCKET"),
+			AccessKey: os.Getenv("CONDUIT_S3_ACCESS_KEY"),
+			SecretKey: os.Getenv("CONDUIT_S3_SECRET_KEY"),
+			PublicURL: os.Getenv("CONDUIT_S3_PUBLIC_URL"),
+		}
+	}
+	dir := os.Getenv("CONDUIT_UPLOAD_DIR")
+	if dir == "" {
+		dir = "uploads"
+	}
+	return NewLocal(dir, "/uploads")
+}
+
+// cleanKey rejects keys that would escape the storage root.
+func cleanKey(key string) (string, error) {
+	cleaned := path.Clean("/" + key)[1:]
+	if cleaned == "" || cleaned != key || strings.HasPrefix(cleaned, "..") {
+		return "", ErrInvalidKey
+	}
+	return cleaned, nil
+}
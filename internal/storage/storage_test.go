@@ -0,0 +1,84 @@
+package storage
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeS3 is a minimal in-memory stand-in for an S3 compatible server.
+type fakeS3 struct {
+	mu      sync.Mutex
+	objects map[string][]byte
+}
+
+func (f *fakeS3) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	auth := r.Header.Get("Authorization")
+	if !strings.HasPrefix(auth, "AWS4-HMAC-SHA256 Credential=access/") || r.Header.Get("X-Amz-Content-Sha256") == "" {
+		w.WriteHeader(http.StatusForbidden)
+		return
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	switch r.Method {
+	case http.MethodPut:
+		body, _ := io.ReadAll(r.Body)
+		if r.Header.Get("X-Amz-Content-Sha256") != sha256Hex(body) {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		f.objects[r.URL.Path] = body
+	case http.MethodGet:
+		body, ok := f.objects[r.URL.Path]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Write(body)
+	case http.MethodDelete:
+		delete(f.objects, r.URL.Path)
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+func testRoundTrip(t *testing.T, store Storage) {
+	asserts := assert.New(t)
+	ctx := context.Background()
+
+	asserts.NoError(store.Put(ctx, "a/b/original.png", []byte("image"), "image/png"))
+	object, err := store.Get(ctx, "a/b/original.png")
+	asserts.NoError(err)
+	data, _ := io.ReadAll(object)
+	object.Close()
+	asserts.Equal("image", string(data))
+
+	asserts.NoError(store.Delete(ctx, "a/b/original.png"))
+	_, err = store.Get(ctx, "a/b/original.png")
+	asserts.ErrorIs(err, ErrNotFound)
+
+	asserts.ErrorIs(store.Put(ctx, "../escape.png", []byte("x"), "image/png"), ErrInvalidKey)
+}
+
+func TestLocalStorage(t *testing.T) {
+	store := NewLocal(t.TempDir(), "/uploads")
+	testRoundTrip(t, store)
+	assert.Equal(t, "/uploads/a/b.png", store.URL("a/b.png"))
+}
+
+func TestS3Storage(t *testing.T) {
+	server := httptest.NewServer(&fakeS3{objects: map[string][]byte{}})
+	defer server.Close()
+
+	store := &S3{Endpoint: server.URL, Bucket: "media", AccessKey: "access", SecretKey: "secret"}
+	testRoundTrip(t, store)
+	assert.Equal(t, server.URL+"/media/a/b.png", store.URL("a/b.png"))
+
+	store.PublicURL = "https://cdn.example.com/"
+	assert.Equal(t, "https://cdn.example.com/a/b.png", store.URL("a/b.png"))
+}
@@ -0,0 +1,98 @@
+package tracing
+
+import (
+	"errors"
+	"strings"
+
+	"github.com/hy00nc/conduit-go/internal/database"
+	"github.com/hy00nc/conduit-go/internal/utils"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	semconv "go.opentelemetry.io/otel/semconv/v1.37.0"
+	"go.opentelemetry.io/otel/trace"
+	"gorm.io/gorm"
+)
+
+var tracer = otel.Tracer(utils.InstrumentationName)
+
+// InstrumentDB gives the statements run through db a span, a child of the
+// span in the statement's context; use db.WithContext to pass it.
+// Statements outside a trace, such as those of background jobs polling
+// the database, are left out.
+func InstrumentDB(db *gorm.DB) error {
+	return db.Use(gormPlugin{})
+}
+
+const spanKey = "tracing:span"
+
+// dbSystems maps the names of GORM dialectors to db.system.name values.
+var dbSystems = map[string]attribute.KeyValue{
+	"sqlite":    semconv.DBSystemNameSQLite,
+	"postgres":  semconv.DBSystemNamePostgreSQL,
+	"mysql":     semconv.DBSystemNameMySQL,
+	"sqlserver": semconv.DBSystemNameMicrosoftSQLServer,
+}
+
+func dbSystem(db *gorm.DB) attribute.KeyValue {
+	if system, ok := dbSystems[db.Dialector.Name()]; ok {
+		return system
+	}
+	return semconv.DBSystemNameOtherSQL
+}
+
+type gormPlugin struct{}
+
+func (gormPlugin) Name() string {
+	return "tracing"
+}
+
+func (gormPlugin) Initialize(db *gorm.DB) error {
+	return database.AroundStatements(db, "tracing", startSpan, endSpan)
+}
+
+func startSpan(db *gorm.DB) {
+	if !trace.SpanContextFromContext(db.Statement.Context).IsValid() {
+		return
+	}
+	_, span := tracer.Start(db.Statement.Context, "db", trace.WithSpanKind(trace.SpanKindClient))
+	db.InstanceSet(spanKey, span)
+}
+
+// endSpan returns the callback naming the span after the statement, which
+// is only known once it ran, and ending it.
+func endSpan(string) func(*gorm.DB) {
+	return func(db *gorm.DB) {
+		value, ok := db.InstanceGet(spanKey)
+		if !ok {
+			return
+		}
+		span := value.(trace.Span)
+		defer span.End()
+
+		// the SQL has placeholders, not the values
+		statement := db.Statement.SQL.String()
+		operation := ""
+		if fields := strings.Fields(statement); len(fields) > 0 {
+			operation = strings.ToUpper(fields[0])
+		}
+		name := strings.TrimSpace(operation + " " + db.Statement.Table)
+		if name == "" {
+			name = "db"
+		}
+		span.SetName(name)
+		span.SetAttributes(
+			dbSystem(db),
+			semconv.DBOperationName(operation),
+			semconv.DBQueryText(statement),
+			attribute.Int64("db.rows_affected", db.Statement.RowsAffected),
+		)
+		if db.Statement.Table != "" {
+			span.SetAttributes(semconv.DBCollectionName(db.Statement.Table))
+		}
+		if err := db.Error; err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+			span.RecordError(err)
+			span.SetStatus(codes.Error, err.Error())
+		}
+	}
+}
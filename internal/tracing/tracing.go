@@ -0,0 +1,66 @@
+// Package tracing sets up OpenTelemetry tracing: the exporter the spans go
+// to, W3C trace context propagation, and spans for database statements.
+//
+// The exporter is chosen with OTEL_TRACES_EXPORTER: "otlp" sends spans
+// over OTLP/HTTP, configured by the standard OTEL_EXPORTER_OTLP_*
+// variables; "stdout" (or "console") prints them, to check tracing
+// locally; "none", the default, records nothing but still passes trace
+// context on. OTEL_SERVICE_NAME and OTEL_TRACES_SAMPLER are honoured too.
+package tracing
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.37.0"
+)
+
+const serviceName = "conduit"
+
+// Setup installs the tracer provider and propagator. The returned function
+// flushes the spans not yet exported and stops the exporter.
+func Setup(ctx context.Context) (shutdown func(context.Context) error, err error) {
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
+	none := func(context.Context) error { return nil }
+
+	var option sdktrace.TracerProviderOption
+	switch exporter := strings.ToLower(os.Getenv("OTEL_TRACES_EXPORTER")); exporter {
+	case "", "none":
+		return none, nil
+	case "otlp":
+		client, err := otlptracehttp.New(ctx)
+		if err != nil {
+			return none, err
+		}
+		option = sdktrace.WithBatcher(client)
+	case "stdout", "console":
+		printer, err := stdouttrace.New(stdouttrace.WithWriter(os.Stdout), stdouttrace.WithPrettyPrint())
+		if err != nil {
+			return none, err
+		}
+		option = sdktrace.WithSyncer(printer)
+	default:
+		return none, fmt.Errorf("unknown OTEL_TRACES_EXPORTER %q", exporter)
+	}
+
+	// OTEL_SERVICE_NAME and OTEL_RESOURCE_ATTRIBUTES override the defaults
+	res, err := resource.New(ctx,
+		resource.WithAttributes(semconv.ServiceName(serviceName)),
+		resource.WithTelemetrySDK(),
+		resource.WithFromEnv(),
+	)
+	if err != nil {
+		return none, err
+	}
+	provider := sdktrace.NewTracerProvider(option, sdktrace.WithResource(res))
+	otel.SetTracerProvider(provider)
+	return provider.Shutdown, nil
+}
@@ -0,0 +1,85 @@
+package tracing
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+func TestSetup(t *testing.T) {
+	asserts := assert.New(t)
+	t.Setenv("OTEL_TRACES_EXPORTER", "none")
+	shutdown, err := Setup(context.Background())
+	asserts.NoError(err)
+	asserts.NoError(shutdown(context.Background()))
+
+	t.Setenv("OTEL_TRACES_EXPORTER", "zipkin")
+	_, err = Setup(context.Background())
+	asserts.ErrorContains(err, "zipkin")
+}
+
+type widget struct {
+	ID   uint
+	Name string `gorm:"unique"`
+}
+
+func TestInstrumentDB(t *testing.T) {
+	asserts := assert.New(t)
+	recorder := tracetest.NewSpanRecorder()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+
+	db, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "tracing.db")), &gorm.Config{
+		Logger: logger.Default.LogMode(logger.Silent),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	asserts.NoError(db.AutoMigrate(&widget{}))
+	asserts.NoError(InstrumentDB(db))
+
+	asserts.NoError(db.Create(&widget{Name: "untraced"}).Error)
+	asserts.Empty(recorder.Ended(), "statements outside a trace are left out")
+
+	ctx, parent := otel.Tracer("test").Start(context.Background(), "request")
+	tx := db.WithContext(ctx)
+	asserts.NoError(tx.Create(&widget{Name: "gear"}).Error)
+	asserts.Error(tx.Create(&widget{Name: "gear"}).Error)
+	var found widget
+	asserts.NoError(tx.Where("name = ?", "gear").First(&found).Error)
+	asserts.Error(tx.Where("name = ?", "cog").First(&found).Error)
+	parent.End()
+
+	spans := recorder.Ended()
+	if !asserts.Len(spans, 5) {
+		return
+	}
+	for _, span := range spans[:4] {
+		asserts.Equal(parent.SpanContext().SpanID(), span.Parent().SpanID(), span.Name())
+		asserts.Equal(trace.SpanKindClient, span.SpanKind())
+	}
+	asserts.Equal("INSERT widgets", spans[0].Name())
+	asserts.Equal(codes.Unset, spans[0].Status().Code)
+	asserts.Equal(codes.Error, spans[1].Status().Code, "failed statements are errors")
+	asserts.Equal("SELECT widgets", spans[2].Name())
+	asserts.Equal(codes.Unset, spans[3].Status().Code, "not finding a row is no error")
+
+	attributes := map[string]string{}
+	for _, attribute := range spans[2].Attributes() {
+		attributes[string(attribute.Key)] = attribute.Value.Emit()
+	}
+	asserts.Equal("sqlite", attributes["db.system.name"])
+	asserts.Equal("SELECT", attributes["db.operation.name"])
+	asserts.Equal("widgets", attributes["db.collection.name"])
+	asserts.Contains(attributes["db.query.text"], "name = ?", "values are left out")
+	asserts.NotContains(attributes["db.query.text"], "gear")
+}
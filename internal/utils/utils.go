@@ -1,7 +1,7 @@
 package utils
 
 import (
-	"log"
+	"log/slog"
 	"time"
 
 	"github.com/golang-jwt/jwt/v5"
@@ -9,6 +9,10 @@ import (
 
 const signingKey = "somethingVeryStrong"
 
+// InstrumentationName names the tracers of this module, whose spans are
+// started by the handlers, the serializers and the database alike.
+const InstrumentationName = "github.com/hy00nc/conduit-go"
+
 func GetToken(id uint) (string, error) {
 	token := jwt.NewWithClaims(
 		jwt.GetSigningMethod("HS256"),
@@ -19,7 +23,7 @@ func GetToken(id uint) (string, error) {
 	)
 	signed, err := token.SignedString([]byte(signingKey))
 	if err != nil {
-		log.Println("Error while signing JWT:", err.Error())
+		slog.Error("signing JWT failed", "error", err)
 		return "", err
 	}
 	return signed, err
@@ -0,0 +1,201 @@
+package webhooks
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/hy00nc/conduit-go/internal/database"
+	"github.com/hy00nc/conduit-go/internal/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	StatusPending   = "pending"
+	StatusSucceeded = "succeeded"
+	StatusFailed    = "failed"
+)
+
+// Dispatcher moves events from the outbox into per-webhook deliveries and
+// sends due deliveries, retrying failures with exponential backoff.
+type Dispatcher struct {
+	DB           *gorm.DB
+	Client       *http.Client
+	PollInterval time.Duration
+	MaxAttempts  int
+	BaseBackoff  time.Duration
+	MaxBackoff   time.Duration
+	BatchSize    int
+}
+
+func NewDispatcher(db *gorm.DB) *Dispatcher {
+	return &Dispatcher{
+		DB:           db,
+		Client:       NewClient(10 * time.Second),
+		PollInterval: 2 * time.Second,
+		MaxAttempts:  8,
+		BaseBackoff:  30 * time.Second,
+		MaxBackoff:   6 * time.Hour,
+		BatchSize:    50,
+	}
+}
+
+// Run polls until the context is cancelled.
+func (d *Dispatcher) Run(ctx context.Context) {
+	ticker := time.NewTicker(d.PollInterval)
+	defer ticker.Stop()
+	for {
+		if err := d.FanOut(); err != nil {
+			slog.Error("webhooks: fan out failed", "error", err)
+		}
+		if err := d.DeliverDue(ctx); err != nil {
+			slog.Error("webhooks: delivery failed", "error", err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// FanOut creates a delivery for every active webhook subscribed to each
+// unprocessed outbox event, marking the event processed in the same transaction.
+// Webhooks receive the events about their owner, and site-wide ones of
+// admins those about everyone.
+func (d *Dispatcher) FanOut() error {
+	var events []models.OutboxEvent
+	if err := d.DB.Where("processed_at IS NULL").Order("id").Limit(d.BatchSize).Find(&events).Error; err != nil {
+		return err
+	}
+	if len(events) == 0 {
+		return nil
+	}
+	var hooks []models.Webhook
+	if err := d.DB.Where("active = ?", true).Find(&hooks).Error; err != nil {
+		return err
+	}
+	var adminIDs []uint
+	if err := d.DB.Model(&models.User{}).Where("role = ?", models.RoleAdmin).Pluck("profile_id", &adminIDs).Error; err != nil {
+		return err
+	}
+	admins := map[uint]bool{}
+	for _, id := range adminIDs {
+		admins[id] = true
+	}
+	for _, event := range events {
+		err := database.Transaction(context.Background(), d.DB, func(tx *gorm.DB) error {
+			now := time.Now()
+			for _, hook := range hooks {
+				if !hook.Subscribed(event.Type) || !receives(hook, event, admins) {
+					continue
+				}
+				delivery := models.WebhookDelivery{
+					WebhookID:     hook.ID,
+					EventID:       event.ID,
+					Status:        StatusPending,
+					NextAttemptAt: now,
+				}
+				if err := tx.Create(&delivery).Error; err != nil {
+					return err
+				}
+			}
+			return tx.Model(&event).Update("processed_at", now).Error
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// receives reports whether hook may see event. The site-wide webhooks of
+// users no longer admins only see their own events.
+func receives(hook models.Webhook, event models.OutboxEvent, admins map[uint]bool) bool {
+	return hook.OwnerID == event.ProfileID || hook.SiteWide && admins[hook.OwnerID]
+}
+
+// DeliverDue sends every pending delivery whose next attempt is due.
+func (d *Dispatcher) DeliverDue(ctx context.Context) error {
+	var deliveries []models.WebhookDelivery
+	err := d.DB.Preload(clause.Associations).
+		Where("status = ? AND next_attempt_at <= ?", StatusPending, time.Now()).
+		Order("next_attempt_at").Limit(d.BatchSize).Find(&deliveries).Error
+	if err != nil {
+		return err
+	}
+	for _, delivery := range deliveries {
+		if ctx.Err() != nil {
+			return nil
+		}
+		// an attempt under way is finished, within the client timeout
+		d.attempt(context.WithoutCancel(ctx), &delivery)
+	}
+	return nil
+}
+
+func (d *Dispatcher) attempt(ctx context.Context, delivery *models.WebhookDelivery) {
+	delivery.Attempts++
+	code, err := d.send(ctx, delivery)
+	delivery.ResponseCode = code
+	if err == nil {
+		delivery.Status = StatusSucceeded
+		delivery.LastError = ""
+	} else {
+		delivery.LastError = err.Error()
+		if delivery.Attempts >= d.MaxAttempts || delivery.Webhook.ID == 0 {
+			delivery.Status = StatusFailed
+		} else {
+			delivery.NextAttemptAt = time.Now().Add(d.backoff(delivery.Attempts))
+		}
+	}
+	if err := d.DB.Model(delivery).Select("Attempts", "ResponseCode", "Status", "LastError", "NextAttemptAt").Updates(delivery).Error; err != nil {
+		slog.Error("webhooks: saving delivery failed", "delivery", delivery.ID, "error", err)
+	}
+}
+
+func (d *Dispatcher) backoff(attempts int) time.Duration {
+	wait := d.BaseBackoff << (attempts - 1)
+	if wait <= 0 || wait > d.MaxBackoff {
+		return d.MaxBackoff
+	}
+	return wait
+}
+
+func (d *Dispatcher) send(ctx context.Context, delivery *models.WebhookDelivery) (int, error) {
+	if delivery.Webhook.ID == 0 {
+		return 0, fmt.Errorf("webhook %d no longer exists", delivery.WebhookID)
+	}
+	body, err := envelope(delivery.Event)
+	if err != nil {
+		return 0, err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, delivery.Webhook.URL, bytes.NewReader(body))
+	if err != nil {
+		return 0, err
+	}
+	timestamp := time.Now().Unix()
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "Conduit-Webhooks/1.0")
+	req.Header.Set(HeaderEvent, delivery.Event.Type)
+	req.Header.Set(HeaderDelivery, strconv.FormatUint(uint64(delivery.ID), 10))
+	req.Header.Set(HeaderTimestamp, strconv.FormatInt(timestamp, 10))
+	req.Header.Set(HeaderSignature, Sign(delivery.Webhook.Secret, timestamp, body))
+
+	resp, err := d.Client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return resp.StatusCode, fmt.Errorf("endpoint responded %s", resp.Status)
+	}
+	return resp.StatusCode, nil
+}
@@ -0,0 +1,194 @@
+package webhooks
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/hy00nc/conduit-go/internal/database"
+	"github.com/hy00nc/conduit-go/internal/models"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+func openDB(t *testing.T) *gorm.DB {
+	db, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "webhooks.db")), &gorm.Config{
+		Logger: logger.Default.LogMode(logger.Silent),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	database.MigrateDB(db)
+	t.Cleanup(func() { database.CloseDB(db) })
+	return db
+}
+
+// endpoint records the requests it receives, answering with status.
+type endpoint struct {
+	mu       sync.Mutex
+	status   int
+	requests []*http.Request
+	bodies   [][]byte
+}
+
+func (e *endpoint) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	body, _ := io.ReadAll(r.Body)
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.requests = append(e.requests, r)
+	e.bodies = append(e.bodies, body)
+	w.WriteHeader(e.status)
+}
+
+func (e *endpoint) count() int {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return len(e.requests)
+}
+
+func TestBackoff(t *testing.T) {
+	d := &Dispatcher{BaseBackoff: 30 * time.Second, MaxBackoff: time.Hour}
+	assert.Equal(t, 30*time.Second, d.backoff(1))
+	assert.Equal(t, time.Minute, d.backoff(2))
+	assert.Equal(t, 16*time.Minute, d.backoff(6))
+	assert.Equal(t, time.Hour, d.backoff(8), "capped")
+	assert.Equal(t, time.Hour, d.backoff(100), "capped on overflow")
+}
+
+func TestFanOut(t *testing.T) {
+	asserts := assert.New(t)
+	db := openDB(t)
+	owner := models.Profile{Name: "sally"}
+	db.Create(&owner)
+	subscribed := models.Webhook{OwnerID: owner.ID, URL: "https://example.com/a", Events: "article.created,comment.created", Active: true}
+	other := models.Webhook{OwnerID: owner.ID, URL: "https://example.com/b", Events: "user.followed", Active: true}
+	inactive := models.Webhook{OwnerID: owner.ID, URL: "https://example.com/c", Events: "article.created"}
+	db.Create(&subscribed)
+	db.Create(&other)
+	db.Create(&inactive)
+	asserts.NoError(Enqueue(db, ArticleCreated, owner.ID, map[string]string{"slug": "hello"}))
+	d := NewDispatcher(db)
+
+	asserts.NoError(d.FanOut())
+	var deliveries []models.WebhookDelivery
+	db.Find(&deliveries)
+	asserts.Len(deliveries, 1, "only active webhooks subscribed to the event")
+	asserts.Equal(subscribed.ID, deliveries[0].WebhookID)
+	asserts.Equal(StatusPending, deliveries[0].Status)
+	var event models.OutboxEvent
+	db.First(&event)
+	asserts.NotNil(event.ProcessedAt)
+
+	asserts.NoError(d.FanOut())
+	var count int64
+	db.Model(&models.WebhookDelivery{}).Count(&count)
+	asserts.Equal(int64(1), count, "events are fanned out once")
+}
+
+func TestFanOutScopesToOwner(t *testing.T) {
+	asserts := assert.New(t)
+	db := openDB(t)
+	sally, harry, admin := models.Profile{Name: "sally"}, models.Profile{Name: "harry"}, models.Profile{Name: "admin"}
+	db.Create(&sally)
+	db.Create(&harry)
+	db.Create(&admin)
+	db.Create(&models.User{Email: "admin@example.com", ProfileID: admin.ID, Role: models.RoleAdmin})
+	db.Create(&models.User{Email: "harry@example.com", ProfileID: harry.ID, Role: models.RoleUser})
+	all := strings.Join(EventTypes, ",")
+	sallyHook := models.Webhook{OwnerID: sally.ID, URL: "https://example.com/sally", Events: all, Active: true}
+	harryHook := models.Webhook{OwnerID: harry.ID, URL: "https://example.com/harry", Events: all, Active: true, SiteWide: true}
+	adminHook := models.Webhook{OwnerID: admin.ID, URL: "https://example.com/admin", Events: all, Active: true, SiteWide: true}
+	db.Create(&sallyHook)
+	db.Create(&harryHook)
+	db.Create(&adminHook)
+	asserts.NoError(Enqueue(db, UserFollowed, sally.ID, map[string]string{"profile": "sally"}))
+	asserts.NoError(Enqueue(db, ArticleFavorited, sally.ID, map[string]string{"article": "sallys"}))
+	asserts.NoError(Enqueue(db, CommentCreated, harry.ID, map[string]string{"article": "harrys"}))
+
+	asserts.NoError(NewDispatcher(db).FanOut())
+	received := func(hook models.Webhook) []string {
+		var events []string
+		db.Model(&models.WebhookDelivery{}).Joins("Event").Where("webhook_id = ?", hook.ID).Order("webhook_deliveries.id").Pluck("Event.type", &events)
+		return events
+	}
+	asserts.Equal([]string{UserFollowed, ArticleFavorited}, received(sallyHook))
+	asserts.Equal([]string{CommentCreated}, received(harryHook), "site-wide webhooks of users who are not admins only see their own events")
+	asserts.Equal([]string{UserFollowed, ArticleFavorited, CommentCreated}, received(adminHook))
+}
+
+func TestDeliverDue(t *testing.T) {
+	asserts := assert.New(t)
+	db := openDB(t)
+	receiver := &endpoint{status: http.StatusNoContent}
+	server := httptest.NewServer(receiver)
+	defer server.Close()
+	owner := models.Profile{Name: "sally"}
+	db.Create(&owner)
+	hook := models.Webhook{OwnerID: owner.ID, URL: server.URL, Secret: "secret", Events: "article.created", Active: true}
+	db.Create(&hook)
+	d := NewDispatcher(db)
+	d.Client = server.Client() // the endpoint is on loopback
+	d.BaseBackoff = time.Minute
+	d.MaxAttempts = 2
+	ctx := context.Background()
+	deliver := func() models.WebhookDelivery {
+		t.Helper()
+		asserts.NoError(d.FanOut())
+		asserts.NoError(d.DeliverDue(ctx))
+		var delivery models.WebhookDelivery
+		db.Order("id desc").First(&delivery)
+		return delivery
+	}
+
+	asserts.NoError(Enqueue(db, ArticleCreated, owner.ID, map[string]string{"slug": "hello"}))
+	delivery := deliver()
+	asserts.Equal(StatusSucceeded, delivery.Status)
+	asserts.Equal(1, delivery.Attempts)
+	asserts.Equal(http.StatusNoContent, delivery.ResponseCode)
+	asserts.Equal(1, receiver.count())
+	request, body := receiver.requests[0], receiver.bodies[0]
+	asserts.Equal(ArticleCreated, request.Header.Get(HeaderEvent))
+	asserts.Equal(strconv.FormatUint(uint64(delivery.ID), 10), request.Header.Get(HeaderDelivery))
+	timestamp, err := strconv.ParseInt(request.Header.Get(HeaderTimestamp), 10, 64)
+	asserts.NoError(err)
+	asserts.True(Verify("secret", timestamp, body, request.Header.Get(HeaderSignature)), "deliveries are signed")
+	var envelope Envelope
+	asserts.NoError(json.Unmarshal(body, &envelope))
+	asserts.Equal(ArticleCreated, envelope.Type)
+	asserts.JSONEq(`{"slug":"hello"}`, string(envelope.Data))
+
+	receiver.status = http.StatusInternalServerError
+	asserts.NoError(Enqueue(db, ArticleCreated, owner.ID, map[string]string{"slug": "again"}))
+	delivery = deliver()
+	asserts.Equal(StatusPending, delivery.Status, "failures are retried")
+	asserts.Equal(1, delivery.Attempts)
+	asserts.Equal(http.StatusInternalServerError, delivery.ResponseCode)
+	asserts.Contains(delivery.LastError, "500")
+	asserts.WithinDuration(time.Now().Add(time.Minute), delivery.NextAttemptAt, 5*time.Second)
+
+	asserts.NoError(d.DeliverDue(ctx))
+	asserts.Equal(2, receiver.count(), "retries wait for their backoff")
+
+	db.Model(&delivery).Update("next_attempt_at", time.Now().Add(-time.Second))
+	delivery = deliver()
+	asserts.Equal(StatusFailed, delivery.Status, "deliveries fail after MaxAttempts")
+	asserts.Equal(2, delivery.Attempts)
+	asserts.Equal(3, receiver.count())
+
+	db.Model(&delivery).Updates(map[string]interface{}{"status": StatusPending, "next_attempt_at": time.Now().Add(-time.Second)})
+	db.Delete(&hook)
+	delivery = deliver()
+	asserts.Equal(StatusFailed, delivery.Status, "deliveries of deleted webhooks fail at once")
+	asserts.Contains(delivery.LastError, "no longer exists")
+	asserts.Equal(3, receiver.count())
+}
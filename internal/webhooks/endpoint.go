@@ -0,0 +1,100 @@
+package webhooks
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"syscall"
+	"time"
+)
+
+// ErrPrivateAddress is returned for endpoints on addresses that are not
+// public, so that webhooks cannot make the server call itself, its network
+// or the metadata service of its cloud.
+var ErrPrivateAddress = errors.New("webhooks: endpoint address is not public")
+
+// nonPublicPrefixes are the special purpose ranges not covered by the
+// netip.Addr predicates in publicAddr.
+var nonPublicPrefixes = []netip.Prefix{
+	netip.MustParsePrefix("0.0.0.0/8"),      // "this network"
+	netip.MustParsePrefix("100.64.0.0/10"),  // carrier-grade NAT, and some metadata services
+	netip.MustParsePrefix("192.0.0.0/24"),   // IETF protocol assignments
+	netip.MustParsePrefix("198.18.0.0/15"),  // benchmarking
+	netip.MustParsePrefix("240.0.0.0/4"),    // reserved, and broadcast
+	netip.MustParsePrefix("64:ff9b::/96"),   // NAT64, which may reach private IPv4
+	netip.MustParsePrefix("64:ff9b:1::/48"), // local NAT64
+	netip.MustParsePrefix("2001:db8::/32"),  // documentation
+	netip.MustParsePrefix("2002::/16"),      // 6to4, which embeds IPv4
+}
+
+// publicAddr reports whether addr is a public unicast address.
+func publicAddr(addr netip.Addr) bool {
+	addr = addr.Unmap()
+	if !addr.IsValid() || addr.IsLoopback() || addr.IsPrivate() || addr.IsUnspecified() ||
+		addr.IsLinkLocalUnicast() || addr.IsMulticast() {
+		return false
+	}
+	for _, prefix := range nonPublicPrefixes {
+		if prefix.Contains(addr) {
+			return false
+		}
+	}
+	return true
+}
+
+// CheckURL checks that rawURL is an http or https URL whose host resolves
+// to public addresses only. Endpoints are checked again on every delivery,
+// as what the host resolves to may change.
+func CheckURL(ctx context.Context, rawURL string) error {
+	endpoint, err := url.Parse(rawURL)
+	if err != nil {
+		return err
+	}
+	if endpoint.Scheme != "http" && endpoint.Scheme != "https" {
+		return fmt.Errorf("webhooks: scheme %q is not supported", endpoint.Scheme)
+	}
+	host := endpoint.Hostname()
+	if host == "" {
+		return errors.New("webhooks: endpoint has no host")
+	}
+	addrs, err := net.DefaultResolver.LookupNetIP(ctx, "ip", host)
+	if err != nil {
+		return err
+	}
+	for _, addr := range addrs {
+		if !publicAddr(addr) {
+			return ErrPrivateAddress
+		}
+	}
+	return nil
+}
+
+// dialPublic refuses connections to addresses that are not public. It runs
+// once the host is resolved, right before connecting, so that a host
+// resolving to a public address when checked and to a private one when
+// delivered to is refused too.
+func dialPublic(network, address string, conn syscall.RawConn) error {
+	addrPort, err := netip.ParseAddrPort(address)
+	if err != nil {
+		return err
+	}
+	if !publicAddr(addrPort.Addr()) {
+		return ErrPrivateAddress
+	}
+	return nil
+}
+
+// NewClient returns an HTTP client for deliveries, which only connects to
+// public addresses, redirects included.
+func NewClient(timeout time.Duration) *http.Client {
+	dialer := &net.Dialer{Timeout: timeout, Control: dialPublic}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	// a proxy would connect on our behalf, unchecked
+	transport.Proxy = nil
+	transport.DialContext = dialer.DialContext
+	return &http.Client{Timeout: timeout, Transport: transport}
+}
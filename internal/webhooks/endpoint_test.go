@@ -0,0 +1,66 @@
+package webhooks
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPublicAddr(t *testing.T) {
+	for addr, public := range map[string]bool{
+		"93.184.216.34":        true,
+		"2606:2800:220:1::":    true,
+		"127.0.0.1":            false,
+		"::1":                  false,
+		"10.1.2.3":             false,
+		"172.16.0.1":           false,
+		"192.168.1.1":          false,
+		"169.254.169.254":      false,
+		"100.100.100.200":      false,
+		"0.0.0.0":              false,
+		"255.255.255.255":      false,
+		"224.0.0.1":            false,
+		"fe80::1":              false,
+		"fd00:ec2::254":        false,
+		"::ffff:127.0.0.1":     false,
+		"::ffff:93.184.216.34": true,
+		"64:ff9b::a00:1":       false,
+	} {
+		assert.Equal(t, public, publicAddr(netip.MustParseAddr(addr)), addr)
+	}
+}
+
+func TestCheckURL(t *testing.T) {
+	asserts := assert.New(t)
+	ctx := context.Background()
+	asserts.NoError(CheckURL(ctx, "https://93.184.216.34/hooks"))
+	for _, url := range []string{
+		"http://127.0.0.1:8000/api/user",
+		"http://localhost/",
+		"http://[::1]/",
+		"http://169.254.169.254/latest/meta-data/",
+		"http://10.0.0.5/",
+		"http://0x7f000001/",
+	} {
+		asserts.Error(CheckURL(ctx, url), url)
+	}
+	asserts.ErrorIs(CheckURL(ctx, "http://169.254.169.254/"), ErrPrivateAddress)
+	asserts.Error(CheckURL(ctx, "ftp://93.184.216.34/"))
+	asserts.Error(CheckURL(ctx, "http:///path"))
+}
+
+func TestClientRefusesPrivateAddresses(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Error("the request reached a loopback server")
+	}))
+	defer server.Close()
+
+	_, err := NewClient(time.Second).Post(server.URL, "application/json", nil)
+	assert.True(t, errors.Is(err, ErrPrivateAddress), "%v", err)
+}
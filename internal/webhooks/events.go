@@ -0,0 +1,54 @@
+package webhooks
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/hy00nc/conduit-go/internal/models"
+	"gorm.io/gorm"
+)
+
+const (
+	ArticleCreated   = "article.created"
+	ArticleUpdated   = "article.updated"
+	ArticleDeleted   = "article.deleted"
+	CommentCreated   = "comment.created"
+	UserFollowed     = "user.followed"
+	ArticleFavorited = "article.favorited"
+)
+
+// EventTypes lists every event a webhook may subscribe to.
+var EventTypes = []string{ArticleCreated, ArticleUpdated, ArticleDeleted, CommentCreated, UserFollowed, ArticleFavorited}
+
+// Envelope is the JSON body POSTed to webhook endpoints.
+type Envelope struct {
+	ID        uint            `json:"id"`
+	Type      string          `json:"type"`
+	CreatedAt string          `json:"createdAt"`
+	Data      json.RawMessage `json:"data"`
+}
+
+// Enqueue records an event about the profile profileID in the outbox. Call
+// it with the transaction that performs the change so the event is
+// committed (or lost) together with it.
+func Enqueue(tx *gorm.DB, eventType string, profileID uint, data interface{}) error {
+	payload, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+	event := models.OutboxEvent{
+		Type:      eventType,
+		ProfileID: profileID,
+		Payload:   string(payload),
+	}
+	return tx.Create(&event).Error
+}
+
+func envelope(event models.OutboxEvent) ([]byte, error) {
+	return json.Marshal(Envelope{
+		ID:        event.ID,
+		Type:      event.Type,
+		CreatedAt: event.CreatedAt.UTC().Format(time.RFC3339Nano),
+		Data:      json.RawMessage(event.Payload),
+	})
+}
@@ -0,0 +1,27 @@
+package webhooks
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSignature(t *testing.T) {
+	asserts := assert.New(t)
+	body := []byte(`{"id":1}`)
+	signature := Sign("secret", 1700000000, body)
+	asserts.Regexp(`^sha256=[0-9a-f]{64}$`, signature)
+	asserts.Equal(signature, Sign("secret", 1700000000, body), "signatures are deterministic")
+
+	asserts.True(Verify("secret", 1700000000, body, signature))
+	asserts.False(Verify("other", 1700000000, body, signature), "secret")
+	asserts.False(Verify("secret", 1700000001, body, signature), "timestamp")
+	asserts.False(Verify("secret", 1700000000, []byte(`{"id":2}`), signature), "body")
+	asserts.False(Verify("secret", 1700000000, body, ""))
+
+	first, err := GenerateSecret()
+	asserts.NoError(err)
+	second, _ := GenerateSecret()
+	asserts.Len(first, 64)
+	asserts.NotEqual(first, second)
+}